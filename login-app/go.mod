@@ -1,19 +1,42 @@
 module github.com/HelloImKevo/UdemyGolangApps/login-app
 
-go 1.22
+go 1.23.0
 
 require (
 	// HTTP web framework for routing, middleware, and API endpoints
 	// Enterprise-grade framework used for REST API and web server functionality
 	github.com/gin-gonic/gin v1.9.1
 	
+	// Enterprise-grade input validation library with struct tag validation
+	// Used through Gin's binding; imported to report which fields failed
+	github.com/go-playground/validator/v10 v10.14.0
+	
 	// JWT (JSON Web Token) library for secure stateless authentication
 	// Handles token generation, validation, and claims management
 	github.com/golang-jwt/jwt/v5 v5.2.0
 	
 	// Official Go cryptography library for secure password hashing
 	// Provides bcrypt implementation for enterprise-grade password security
-	golang.org/x/crypto v0.18.0
+	golang.org/x/crypto v0.39.0
+
+	// YAML parsing for configuration files
+	gopkg.in/yaml.v3 v3.0.1
+
+	// WebAuthn relying party implementation for passkey registration and login
+	github.com/go-webauthn/webauthn v0.9.4
+
+	// Redis client for sharing sessions and tokens between replicas
+	github.com/go-redis/redis v6.15.9+incompatible
+
+	// SQLite driver for database/sql (cgo), so migrations run without
+	// building a custom binary
+	github.com/mattn/go-sqlite3 v1.14.22
+
+	// OpenTelemetry API, SDK and OTLP/HTTP exporter for distributed tracing
+	go.opentelemetry.io/otel v1.37.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.37.0
+	go.opentelemetry.io/otel/sdk v1.37.0
+	go.opentelemetry.io/otel/trace v1.37.0
 )
 
 require (
@@ -35,10 +58,6 @@ require (
 	// Universal translator for multi-language validation error messages
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	
-	// Enterprise-grade input validation library with struct tag validation
-	// Critical security component for preventing injection attacks
-	github.com/go-playground/validator/v10 v10.14.0 // indirect
-	
 	// Alternative high-performance JSON library used by Gin framework
 	github.com/goccy/go-json v0.10.2 // indirect
 	
@@ -73,17 +92,39 @@ require (
 	golang.org/x/arch v0.3.0 // indirect
 	
 	// Official Go extended networking libraries with HTTP/2 and TLS support
-	golang.org/x/net v0.10.0 // indirect
+	golang.org/x/net v0.41.0 // indirect
 	
 	// Official Go system call interface for cross-platform compatibility
-	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
 	
 	// Official Go text processing library for character encoding and i18n
-	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
 	
 	// Official Google Protocol Buffers library for efficient binary serialization
-	google.golang.org/protobuf v1.30.0 // indirect
-	
-	// YAML parsing and generation library for configuration file processing
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
+)
+
+require (
+	// Dependencies of the OpenTelemetry SDK and OTLP exporter
+	github.com/cenkalti/backoff/v5 v5.0.2 // indirect
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.27.1 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.37.0 // indirect
+	go.opentelemetry.io/otel/metric v1.37.0 // indirect
+	go.opentelemetry.io/proto/otlp v1.7.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250603155806-513f23925822 // indirect
+	google.golang.org/grpc v1.73.0 // indirect
+)
+
+require (
+	// Dependencies of the WebAuthn library: CBOR decoding and attestation formats
+	github.com/fxamacker/cbor/v2 v2.5.0 // indirect
+	github.com/go-webauthn/x v0.1.5 // indirect
+	github.com/google/go-tpm v0.9.0 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 )
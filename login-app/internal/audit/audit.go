@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries bounds the in-memory audit log; the oldest entries are
+// discarded once it is full
+const DefaultMaxEntries = 10000
+
+// Entry represents a single recorded authentication event
+type Entry struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Outcome   string            `json:"outcome"`
+	UserID    string            `json:"user_id,omitempty"`
+	Email     string            `json:"email,omitempty"` // Attempted email for failures
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Device    *Device           `json:"device,omitempty"` // Parsed from UserAgent
+	Timestamp time.Time         `json:"timestamp"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Filter selects audit entries; zero values match everything
+type Filter struct {
+	UserID string
+	Type   string
+	Since  time.Time
+	Until  time.Time // Exclusive
+	Limit  int       // Maximum number of entries to return; zero means no limit
+	Offset int
+}
+
+// AuditLog defines the interface for recording and querying audit entries
+type AuditLog interface {
+	// Record appends an entry to the log
+	Record(entry Entry) error
+
+	// Query returns matching entries, newest first, along with the total
+	// number of matches
+	Query(filter Filter) ([]Entry, int, error)
+}
+
+// MemoryAuditLog implements AuditLog using a bounded in-memory buffer
+type MemoryAuditLog struct {
+	mu         sync.RWMutex
+	entries    []Entry
+	maxEntries int
+}
+
+// NewMemoryAuditLog creates an in-memory audit log holding at most maxEntries
+func NewMemoryAuditLog(maxEntries int) *MemoryAuditLog {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &MemoryAuditLog{
+		maxEntries: maxEntries,
+	}
+}
+
+// Record appends an entry to the log
+func (l *MemoryAuditLog) Record(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Details = copyDetails(entry.Details)
+
+	if len(l.entries) >= l.maxEntries {
+		// Drop the oldest entry
+		copy(l.entries, l.entries[1:])
+		l.entries = l.entries[:len(l.entries)-1]
+	}
+	l.entries = append(l.entries, entry)
+
+	return nil
+}
+
+// Query returns matching entries, newest first, and the total match count
+func (l *MemoryAuditLog) Query(filter Filter) ([]Entry, int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	matches := make([]Entry, 0)
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if filter.UserID != "" && entry.UserID != filter.UserID {
+			continue
+		}
+		if filter.Type != "" && entry.Type != filter.Type {
+			continue
+		}
+		if !filter.Since.IsZero() && entry.Timestamp.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !entry.Timestamp.Before(filter.Until) {
+			continue
+		}
+		matches = append(matches, entry)
+	}
+
+	total := len(matches)
+	if filter.Offset > 0 {
+		if filter.Offset >= total {
+			return []Entry{}, total, nil
+		}
+		matches = matches[filter.Offset:]
+	}
+	if filter.Limit > 0 && len(matches) > filter.Limit {
+		matches = matches[:filter.Limit]
+	}
+
+	result := make([]Entry, len(matches))
+	for i, entry := range matches {
+		entry.Details = copyDetails(entry.Details)
+		result[i] = entry
+	}
+
+	return result, total, nil
+}
+
+// copyDetails returns a copy of a details map so callers can't modify stored state
+func copyDetails(details map[string]string) map[string]string {
+	if details == nil {
+		return nil
+	}
+	detailsCopy := make(map[string]string, len(details))
+	for k, v := range details {
+		detailsCopy[k] = v
+	}
+	return detailsCopy
+}
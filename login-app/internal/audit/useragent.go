@@ -0,0 +1,122 @@
+package audit
+
+import "strings"
+
+// Device types
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceOther   = "other"
+)
+
+// Device is the readable client information parsed from a User-Agent
+// header. Fields that can't be determined are left empty.
+type Device struct {
+	OS      string `json:"os,omitempty"`
+	Browser string `json:"browser,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// IsZero reports whether nothing was parsed
+func (d Device) IsZero() bool {
+	return d == Device{}
+}
+
+// uaRule maps a User-Agent substring to a name. Rules are checked in order,
+// so more specific tokens come before the generic ones they contain.
+type uaRule struct {
+	token string
+	name  string
+}
+
+var osRules = []uaRule{
+	{"Windows Phone", "Windows Phone"},
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"iPod", "iOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// Chromium-based browsers also send "Chrome/" and nearly every browser
+// sends "Safari/", so those come last
+var browserRules = []uaRule{
+	{"Edg/", "Edge"},
+	{"Edge/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Opera", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Trident/", "Internet Explorer"},
+	{"MSIE ", "Internet Explorer"},
+	{"Version/", "Safari"},
+	{"curl/", "curl"},
+	{"Wget/", "Wget"},
+	{"Go-http-client/", "Go HTTP client"},
+	{"python-requests/", "Python Requests"},
+	{"okhttp/", "OkHttp"},
+}
+
+// botTokens identify crawlers, matched case-insensitively
+var botTokens = []string{"bot", "crawler", "spider", "slurp", "headless"}
+
+// ParseUserAgent extracts the OS, browser and device type from a
+// User-Agent header. It never fails: an empty header gives a zero Device
+// and an unrecognized one gives the device type "other".
+func ParseUserAgent(ua string) Device {
+	ua = strings.TrimSpace(ua)
+	if ua == "" {
+		return Device{}
+	}
+
+	device := Device{
+		OS:      matchRule(ua, osRules),
+		Browser: matchRule(ua, browserRules),
+	}
+	device.Type = deviceType(ua, device.OS)
+	return device
+}
+
+// matchRule returns the name of the first rule whose token occurs in ua
+func matchRule(ua string, rules []uaRule) string {
+	for _, rule := range rules {
+		if strings.Contains(ua, rule.token) {
+			return rule.name
+		}
+	}
+	return ""
+}
+
+// deviceType classifies the client by its User-Agent and parsed OS
+func deviceType(ua, os string) string {
+	lower := strings.ToLower(ua)
+	for _, token := range botTokens {
+		if strings.Contains(lower, token) {
+			return DeviceBot
+		}
+	}
+
+	switch {
+	case strings.Contains(ua, "iPad") || strings.Contains(lower, "tablet"):
+		return DeviceTablet
+	case os == "Android" && !strings.Contains(ua, "Mobile"):
+		// Android phones send "Mobile", tablets don't
+		return DeviceTablet
+	case strings.Contains(ua, "Mobi") || os == "iOS" || os == "Windows Phone":
+		return DeviceMobile
+	case os == "Windows" || os == "macOS" || os == "Linux" || os == "ChromeOS":
+		return DeviceDesktop
+	}
+	return DeviceOther
+}
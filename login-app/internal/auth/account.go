@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+// DeactivateUser disables an account and revokes its refresh tokens and
+// sessions. Access tokens and API keys stop working immediately because
+// validation rejects inactive users.
+func (s *Service) DeactivateUser(ctx context.Context, userID string) error {
+	if err := s.setUserActive(ctx, userID, false); err != nil {
+		return err
+	}
+	if err := s.refreshStore.DeleteUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	return s.sessionStore.DeleteUserSessions(userID)
+}
+
+// ReactivateUser re-enables a deactivated account
+func (s *Service) ReactivateUser(ctx context.Context, userID string) error {
+	return s.setUserActive(ctx, userID, true)
+}
+
+// DeleteAccount permanently deletes a user after re-confirming their password
+// and purges everything issued to them: refresh tokens, sessions, pending
+// reset and verification tokens, and API keys
+func (s *Service) DeleteAccount(ctx context.Context, userID, password string) error {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	if err := s.verifyPassword(user.PasswordHash, password); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	// Purge credentials first so a failure part-way leaves nothing usable behind
+	if err := s.purgeCredentials(userID); err != nil {
+		return err
+	}
+
+	if err := s.userStore.DeleteUser(ctx, userID); err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SoftDeleteUser deletes an account for an administrator but keeps its
+// record, so the audit trail still resolves and the account can be restored.
+// Credentials are purged as for DeleteAccount and the email and username
+// become free for new accounts.
+func (s *Service) SoftDeleteUser(ctx context.Context, userID string) error {
+	if _, err := s.userStore.GetUserByID(ctx, userID); err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	if err := s.purgeCredentials(userID); err != nil {
+		return err
+	}
+
+	if err := s.userStore.SoftDeleteUser(ctx, userID); err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RestoreUser brings back a soft-deleted account. It fails with
+// ErrUserExists when the email or username has been reused since.
+func (s *Service) RestoreUser(ctx context.Context, userID string) error {
+	if err := s.userStore.RestoreUser(ctx, userID); err != nil {
+		switch err {
+		case storage.ErrUserNotFound:
+			return ErrUserNotFound
+		case storage.ErrUserExists:
+			return ErrUserExists
+		}
+		return err
+	}
+	return nil
+}
+
+// purgeCredentials removes everything issued to a user: refresh tokens,
+// sessions, pending reset and verification tokens, and API keys
+func (s *Service) purgeCredentials(userID string) error {
+	if err := s.refreshStore.DeleteUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	if err := s.sessionStore.DeleteUserSessions(userID); err != nil {
+		return err
+	}
+	if err := s.tokenStore.DeleteUserTokens(userID, ""); err != nil {
+		return err
+	}
+	return s.apiKeyStore.DeleteUserAPIKeys(userID)
+}
+
+// ExportAccount returns everything stored about a user, minus secrets
+func (s *Service) ExportAccount(ctx context.Context, userID string) (*AccountExport, error) {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	apiKeys, err := s.ListAPIKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccountExport{
+		User:       user,
+		APIKeys:    apiKeys,
+		ExportedAt: time.Now(),
+	}, nil
+}
+
+// setUserActive updates the active flag on an account
+func (s *Service) setUserActive(ctx context.Context, userID string, active bool) error {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	// Administrators can only manage accounts in their own organization
+	if user.OrgID != tenant.FromContext(ctx) {
+		return ErrUserNotFound
+	}
+
+	if user.IsActive == active {
+		return nil
+	}
+
+	user.IsActive = active
+	return s.userStore.UpdateUser(ctx, user)
+}
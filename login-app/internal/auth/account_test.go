@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDeactivatedUserTokenStopsWorking(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "deactivate@example.com", "deactivate")
+	ctx := context.Background()
+
+	if err := service.DeactivateUser(ctx, registered.User.ID); err != nil {
+		t.Fatalf("DeactivateUser: %v", err)
+	}
+
+	if _, err := service.ValidateToken(ctx, registered.Token); err == nil {
+		t.Error("access token still validates after deactivation")
+	}
+	if _, err := service.Refresh(ctx, registered.RefreshToken); err == nil {
+		t.Error("refresh token still works after deactivation")
+	}
+	if _, err := service.Login(ctx, &LoginRequest{Email: "deactivate@example.com", Password: testPassword}, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("login while deactivated: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestReactivatedUserCanLogIn(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "reactivate@example.com", "reactivate")
+	ctx := context.Background()
+
+	if err := service.DeactivateUser(ctx, registered.User.ID); err != nil {
+		t.Fatalf("DeactivateUser: %v", err)
+	}
+	if err := service.ReactivateUser(ctx, registered.User.ID); err != nil {
+		t.Fatalf("ReactivateUser: %v", err)
+	}
+
+	response, err := service.Login(ctx, &LoginRequest{Email: "reactivate@example.com", Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("login after reactivation: %v", err)
+	}
+	if _, err := service.ValidateToken(ctx, response.Token); err != nil {
+		t.Errorf("new token: %v", err)
+	}
+
+	// Sessions ended by the deactivation stay ended
+	if _, err := service.ValidateToken(ctx, registered.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("token from before the deactivation: got %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestReactivateUnknownUser(t *testing.T) {
+	service := newTestService(t, nil)
+	if err := service.ReactivateUser(context.Background(), "missing"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("got %v, want ErrUserNotFound", err)
+	}
+}
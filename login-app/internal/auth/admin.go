@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+// Paging limits for admin user listings
+const (
+	DefaultUserPageSize = 20
+	MaxUserPageSize     = 100
+)
+
+// ListUsers returns one page of users and the total number of matches
+func (s *Service) ListUsers(ctx context.Context, opts storage.ListUsersOptions) ([]UserInfo, int, error) {
+	users, total, err := s.userStore.ListUsersPaged(ctx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	infos := make([]UserInfo, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, s.userToUserInfo(user))
+	}
+	return infos, total, nil
+}
+
+// SearchUsers returns up to limit users whose email or username matches
+// query, most relevant first
+func (s *Service) SearchUsers(ctx context.Context, query string, limit int) ([]UserInfo, error) {
+	users, err := s.userStore.SearchUsers(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]UserInfo, 0, len(users))
+	for _, user := range users {
+		infos = append(infos, s.userToUserInfo(user))
+	}
+	return infos, nil
+}
+
+// CreateAdmin creates an account with the admin role, for bootstrapping
+// the first administrator outside the HTTP API
+func (s *Service) CreateAdmin(ctx context.Context, req *RegisterRequest) (*UserInfo, error) {
+	user, err := s.createUser(ctx, req, storage.RoleAdmin, false)
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo := s.userToUserInfo(user)
+	return &userInfo, nil
+}
+
+// AdminResetPassword sets a user's password on an administrator's behalf,
+// for example when they are locked out. An empty password generates one,
+// which is returned; a provided one must meet the password policy. The
+// account is unlocked, and the user is logged out everywhere so only the
+// new password works. With mustChange the user has to choose their own
+// password before doing anything else.
+func (s *Service) AdminResetPassword(ctx context.Context, userID, password string, mustChange bool) (string, error) {
+	user, err := s.adminUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	generated := ""
+	if password == "" {
+		if generated, err = generatePassword(); err != nil {
+			return "", err
+		}
+		password = generated
+	} else if err := s.validateNewPassword(ctx, password); err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := s.hashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	user.PasswordHash = hashedPassword
+	user.MustChangePassword = mustChange
+	user.FailedAttempts = 0
+	user.LockedUntil = time.Time{}
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return "", err
+	}
+
+	if err := s.RevokeAllSessions(ctx, userID); err != nil {
+		return "", err
+	}
+	return generated, nil
+}
+
+// adminUser loads a user an administrator of the context's organization
+// may manage
+func (s *Service) adminUser(ctx context.Context, userID string) (*storage.User, error) {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	// Administrators can only manage accounts in their own organization
+	if user.OrgID != tenant.FromContext(ctx) {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// roleForEmail returns the role a newly registered account should get
+func (s *Service) roleForEmail(email string) string {
+	for _, admin := range s.config.Auth.AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return storage.RoleAdmin
+		}
+	}
+	return storage.RoleUser
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAPIKeyRouter mirrors the API key routes of the server
+func newAPIKeyRouter(service *Service) *gin.Engine {
+	h := NewHandler(service)
+	router := gin.New()
+	router.GET("/profile", h.Middleware(), h.RequireScope(ScopeProfileRead), h.Profile)
+
+	apiKeys := router.Group("/api-keys", h.Middleware(), h.RequireScope(ScopeAPIKeysManage))
+	apiKeys.POST("", h.CreateAPIKey)
+	apiKeys.GET("", h.ListAPIKeys)
+	apiKeys.DELETE("/:id", h.RevokeAPIKey)
+	apiKeys.POST("/:id/rotate", h.RotateAPIKey)
+	return router
+}
+
+func apiKeyHeader(key string) map[string]string {
+	return map[string]string{"Authorization": "ApiKey " + key}
+}
+
+func TestValidateAPIKey(t *testing.T) {
+	service := newTestService(t, nil)
+	user := registerTestUser(t, service, "keys@example.com", "keys")
+	ctx := context.Background()
+
+	key, err := service.CreateAPIKey(ctx, user.User.ID, "ci", []string{ScopeProfileRead, ScopeProfileRead, ""})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	info, scopes, err := service.ValidateAPIKey(ctx, key)
+	if err != nil {
+		t.Fatalf("ValidateAPIKey: %v", err)
+	}
+	if info.ID != user.User.ID {
+		t.Errorf("key resolved to user %s, want %s", info.ID, user.User.ID)
+	}
+	if len(scopes) != 1 || scopes[0] != ScopeProfileRead {
+		t.Errorf("scopes = %v, want [%s]", scopes, ScopeProfileRead)
+	}
+
+	if _, _, err := service.ValidateAPIKey(ctx, key+"x"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("unknown key: got %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestRevokedAPIKeyIsRejected(t *testing.T) {
+	service := newTestService(t, nil)
+	user := registerTestUser(t, service, "revoke@example.com", "revoke")
+	ctx := context.Background()
+
+	key, err := service.CreateAPIKey(ctx, user.User.ID, "ci", nil)
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	keys, err := service.ListAPIKeys(user.User.ID)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("ListAPIKeys = %v, %v; want one key", keys, err)
+	}
+
+	if err := service.RevokeAPIKey(user.User.ID, keys[0].ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if _, _, err := service.ValidateAPIKey(ctx, key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("revoked key: got %v, want ErrInvalidAPIKey", err)
+	}
+	if err := service.RevokeAPIKey("someone-else", keys[0].ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("revoking another user's key: got %v, want ErrAPIKeyNotFound", err)
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	service := newTestService(t, nil)
+	user := registerTestUser(t, service, "rotate@example.com", "rotate")
+	ctx := context.Background()
+
+	oldKey, err := service.CreateAPIKey(ctx, user.User.ID, "deploy", []string{ScopeProfileRead})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	keys, _ := service.ListAPIKeys(user.User.ID)
+
+	newKey, err := service.RotateAPIKey(ctx, user.User.ID, keys[0].ID)
+	if err != nil {
+		t.Fatalf("RotateAPIKey: %v", err)
+	}
+	if _, _, err := service.ValidateAPIKey(ctx, oldKey); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("old key after rotation: got %v, want ErrInvalidAPIKey", err)
+	}
+	_, scopes, err := service.ValidateAPIKey(ctx, newKey)
+	if err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+	if len(scopes) != 1 || scopes[0] != ScopeProfileRead {
+		t.Errorf("new key scopes = %v, want the old key's", scopes)
+	}
+
+	if _, err := service.RotateAPIKey(ctx, user.User.ID, keys[0].ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("rotating a revoked key: got %v, want ErrAPIKeyNotFound", err)
+	}
+}
+
+func TestCheckDelegatedScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		granted   []string
+		requested []string
+		wantErr   bool
+	}{
+		{"subset", []string{ScopeAPIKeysManage, ScopeProfileRead}, []string{ScopeProfileRead}, false},
+		{"same", []string{ScopeAPIKeysManage}, []string{ScopeAPIKeysManage}, false},
+		{"unrestricted caller", nil, []string{ScopeUsersWrite}, false},
+		{"empty request", []string{ScopeAPIKeysManage}, nil, true},
+		{"empty after normalizing", []string{ScopeAPIKeysManage}, []string{""}, true},
+		{"unrestricted caller, empty request", nil, nil, true},
+		{"escalation", []string{ScopeAPIKeysManage}, []string{ScopeUsersWrite}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDelegatedScopes(tt.granted, tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkDelegatedScopes(%v, %v) = %v, wantErr %v", tt.granted, tt.requested, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopeEnforcement(t *testing.T) {
+	service := newTestService(t, nil)
+	user := registerTestUser(t, service, "scopes@example.com", "scopes")
+	router := newAPIKeyRouter(service)
+
+	key, err := service.CreateAPIKey(context.Background(), user.User.ID, "manage", []string{ScopeAPIKeysManage})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	if w := doRequest(t, router, http.MethodGet, "/profile", nil, apiKeyHeader(key)); w.Code != http.StatusForbidden {
+		t.Errorf("profile without profile:read: status %d, want 403", w.Code)
+	}
+	if w := doRequest(t, router, http.MethodGet, "/api-keys", nil, apiKeyHeader(key)); w.Code != http.StatusOK {
+		t.Errorf("list with api_keys:manage: status %d, want 200", w.Code)
+	}
+	if w := doRequest(t, router, http.MethodGet, "/profile", nil, bearer(user.Token)); w.Code != http.StatusOK {
+		t.Errorf("profile with session token: status %d, want 200", w.Code)
+	}
+}
+
+func TestCreateAPIKeyCannotEscalateScopes(t *testing.T) {
+	service := newTestService(t, nil)
+	user := registerTestUser(t, service, "escalate@example.com", "escalate")
+	router := newAPIKeyRouter(service)
+
+	key, err := service.CreateAPIKey(context.Background(), user.User.ID, "manage", []string{ScopeAPIKeysManage})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   int
+	}{
+		{"no scopes", nil, http.StatusForbidden},
+		{"scope the key lacks", []string{ScopeUsersWrite}, http.StatusForbidden},
+		{"own scope", []string{ScopeAPIKeysManage}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := CreateAPIKeyRequest{Name: "child", Scopes: tt.scopes}
+			if w := doRequest(t, router, http.MethodPost, "/api-keys", body, apiKeyHeader(key)); w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// A session token is not scope-limited, so it may create an unrestricted key
+	body := CreateAPIKeyRequest{Name: "full"}
+	if w := doRequest(t, router, http.MethodPost, "/api-keys", body, bearer(user.Token)); w.Code != http.StatusCreated {
+		t.Errorf("session token: status %d, want 201: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRotateAPIKeyHandler(t *testing.T) {
+	service := newTestService(t, nil)
+	user := registerTestUser(t, service, "rotatehandler@example.com", "rotatehandler")
+	router := newAPIKeyRouter(service)
+	ctx := context.Background()
+
+	limited, err := service.CreateAPIKey(ctx, user.User.ID, "limited", []string{ScopeAPIKeysManage})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if _, err := service.CreateAPIKey(ctx, user.User.ID, "admin", []string{ScopeAPIKeysManage, ScopeUsersWrite}); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	keys, _ := service.ListAPIKeys(user.User.ID)
+	limitedID, adminID := keys[0].ID, keys[1].ID
+	if keys[0].Name != "limited" {
+		limitedID, adminID = adminID, limitedID
+	}
+
+	// The limited key can't rotate a key with more scopes than itself
+	if w := doRequest(t, router, http.MethodPost, "/api-keys/"+adminID+"/rotate", nil, apiKeyHeader(limited)); w.Code != http.StatusForbidden {
+		t.Errorf("rotating a broader key: status %d, want 403", w.Code)
+	}
+
+	w := doRequest(t, router, http.MethodPost, "/api-keys/"+limitedID+"/rotate", nil, bearer(user.Token))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("rotate: status %d, want 201: %s", w.Code, w.Body.String())
+	}
+	var rotated CreateAPIKeyResponse
+	decodeData(t, w, &rotated)
+	if rotated.Key == "" || rotated.Key == limited || rotated.Name != "limited" {
+		t.Errorf("rotated key = %+v, want a new key named limited", rotated)
+	}
+
+	if w := doRequest(t, router, http.MethodGet, "/api-keys", nil, apiKeyHeader(limited)); w.Code != http.StatusUnauthorized {
+		t.Errorf("old key after rotation: status %d, want 401", w.Code)
+	}
+	if w := doRequest(t, router, http.MethodGet, "/api-keys", nil, apiKeyHeader(rotated.Key)); w.Code != http.StatusOK {
+		t.Errorf("new key: status %d, want 200", w.Code)
+	}
+	if w := doRequest(t, router, http.MethodPost, "/api-keys/"+limitedID+"/rotate", nil, bearer(user.Token)); w.Code != http.StatusNotFound {
+		t.Errorf("rotating a revoked key: status %d, want 404", w.Code)
+	}
+}
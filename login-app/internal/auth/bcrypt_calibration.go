@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"log/slog"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// calibrationPassword is hashed to time bcrypt; any value will do
+const calibrationPassword = "bcrypt-calibration"
+
+// measureBcrypt returns how long one bcrypt hash at cost takes
+func measureBcrypt(cost int) time.Duration {
+	start := time.Now()
+	// The cost was validated, so hashing can't fail
+	_, _ = bcrypt.GenerateFromPassword([]byte(calibrationPassword), cost)
+	return time.Since(start)
+}
+
+// selectBcryptCost returns the highest cost, from minCost up to bcrypt's
+// maximum, whose hash is expected to take at most target. Each extra cost
+// doubles the work, so one measurement at minCost predicts the others.
+// When even minCost is slower than target, minCost is returned.
+func selectBcryptCost(measure func(cost int) time.Duration, target time.Duration, minCost int) int {
+	elapsed := measure(minCost)
+	cost := minCost
+	for cost < bcrypt.MaxCost && elapsed*2 <= target {
+		elapsed *= 2
+		cost++
+	}
+	return cost
+}
+
+// CalibrateBCrypt times bcrypt on this machine at startup. With AutoCost it
+// replaces BCryptCost with the highest cost that hashes within the target
+// duration; an explicit BCRYPT_COST turns AutoCost off when the
+// configuration is loaded. Either way it warns when one hash at the cost
+// in use is slower than SlowWarning, since every login and registration
+// pays it. Nothing is measured when argon2id is the hasher.
+func CalibrateBCrypt(cfg *config.AuthConfig) {
+	if cfg.PasswordHasher != config.PasswordHasherBcrypt {
+		return
+	}
+	calibration := cfg.BCryptCalibration
+
+	if calibration.AutoCost {
+		cost := selectBcryptCost(measureBcrypt, calibration.TargetDuration, calibration.MinCost)
+		slog.Info("Selected bcrypt cost",
+			"cost", cost,
+			"configured_cost", cfg.BCryptCost,
+			"target_duration", calibration.TargetDuration,
+		)
+		cfg.BCryptCost = cost
+	}
+
+	if calibration.SlowWarning <= 0 {
+		return
+	}
+	if elapsed := measureBcrypt(cfg.BCryptCost); elapsed > calibration.SlowWarning {
+		slog.Warn("Hashing a password with bcrypt is slow on this machine, so logins will be too. Lower BCRYPT_COST or set BCRYPT_COST=auto",
+			"cost", cfg.BCryptCost,
+			"duration", elapsed.Round(time.Millisecond),
+			"threshold", calibration.SlowWarning,
+		)
+	}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+)
+
+var (
+	ErrCaptchaRequired    = errors.New("captcha token required")
+	ErrCaptchaInvalid     = errors.New("captcha verification failed")
+	ErrCaptchaUnavailable = errors.New("captcha verification unavailable")
+)
+
+// StubCaptchaToken is the only token the stub CAPTCHA verifier accepts
+const StubCaptchaToken = "stub-captcha-pass"
+
+// captchaVerifyURLs are the siteverify endpoints of the CAPTCHA providers
+var captchaVerifyURLs = map[string]string{
+	config.CaptchaHCaptcha:  "https://api.hcaptcha.com/siteverify",
+	config.CaptchaReCAPTCHA: "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// CaptchaVerifier checks a token a client got from solving a CAPTCHA. It
+// reports false for tokens the provider rejects, and an error when the
+// token couldn't be checked.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) (bool, error)
+}
+
+// siteVerifyCaptcha checks tokens with the siteverify API shared by
+// hCaptcha and reCAPTCHA
+type siteVerifyCaptcha struct {
+	client    *http.Client
+	verifyURL string
+	secret    string
+}
+
+// siteVerifyResponse is the part of a siteverify response that matters here
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// newCaptchaVerifier creates the verifier for the configured provider, or
+// returns nil when registration doesn't require a CAPTCHA
+func newCaptchaVerifier(cfg config.CaptchaConfig) CaptchaVerifier {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Provider == config.CaptchaStub {
+		return StubCaptchaVerifier{}
+	}
+
+	verifyURL := cfg.VerifyURL
+	if verifyURL == "" {
+		verifyURL = captchaVerifyURLs[cfg.Provider]
+	}
+	return &siteVerifyCaptcha{
+		client:    &http.Client{Timeout: cfg.Timeout},
+		verifyURL: verifyURL,
+		secret:    cfg.Secret,
+	}
+}
+
+func (v *siteVerifyCaptcha) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("captcha siteverify returned %s", resp.Status)
+	}
+
+	var result siteVerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("invalid captcha siteverify response: %w", err)
+	}
+	return result.Success, nil
+}
+
+// StubCaptchaVerifier accepts StubCaptchaToken and rejects anything else,
+// so tests can register without solving a real CAPTCHA
+type StubCaptchaVerifier struct{}
+
+func (StubCaptchaVerifier) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	return token == StubCaptchaToken, nil
+}
+
+// checkCaptcha verifies a registration's CAPTCHA token when a CAPTCHA is
+// required. Registration is refused when the token can't be checked.
+func (s *Service) checkCaptcha(ctx context.Context, token, clientIP string) error {
+	if s.captcha == nil {
+		return nil
+	}
+	if token == "" {
+		return ErrCaptchaRequired
+	}
+
+	ok, err := s.captcha.Verify(ctx, token, clientIP)
+	if err != nil {
+		logging.FromContext(ctx).Error("CAPTCHA verification failed", "error", err)
+		return ErrCaptchaUnavailable
+	}
+	if !ok {
+		return ErrCaptchaInvalid
+	}
+	return nil
+}
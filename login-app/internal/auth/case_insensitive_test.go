@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLoginWithMixedCaseEmail(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "Alice@Example.com", "Alice")
+	if registered.User.Email != "alice@example.com" || registered.User.Username != "Alice" {
+		t.Errorf("registered as %q/%q, want the email lowercased and the username as entered", registered.User.Email, registered.User.Username)
+	}
+
+	for _, address := range []string{"alice@example.com", "ALICE@EXAMPLE.COM", " Alice@Example.com "} {
+		if _, err := service.Login(context.Background(), &LoginRequest{Email: address, Password: testPassword}, "192.0.2.1"); err != nil {
+			t.Errorf("Login(%q): %v", address, err)
+		}
+	}
+}
+
+func TestRegisterRejectsCaseVariantDuplicates(t *testing.T) {
+	service := newTestService(t, nil)
+	registerTestUser(t, service, "Alice@Example.com", "Alice")
+
+	for _, req := range []*RegisterRequest{
+		{Email: "alice@example.com", Username: "someone"},
+		{Email: "other@example.com", Username: "alice"},
+		{Email: "another@example.com", Username: "ALICE"},
+	} {
+		req.Password, req.FirstName, req.LastName = testPassword, "Test", "User"
+		if _, err := service.Register(context.Background(), req, "192.0.2.1"); !errors.Is(err, ErrUserExists) {
+			t.Errorf("Register(%s, %s): got %v, want ErrUserExists", req.Email, req.Username, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+func TestChangePassword(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "change@example.com", "change")
+	ctx := context.Background()
+
+	revoked, err := service.ChangePassword(ctx, registered.User.ID, "", testPassword, newTestPassword, false)
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if revoked {
+		t.Error("sessions were revoked without being asked to")
+	}
+
+	if _, err := service.Login(ctx, &LoginRequest{Email: "change@example.com", Password: newTestPassword}, "192.0.2.1"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+	if _, err := service.Login(ctx, &LoginRequest{Email: "change@example.com", Password: testPassword}, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("login with the old password: got %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := service.ValidateToken(ctx, registered.Token); err != nil {
+		t.Errorf("existing token after the change: %v", err)
+	}
+}
+
+func TestChangePasswordWrongOldPassword(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "wrongold@example.com", "wrongold")
+	ctx := context.Background()
+
+	if _, err := service.ChangePassword(ctx, registered.User.ID, "", "Wrong1!pass", newTestPassword, false); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := service.Login(ctx, &LoginRequest{Email: "wrongold@example.com", Password: testPassword}, "192.0.2.1"); err != nil {
+		t.Errorf("the password changed anyway: %v", err)
+	}
+}
+
+func TestChangePasswordWeakNewPassword(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "weaknew@example.com", "weaknew")
+	ctx := context.Background()
+
+	var policyErr *PasswordPolicyError
+	if _, err := service.ChangePassword(ctx, registered.User.ID, "", testPassword, "short", false); !errors.As(err, &policyErr) {
+		t.Fatalf("got %v, want a PasswordPolicyError", err)
+	}
+	if _, err := service.Login(ctx, &LoginRequest{Email: "weaknew@example.com", Password: testPassword}, "192.0.2.1"); err != nil {
+		t.Errorf("the password changed anyway: %v", err)
+	}
+}
+
+func TestChangePasswordRevokesOtherSessions(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "devices@example.com", "devices")
+	ctx := context.Background()
+
+	other, err := service.Login(ctx, &LoginRequest{Email: "devices@example.com", Password: testPassword}, "192.0.2.2")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	current, err := service.SessionIDFromToken(registered.Token)
+	if err != nil {
+		t.Fatalf("SessionIDFromToken: %v", err)
+	}
+
+	revoked, err := service.ChangePassword(ctx, registered.User.ID, current, testPassword, newTestPassword, true)
+	if err != nil || !revoked {
+		t.Fatalf("ChangePassword = %v, %v; want sessions revoked", revoked, err)
+	}
+	if _, err := service.ValidateToken(ctx, other.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("other session's token: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.ValidateToken(ctx, registered.Token); err != nil {
+		t.Errorf("current session's token: %v", err)
+	}
+}
+
+func TestPasswordChangeRevokesSessionsWhenConfigured(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.RevokeSessionsOnPasswordChange = true
+	})
+	registered := registerTestUser(t, service, "everywhere@example.com", "everywhere")
+	ctx := context.Background()
+
+	before, err := service.Login(ctx, &LoginRequest{Email: "everywhere@example.com", Password: testPassword}, "192.0.2.2")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	current, _ := service.SessionIDFromToken(registered.Token)
+
+	revoked, err := service.ChangePassword(ctx, registered.User.ID, current, testPassword, newTestPassword, false)
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if !revoked {
+		t.Error("ChangePassword didn't report revoking sessions")
+	}
+	if _, err := service.ValidateToken(ctx, before.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("token issued before the change: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.Refresh(ctx, before.RefreshToken); err == nil {
+		t.Error("refresh token issued before the change still works")
+	}
+	if _, err := service.ValidateToken(ctx, registered.Token); err != nil {
+		t.Errorf("the session the change was made from: %v", err)
+	}
+}
+
+func TestPasswordResetRevokesSessionsWhenConfigured(t *testing.T) {
+	for _, revokeSessions := range []bool{false, true} {
+		service := newTestService(t, func(cfg *config.Config) {
+			cfg.Auth.RevokeSessionsOnPasswordChange = revokeSessions
+		})
+		registered := registerTestUser(t, service, "resetall@example.com", "resetall")
+		ctx := context.Background()
+
+		token, _ := service.RequestPasswordReset(ctx, "resetall@example.com")
+		revoked, err := service.ResetPassword(ctx, token, newTestPassword)
+		if err != nil {
+			t.Fatalf("ResetPassword: %v", err)
+		}
+		if revoked != revokeSessions {
+			t.Errorf("with the toggle %v, ResetPassword reported revoked %v", revokeSessions, revoked)
+		}
+
+		_, err = service.ValidateToken(ctx, registered.Token)
+		if revokeSessions && !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("token issued before the reset: got %v, want ErrTokenRevoked", err)
+		}
+		if !revokeSessions && err != nil {
+			t.Errorf("token issued before the reset with the toggle off: %v", err)
+		}
+
+		// Refresh tokens are revoked either way
+		if _, err := service.Refresh(ctx, registered.RefreshToken); err == nil {
+			t.Errorf("with the toggle %v, the refresh token still works", revokeSessions)
+		}
+	}
+}
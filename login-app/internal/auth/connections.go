@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+var (
+	ErrConnectionNotFound = errors.New("provider is not linked to the account")
+	ErrLastLoginMethod    = errors.New("cannot remove the account's only way to log in")
+)
+
+// ListConnections returns the external providers linked to a user, and
+// the user's other ways to log in
+func (s *Service) ListConnections(ctx context.Context, userID string) (*ConnectionsResponse, error) {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	connections := make([]ConnectionInfo, 0, len(user.LinkedProviders))
+	for _, linked := range user.LinkedProviders {
+		connections = append(connections, ConnectionInfo{
+			Provider:       linked.Provider,
+			ProviderUserID: linked.ProviderUserID,
+			LinkedAt:       linked.LinkedAt,
+		})
+	}
+	return &ConnectionsResponse{
+		Connections: connections,
+		HasPassword: user.PasswordHash != "",
+		Passkeys:    len(user.WebAuthnCredentials),
+	}, nil
+}
+
+// UnlinkProvider removes every identity of provider linked to a user. It
+// refuses with ErrLastLoginMethod when the account would be left without a
+// password, passkey or other linked provider to log in with.
+func (s *Service) UnlinkProvider(ctx context.Context, userID, provider string) error {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	remaining := make([]storage.LinkedProvider, 0, len(user.LinkedProviders))
+	for _, linked := range user.LinkedProviders {
+		if linked.Provider != provider {
+			remaining = append(remaining, linked)
+		}
+	}
+	if len(remaining) == len(user.LinkedProviders) {
+		return ErrConnectionNotFound
+	}
+	if len(remaining) == 0 && user.PasswordHash == "" && len(user.WebAuthnCredentials) == 0 {
+		return ErrLastLoginMethod
+	}
+
+	user.LinkedProviders = remaining
+	if len(remaining) == 0 {
+		user.LinkedProviders = nil
+	}
+	return s.userStore.UpdateUser(ctx, user)
+}
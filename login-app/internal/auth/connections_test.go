@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+// linkProviders links the given providers to a user, and removes the
+// user's password unless keepPassword is set
+func linkProviders(t *testing.T, service *Service, userID string, keepPassword bool, providers ...string) {
+	t.Helper()
+
+	ctx := context.Background()
+	user, err := service.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	for _, provider := range providers {
+		user.LinkedProviders = append(user.LinkedProviders, storage.LinkedProvider{Provider: provider, ProviderUserID: provider + "-" + userID})
+	}
+	if !keepPassword {
+		user.PasswordHash = ""
+	}
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+}
+
+func TestListConnections(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "linked@example.com", "linked")
+	ctx := context.Background()
+
+	response, err := service.ListConnections(ctx, registered.User.ID)
+	if err != nil {
+		t.Fatalf("ListConnections: %v", err)
+	}
+	if len(response.Connections) != 0 || !response.HasPassword {
+		t.Errorf("before linking: %+v, want no connections and a password", response)
+	}
+
+	linkProviders(t, service, registered.User.ID, true, "github", "google")
+	response, err = service.ListConnections(ctx, registered.User.ID)
+	if err != nil {
+		t.Fatalf("ListConnections: %v", err)
+	}
+	if len(response.Connections) != 2 || response.Connections[0].Provider != "github" || response.Connections[1].Provider != "google" {
+		t.Errorf("connections = %+v, want github and google", response.Connections)
+	}
+
+	if _, err := service.ListConnections(ctx, "no-such-user"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: got %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestUnlinkProvider(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "unlink@example.com", "unlink")
+	linkProviders(t, service, registered.User.ID, false, "github", "google")
+	ctx := context.Background()
+
+	if err := service.UnlinkProvider(ctx, registered.User.ID, "github"); err != nil {
+		t.Fatalf("UnlinkProvider: %v", err)
+	}
+	response, _ := service.ListConnections(ctx, registered.User.ID)
+	if len(response.Connections) != 1 || response.Connections[0].Provider != "google" {
+		t.Errorf("connections = %+v, want only google", response.Connections)
+	}
+
+	if err := service.UnlinkProvider(ctx, registered.User.ID, "github"); !errors.Is(err, ErrConnectionNotFound) {
+		t.Errorf("unlinking again: got %v, want ErrConnectionNotFound", err)
+	}
+}
+
+func TestUnlinkProviderKeepsALoginMethod(t *testing.T) {
+	ctx := context.Background()
+
+	// With nothing else to log in with, the last provider stays
+	service := newTestService(t, nil)
+	only := registerTestUser(t, service, "only@example.com", "only")
+	linkProviders(t, service, only.User.ID, false, "github")
+	if err := service.UnlinkProvider(ctx, only.User.ID, "github"); !errors.Is(err, ErrLastLoginMethod) {
+		t.Errorf("only login method: got %v, want ErrLastLoginMethod", err)
+	}
+	if response, _ := service.ListConnections(ctx, only.User.ID); len(response.Connections) != 1 {
+		t.Errorf("connections = %+v, want github kept", response.Connections)
+	}
+
+	// A password or a passkey is enough to fall back on
+	withPassword := registerTestUser(t, service, "password@example.com", "password")
+	linkProviders(t, service, withPassword.User.ID, true, "github")
+	if err := service.UnlinkProvider(ctx, withPassword.User.ID, "github"); err != nil {
+		t.Errorf("with a password: %v", err)
+	}
+
+	withPasskey := registerTestUser(t, service, "passkey@example.com", "passkey")
+	linkProviders(t, service, withPasskey.User.ID, false, "github")
+	user, _ := service.userStore.GetUserByID(ctx, withPasskey.User.ID)
+	user.WebAuthnCredentials = []storage.WebAuthnCredential{{ID: []byte("passkey")}}
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := service.UnlinkProvider(ctx, withPasskey.User.ID, "github"); err != nil {
+		t.Errorf("with a passkey: %v", err)
+	}
+}
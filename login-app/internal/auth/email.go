@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+)
+
+// sendEmail renders a message template and sends it to a user
+func (s *Service) sendEmail(to, subject, template string, data map[string]interface{}) error {
+	body, err := email.Render(template, data)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(to, subject, body)
+}
+
+// linkURL builds an absolute link to a path on this app with a token query parameter
+func (s *Service) linkURL(path, token string) string {
+	return strings.TrimSuffix(s.config.Email.BaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// formatTTL describes a token lifetime for people, e.g. "30 minutes"
+func formatTTL(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		if d == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	case d >= time.Minute:
+		if d < 2*time.Minute {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
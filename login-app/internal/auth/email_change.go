@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+var (
+	ErrEmailTaken              = errors.New("email address is already in use")
+	ErrEmailUnchanged          = errors.New("new email address is the same as the current one")
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+)
+
+// RequestEmailChange stores a pending email change and sends a confirmation
+// link to the new address. The change only takes effect once
+// ConfirmEmailChange is called with the token.
+func (s *Service) RequestEmailChange(ctx context.Context, userID, newEmail string) (string, error) {
+	newEmail = storage.NormalizeEmail(newEmail)
+
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+
+	if newEmail == user.Email {
+		return "", ErrEmailUnchanged
+	}
+
+	if _, err := s.userStore.GetUserByEmail(ctx, newEmail); err == nil {
+		return "", ErrEmailTaken
+	} else if err != storage.ErrUserNotFound {
+		return "", err
+	}
+
+	// Only the most recently requested change stays pending
+	if err := s.tokenStore.DeleteUserTokens(user.ID, storage.TokenPurposeEmailChange); err != nil {
+		return "", err
+	}
+
+	token, err := s.newSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.tokenStore.SaveToken(&storage.VerificationToken{
+		Token:     token,
+		Purpose:   storage.TokenPurposeEmailChange,
+		UserID:    user.ID,
+		Data:      newEmail,
+		ExpiresAt: time.Now().Add(s.config.Auth.EmailChangeTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	if err := s.sendEmail(newEmail, "Confirm your new email address", "email_change.html", map[string]interface{}{
+		"Email":     newEmail,
+		"URL":       s.linkURL("/api/auth/change-email/confirm", token),
+		"ExpiresIn": formatTTL(s.config.Auth.EmailChangeTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConfirmEmailChange applies a pending email change and notifies the old
+// address, so an unexpected change doesn't go unnoticed
+func (s *Service) ConfirmEmailChange(ctx context.Context, token string) error {
+	stored, err := s.tokenStore.ConsumeToken(token, storage.TokenPurposeEmailChange)
+	if err != nil {
+		if err == storage.ErrTokenNotFound || err == storage.ErrTokenExpired {
+			return ErrInvalidEmailChangeToken
+		}
+		return err
+	}
+
+	user, err := s.userStore.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrInvalidEmailChangeToken
+		}
+		return err
+	}
+
+	if !user.IsActive {
+		return ErrInvalidEmailChangeToken
+	}
+
+	oldEmail := user.Email
+	user.Email = stored.Data
+
+	// Following the confirmation link proves the new address is the user's
+	markEmailVerified(user)
+
+	// The address may have been claimed since the change was requested
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		if err == storage.ErrUserExists {
+			return ErrEmailTaken
+		}
+		return err
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypeEmailChanged,
+		Outcome: events.OutcomeSuccess,
+		UserID:  user.ID,
+		Email:   user.Email,
+		Details: map[string]string{"previous_email": oldEmail},
+	})
+
+	return s.sendEmail(oldEmail, "Your email address was changed", "email_changed.html", map[string]interface{}{
+		"Email": user.Email,
+	})
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/audit"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+)
+
+// eventSource identifies the auth subsystem in published security events
+const eventSource = "auth"
+
+// auditedEvents are the event types also kept in the queryable audit log
+var auditedEvents = map[string]bool{
+	events.TypeLoginSucceeded:  true,
+	events.TypeLoginFailed:     true,
+	events.TypeLogout:          true,
+	events.TypeRegistered:      true,
+	events.TypePasswordChanged: true,
+	events.TypePasswordReset:   true,
+}
+
+// publishEvent publishes a security event from the auth service and records
+// authentication events in the audit log
+func (s *Service) publishEvent(event events.Event) {
+	event.Source = eventSource
+	s.events.Publish(event)
+
+	if auditedEvents[event.Type] {
+		s.recordAudit(event)
+	}
+}
+
+// recordAudit appends an event to the audit log
+func (s *Service) recordAudit(event events.Event) {
+	id, err := s.generateID()
+	if err != nil {
+		slog.Error("Failed to generate audit entry ID", "error", err)
+		return
+	}
+
+	entry := audit.Entry{
+		ID:        id,
+		Type:      event.Type,
+		Outcome:   event.Outcome,
+		UserID:    event.UserID,
+		Email:     event.Email,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		Timestamp: time.Now(),
+		Details:   event.Details,
+	}
+	if device := audit.ParseUserAgent(event.UserAgent); !device.IsZero() {
+		entry.Device = &device
+	}
+
+	if err := s.auditLog.Record(entry); err != nil {
+		slog.Error("Failed to record audit entry", "type", event.Type, "error", err)
+	}
+}
+
+// QueryAuditLog returns audit entries matching the filter, newest first
+func (s *Service) QueryAuditLog(filter audit.Filter) ([]audit.Entry, int, error) {
+	return s.auditLog.Query(filter)
+}
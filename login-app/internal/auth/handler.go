@@ -1,12 +1,27 @@
 package auth
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	// Gin HTTP framework for REST API routing and middleware
 	// Enterprise-grade web framework for secure HTTP request handling
 	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/audit"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tokens"
 )
 
 // Handler handles HTTP requests for authentication
@@ -26,38 +41,87 @@ func (h *Handler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "validation_error",
-			Message: "Invalid request data",
-			Code:    http.StatusBadRequest,
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
 		})
 		return
 	}
 
-	response, err := h.service.Register(&req)
+	response, err := h.service.Register(c.Request.Context(), &req, c.ClientIP())
 	if err != nil {
 		status := http.StatusInternalServerError
 		message := "Registration failed"
 
+		var details interface{}
+		var policyErr *PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			status = http.StatusBadRequest
+			message = "Password does not meet requirements"
+			details = policyErr.Violations
+		}
+
+		var throttled *SubnetThrottledError
+		if errors.As(err, &throttled) {
+			status = http.StatusTooManyRequests
+			message = "Too many registrations from your network, please try again later"
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		}
+
 		switch err {
+		case ErrBreachCheckUnavailable:
+			status = http.StatusServiceUnavailable
+			message = "Password could not be checked, try again later"
 		case ErrUserExists:
 			status = http.StatusConflict
 			message = "User already exists"
 		case ErrInvalidCredentials:
 			status = http.StatusBadRequest
 			message = "Invalid credentials"
+		case ErrInviteRequired:
+			status = http.StatusForbidden
+			message = "Registration is by invitation only, an invite code is required"
+		case ErrInvalidInvite:
+			status = http.StatusForbidden
+			message = "Invalid, used or expired invite code"
+		case ErrCaptchaRequired:
+			status = http.StatusBadRequest
+			message = "A CAPTCHA token is required"
+		case ErrCaptchaInvalid:
+			status = http.StatusBadRequest
+			message = "CAPTCHA verification failed"
+		case ErrCaptchaUnavailable:
+			status = http.StatusServiceUnavailable
+			message = "CAPTCHA could not be verified, try again later"
 		}
 
+		h.publishRequestEvent(c, events.TypeRegistered, events.OutcomeFailure, "", req.Email,
+			map[string]string{"reason": err.Error()})
+
 		c.JSON(status, ErrorResponse{
-			Error:   "registration_error",
-			Message: message,
-			Code:    status,
+			Error:     "registration_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+			Details:   details,
 		})
 		return
 	}
 
+	h.recordSessionClient(c, response)
+	h.setTokenCookie(c, response)
+	h.publishRequestEvent(c, events.TypeRegistered, events.OutcomeSuccess, response.User.ID, response.User.Email, nil)
+
+	message := "User registered successfully"
+	if response.EmailVerificationRequired {
+		message = "User registered successfully, verify your email address to log in"
+	}
+
 	c.JSON(http.StatusCreated, SuccessResponse{
 		Success: true,
-		Message: "User registered successfully",
+		Message: message,
 		Data:    response,
 	})
 }
@@ -67,18 +131,34 @@ func (h *Handler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "validation_error",
-			Message: "Invalid request data",
-			Code:    http.StatusBadRequest,
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
 		})
 		return
 	}
 
-	response, err := h.service.Login(&req)
+	response, err := h.service.Login(c.Request.Context(), &req, c.ClientIP())
 	if err != nil {
 		status := http.StatusInternalServerError
 		message := "Login failed"
 
+		// The failure stands, the header tells the client when to try again
+		var backoff *BackoffError
+		if errors.As(err, &backoff) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(backoff.RetryAfter.Seconds()))))
+			err = backoff.Err
+		}
+
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			status = http.StatusTooManyRequests
+			message = "Too many failed login attempts, please try again later"
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		}
+
 		switch err {
 		case ErrInvalidCredentials:
 			status = http.StatusUnauthorized
@@ -86,16 +166,341 @@ func (h *Handler) Login(c *gin.Context) {
 		case ErrUserNotFound:
 			status = http.StatusUnauthorized
 			message = "Invalid email or password"
+		case ErrEmailNotVerified:
+			status = http.StatusForbidden
+			message = "Verify your email address before logging in"
+		}
+
+		logging.FromContext(c.Request.Context()).Warn("Login failed",
+			"email", req.Email, "reason", err.Error())
+		h.publishRequestEvent(c, events.TypeLoginFailed, events.OutcomeFailure, "", req.Email,
+			map[string]string{"reason": err.Error()})
+
+		c.JSON(status, ErrorResponse{
+			Error:     "login_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	if response.TwoFactorRequired {
+		c.JSON(http.StatusOK, SuccessResponse{
+			Success: true,
+			Message: "Two-factor authentication required",
+			Data:    response,
+		})
+		return
+	}
+
+	h.recordSessionClient(c, response)
+	h.setTokenCookie(c, response)
+	logging.FromContext(c.Request.Context()).Info("Login succeeded", "user_id", response.User.ID)
+	h.publishRequestEvent(c, events.TypeLoginSucceeded, events.OutcomeSuccess, response.User.ID, response.User.Email, nil)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Login successful",
+		Data:    response,
+	})
+}
+
+// LoginTwoFactor completes a login with a TOTP or recovery code
+func (h *Handler) LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	response, err := h.service.CompleteTwoFactorLogin(c.Request.Context(), req.ChallengeToken, req.Code, req.RememberMe)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Login failed"
+
+		switch err {
+		case ErrInvalidTOTPCode:
+			status = http.StatusUnauthorized
+			message = "Invalid two-factor code"
+		case ErrInvalidChallenge:
+			status = http.StatusUnauthorized
+			message = "Invalid or expired two-factor challenge, please log in again"
+		case ErrTooManyTwoFactorErrors:
+			status = http.StatusUnauthorized
+			message = "Too many invalid codes, please log in again"
+		}
+
+		logging.FromContext(c.Request.Context()).Warn("Two-factor login failed", "reason", err.Error())
+		h.publishRequestEvent(c, events.TypeLoginFailed, events.OutcomeFailure, "", "",
+			map[string]string{"reason": err.Error(), "factor": "totp"})
+
+		c.JSON(status, ErrorResponse{
+			Error:     "login_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.recordSessionClient(c, response)
+	h.setTokenCookie(c, response)
+	logging.FromContext(c.Request.Context()).Info("Login succeeded", "user_id", response.User.ID, "factor", "totp")
+	h.publishRequestEvent(c, events.TypeLoginSucceeded, events.OutcomeSuccess, response.User.ID, response.User.Email,
+		map[string]string{"factor": "totp"})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Login successful",
+		Data:    response,
+	})
+}
+
+// EnableTOTP provisions a TOTP secret for the authenticated user
+func (h *Handler) EnableTOTP(c *gin.Context) {
+	secret, otpauthURL, err := h.service.EnableTOTP(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to enable two-factor authentication"
+
+		switch err {
+		case ErrTOTPAlreadyEnabled:
+			status = http.StatusConflict
+			message = "Two-factor authentication is already enabled"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "two_factor_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Scan the secret with an authenticator app, then confirm with a code",
+		Data: EnableTOTPResponse{
+			Secret:     secret,
+			OTPAuthURL: otpauthURL,
+		},
+	})
+}
+
+// ConfirmTOTP switches two-factor authentication on after verifying a code
+func (h *Handler) ConfirmTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTP(c.Request.Context(), c.GetString("user_id"), req.Code)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to confirm two-factor authentication"
+
+		switch err {
+		case ErrInvalidTOTPCode:
+			status = http.StatusBadRequest
+			message = "Invalid two-factor code"
+		case ErrTOTPNotEnabled:
+			status = http.StatusBadRequest
+			message = "Two-factor authentication has not been set up"
+		case ErrTOTPAlreadyEnabled:
+			status = http.StatusConflict
+			message = "Two-factor authentication is already enabled"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "two_factor_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Two-factor authentication enabled. Store the recovery codes somewhere safe",
+		Data:    ConfirmTOTPResponse{RecoveryCodes: codes},
+	})
+}
+
+// BeginPasskeyRegistration starts registering a passkey for the authenticated user
+func (h *Handler) BeginPasskeyRegistration(c *gin.Context) {
+	options, err := h.service.BeginPasskeyRegistration(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to start passkey registration"
+
+		switch err {
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "passkey_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Create a passkey with these options, then send the result to finish registration",
+		Data:    options,
+	})
+}
+
+// FinishPasskeyRegistration stores a passkey after verifying the browser's response
+func (h *Handler) FinishPasskeyRegistration(c *gin.Context) {
+	var req PasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	passkey, err := h.service.FinishPasskeyRegistration(c.Request.Context(), c.GetString("user_id"), &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to register passkey"
+
+		switch err {
+		case ErrInvalidPasskeySession:
+			status = http.StatusBadRequest
+			message = "Invalid or expired passkey session, please start again"
+		case ErrPasskeyRejected:
+			status = http.StatusBadRequest
+			message = "The passkey could not be verified"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "passkey_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, SuccessResponse{
+		Success: true,
+		Message: "Passkey registered",
+		Data:    passkey,
+	})
+}
+
+// BeginPasskeyLogin starts a passwordless login with a passkey
+func (h *Handler) BeginPasskeyLogin(c *gin.Context) {
+	options, err := h.service.BeginPasskeyLogin(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "passkey_error",
+			Message:   "Failed to start passkey login",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Sign the challenge with a passkey, then send the result to finish logging in",
+		Data:    options,
+	})
+}
+
+// FinishPasskeyLogin logs a user in after verifying the browser's passkey assertion
+func (h *Handler) FinishPasskeyLogin(c *gin.Context) {
+	var req PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	response, err := h.service.FinishPasskeyLogin(c.Request.Context(), &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Login failed"
+
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			status = http.StatusTooManyRequests
+			message = "Too many failed login attempts, please try again later"
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		}
+
+		switch err {
+		case ErrInvalidPasskeySession:
+			status = http.StatusUnauthorized
+			message = "Invalid or expired passkey session, please start again"
+		case ErrPasskeyRejected:
+			status = http.StatusUnauthorized
+			message = "The passkey could not be verified"
+		case ErrPasskeyCloned:
+			status = http.StatusUnauthorized
+			message = "This passkey can no longer be used, please sign in another way"
 		}
 
+		logging.FromContext(c.Request.Context()).Warn("Passkey login failed", "reason", err.Error())
+		h.publishRequestEvent(c, events.TypeLoginFailed, events.OutcomeFailure, "", "",
+			map[string]string{"reason": err.Error(), "method": "passkey"})
+
 		c.JSON(status, ErrorResponse{
-			Error:   "login_error",
-			Message: message,
-			Code:    status,
+			Error:     "login_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
 		})
 		return
 	}
 
+	h.recordSessionClient(c, response)
+	h.setTokenCookie(c, response)
+	logging.FromContext(c.Request.Context()).Info("Login succeeded", "user_id", response.User.ID, "method", "passkey")
+	h.publishRequestEvent(c, events.TypeLoginSucceeded, events.OutcomeSuccess, response.User.ID, response.User.Email,
+		map[string]string{"method": "passkey"})
+
 	c.JSON(http.StatusOK, SuccessResponse{
 		Success: true,
 		Message: "Login successful",
@@ -103,10 +508,94 @@ func (h *Handler) Login(c *gin.Context) {
 	})
 }
 
-// Logout handles user logout
+// Refresh exchanges a refresh token for a new access token
+func (h *Handler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	response, err := h.service.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Token refresh failed"
+
+		switch err {
+		case ErrInvalidRefreshToken:
+			status = http.StatusUnauthorized
+			message = "Invalid or expired refresh token"
+		case ErrRefreshTokenReused:
+			status = http.StatusUnauthorized
+			message = "Refresh token has already been used, please log in again"
+		}
+
+		h.publishRequestEvent(c, events.TypeTokenRefreshed, events.OutcomeFailure, "", "",
+			map[string]string{"reason": err.Error()})
+
+		c.JSON(status, ErrorResponse{
+			Error:     "refresh_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.setTokenCookie(c, response)
+	h.publishRequestEvent(c, events.TypeTokenRefreshed, events.OutcomeSuccess, response.User.ID, response.User.Email, nil)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Token refreshed successfully",
+		Data:    response,
+	})
+}
+
+// Logout handles user logout by revoking the presented access token and,
+// when supplied, the refresh token
 func (h *Handler) Logout(c *gin.Context) {
-	// In a JWT-based system, logout is typically handled client-side
-	// by removing the token from storage
+	// The body is optional, so binding errors are ignored
+	var req LogoutRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var userID string
+	if token, ok := h.accessToken(c); ok {
+		if userInfo, err := h.service.ValidateToken(c.Request.Context(), token); err == nil {
+			userID = userInfo.ID
+		}
+		if err := h.service.RevokeToken(token); err != nil && err != ErrInvalidToken {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:     "logout_error",
+				Message:   "Logout failed",
+				Code:      http.StatusInternalServerError,
+				RequestID: c.GetString("request_id"),
+			})
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.service.RevokeRefreshToken(req.RefreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:     "logout_error",
+				Message:   "Logout failed",
+				Code:      http.StatusInternalServerError,
+				RequestID: c.GetString("request_id"),
+			})
+			return
+		}
+	}
+
+	h.clearTokenCookie(c)
+	h.publishRequestEvent(c, events.TypeLogout, events.OutcomeSuccess, userID, "", nil)
+
 	c.JSON(http.StatusOK, SuccessResponse{
 		Success: true,
 		Message: "Logout successful",
@@ -118,14 +607,15 @@ func (h *Handler) Profile(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "unauthorized",
-			Message: "User not authenticated",
-			Code:    http.StatusUnauthorized,
+			Error:     "unauthorized",
+			Message:   "User not authenticated",
+			Code:      http.StatusUnauthorized,
+			RequestID: c.GetString("request_id"),
 		})
 		return
 	}
 
-	profile, err := h.service.GetUserProfile(userID)
+	profile, err := h.service.GetUserProfile(c.Request.Context(), userID)
 	if err != nil {
 		status := http.StatusInternalServerError
 		message := "Failed to get user profile"
@@ -137,9 +627,10 @@ func (h *Handler) Profile(c *gin.Context) {
 		}
 
 		c.JSON(status, ErrorResponse{
-			Error:   "profile_error",
-			Message: message,
-			Code:    status,
+			Error:     "profile_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
 		})
 		return
 	}
@@ -151,59 +642,2308 @@ func (h *Handler) Profile(c *gin.Context) {
 	})
 }
 
-// Middleware creates authentication middleware
-func (h *Handler) Middleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{
-				Error:   "unauthorized",
-				Message: "Authorization header required",
-				Code:    http.StatusUnauthorized,
-			})
-			c.Abort()
-			return
-		}
-
-		// Extract token from "Bearer <token>"
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{
-				Error:   "unauthorized",
-				Message: "Invalid authorization header format",
-				Code:    http.StatusUnauthorized,
-			})
-			c.Abort()
-			return
-		}
+// WhoAmI reports who the request's credentials belong to from the access
+// token's claims, which the auth middleware has already checked
+func (h *Handler) WhoAmI(c *gin.Context) {
+	userInfo, ok := c.Get("user_info")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:     "unauthorized",
+			Message:   "User not authenticated",
+			Code:      http.StatusUnauthorized,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
 
-		token := tokenParts[1]
-		userInfo, err := h.service.ValidateToken(token)
-		if err != nil {
-			status := http.StatusUnauthorized
-			message := "Invalid token"
-
-			switch err {
-			case ErrTokenExpired:
-				message = "Token expired"
-			case ErrInvalidToken:
-				message = "Invalid token"
-			}
+	claims, _ := c.Get("token_claims")
+	tokenClaims, _ := claims.(*JWTClaims)
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Token is valid",
+		Data:    WhoAmI(userInfo.(*UserInfo), tokenClaims),
+	})
+}
 
-			c.JSON(status, ErrorResponse{
-				Error:   "unauthorized",
-				Message: message,
-				Code:    status,
-			})
+// ValidateToken reports how long the request's access token has left. The
+// auth middleware has already rejected invalid, expired and revoked tokens
+// with the reason in the 401's details, so this only sees valid ones.
+func (h *Handler) ValidateToken(c *gin.Context) {
+	token, ok := h.accessToken(c)
+	if !ok || c.GetString("auth_method") != "token" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Only access tokens can be validated",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	claims, err := h.service.parseToken(token)
+	if err != nil {
+		reason, _ := tokenErrorReason(err)
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:     "unauthorized",
+			Message:   tokenErrorMessage(err),
+			Code:      http.StatusUnauthorized,
+			RequestID: c.GetString("request_id"),
+			Details:   TokenErrorDetails{Reason: reason},
+		})
+		return
+	}
+
+	expiresAt := claims.ExpiresAt.Time
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Token is valid",
+		Data: TokenValidationResponse{
+			Valid:            true,
+			ExpiresAt:        expiresAt,
+			SecondsRemaining: max(int64(time.Until(expiresAt).Seconds()), 0),
+		},
+	})
+}
+
+// tokenErrorReason returns the machine-readable reason for an access token
+// error, or false for errors that aren't about the token
+func tokenErrorReason(err error) (string, bool) {
+	switch err {
+	case ErrTokenExpired:
+		return TokenReasonExpired, true
+	case ErrTokenRevoked:
+		return TokenReasonRevoked, true
+	case ErrInvalidToken:
+		return TokenReasonInvalid, true
+	}
+	return "", false
+}
+
+// tokenErrorMessage describes an access token error for people
+func tokenErrorMessage(err error) string {
+	switch err {
+	case ErrTokenExpired:
+		return "Token expired"
+	case ErrTokenRevoked:
+		return "Token revoked"
+	}
+	return "Invalid token"
+}
+
+// UpdateProfile updates the authenticated user's name and username
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	profile, err := h.service.UpdateProfile(c.Request.Context(), userID, &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to update profile"
+
+		switch err {
+		case ErrUserExists:
+			status = http.StatusConflict
+			message = "Username is already taken"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "profile_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeProfileUpdated, events.OutcomeSuccess, userID, profile.Email, nil)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Profile updated successfully",
+		Data:    profile,
+	})
+}
+
+// RequestEmailChange sends a confirmation link to the authenticated user's new email address
+func (h *Handler) RequestEmailChange(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	if _, err := h.service.RequestEmailChange(c.Request.Context(), c.GetString("user_id"), req.NewEmail); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to request email change"
+
+		switch err {
+		case ErrEmailTaken:
+			status = http.StatusConflict
+			message = "Email address is already in use"
+		case ErrEmailUnchanged:
+			status = http.StatusBadRequest
+			message = "New email address is the same as the current one"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "email_change_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "A confirmation link has been sent to the new email address",
+	})
+}
+
+// ConfirmEmailChange applies a pending email change
+func (h *Handler) ConfirmEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Token is required",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	if err := h.service.ConfirmEmailChange(c.Request.Context(), token); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to change email address"
+
+		switch err {
+		case ErrInvalidEmailChangeToken:
+			status = http.StatusBadRequest
+			message = "Invalid or expired confirmation link"
+		case ErrEmailTaken:
+			status = http.StatusConflict
+			message = "Email address is already in use"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "email_change_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Email address changed successfully",
+	})
+}
+
+// VerifyEmail verifies an account's email address with the token from a
+// verification email
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Token is required",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	if err := h.service.VerifyEmail(c.Request.Context(), token); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to verify email address"
+
+		switch err {
+		case ErrInvalidVerificationToken:
+			status = http.StatusBadRequest
+			message = "Invalid or expired verification link"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "email_verification_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Email address verified successfully",
+	})
+}
+
+// ResendEmailVerification sends a new verification link. The response is
+// the same whether or not the email belongs to an unverified account.
+func (h *Handler) ResendEmailVerification(c *gin.Context) {
+	var req EmailVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	if _, err := h.service.RequestEmailVerification(c.Request.Context(), req.Email); err != nil {
+		// Log but don't reveal anything about the account to the caller
+		logging.FromContext(c.Request.Context()).Error("Email verification request failed", "error", err)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "If an unverified account exists for that email, a verification link has been sent",
+	})
+}
+
+// UnlockAccount lifts an account lock with the token from an unlock email
+func (h *Handler) UnlockAccount(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Token is required",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	if err := h.service.UnlockAccount(c.Request.Context(), token); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to unlock account"
+
+		switch err {
+		case ErrInvalidUnlockToken:
+			status = http.StatusBadRequest
+			message = "Invalid or expired unlock link"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "unlock_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Account unlocked, you can log in again",
+	})
+}
+
+// ForgotPassword starts the password reset flow. It always responds with the
+// same message so callers can't tell whether the email is registered.
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	if _, err := h.service.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		// Log but don't reveal anything about the account to the caller
+		logging.FromContext(c.Request.Context()).Error("Password reset request failed", "error", err)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "If an account exists for that email, a password reset link has been sent",
+	})
+}
+
+// RequestMagicLink issues a passwordless login link. The response is the
+// same whether or not the email belongs to an account.
+func (h *Handler) RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	if _, err := h.service.RequestMagicLink(c.Request.Context(), req.Email); err != nil {
+		// Log but don't reveal anything about the account to the caller
+		logging.FromContext(c.Request.Context()).Error("Magic link request failed", "error", err)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "If an account exists for that email, a login link has been sent",
+	})
+}
+
+// ConsumeMagicLink logs a user in with a magic link token
+func (h *Handler) ConsumeMagicLink(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Token is required",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	response, err := h.service.ConsumeMagicLink(c.Request.Context(), token)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Login failed"
+
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			status = http.StatusTooManyRequests
+			message = "Too many failed login attempts, please try again later"
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		}
+
+		switch err {
+		case ErrInvalidMagicLink:
+			status = http.StatusUnauthorized
+			message = "Invalid or expired login link"
+		}
+
+		h.publishRequestEvent(c, events.TypeLoginFailed, events.OutcomeFailure, "", "",
+			map[string]string{"reason": err.Error(), "method": "magic_link"})
+
+		c.JSON(status, ErrorResponse{
+			Error:     "login_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	if response.TwoFactorRequired {
+		c.JSON(http.StatusOK, SuccessResponse{
+			Success: true,
+			Message: "Two-factor authentication required",
+			Data:    response,
+		})
+		return
+	}
+
+	h.recordSessionClient(c, response)
+	h.setTokenCookie(c, response)
+	logging.FromContext(c.Request.Context()).Info("Login succeeded", "user_id", response.User.ID, "method", "magic_link")
+	h.publishRequestEvent(c, events.TypeLoginSucceeded, events.OutcomeSuccess, response.User.ID, response.User.Email,
+		map[string]string{"method": "magic_link"})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Login successful",
+		Data:    response,
+	})
+}
+
+// ResetPassword sets a new password using a reset token
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	revoked, err := h.service.ResetPassword(c.Request.Context(), req.Token, req.Password)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Password reset failed"
+
+		var details interface{}
+		var policyErr *PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			status = http.StatusBadRequest
+			message = "Password does not meet requirements"
+			details = policyErr.Violations
+		}
+
+		switch err {
+		case ErrBreachCheckUnavailable:
+			status = http.StatusServiceUnavailable
+			message = "Password could not be checked, try again later"
+		case ErrInvalidResetToken:
+			status = http.StatusBadRequest
+			message = "Invalid or already used reset token"
+		case ErrResetTokenExpired:
+			status = http.StatusBadRequest
+			message = "Reset token has expired"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "reset_password_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+			Details:   details,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Password reset successfully",
+		Data:    PasswordChangeResponse{SessionsRevoked: revoked},
+	})
+}
+
+// RecoveryQuestions returns the security questions for an account so they
+// can be answered to recover it
+func (h *Handler) RecoveryQuestions(c *gin.Context) {
+	var req RecoveryQuestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	questions, err := h.service.SecurityQuestions(c.Request.Context(), req.Email)
+	if err != nil {
+		status, message := recoveryErrorStatus(err)
+		c.JSON(status, ErrorResponse{
+			Error:     "recovery_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Answer the security questions to recover your account",
+		Data:    RecoveryQuestionsResponse{Questions: questions},
+	})
+}
+
+// RecoveryAnswers verifies answers to an account's security questions and
+// returns a password reset token
+func (h *Handler) RecoveryAnswers(c *gin.Context) {
+	var req RecoveryAnswersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	token, err := h.service.VerifySecurityAnswers(c.Request.Context(), req.Email, req.Answers)
+	if err != nil {
+		status, message := recoveryErrorStatus(err)
+
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			status = http.StatusTooManyRequests
+			message = "Too many wrong answers, please try again later"
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "recovery_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Answers verified, use the reset token to choose a new password",
+		Data:    RecoveryAnswersResponse{ResetToken: token},
+	})
+}
+
+// recoveryErrorStatus maps security question recovery errors to a status and message
+func recoveryErrorStatus(err error) (int, string) {
+	switch err {
+	case ErrSecurityQuestionsDisabled:
+		return http.StatusNotFound, "Security question recovery is not enabled"
+	case ErrSecurityQuestionsNotSet:
+		return http.StatusNotFound, "Security question recovery is not available for this account"
+	case ErrWrongSecurityAnswers:
+		return http.StatusUnauthorized, "One or more answers are incorrect"
+	}
+	return http.StatusInternalServerError, "Account recovery failed"
+}
+
+// ChangePassword changes the authenticated user's password
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	// API key requests have no session to keep
+	var currentSessionID string
+	if token, ok := h.accessToken(c); ok {
+		currentSessionID, _ = h.service.SessionIDFromToken(token)
+	}
+
+	userID := c.GetString("user_id")
+	revoked, err := h.service.ChangePassword(c.Request.Context(), userID, currentSessionID, req.OldPassword, req.NewPassword, req.RevokeSessions)
+	if err == nil {
+		h.publishRequestEvent(c, events.TypePasswordChanged, events.OutcomeSuccess, userID, c.GetString("user_email"), nil)
+	} else if err == ErrInvalidCredentials {
+		h.publishRequestEvent(c, events.TypePasswordChanged, events.OutcomeFailure, userID, c.GetString("user_email"),
+			map[string]string{"reason": err.Error()})
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to change password"
+
+		var details interface{}
+		var policyErr *PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			status = http.StatusBadRequest
+			message = "Password does not meet requirements"
+			details = policyErr.Violations
+		}
+
+		switch err {
+		case ErrBreachCheckUnavailable:
+			status = http.StatusServiceUnavailable
+			message = "Password could not be checked, try again later"
+		case ErrInvalidCredentials:
+			status = http.StatusUnauthorized
+			message = "Current password is incorrect"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "change_password_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+			Details:   details,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Password changed successfully",
+		Data:    PasswordChangeResponse{SessionsRevoked: revoked},
+	})
+}
+
+// CreateAPIKey creates a new API key for the authenticated user
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	if !h.checkDelegatedScopes(c, req.Scopes) {
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(c.Request.Context(), c.GetString("user_id"), req.Name, req.Scopes)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to create API key"
+
+		switch err {
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "api_key_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeAPIKeyCreated, events.OutcomeSuccess, c.GetString("user_id"), "",
+		map[string]string{"name": req.Name, "scopes": strings.Join(normalizeScopes(req.Scopes), " ")})
+
+	c.JSON(http.StatusCreated, SuccessResponse{
+		Success: true,
+		Message: "API key created successfully. Store it now, it will not be shown again",
+		Data: CreateAPIKeyResponse{
+			Key:    key,
+			Name:   req.Name,
+			Scopes: normalizeScopes(req.Scopes),
+		},
+	})
+}
+
+// ListAPIKeys lists the authenticated user's API keys
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.service.ListAPIKeys(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "api_key_error",
+			Message:   "Failed to list API keys",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "API keys retrieved successfully",
+		Data:    keys,
+	})
+}
+
+// RevokeAPIKey revokes one of the authenticated user's API keys
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	if err := h.service.RevokeAPIKey(c.GetString("user_id"), c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to revoke API key"
+
+		switch err {
+		case ErrAPIKeyNotFound:
+			status = http.StatusNotFound
+			message = "API key not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "api_key_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeAPIKeyRevoked, events.OutcomeSuccess, c.GetString("user_id"), "",
+		map[string]string{"key_id": c.Param("id")})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "API key revoked successfully",
+	})
+}
+
+// RotateAPIKey replaces one of the authenticated user's API keys with a new
+// key of the same name and scopes, and revokes the old one
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	key, err := h.service.GetAPIKey(userID, c.Param("id"))
+	if err != nil {
+		rotateAPIKeyError(c, err)
+		return
+	}
+	if !h.checkDelegatedScopes(c, key.Scopes) {
+		return
+	}
+
+	plaintext, err := h.service.RotateAPIKey(c.Request.Context(), userID, key.ID)
+	if err != nil {
+		rotateAPIKeyError(c, err)
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeAPIKeyRotated, events.OutcomeSuccess, userID, "",
+		map[string]string{"key_id": key.ID, "name": key.Name})
+
+	c.JSON(http.StatusCreated, SuccessResponse{
+		Success: true,
+		Message: "API key rotated successfully. Store the new key now, it will not be shown again",
+		Data: CreateAPIKeyResponse{
+			Key:    plaintext,
+			Name:   key.Name,
+			Scopes: key.Scopes,
+		},
+	})
+}
+
+// rotateAPIKeyError writes the response for a failed API key rotation
+func rotateAPIKeyError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	message := "Failed to rotate API key"
+
+	switch err {
+	case ErrAPIKeyNotFound:
+		status = http.StatusNotFound
+		message = "API key not found"
+	case ErrUserNotFound:
+		status = http.StatusNotFound
+		message = "User not found"
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:     "api_key_error",
+		Message:   message,
+		Code:      status,
+		RequestID: c.GetString("request_id"),
+	})
+}
+
+// checkDelegatedScopes rejects requests authenticated with an API key that
+// would create a key without scopes or with scopes the calling key lacks.
+// Session tokens may create keys with any scopes.
+func (h *Handler) checkDelegatedScopes(c *gin.Context, requested []string) bool {
+	if c.GetString("auth_method") != "api_key" {
+		return true
+	}
+	if err := checkDelegatedScopes(c.GetStringSlice("auth_scopes"), requested); err != nil {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:     "forbidden",
+			Message:   "An API key can only create keys limited to scopes it has itself",
+			Code:      http.StatusForbidden,
+			RequestID: c.GetString("request_id"),
+		})
+		return false
+	}
+	return true
+}
+
+// ListSessions lists the authenticated user's active sessions
+func (h *Handler) ListSessions(c *gin.Context) {
+	// Sessions are only known for token logins, API key requests have no current session
+	var currentSessionID string
+	if token, ok := h.accessToken(c); ok {
+		currentSessionID, _ = h.service.SessionIDFromToken(token)
+	}
+
+	sessions, err := h.service.ListSessions(c.GetString("user_id"), currentSessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "session_error",
+			Message:   "Failed to list sessions",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Sessions retrieved successfully",
+		Data:    sessions,
+	})
+}
+
+// RevokeSession ends one of the authenticated user's sessions
+func (h *Handler) RevokeSession(c *gin.Context) {
+	if err := h.service.RevokeSession(c.GetString("user_id"), c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to revoke session"
+
+		switch err {
+		case ErrSessionNotFound:
+			status = http.StatusNotFound
+			message = "Session not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "session_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Session revoked successfully",
+	})
+}
+
+// Deactivate disables the authenticated user's own account
+func (h *Handler) Deactivate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if err := h.service.DeactivateUser(c.Request.Context(), userID); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to deactivate account"
+
+		switch err {
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "account_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeUserDeactivated, events.OutcomeSuccess, userID, c.GetString("user_email"),
+		map[string]string{"actor": userID})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Account deactivated successfully",
+	})
+}
+
+// DeleteAccount permanently deletes the authenticated user's account
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Password confirmation is required",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if err := h.service.DeleteAccount(c.Request.Context(), userID, req.Password); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to delete account"
+
+		switch err {
+		case ErrInvalidCredentials:
+			status = http.StatusUnauthorized
+			message = "Password is incorrect"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "Account not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "account_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeUserDeleted, events.OutcomeSuccess, userID, c.GetString("user_email"), nil)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Account deleted successfully",
+	})
+}
+
+// SetSecurityQuestions replaces the authenticated user's security questions
+func (h *Handler) SetSecurityQuestions(c *gin.Context) {
+	var req SecurityQuestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	if err := h.service.SetSecurityQuestions(c.Request.Context(), c.GetString("user_id"), &req); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to save security questions"
+
+		switch err {
+		case ErrSecurityQuestionsDisabled:
+			status = http.StatusNotFound
+			message = "Security question recovery is not enabled"
+		case ErrInvalidSecurityQuestions:
+			status = http.StatusBadRequest
+			message = "Provide the required number of different questions, each with an answer"
+		case ErrInvalidCredentials:
+			status = http.StatusUnauthorized
+			message = "Password is incorrect"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "Account not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "account_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Security questions saved",
+	})
+}
+
+// ExportAccount returns the authenticated user's data as a JSON download
+func (h *Handler) ExportAccount(c *gin.Context) {
+	userID := c.GetString("user_id")
+	export, err := h.service.ExportAccount(c.Request.Context(), userID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to export account"
+
+		switch err {
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "Account not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "account_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeDataExported, events.OutcomeSuccess, userID, c.GetString("user_email"), nil)
+
+	c.Header("Content-Disposition", `attachment; filename="account-export.json"`)
+	c.IndentedJSON(http.StatusOK, export)
+}
+
+// ReactivateUser re-enables a deactivated account on behalf of an administrator
+func (h *Handler) ReactivateUser(c *gin.Context) {
+	userID := c.Param("id")
+	if err := h.service.ReactivateUser(c.Request.Context(), userID); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to reactivate account"
+
+		switch err {
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "account_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeUserReactivated, events.OutcomeSuccess, userID, "",
+		map[string]string{"actor": c.GetString("user_id")})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Account reactivated successfully",
+	})
+}
+
+// RevokeUserSessions logs a user out on every device for administrators,
+// for example when the account is compromised
+func (h *Handler) RevokeUserSessions(c *gin.Context) {
+	userID := c.Param("id")
+	if err := h.service.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to revoke sessions"
+
+		switch err {
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "session_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeTokenRevoked, events.OutcomeSuccess, userID, "",
+		map[string]string{"reason": "all_sessions_revoked", "actor": c.GetString("user_id")})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "All sessions revoked successfully",
+	})
+}
+
+// AdminResetPassword sets a user's password for administrators, returning
+// it once when it was generated
+func (h *Handler) AdminResetPassword(c *gin.Context) {
+	var req AdminResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	userID := c.Param("id")
+	password, err := h.service.AdminResetPassword(c.Request.Context(), userID, req.Password, req.MustChangePassword)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to reset password"
+
+		var details interface{}
+		var policyErr *PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			status = http.StatusBadRequest
+			message = "Password does not meet requirements"
+			details = policyErr.Violations
+		}
+
+		switch err {
+		case ErrBreachCheckUnavailable:
+			status = http.StatusServiceUnavailable
+			message = "Password could not be checked, try again later"
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "reset_password_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+			Details:   details,
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypePasswordReset, events.OutcomeSuccess, userID, "",
+		map[string]string{"actor": c.GetString("user_id")})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Password reset successfully",
+		Data:    AdminResetPasswordResponse{Password: password},
+	})
+}
+
+// CreateInvite invites an email address to register for administrators
+func (h *Handler) CreateInvite(c *gin.Context) {
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	code, err := h.service.CreateInvite(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "invite_error",
+			Message:   "Failed to create invite",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, SuccessResponse{
+		Success: true,
+		Message: "Invite created successfully",
+		Data: CreateInviteResponse{
+			Code:  code,
+			Email: storage.NormalizeEmail(req.Email),
+		},
+	})
+}
+
+// ListInvites lists the organization's invites for administrators
+func (h *Handler) ListInvites(c *gin.Context) {
+	invites, err := h.service.ListInvites(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "invite_error",
+			Message:   "Failed to list invites",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Invites retrieved successfully",
+		Data:    invites,
+	})
+}
+
+// RevokeInvite revokes an invite for administrators
+func (h *Handler) RevokeInvite(c *gin.Context) {
+	if err := h.service.RevokeInvite(c.Request.Context(), c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to revoke invite"
+
+		switch err {
+		case ErrInviteNotFound:
+			status = http.StatusNotFound
+			message = "Invite not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "invite_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Invite revoked successfully",
+	})
+}
+
+// DeleteUser soft-deletes an account for administrators. The record is kept
+// and can be restored; DELETE /api/auth/account remains the permanent purge.
+func (h *Handler) DeleteUser(c *gin.Context) {
+	userID := c.Param("id")
+	if err := h.service.SoftDeleteUser(c.Request.Context(), userID); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to delete account"
+
+		switch err {
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "account_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeUserDeleted, events.OutcomeSuccess, userID, "",
+		map[string]string{"actor": c.GetString("user_id"), "mode": "soft"})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Account deleted successfully",
+	})
+}
+
+// RestoreUser restores a soft-deleted account for administrators
+func (h *Handler) RestoreUser(c *gin.Context) {
+	userID := c.Param("id")
+	if err := h.service.RestoreUser(c.Request.Context(), userID); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to restore account"
+
+		switch err {
+		case ErrUserNotFound:
+			status = http.StatusNotFound
+			message = "No deleted user with this ID"
+		case ErrUserExists:
+			status = http.StatusConflict
+			message = "The account's email or username has been taken by another account"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "account_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeUserRestored, events.OutcomeSuccess, userID, "",
+		map[string]string{"actor": c.GetString("user_id")})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Account restored successfully",
+	})
+}
+
+// ImportUsers creates accounts from an uploaded CSV file for administrators
+func (h *Handler) ImportUsers(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "A CSV file is required in the file field",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	if fileHeader.Size > MaxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Import file is too large",
+			Code:      http.StatusRequestEntityTooLarge,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	generatePasswords, _ := strconv.ParseBool(c.PostForm("generate_passwords"))
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Failed to read import file",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+	defer file.Close()
+
+	report, err := h.service.ImportUsers(c.Request.Context(), file, generatePasswords)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to import users"
+
+		if errors.Is(err, ErrInvalidImportFile) {
+			status = http.StatusBadRequest
+			message = err.Error()
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "import_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeUsersImported, events.OutcomeSuccess, "", "",
+		map[string]string{
+			"actor":   c.GetString("user_id"),
+			"created": strconv.Itoa(report.Created),
+			"skipped": strconv.Itoa(report.Skipped),
+			"failed":  strconv.Itoa(report.Failed),
+		})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "User import completed",
+		Data:    report,
+	})
+}
+
+// AdminGetUserMetadata returns a user's metadata for administrators
+func (h *Handler) AdminGetUserMetadata(c *gin.Context) {
+	response, err := h.service.GetUserMetadata(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.metadataError(c, err, "Failed to get metadata")
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Metadata retrieved successfully",
+		Data:    response,
+	})
+}
+
+// AdminSetUserMetadata replaces a user's metadata for administrators
+func (h *Handler) AdminSetUserMetadata(c *gin.Context) {
+	var req UserMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	userID := c.Param("id")
+	response, err := h.service.SetUserMetadata(c.Request.Context(), userID, req.Metadata)
+	if err != nil {
+		h.metadataError(c, err, "Failed to set metadata")
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeMetadataUpdated, events.OutcomeSuccess, userID, "",
+		map[string]string{"actor": c.GetString("user_id")})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Metadata updated successfully",
+		Data:    response,
+	})
+}
+
+// metadataError responds to a failed metadata request
+func (h *Handler) metadataError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+
+	var metadataErr *MetadataError
+	if errors.As(err, &metadataErr) {
+		status = http.StatusBadRequest
+		message = "Invalid metadata: " + metadataErr.Message
+	}
+
+	switch err {
+	case ErrTokenMetadataTooLarge:
+		status = http.StatusBadRequest
+		message = fmt.Sprintf("Metadata copied into access tokens must encode to at most %d bytes",
+			h.service.config.Auth.TokenMetadata.MaxBytes)
+	case ErrUserNotFound:
+		status = http.StatusNotFound
+		message = "User not found"
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:     "metadata_error",
+		Message:   message,
+		Code:      status,
+		RequestID: c.GetString("request_id"),
+	})
+}
+
+// oauthStateCookie binds an OAuth login to the browser that started it
+const oauthStateCookie = "oauth_state"
+
+// OAuthLogin redirects the browser to the provider's consent page
+func (h *Handler) OAuthLogin(c *gin.Context) {
+	provider := c.Param("provider")
+
+	authURL, state, err := h.service.OAuthAuthURL(provider)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to start login"
+
+		switch err {
+		case ErrProviderNotConfigured:
+			status = http.StatusNotFound
+			message = "Unknown login provider"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "oauth_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	// Scoped to the provider's path so concurrent logins don't clash
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, int((10 * time.Minute).Seconds()), oauthCookiePath(provider), "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OAuthCallback completes a provider login after the user returns from the consent page
+func (h *Handler) OAuthCallback(c *gin.Context) {
+	provider := c.Param("provider")
+
+	// The state must match the cookie set when the flow started, so an
+	// attacker can't complete a login in someone else's browser
+	state, _ := c.Cookie(oauthStateCookie)
+	c.SetCookie(oauthStateCookie, "", -1, oauthCookiePath(provider), "", c.Request.TLS != nil, true)
+
+	response, err := h.completeOAuthLogin(c, provider, state)
+	if err != nil {
+		if err == ErrLinkConfirmationRequired {
+			c.JSON(http.StatusAccepted, SuccessResponse{
+				Success: true,
+				Message: "An account with this email already exists; check your email to confirm linking it",
+			})
+			return
+		}
+
+		status := http.StatusInternalServerError
+		message := "Login failed"
+
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			status = http.StatusTooManyRequests
+			message = "Too many failed login attempts, please try again later"
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		}
+
+		switch err {
+		case ErrProviderNotConfigured:
+			status = http.StatusNotFound
+			message = "Unknown login provider"
+		case errInvalidOAuthState:
+			status = http.StatusBadRequest
+			message = "Invalid or expired login state, please try again"
+		case errOAuthDenied:
+			status = http.StatusBadRequest
+			message = "Login was cancelled or denied by the provider"
+		case ErrOAuthExchangeFailed:
+			status = http.StatusBadGateway
+			message = "Could not verify the login with the provider"
+		case ErrAccountLinkRejected:
+			status = http.StatusConflict
+			message = "An account with this email already exists, log in with your password"
+		case ErrProviderEmailUnverified:
+			status = http.StatusForbidden
+			message = "Your email address is not verified with the provider"
+		case ErrInvalidCredentials:
+			status = http.StatusUnauthorized
+			message = "Account is disabled"
+		case ErrInviteRequired:
+			status = http.StatusForbidden
+			message = "Registration is by invitation only"
+		}
+
+		h.publishRequestEvent(c, events.TypeLoginFailed, events.OutcomeFailure, "", "",
+			map[string]string{"reason": err.Error(), "method": provider})
+
+		c.JSON(status, ErrorResponse{
+			Error:     "login_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	if response.TwoFactorRequired {
+		c.JSON(http.StatusOK, SuccessResponse{
+			Success: true,
+			Message: "Two-factor authentication required",
+			Data:    response,
+		})
+		return
+	}
+
+	h.recordSessionClient(c, response)
+	h.setTokenCookie(c, response)
+	logging.FromContext(c.Request.Context()).Info("Login succeeded", "user_id", response.User.ID, "method", provider)
+	h.publishRequestEvent(c, events.TypeLoginSucceeded, events.OutcomeSuccess, response.User.ID, response.User.Email,
+		map[string]string{"method": provider})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Login successful",
+		Data:    response,
+	})
+}
+
+// Callback request errors, reported before the provider is contacted
+var (
+	errInvalidOAuthState = errors.New("invalid oauth state")
+	errOAuthDenied       = errors.New("oauth consent denied")
+)
+
+// completeOAuthLogin checks the callback request and finishes the login
+func (h *Handler) completeOAuthLogin(c *gin.Context, provider, state string) (*LoginResponse, error) {
+	if state == "" || !tokens.Equal(state, c.Query("state")) {
+		return nil, errInvalidOAuthState
+	}
+
+	// No code means the user declined consent or the provider reported an error
+	code := c.Query("code")
+	if code == "" {
+		return nil, errOAuthDenied
+	}
+
+	return h.service.LoginWithOAuth(c.Request.Context(), provider, code)
+}
+
+// oauthCookiePath returns the path the state cookie is scoped to
+func oauthCookiePath(provider string) string {
+	return "/api/auth/oauth/" + url.PathEscape(provider)
+}
+
+// ConfirmOAuthLink completes a pending external provider link
+func (h *Handler) ConfirmOAuthLink(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Token is required",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	if err := h.service.ConfirmExternalLink(c.Request.Context(), token); err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to link account"
+
+		switch err {
+		case ErrInvalidLinkToken:
+			status = http.StatusBadRequest
+			message = "Invalid or expired link token"
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:     "oauth_link_error",
+			Message:   message,
+			Code:      status,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Account linked successfully",
+	})
+}
+
+// ListConnections lists the external providers linked to the authenticated
+// user's account
+func (h *Handler) ListConnections(c *gin.Context) {
+	response, err := h.service.ListConnections(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		h.connectionError(c, err, "Failed to list connections")
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Connections retrieved successfully",
+		Data:    response,
+	})
+}
+
+// UnlinkProvider unlinks an external provider from the authenticated
+// user's account
+func (h *Handler) UnlinkProvider(c *gin.Context) {
+	userID := c.GetString("user_id")
+	provider := c.Param("provider")
+	if err := h.service.UnlinkProvider(c.Request.Context(), userID, provider); err != nil {
+		h.connectionError(c, err, "Failed to unlink provider")
+		return
+	}
+
+	h.publishRequestEvent(c, events.TypeProviderUnlinked, events.OutcomeSuccess, userID, "",
+		map[string]string{"provider": provider})
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Provider unlinked successfully",
+	})
+}
+
+// connectionError responds to a failed connections request
+func (h *Handler) connectionError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+
+	switch err {
+	case ErrUserNotFound:
+		status = http.StatusNotFound
+		message = "User not found"
+	case ErrConnectionNotFound:
+		status = http.StatusNotFound
+		message = "Provider is not linked to your account"
+	case ErrLastLoginMethod:
+		status = http.StatusConflict
+		message = "This provider is your only way to log in; set a password or add a passkey before unlinking it"
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:     "connection_error",
+		Message:   message,
+		Code:      status,
+		RequestID: c.GetString("request_id"),
+	})
+}
+
+// JWKS publishes the public token verification keys
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.JWKS())
+}
+
+// Middleware creates authentication middleware
+func (h *Handler) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Service callers may send an API key on its own header
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" {
+			h.authenticateAPIKey(c, apiKey)
+			return
+		}
+
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			// Browsers holding the token cookie send it instead of a header
+			if token, ok := h.cookieToken(c); ok {
+				h.authenticateToken(c, token, "cookie")
+				return
+			}
+
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error:     "unauthorized",
+				Message:   "Authorization header required",
+				Code:      http.StatusUnauthorized,
+				RequestID: c.GetString("request_id"),
+			})
+			c.Abort()
+			return
+		}
+
+		// Extract credentials from "Bearer <token>" or "ApiKey <key>"
+		tokenParts := strings.Split(authHeader, " ")
+		if len(tokenParts) != 2 || (tokenParts[0] != "Bearer" && tokenParts[0] != "ApiKey") {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error:     "unauthorized",
+				Message:   "Invalid authorization header format",
+				Code:      http.StatusUnauthorized,
+				RequestID: c.GetString("request_id"),
+			})
+			c.Abort()
+			return
+		}
+
+		if tokenParts[0] == "ApiKey" {
+			h.authenticateAPIKey(c, tokenParts[1])
+			return
+		}
+
+		h.authenticateToken(c, tokenParts[1], "header")
+	}
+}
+
+// authenticateToken authenticates the request with an access token, taken
+// from the given source, and sets the user's details in the context
+func (h *Handler) authenticateToken(c *gin.Context, token, source string) {
+	userInfo, claims, err := h.service.validateToken(c.Request.Context(), token)
+	if err != nil {
+		h.publishRequestEvent(c, events.TypeUnauthenticated, events.OutcomeFailure, "", "",
+			map[string]string{"method": "token", "source": source, "reason": err.Error()})
+
+		// Store failures are reported as invalid so the client logs in
+		// again instead of retrying a token that may never work
+		reason, ok := tokenErrorReason(err)
+		if !ok {
+			reason = TokenReasonInvalid
+		}
+
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:     "unauthorized",
+			Message:   tokenErrorMessage(err),
+			Code:      http.StatusUnauthorized,
+			RequestID: c.GetString("request_id"),
+			Details:   TokenErrorDetails{Reason: reason},
+		})
+		c.Abort()
+		return
+	}
+
+	// Set user information in context
+	c.Set("user_id", userInfo.ID)
+	c.Set("user_email", userInfo.Email)
+	c.Set("user_username", userInfo.Username)
+	c.Set("user_info", userInfo)
+	c.Set("user_role", userInfo.Role)
+	c.Set("user_metadata", userInfo.Metadata)
+	c.Set("auth_method", "token")
+	c.Set("token_claims", claims)
+
+	h.requirePasswordChanged(c, userInfo)
+}
+
+// authenticateAPIKey authenticates the request with an API key and sets
+// the same context values as a token login, plus the key's scopes
+func (h *Handler) authenticateAPIKey(c *gin.Context, apiKey string) {
+	userInfo, scopes, err := h.service.ValidateAPIKey(c.Request.Context(), apiKey)
+	if err != nil {
+		h.publishRequestEvent(c, events.TypeUnauthenticated, events.OutcomeFailure, "", "",
+			map[string]string{"method": "api_key", "reason": err.Error()})
+
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:     "unauthorized",
+			Message:   "Invalid API key",
+			Code:      http.StatusUnauthorized,
+			RequestID: c.GetString("request_id"),
+		})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", userInfo.ID)
+	c.Set("user_email", userInfo.Email)
+	c.Set("user_username", userInfo.Username)
+	c.Set("user_info", userInfo)
+	c.Set("user_role", userInfo.Role)
+	c.Set("auth_method", "api_key")
+	c.Set("auth_scopes", scopes)
+
+	h.requirePasswordChanged(c, userInfo)
+}
+
+// passwordChangeExempt lists the routes a user who must change their
+// password may still use: the change itself and logging out
+var passwordChangeExempt = map[string]bool{
+	"/api/auth/change-password": true,
+	"/api/auth/logout":          true,
+}
+
+// requirePasswordChanged continues an authenticated request unless the user
+// must change their password first, in which case it is rejected with a
+// 403 password_change_required so clients can send the user to the
+// password change flow
+func (h *Handler) requirePasswordChanged(c *gin.Context, userInfo *UserInfo) {
+	if userInfo.MustChangePassword && !passwordChangeExempt[c.FullPath()] {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:     "password_change_required",
+			Message:   "You must change your password before continuing",
+			Code:      http.StatusForbidden,
+			RequestID: c.GetString("request_id"),
+		})
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+// bearerToken extracts the token from a "Bearer <token>" Authorization header
+func bearerToken(c *gin.Context) (string, bool) {
+	tokenParts := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" || tokenParts[1] == "" {
+		return "", false
+	}
+	return tokenParts[1], true
+}
+
+// setTokenCookie sends a login response's access token in the token cookie
+// when it is enabled, and drops the token from the response body if it
+// should only travel in the cookie
+func (h *Handler) setTokenCookie(c *gin.Context, response *LoginResponse) {
+	if response.Token == "" {
+		return
+	}
+
+	cookie := h.service.tokenCookie(response.Token, response.ExpiresAt)
+	if cookie == nil {
+		return
+	}
+	http.SetCookie(c.Writer, cookie)
+
+	if h.service.config.Auth.TokenCookie.OmitFromBody {
+		response.Token = ""
+	}
+}
+
+// clearTokenCookie tells the browser to discard the token cookie
+func (h *Handler) clearTokenCookie(c *gin.Context) {
+	if cookie := h.service.tokenCookie("", time.Time{}); cookie != nil {
+		http.SetCookie(c.Writer, cookie)
+	}
+}
+
+// cookieToken extracts the access token from the token cookie when token
+// cookies are enabled
+func (h *Handler) cookieToken(c *gin.Context) (string, bool) {
+	cfg := h.service.config.Auth.TokenCookie
+	if !cfg.Enabled {
+		return "", false
+	}
+
+	token, err := c.Cookie(cfg.Name)
+	if err != nil || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// accessToken extracts the access token from the Authorization header,
+// falling back to the token cookie
+func (h *Handler) accessToken(c *gin.Context) (string, bool) {
+	if token, ok := bearerToken(c); ok {
+		return token, true
+	}
+	if c.GetHeader("Authorization") != "" {
+		return "", false
+	}
+	return h.cookieToken(c)
+}
+
+// recordSessionClient attaches the requesting client's IP and user agent to
+// the session a login response started. Failures only cost the session its
+// device details, so they are logged rather than returned.
+func (h *Handler) recordSessionClient(c *gin.Context, response *LoginResponse) {
+	if response.SessionID == "" {
+		return
+	}
+	if err := h.service.RecordSessionClient(response.SessionID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("Failed to record session client",
+			"session_id", response.SessionID, "error", err.Error())
+	}
+}
+
+// publishRequestEvent publishes a security event enriched with request metadata
+func (h *Handler) publishRequestEvent(c *gin.Context, eventType, outcome, userID, email string, details map[string]string) {
+	h.service.publishEvent(events.Event{
+		Type:      eventType,
+		Outcome:   outcome,
+		UserID:    userID,
+		Email:     email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Details:   details,
+	})
+}
+
+// RequireRole creates middleware that only admits users with the given role
+func (h *Handler) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("user_role") != role {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Error:     "forbidden",
+				Message:   "Insufficient permissions",
+				Code:      http.StatusForbidden,
+				RequestID: c.GetString("request_id"),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ListUsers returns a page of users for administrators
+func (h *Handler) ListUsers(c *gin.Context) {
+	limit, err := queryInt(c, "limit", DefaultUserPageSize)
+	if err != nil || limit < 1 || limit > MaxUserPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "limit must be between 1 and " + strconv.Itoa(MaxUserPageSize),
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "offset must be zero or greater",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", storage.SortByCreatedAt)
+	switch sortBy {
+	case storage.SortByCreatedAt, storage.SortByEmail, storage.SortByUsername:
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "sort must be one of created_at, email, username",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	users, total, err := h.service.ListUsers(c.Request.Context(), storage.ListUsersOptions{
+		Limit:    limit,
+		Offset:   offset,
+		Query:    c.Query("q"),
+		SortBy:   sortBy,
+		SortDesc: c.Query("order") == "desc",
+
+		IncludeDeleted: c.Query("include_deleted") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "internal_error",
+			Message:   "Failed to list users",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Users retrieved successfully",
+		Data: UserListResponse{
+			Users:  users,
+			Total:  total,
+			Limit:  limit,
+			Offset: offset,
+		},
+	})
+}
+
+// SearchUsers finds users by partial email or username for administrators
+func (h *Handler) SearchUsers(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "q is required",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	limit, err := queryInt(c, "limit", DefaultUserPageSize)
+	if err != nil || limit < 1 || limit > MaxUserPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "limit must be between 1 and " + strconv.Itoa(MaxUserPageSize),
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	users, err := h.service.SearchUsers(c.Request.Context(), query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "internal_error",
+			Message:   "Failed to search users",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Users retrieved successfully",
+		Data: UserSearchResponse{
+			Users: users,
+			Query: query,
+			Limit: limit,
+		},
+	})
+}
+
+// AdminStats returns aggregate counts for the admin dashboard
+func (h *Handler) AdminStats(c *gin.Context) {
+	response, err := h.service.AdminStats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "internal_error",
+			Message:   "Failed to get stats",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Stats retrieved successfully",
+		Data:    response,
+	})
+}
+
+// AuditLog returns a page of audit log entries for administrators
+func (h *Handler) AuditLog(c *gin.Context) {
+	limit, err := queryInt(c, "limit", DefaultUserPageSize)
+	if err != nil || limit < 1 || limit > MaxUserPageSize {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "limit must be between 1 and " + strconv.Itoa(MaxUserPageSize),
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "offset must be zero or greater",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		since, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:     "validation_error",
+				Message:   "since must be an RFC 3339 timestamp",
+				Code:      http.StatusBadRequest,
+				RequestID: c.GetString("request_id"),
+			})
+			return
+		}
+	}
+
+	entries, total, err := h.service.QueryAuditLog(audit.Filter{
+		UserID: c.Query("user_id"),
+		Type:   c.Query("type"),
+		Since:  since,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "internal_error",
+			Message:   "Failed to query audit log",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Audit entries retrieved successfully",
+		Data: AuditLogResponse{
+			Entries: entries,
+			Total:   total,
+			Limit:   limit,
+			Offset:  offset,
+		},
+	})
+}
+
+// AuditExport streams every audit entry matching the query as a CSV or JSON
+// Lines download, a page at a time so large exports aren't held in memory
+func (h *Handler) AuditExport(c *gin.Context) {
+	format := c.DefaultQuery("format", AuditExportJSONL)
+	if format != AuditExportCSV && format != AuditExportJSONL {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "format must be csv or jsonl",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	var since, until time.Time
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:     "validation_error",
+				Message:   param.name + " must be an RFC 3339 timestamp",
+				Code:      http.StatusBadRequest,
+				RequestID: c.GetString("request_id"),
+			})
+			return
+		}
+		*param.value = parsed
+	}
+
+	contentType := "application/x-ndjson"
+	if format == AuditExportCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	filename := fmt.Sprintf("audit-export-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	var encoder auditEncoder = &jsonlAuditEncoder{enc: json.NewEncoder(c.Writer)}
+	if format == AuditExportCSV {
+		csvEncoder, err := newCSVAuditEncoder(c.Writer)
+		if err != nil {
+			slog.Warn("Audit export aborted", "request_id", c.GetString("request_id"), "error", err)
+			return
+		}
+		encoder = csvEncoder
+	}
+
+	// The status is already sent, so a failure part-way can only end the
+	// download early
+	err := h.service.ExportAuditLog(audit.Filter{
+		UserID: c.Query("user_id"),
+		Type:   c.Query("type"),
+		Since:  since,
+		Until:  until,
+	}, func(entries []audit.Entry) error {
+		if err := encoder.Encode(entries); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+	if err != nil {
+		slog.Warn("Audit export aborted", "request_id", c.GetString("request_id"), "error", err)
+	}
+}
+
+// queryInt parses an optional integer query parameter
+func queryInt(c *gin.Context, key string, fallback int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// RequireAPIKey rejects requests that weren't authenticated with an API
+// key, for endpoints meant for backend services rather than users
+func (h *Handler) RequireAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_method") != "api_key" {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Error:     "forbidden",
+				Message:   "This endpoint requires an API key",
+				Code:      http.StatusForbidden,
+				RequestID: c.GetString("request_id"),
+			})
 			c.Abort()
 			return
 		}
 
-		// Set user information in context
-		c.Set("user_id", userInfo.ID)
-		c.Set("user_email", userInfo.Email)
-		c.Set("user_username", userInfo.Username)
-		c.Set("user_info", userInfo)
+		c.Next()
+	}
+}
+
+// IntrospectToken reports whether an access token is active. Invalid tokens
+// get a 200 response with active set to false.
+func (h *Handler) IntrospectToken(c *gin.Context) {
+	var req IntrospectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:     "validation_error",
+			Message:   "Invalid request data",
+			Code:      http.StatusBadRequest,
+			RequestID: c.GetString("request_id"),
+			Fields:    fieldErrors(err),
+		})
+		return
+	}
+
+	response, err := h.service.IntrospectToken(c.Request.Context(), req.Token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:     "introspection_error",
+			Message:   "Failed to introspect token",
+			Code:      http.StatusInternalServerError,
+			RequestID: c.GetString("request_id"),
+		})
+		return
+	}
+
+	// Introspection responses must not be cached
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
+
+// RequireScope creates middleware that rejects API key requests lacking a scope.
+// Session tokens are not scope-limited.
+func (h *Handler) RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_method") == "api_key" && !HasScope(c.GetStringSlice("auth_scopes"), scope) {
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Error:     "forbidden",
+				Message:   "API key is missing required scope: " + scope,
+				Code:      http.StatusForbidden,
+				RequestID: c.GetString("request_id"),
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+const testPassword = "Secret1!x"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestService returns a service on in-memory stores with the test
+// configuration, after applying configure if it's not nil
+func newTestService(t *testing.T, configure func(cfg *config.Config)) *Service {
+	t.Helper()
+
+	cfg, err := config.Load("test")
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	service, err := NewService(storage.NewMemoryUserStore(), storage.SessionStores{}, cfg, nil, email.LogSender{}, nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return service
+}
+
+// registerTestUser registers a user with testPassword and returns the
+// login response
+func registerTestUser(t *testing.T, service *Service, emailAddress, username string) *LoginResponse {
+	t.Helper()
+
+	response, err := service.Register(context.Background(), &RegisterRequest{
+		Email:     emailAddress,
+		Username:  username,
+		Password:  testPassword,
+		FirstName: "Test",
+		LastName:  "User",
+	}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Register(%s): %v", emailAddress, err)
+	}
+	return response
+}
+
+// doRequest sends a JSON request to router with the given headers and
+// returns the recorded response
+func doRequest(t *testing.T, router http.Handler, method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// decodeData decodes the data of a SuccessResponse body into v
+func decodeData(t *testing.T, recorder *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response %q: %v", recorder.Body.String(), err)
+	}
+	if err := json.Unmarshal(response.Data, v); err != nil {
+		t.Fatalf("decode data %q: %v", response.Data, err)
+	}
+}
+
+func bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+// captureSender records the emails a service sends instead of logging them
+type captureSender struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+// captureEmails makes service send its emails to a new captureSender
+func captureEmails(service *Service) *captureSender {
+	sender := &captureSender{}
+	service.mailer = sender
+	return sender
+}
+
+func (c *captureSender) Send(to, subject, body string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bodies = append(c.bodies, body)
+	return nil
+}
+
+var emailTokenPattern = regexp.MustCompile(`token=([^"&\s<]+)`)
+
+// lastToken returns the token in the link of the last email sent
+func (c *captureSender) lastToken(t *testing.T) string {
+	t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.bodies) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := emailTokenPattern.FindStringSubmatch(c.bodies[len(c.bodies)-1])
+	if match == nil {
+		t.Fatalf("no token link in email: %s", c.bodies[len(c.bodies)-1])
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("unescape token: %v", err)
+	}
+	return token
+}
+
+// capturePublisher records the events a service publishes
+type capturePublisher struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+// captureEvents makes service publish its events to a new capturePublisher
+func captureEvents(service *Service) *capturePublisher {
+	publisher := &capturePublisher{}
+	service.events = publisher
+	return publisher
+}
+
+func (c *capturePublisher) Publish(event events.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+}
+
+// ofType returns the published events of one type
+func (c *capturePublisher) ofType(eventType string) []events.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matched []events.Event
+	for _, event := range c.events {
+		if event.Type == eventType {
+			matched = append(matched, event)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidImportFile = errors.New("invalid import file")
+)
+
+// Import row outcomes
+const (
+	ImportStatusCreated = "created"
+	ImportStatusSkipped = "skipped"
+	ImportStatusError   = "error"
+)
+
+// MaxImportFileSize bounds the size of an uploaded import CSV
+const MaxImportFileSize = 5 << 20
+
+// importColumns are the CSV columns every import file must have. A password
+// column is also required unless passwords are generated.
+var importColumns = []string{"email", "username", "first_name", "last_name"}
+
+// ImportUsers creates accounts from a CSV file with a header row naming the
+// columns email, username, first_name, last_name and password. A bad row is
+// reported and skipped rather than aborting the import. When
+// generatePasswords is set, rows without a password get a random one that
+// is returned in the report.
+func (s *Service) ImportUsers(ctx context.Context, r io.Reader, generatePasswords bool) (*ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header row", ErrInvalidImportFile)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+
+	required := append([]string{}, importColumns...)
+	if !generatePasswords {
+		required = append(required, "password")
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrInvalidImportFile, name)
+		}
+	}
+
+	report := &ImportReport{Rows: make([]ImportRowResult, 0)}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			report.add(ImportRowResult{Row: row, Status: ImportStatusError, Reason: parseErr.Err.Error()})
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		req := &RegisterRequest{
+			Email:     field("email"),
+			Username:  field("username"),
+			Password:  field("password"),
+			FirstName: field("first_name"),
+			LastName:  field("last_name"),
+		}
+		report.add(s.importRow(ctx, row, req, generatePasswords))
+	}
+
+	return report, nil
+}
+
+// importRow validates and creates the account for one CSV row
+func (s *Service) importRow(ctx context.Context, row int, req *RegisterRequest, generatePasswords bool) ImportRowResult {
+	result := ImportRowResult{Row: row, Email: req.Email}
+
+	var generated string
+	if req.Password == "" && generatePasswords {
+		password, err := generatePassword()
+		if err != nil {
+			result.Status = ImportStatusError
+			result.Reason = "failed to generate password"
+			return result
+		}
+		req.Password = password
+		generated = password
+	}
+
+	if reason := validateImportRow(req); reason != "" {
+		result.Status = ImportStatusError
+		result.Reason = reason
+		return result
+	}
+
+	user, err := s.createUser(ctx, req, s.roleForEmail(req.Email), false)
+	if err != nil {
+		var policyErr *PasswordPolicyError
+		switch {
+		case err == ErrUserExists:
+			result.Status = ImportStatusSkipped
+			result.Reason = "user already exists"
+		case errors.As(err, &policyErr):
+			result.Status = ImportStatusError
+			result.Reason = policyErr.Error()
+		default:
+			result.Status = ImportStatusError
+			result.Reason = "failed to create user"
+		}
+		return result
+	}
+
+	result.Status = ImportStatusCreated
+	result.Email = user.Email
+	result.UserID = user.ID
+	result.Password = generated
+	return result
+}
+
+// validateImportRow applies the same field rules as registration and
+// returns the reason a row is invalid, or "" when it is valid
+func validateImportRow(req *RegisterRequest) string {
+	if _, err := mail.ParseAddress(req.Email); err != nil || strings.ContainsAny(req.Email, "<> ") {
+		return "invalid email address"
+	}
+	if n := utf8.RuneCountInString(req.Username); n < 3 || n > 50 {
+		return "username must be between 3 and 50 characters"
+	}
+	if n := utf8.RuneCountInString(req.FirstName); n < 1 || n > 50 {
+		return "first_name must be between 1 and 50 characters"
+	}
+	if n := utf8.RuneCountInString(req.LastName); n < 1 || n > 50 {
+		return "last_name must be between 1 and 50 characters"
+	}
+	if req.Password == "" {
+		return "password is required"
+	}
+	if len(req.Password) > 72 {
+		return "password must be at most 72 bytes"
+	}
+	return ""
+}
+
+// add appends a row result and updates the totals
+func (r *ImportReport) add(result ImportRowResult) {
+	switch result.Status {
+	case ImportStatusCreated:
+		r.Created++
+	case ImportStatusSkipped:
+		r.Skipped++
+	default:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// Character classes for generated passwords
+const (
+	passwordUpper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordLower  = "abcdefghijkmnopqrstuvwxyz"
+	passwordDigits = "23456789"
+	passwordSymbol = "!@#$%^&*-_=+?"
+)
+
+// generatePassword returns a random 16 character password containing every
+// character class so it satisfies any password policy
+func generatePassword() (string, error) {
+	classes := []string{passwordUpper, passwordLower, passwordDigits, passwordSymbol}
+	all := strings.Join(classes, "")
+
+	password := make([]byte, 16)
+	for i := range password {
+		set := all
+		if i < len(classes) {
+			set = classes[i]
+		}
+		c, err := randomChar(set)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// Shuffle so the guaranteed classes aren't always at the front
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+// randomChar picks a uniformly random character from set
+func randomChar(set string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+	return set[n.Int64()], nil
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+)
+
+// IntrospectToken reports whether an access token is currently valid, in
+// the shape of an RFC 7662 introspection response. Tokens that are invalid,
+// expired, revoked or belong to a disabled user are reported as inactive
+// rather than as an error, so callers can't tell why a token was rejected.
+func (s *Service) IntrospectToken(ctx context.Context, token string) (*IntrospectResponse, error) {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return &IntrospectResponse{Active: false}, nil
+	}
+
+	userInfo, err := s.ValidateToken(ctx, token)
+	if err != nil {
+		if err == ErrInvalidToken || err == ErrTokenExpired || err == ErrTokenRevoked {
+			return &IntrospectResponse{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	response := &IntrospectResponse{
+		Active:   true,
+		UserID:   userInfo.ID,
+		OrgID:    userInfo.OrgID,
+		Email:    userInfo.Email,
+		Username: userInfo.Username,
+		Exp:      claims.ExpiresAt.Unix(),
+	}
+	if claims.IssuedAt != nil {
+		response.Iat = claims.IssuedAt.Unix()
+	}
+	return response, nil
+}
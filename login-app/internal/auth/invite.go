@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+var (
+	ErrInviteRequired = errors.New("invite code required")
+	ErrInvalidInvite  = errors.New("invalid or expired invite code")
+	ErrInviteNotFound = errors.New("invite not found")
+)
+
+// Invite statuses
+const (
+	InviteStatusPending = "pending"
+	InviteStatusUsed    = "used"
+	InviteStatusRevoked = "revoked"
+	InviteStatusExpired = "expired"
+)
+
+// CreateInvite invites an email address to register while registration is
+// invite-only and emails it the code. The plaintext code is only returned
+// here; the store keeps a hash of it.
+func (s *Service) CreateInvite(ctx context.Context, email string) (string, error) {
+	id, err := s.generateID()
+	if err != nil {
+		return "", err
+	}
+
+	code, err := s.newSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	invite := &storage.Invite{
+		ID:        id,
+		OrgID:     tenant.FromContext(ctx),
+		Email:     storage.NormalizeEmail(email),
+		CodeHash:  storage.HashToken(code),
+		ExpiresAt: time.Now().Add(s.config.Auth.InviteTTL),
+	}
+	if err := s.inviteStore.CreateInvite(invite); err != nil {
+		return "", err
+	}
+
+	// The administrator gets the code either way and can pass it on
+	if err := s.sendEmail(invite.Email, "You're invited to Login App", "invite.html", map[string]interface{}{
+		"Code":      code,
+		"ExpiresIn": formatTTL(s.config.Auth.InviteTTL),
+	}); err != nil {
+		logging.FromContext(ctx).Warn("Failed to send invite email", "invite_id", invite.ID, "error", err)
+	}
+
+	return code, nil
+}
+
+// ListInvites returns the invites of the context's organization, newest first
+func (s *Service) ListInvites(ctx context.Context) ([]InviteInfo, error) {
+	invites, err := s.inviteStore.ListInvites(tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(invites, func(i, j int) bool {
+		return invites[i].CreatedAt.After(invites[j].CreatedAt)
+	})
+
+	now := time.Now()
+	infos := make([]InviteInfo, 0, len(invites))
+	for _, invite := range invites {
+		infos = append(infos, inviteToInfo(invite, now))
+	}
+	return infos, nil
+}
+
+// RevokeInvite revokes an invite of the context's organization so its code
+// can no longer be used
+func (s *Service) RevokeInvite(ctx context.Context, id string) error {
+	if err := s.inviteStore.RevokeInvite(tenant.FromContext(ctx), id); err != nil {
+		if err == storage.ErrInviteNotFound {
+			return ErrInviteNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// useInvite uses up an invite code for a registration with the given email
+func (s *Service) useInvite(ctx context.Context, code, email string) (*storage.Invite, error) {
+	if code == "" {
+		return nil, ErrInviteRequired
+	}
+
+	invite, err := s.inviteStore.UseInvite(tenant.FromContext(ctx), storage.HashToken(code), email)
+	if err != nil {
+		if err == storage.ErrInviteNotFound {
+			return nil, ErrInvalidInvite
+		}
+		return nil, err
+	}
+	return invite, nil
+}
+
+// inviteToInfo converts a stored invite to its public representation
+func inviteToInfo(invite *storage.Invite, now time.Time) InviteInfo {
+	status := InviteStatusPending
+	switch {
+	case invite.UsedAt != nil:
+		status = InviteStatusUsed
+	case invite.RevokedAt != nil:
+		status = InviteStatusRevoked
+	case !now.Before(invite.ExpiresAt):
+		status = InviteStatusExpired
+	}
+
+	return InviteInfo{
+		ID:        invite.ID,
+		Email:     invite.Email,
+		Status:    status,
+		CreatedAt: invite.CreatedAt,
+		ExpiresAt: invite.ExpiresAt,
+		UsedAt:    invite.UsedAt,
+		RevokedAt: invite.RevokedAt,
+	}
+}
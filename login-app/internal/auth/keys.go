@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// Supported JWT signing methods
+const (
+	SigningMethodHS256 = "HS256"
+	SigningMethodRS256 = "RS256"
+)
+
+// signingKey is a key tokens are signed or verified with
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	sign   interface{} // []byte or *rsa.PrivateKey, nil for verification-only keys
+	verify interface{} // []byte or *rsa.PublicKey
+}
+
+// keyRing holds the key new tokens are signed with plus every key a token
+// may still be verified with, indexed by key ID
+type keyRing struct {
+	current *signingKey
+	keys    map[string]*signingKey
+}
+
+// JWK represents a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS represents a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// newKeyRing builds the key ring for the configured signing method
+func newKeyRing(cfg config.AuthConfig) (*keyRing, error) {
+	ring := &keyRing{keys: make(map[string]*signingKey)}
+
+	switch cfg.SigningMethod {
+	case SigningMethodHS256, "":
+		secret := []byte(cfg.JWTSecret)
+		ring.current = &signingKey{
+			kid:    hmacKeyID(secret),
+			method: jwt.SigningMethodHS256,
+			sign:   secret,
+			verify: secret,
+		}
+
+		// Rotated-out secrets stay valid for verification
+		for _, previous := range cfg.PreviousJWTSecrets {
+			secret := []byte(previous)
+			kid := hmacKeyID(secret)
+			ring.keys[kid] = &signingKey{
+				kid:    kid,
+				method: jwt.SigningMethodHS256,
+				verify: secret,
+			}
+		}
+
+	case SigningMethodRS256:
+		privateKey, err := loadRSAPrivateKey(cfg.RSAPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		ring.current = &signingKey{
+			kid:    rsaKeyID(&privateKey.PublicKey),
+			method: jwt.SigningMethodRS256,
+			sign:   privateKey,
+			verify: &privateKey.PublicKey,
+		}
+
+		// Public keys of rotated-out private keys stay valid for verification
+		for _, path := range cfg.RSAPublicKeyFiles {
+			publicKey, err := loadRSAPublicKey(path)
+			if err != nil {
+				return nil, err
+			}
+			kid := rsaKeyID(publicKey)
+			ring.keys[kid] = &signingKey{
+				kid:    kid,
+				method: jwt.SigningMethodRS256,
+				verify: publicKey,
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing method %q", cfg.SigningMethod)
+	}
+
+	ring.keys[ring.current.kid] = ring.current
+	return ring, nil
+}
+
+// sign signs claims with the current key and sets its kid header
+func (r *keyRing) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.current.method, claims)
+	token.Header["kid"] = r.current.kid
+	return token.SignedString(r.current.sign)
+}
+
+// keyFunc selects the verification key for a token by its kid header. Tokens
+// without a kid are verified with the current key.
+func (r *keyRing) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := r.current
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = r.keys[kid]; !ok {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	// The token's algorithm must match the key it claims to be signed with
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidToken
+	}
+
+	return key.verify, nil
+}
+
+// jwks returns the public RSA keys in the ring. HMAC secrets are never published.
+func (r *keyRing) jwks() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range r.keys {
+		publicKey, ok := key.verify.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		set.Keys = append(set.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: key.method.Alg(),
+			Kid: key.kid,
+			N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		})
+	}
+	return set
+}
+
+// loadRSAPrivateKey reads a PEM-encoded RSA private key. Without a path an
+// ephemeral key is generated, which is only suitable for development.
+func loadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		slog.Warn("No RSA private key configured, generating an ephemeral key; tokens will not survive a restart")
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA private key %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an RSA key", path)
+	}
+	return key, nil
+}
+
+// loadRSAPublicKey reads a PEM-encoded RSA public key
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA public key %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an RSA key", path)
+	}
+	return key, nil
+}
+
+// readPEM reads the first PEM block from a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in " + path)
+	}
+	return block, nil
+}
+
+// rsaKeyID derives a stable key ID from an RSA public key (RFC 7638 thumbprint)
+func rsaKeyID(key *rsa.PublicKey) string {
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	n := base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+	sum := sha256.Sum256([]byte(`{"e":"` + e + `","kty":"RSA","n":"` + n + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// hmacKeyID derives a short key ID from an HMAC secret
+func hmacKeyID(secret []byte) string {
+	sum := sha256.Sum256(append([]byte("kid:"), secret...))
+	return "hs-" + hex.EncodeToString(sum[:8])
+}
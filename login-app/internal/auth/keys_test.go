@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// writeRSAKey generates an RSA key and writes the private key and its
+// public key as PEM files, returning their paths
+func writeRSAKey(t *testing.T) (privatePath, publicPath string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+
+	dir := t.TempDir()
+	privatePath = filepath.Join(dir, "private.pem")
+	publicPath = filepath.Join(dir, "public.pem")
+	for path, block := range map[string]*pem.Block{
+		privatePath: {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		publicPath:  {Type: "PUBLIC KEY", Bytes: publicDER},
+	} {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	return privatePath, publicPath
+}
+
+// tokenHeader returns the header of a token without verifying it
+func tokenHeader(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &JWTClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	return parsed.Header
+}
+
+func TestHS256Tokens(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.SigningMethod = SigningMethodHS256
+	})
+	registered := registerTestUser(t, service, "hs256@example.com", "hs256")
+
+	header := tokenHeader(t, registered.Token)
+	if header["alg"] != SigningMethodHS256 || header["kid"] != hmacKeyID([]byte(service.config.Auth.JWTSecret)) {
+		t.Errorf("header = %v, want HS256 with the secret's kid", header)
+	}
+	if _, err := service.ValidateToken(context.Background(), registered.Token); err != nil {
+		t.Errorf("ValidateToken: %v", err)
+	}
+
+	// The shared secret is never published
+	if keys := service.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("JWKS has %d keys under HS256, want none", len(keys))
+	}
+}
+
+func TestRS256Tokens(t *testing.T) {
+	privatePath, _ := writeRSAKey(t)
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.SigningMethod = SigningMethodRS256
+		cfg.Auth.RSAPrivateKeyFile = privatePath
+	})
+	registered := registerTestUser(t, service, "rs256@example.com", "rs256")
+
+	header := tokenHeader(t, registered.Token)
+	if header["alg"] != SigningMethodRS256 {
+		t.Errorf("alg = %v, want RS256", header["alg"])
+	}
+	if _, err := service.ValidateToken(context.Background(), registered.Token); err != nil {
+		t.Errorf("ValidateToken: %v", err)
+	}
+
+	// Another service can verify the token with nothing but the JWKS
+	keys := service.JWKS().Keys
+	if len(keys) != 1 || keys[0].Kid != header["kid"] || keys[0].Alg != SigningMethodRS256 {
+		t.Fatalf("JWKS = %+v, want the signing key with kid %v", keys, header["kid"])
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(keys[0].N)
+	e, _ := base64.RawURLEncoding.DecodeString(keys[0].E)
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if _, err := jwt.Parse(registered.Token, func(*jwt.Token) (interface{}, error) { return publicKey, nil },
+		jwt.WithValidMethods([]string{SigningMethodRS256})); err != nil {
+		t.Errorf("verify with the published key: %v", err)
+	}
+}
+
+func TestHS256SecretRotation(t *testing.T) {
+	old := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.JWTSecret = "the-old-secret"
+	})
+	token := registerTestUser(t, old, "rotate@example.com", "rotate").Token
+
+	rotated := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.JWTSecret = "the-new-secret"
+		cfg.Auth.PreviousJWTSecrets = []string{"the-old-secret"}
+	})
+	if _, err := rotated.parseToken(token); err != nil {
+		t.Errorf("token signed with the previous secret: %v", err)
+	}
+	if kid := tokenHeader(t, registerTestUser(t, rotated, "new@example.com", "new").Token)["kid"]; kid != hmacKeyID([]byte("the-new-secret")) {
+		t.Errorf("new tokens have kid %v, want the new secret's", kid)
+	}
+
+	// Once the old secret is dropped its tokens stop verifying
+	dropped := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.JWTSecret = "the-new-secret"
+	})
+	if _, err := dropped.parseToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with a dropped secret: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRS256KeyRotation(t *testing.T) {
+	oldPrivate, oldPublic := writeRSAKey(t)
+	newPrivate, _ := writeRSAKey(t)
+
+	old := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.SigningMethod = SigningMethodRS256
+		cfg.Auth.RSAPrivateKeyFile = oldPrivate
+	})
+	token := registerTestUser(t, old, "rotate@example.com", "rotate").Token
+	oldKid := tokenHeader(t, token)["kid"]
+
+	rotated := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.SigningMethod = SigningMethodRS256
+		cfg.Auth.RSAPrivateKeyFile = newPrivate
+		cfg.Auth.RSAPublicKeyFiles = []string{oldPublic}
+	})
+	if _, err := rotated.parseToken(token); err != nil {
+		t.Errorf("token signed with the rotated-out key: %v", err)
+	}
+	newKid := tokenHeader(t, registerTestUser(t, rotated, "new@example.com", "new").Token)["kid"]
+	if newKid == oldKid {
+		t.Errorf("new tokens still have the old kid %v", oldKid)
+	}
+
+	// Both keys are published until the old one is dropped
+	published := make(map[string]bool)
+	for _, key := range rotated.JWKS().Keys {
+		published[key.Kid] = true
+	}
+	if len(published) != 2 || !published[oldKid.(string)] || !published[newKid.(string)] {
+		t.Errorf("JWKS kids = %v, want %v and %v", published, oldKid, newKid)
+	}
+
+	dropped := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.SigningMethod = SigningMethodRS256
+		cfg.Auth.RSAPrivateKeyFile = newPrivate
+	})
+	if _, err := dropped.parseToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with a dropped key: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	privatePath, _ := writeRSAKey(t)
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.SigningMethod = SigningMethodRS256
+		cfg.Auth.RSAPrivateKeyFile = privatePath
+	})
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", NewHandler(service).JWKS)
+
+	w := doRequest(t, router, http.MethodGet, "/.well-known/jwks.json", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	var set JWKS
+	if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil {
+		t.Fatalf("decode JWKS %q: %v", w.Body.String(), err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kty != "RSA" || set.Keys[0].Use != "sig" || set.Keys[0].N == "" {
+		t.Errorf("JWKS = %+v, want one RSA signing key", set)
+	}
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+var (
+	ErrInvalidUnlockToken = errors.New("invalid or expired unlock link")
+)
+
+// LockedError is returned when an account or client IP is temporarily locked
+// out after too many failed login attempts
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// newLockedError builds a LockedError for a lock expiring at until
+func newLockedError(until time.Time) *LockedError {
+	retryAfter := time.Until(until)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &LockedError{RetryAfter: retryAfter}
+}
+
+// checkAccountLock returns a LockedError while the user's lockout is active
+func (s *Service) checkAccountLock(user *storage.User) error {
+	if time.Now().Before(user.LockedUntil) {
+		return newLockedError(user.LockedUntil)
+	}
+	return nil
+}
+
+// recordFailedLogin counts a failed password attempt against the account and
+// locks it once the configured threshold is reached
+func (s *Service) recordFailedLogin(ctx context.Context, user *storage.User) error {
+	user.FailedAttempts++
+
+	var lockErr error
+	if s.config.Auth.MaxFailedLogins > 0 && user.FailedAttempts >= s.config.Auth.MaxFailedLogins {
+		user.FailedAttempts = 0
+		user.LockedUntil = time.Now().Add(s.config.Auth.LockoutDuration)
+		lockErr = newLockedError(user.LockedUntil)
+
+		s.publishEvent(events.Event{
+			Type:    events.TypeAccountLocked,
+			Outcome: events.OutcomeFailure,
+			UserID:  user.ID,
+			Email:   user.Email,
+			Details: map[string]string{"locked_until": user.LockedUntil.UTC().Format(time.RFC3339)},
+		})
+	}
+
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	// The lock is what protects the account, so it holds even when the
+	// unlock email can't be sent
+	if lockErr != nil && s.config.Auth.UnlockEmail {
+		if err := s.sendUnlockEmail(user); err != nil {
+			logging.FromContext(ctx).Warn("Failed to send account unlock email", "user_id", user.ID, "error", err)
+		}
+	}
+	return lockErr
+}
+
+// sendUnlockEmail tells a user their account was locked and sends a link
+// that lifts the lock. Only the link for the latest lock stays valid.
+func (s *Service) sendUnlockEmail(user *storage.User) error {
+	if err := s.tokenStore.DeleteUserTokens(user.ID, storage.TokenPurposeUnlock); err != nil {
+		return err
+	}
+
+	token, err := s.newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokenStore.SaveToken(&storage.VerificationToken{
+		Token:     token,
+		Purpose:   storage.TokenPurposeUnlock,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.config.Auth.UnlockLinkTTL),
+	}); err != nil {
+		return err
+	}
+
+	return s.sendEmail(user.Email, "Your account was locked", "account_locked.html", map[string]interface{}{
+		"URL":       s.linkURL("/api/auth/unlock", token),
+		"LockedFor": formatTTL(s.config.Auth.LockoutDuration),
+		"ExpiresIn": formatTTL(s.config.Auth.UnlockLinkTTL),
+	})
+}
+
+// UnlockAccount lifts an account lock with a token from an unlock email and
+// clears the failed login counter. A lock that already expired on its own
+// is not an error, so a late click still reports the account as unlocked.
+func (s *Service) UnlockAccount(ctx context.Context, token string) error {
+	stored, err := s.tokenStore.ConsumeToken(token, storage.TokenPurposeUnlock)
+	if err != nil {
+		if err == storage.ErrTokenNotFound || err == storage.ErrTokenExpired {
+			return ErrInvalidUnlockToken
+		}
+		return err
+	}
+
+	user, err := s.userStore.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrInvalidUnlockToken
+		}
+		return err
+	}
+
+	wasLocked := time.Now().Before(user.LockedUntil)
+	if err := s.resetFailedLogins(ctx, user); err != nil {
+		return err
+	}
+
+	if wasLocked {
+		s.publishEvent(events.Event{
+			Type:    events.TypeAccountUnlocked,
+			Outcome: events.OutcomeSuccess,
+			UserID:  user.ID,
+			Email:   user.Email,
+		})
+	}
+	return nil
+}
+
+// resetFailedLogins clears the failure counter after a successful login
+func (s *Service) resetFailedLogins(ctx context.Context, user *storage.User) error {
+	if user.FailedAttempts == 0 && user.LockedUntil.IsZero() {
+		return nil
+	}
+
+	user.FailedAttempts = 0
+	user.LockedUntil = time.Time{}
+	return s.userStore.UpdateUser(ctx, user)
+}
+
+// ipThrottle tracks failed login attempts per client IP
+type ipThrottle struct {
+	mu      sync.Mutex
+	entries map[string]*ipThrottleEntry
+}
+
+type ipThrottleEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// maxThrottleEntries bounds how many IPs are tracked before stale entries are pruned
+const maxThrottleEntries = 10000
+
+func newIPThrottle() *ipThrottle {
+	return &ipThrottle{
+		entries: make(map[string]*ipThrottleEntry),
+	}
+}
+
+// check returns a LockedError while the IP is locked out
+func (t *ipThrottle) check(ip string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, exists := t.entries[ip]
+	if exists && time.Now().Before(entry.lockedUntil) {
+		return newLockedError(entry.lockedUntil)
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt from an IP within the window and
+// locks the IP once max failures are reached. A max of zero disables it.
+func (t *ipThrottle) recordFailure(ip string, max int, window, lockout time.Duration) error {
+	if max <= 0 || ip == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if len(t.entries) >= maxThrottleEntries {
+		t.prune(now, window)
+	}
+
+	entry, exists := t.entries[ip]
+	if !exists || now.Sub(entry.lastFailure) > window {
+		entry = &ipThrottleEntry{}
+		t.entries[ip] = entry
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+
+	if entry.failures >= max {
+		entry.failures = 0
+		entry.lockedUntil = now.Add(lockout)
+		return newLockedError(entry.lockedUntil)
+	}
+	return nil
+}
+
+// reset forgets the failures recorded for an IP
+func (t *ipThrottle) reset(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if entry, exists := t.entries[ip]; exists && time.Now().After(entry.lockedUntil) {
+		delete(t.entries, ip)
+	}
+}
+
+// prune removes entries that are neither locked nor inside the failure window
+func (t *ipThrottle) prune(now time.Time, window time.Duration) {
+	for ip, entry := range t.entries {
+		if now.After(entry.lockedUntil) && now.Sub(entry.lastFailure) > window {
+			delete(t.entries, ip)
+		}
+	}
+}
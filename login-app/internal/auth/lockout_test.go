@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+)
+
+const wrongPassword = "Wrong1!pass"
+
+func lockoutService(t *testing.T, configure func(cfg *config.Config)) *Service {
+	return newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.MaxFailedLogins = 3
+		cfg.Auth.MaxFailedLoginsPerIP = 0
+		cfg.Auth.FailedLoginWindow = time.Minute
+		cfg.Auth.LockoutDuration = time.Minute
+		cfg.Auth.LoginBackoff.Enabled = false
+		if configure != nil {
+			configure(cfg)
+		}
+	})
+}
+
+func login(service *Service, emailAddress, password, clientIP string) (*LoginResponse, error) {
+	return service.Login(context.Background(), &LoginRequest{Email: emailAddress, Password: password}, clientIP)
+}
+
+func TestAccountLocksAfterMaxFailedLogins(t *testing.T) {
+	service := lockoutService(t, nil)
+	publisher := captureEvents(service)
+	registerTestUser(t, service, "lock@example.com", "lock")
+
+	for i := 1; i < 3; i++ {
+		if _, err := login(service, "lock@example.com", wrongPassword, "192.0.2.10"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: got %v, want ErrInvalidCredentials", i, err)
+		}
+	}
+
+	_, err := login(service, "lock@example.com", wrongPassword, "192.0.2.10")
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("third failure: got %v, want LockedError", err)
+	}
+	if locked.RetryAfter <= 0 || locked.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v, want within the lockout duration", locked.RetryAfter)
+	}
+	if n := len(publisher.ofType(events.TypeAccountLocked)); n != 1 {
+		t.Errorf("published %d account locked events, want 1", n)
+	}
+
+	// The right password doesn't get past the lock, from any IP
+	if _, err := login(service, "lock@example.com", testPassword, "198.51.100.1"); !errors.As(err, &locked) {
+		t.Errorf("correct password while locked: got %v, want LockedError", err)
+	}
+}
+
+func TestSuccessfulLoginResetsFailedAttempts(t *testing.T) {
+	service := lockoutService(t, nil)
+	registerTestUser(t, service, "reset@example.com", "reset")
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 2; i++ {
+			if _, err := login(service, "reset@example.com", wrongPassword, "192.0.2.10"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("round %d failure %d: got %v, want ErrInvalidCredentials", round, i, err)
+			}
+		}
+		if _, err := login(service, "reset@example.com", testPassword, "192.0.2.10"); err != nil {
+			t.Fatalf("round %d login: %v", round, err)
+		}
+	}
+}
+
+func TestExpiredAccountLockAllowsLogin(t *testing.T) {
+	service := lockoutService(t, nil)
+	registered := registerTestUser(t, service, "expired@example.com", "expired")
+	ctx := context.Background()
+
+	user, _ := service.userStore.GetUserByID(ctx, registered.User.ID)
+	user.FailedAttempts = 2
+	user.LockedUntil = time.Now().Add(-time.Second)
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	if _, err := login(service, "expired@example.com", testPassword, "192.0.2.10"); err != nil {
+		t.Fatalf("login after the lock expired: %v", err)
+	}
+	user, _ = service.userStore.GetUserByID(ctx, registered.User.ID)
+	if user.FailedAttempts != 0 || !user.LockedUntil.IsZero() {
+		t.Errorf("after login: %d failed attempts, locked until %v; want both cleared",
+			user.FailedAttempts, user.LockedUntil)
+	}
+}
+
+func TestUnlockEmailLiftsLock(t *testing.T) {
+	service := lockoutService(t, func(cfg *config.Config) {
+		cfg.Auth.UnlockEmail = true
+		cfg.Auth.UnlockLinkTTL = time.Hour
+	})
+	mail := captureEmails(service)
+	registerTestUser(t, service, "unlock@example.com", "unlock")
+	ctx := context.Background()
+
+	var locked *LockedError
+	for i := 0; i < 3; i++ {
+		_, err := login(service, "unlock@example.com", wrongPassword, "192.0.2.10")
+		if i == 2 && !errors.As(err, &locked) {
+			t.Fatalf("third failure: got %v, want LockedError", err)
+		}
+	}
+
+	token := mail.lastToken(t)
+	if err := service.UnlockAccount(ctx, token); err != nil {
+		t.Fatalf("UnlockAccount: %v", err)
+	}
+	if _, err := login(service, "unlock@example.com", testPassword, "192.0.2.10"); err != nil {
+		t.Errorf("login after unlocking: %v", err)
+	}
+	if err := service.UnlockAccount(ctx, token); !errors.Is(err, ErrInvalidUnlockToken) {
+		t.Errorf("reused unlock token: got %v, want ErrInvalidUnlockToken", err)
+	}
+}
+
+func TestIPThrottleLocksClientIP(t *testing.T) {
+	service := lockoutService(t, func(cfg *config.Config) {
+		cfg.Auth.MaxFailedLogins = 0
+		cfg.Auth.MaxFailedLoginsPerIP = 3
+	})
+	registerTestUser(t, service, "ip@example.com", "ip")
+
+	// Failures against unknown accounts count against the IP too
+	for _, address := range []string{"ip@example.com", "nobody@example.com"} {
+		if _, err := login(service, address, wrongPassword, "192.0.2.20"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("%s: got %v, want ErrInvalidCredentials", address, err)
+		}
+	}
+	var locked *LockedError
+	if _, err := login(service, "ip@example.com", wrongPassword, "192.0.2.20"); !errors.As(err, &locked) {
+		t.Fatalf("third failure: got %v, want LockedError", err)
+	}
+
+	if _, err := login(service, "ip@example.com", testPassword, "192.0.2.20"); !errors.As(err, &locked) {
+		t.Errorf("locked IP: got %v, want LockedError", err)
+	}
+	if _, err := login(service, "ip@example.com", testPassword, "192.0.2.21"); err != nil {
+		t.Errorf("other IP: %v", err)
+	}
+}
+
+func TestIPThrottleWindow(t *testing.T) {
+	throttle := newIPThrottle()
+
+	if err := throttle.recordFailure("192.0.2.1", 2, time.Millisecond, time.Minute); err != nil {
+		t.Fatalf("first failure: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// The first failure fell out of the window, so this one starts over
+	if err := throttle.recordFailure("192.0.2.1", 2, time.Millisecond, time.Minute); err != nil {
+		t.Errorf("failure after the window: %v", err)
+	}
+	if err := throttle.check("192.0.2.1"); err != nil {
+		t.Errorf("check: %v", err)
+	}
+
+	if err := throttle.recordFailure("192.0.2.2", 0, time.Minute, time.Minute); err != nil {
+		t.Errorf("disabled throttle: %v", err)
+	}
+}
+
+func TestLoginHandlerLockedReturnsRetryAfter(t *testing.T) {
+	service := lockoutService(t, nil)
+	registerTestUser(t, service, "handler@example.com", "handler")
+
+	router := gin.New()
+	router.POST("/login", NewHandler(service).Login)
+
+	body := LoginRequest{Email: "handler@example.com", Password: wrongPassword}
+	for i := 1; i < 3; i++ {
+		if w := doRequest(t, router, http.MethodPost, "/login", body, nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d, want 401", i, w.Code)
+		}
+	}
+
+	w := doRequest(t, router, http.MethodPost, "/login", body, nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429: %s", w.Code, w.Body)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("Retry-After = %q, want 1 to 60 seconds", w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+// BackoffError wraps the error of a failed login with the wait before the
+// next attempt for the same email is accepted
+type BackoffError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *BackoffError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the login error
+func (e *BackoffError) Unwrap() error {
+	return e.Err
+}
+
+// loginBackoff tracks consecutive failed logins per email and the time the
+// next attempt is accepted. Unlike the account lockout it also delays
+// attempts for emails that don't belong to an account.
+type loginBackoff struct {
+	mu      sync.Mutex
+	entries map[string]*loginBackoffEntry
+}
+
+type loginBackoffEntry struct {
+	failures    int
+	nextAttempt time.Time
+}
+
+func newLoginBackoff() *loginBackoff {
+	return &loginBackoff{
+		entries: make(map[string]*loginBackoffEntry),
+	}
+}
+
+// backoffKey scopes a normalized email to the context's organization
+func backoffKey(ctx context.Context, email string) string {
+	return tenant.FromContext(ctx) + "\x00" + email
+}
+
+// check returns a LockedError while the email has to wait before its next attempt
+func (b *loginBackoff) check(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, exists := b.entries[key]
+	if exists && time.Now().Before(entry.nextAttempt) {
+		return newLockedError(entry.nextAttempt)
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt and returns the wait before the
+// next one: base after the first failure, doubling with each consecutive
+// failure up to max. Failures are forgotten once an email has been idle
+// for max after its wait ended.
+func (b *loginBackoff) recordFailure(key string, base, max time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if len(b.entries) >= maxThrottleEntries {
+		b.prune(now, max)
+	}
+
+	entry, exists := b.entries[key]
+	if !exists || now.Sub(entry.nextAttempt) > max {
+		entry = &loginBackoffEntry{}
+		b.entries[key] = entry
+	}
+
+	delay := base
+	for i := 0; i < entry.failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	entry.failures++
+	entry.nextAttempt = now.Add(delay)
+	return delay
+}
+
+// reset forgets the failures recorded for an email
+func (b *loginBackoff) reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, key)
+}
+
+// prune removes entries that have been idle for max after their wait ended
+func (b *loginBackoff) prune(now time.Time, max time.Duration) {
+	for key, entry := range b.entries {
+		if now.Sub(entry.nextAttempt) > max {
+			delete(b.entries, key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// loginTiming hides whether an email is registered from the time a login
+// takes: unknown emails are checked against a dummy hash so they cost as
+// much as a wrong password, and every login is padded to a minimum duration
+// to cover what remains, such as store lookups
+type loginTiming struct {
+	minDuration time.Duration
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// pad sleeps until minDuration has passed since start, or the context is done
+func (t *loginTiming) pad(ctx context.Context, start time.Time) {
+	remaining := t.minDuration - time.Since(start)
+	if remaining <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// maskUnknownAccount verifies a password against a dummy hash made with the
+// configured hasher when login timing is masked, so a login without an
+// account to check costs the same as a wrong password
+func (s *Service) maskUnknownAccount(password string) {
+	t := s.loginTiming
+	if t.minDuration <= 0 {
+		return
+	}
+
+	t.dummyOnce.Do(func() {
+		t.dummyHash, _ = s.hashPassword("login-timing-dummy-password")
+	})
+	if t.dummyHash != "" {
+		_ = s.verifyPassword(t.dummyHash, password)
+	}
+}
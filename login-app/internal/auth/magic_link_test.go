@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+func TestMagicLinkLogin(t *testing.T) {
+	service := newTestService(t, nil)
+	mail := captureEmails(service)
+	registered := registerTestUser(t, service, "magic@example.com", "magic")
+	ctx := context.Background()
+
+	token, err := service.RequestMagicLink(ctx, "magic@example.com")
+	if err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	if emailed := mail.lastToken(t); emailed != token {
+		t.Errorf("emailed token %q, want %q", emailed, token)
+	}
+
+	response, err := service.ConsumeMagicLink(ctx, token)
+	if err != nil {
+		t.Fatalf("ConsumeMagicLink: %v", err)
+	}
+	if response.Token == "" || response.User.ID != registered.User.ID {
+		t.Errorf("response = %+v, want tokens for the user", response)
+	}
+
+	if _, err := service.ConsumeMagicLink(ctx, token); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("reused link: got %v, want ErrInvalidMagicLink", err)
+	}
+}
+
+func TestMagicLinkOnlyLatestIsValid(t *testing.T) {
+	service := newTestService(t, nil)
+	registerTestUser(t, service, "latest@example.com", "latest")
+	ctx := context.Background()
+
+	first, err := service.RequestMagicLink(ctx, "latest@example.com")
+	if err != nil {
+		t.Fatalf("first RequestMagicLink: %v", err)
+	}
+	second, err := service.RequestMagicLink(ctx, "latest@example.com")
+	if err != nil {
+		t.Fatalf("second RequestMagicLink: %v", err)
+	}
+
+	if _, err := service.ConsumeMagicLink(ctx, first); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("superseded link: got %v, want ErrInvalidMagicLink", err)
+	}
+	if _, err := service.ConsumeMagicLink(ctx, second); err != nil {
+		t.Errorf("latest link: %v", err)
+	}
+}
+
+func TestMagicLinkExpires(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.MagicLinkTTL = time.Millisecond
+	})
+	registerTestUser(t, service, "expired@example.com", "expired")
+	ctx := context.Background()
+
+	token, err := service.RequestMagicLink(ctx, "expired@example.com")
+	if err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := service.ConsumeMagicLink(ctx, token); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Errorf("expired link: got %v, want ErrInvalidMagicLink", err)
+	}
+}
+
+func TestMagicLinkUnknownOrInactiveAccount(t *testing.T) {
+	service := newTestService(t, nil)
+	mail := captureEmails(service)
+	registered := registerTestUser(t, service, "inactive@example.com", "inactive")
+	ctx := context.Background()
+	sent := len(mail.bodies)
+
+	user, _ := service.userStore.GetUserByID(ctx, registered.User.ID)
+	user.IsActive = false
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	for _, address := range []string{"nobody@example.com", "inactive@example.com"} {
+		token, err := service.RequestMagicLink(ctx, address)
+		if err != nil || token != "" {
+			t.Errorf("RequestMagicLink(%s) = %q, %v; want no link and no error", address, token, err)
+		}
+	}
+	if len(mail.bodies) != sent {
+		t.Errorf("sent %d emails, want none", len(mail.bodies)-sent)
+	}
+}
+
+func TestMagicLinkRespectsLockAndTwoFactor(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "guarded@example.com", "guarded")
+	ctx := context.Background()
+
+	user, _ := service.userStore.GetUserByID(ctx, registered.User.ID)
+	user.LockedUntil = time.Now().Add(time.Hour)
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	token, _ := service.RequestMagicLink(ctx, "guarded@example.com")
+	var locked *LockedError
+	if _, err := service.ConsumeMagicLink(ctx, token); !errors.As(err, &locked) {
+		t.Errorf("locked account: got %v, want LockedError", err)
+	}
+
+	user, _ = service.userStore.GetUserByID(ctx, registered.User.ID)
+	user.LockedUntil = time.Time{}
+	user.TOTPEnabled = true
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	token, _ = service.RequestMagicLink(ctx, "guarded@example.com")
+	response, err := service.ConsumeMagicLink(ctx, token)
+	if err != nil {
+		t.Fatalf("ConsumeMagicLink: %v", err)
+	}
+	if !response.TwoFactorRequired || response.Token != "" {
+		t.Errorf("response = %+v, want a two-factor challenge without tokens", response)
+	}
+}
+
+func TestMagicLinkHandlers(t *testing.T) {
+	service := newTestService(t, nil)
+	mail := captureEmails(service)
+	registerTestUser(t, service, "handler@example.com", "handler")
+
+	h := NewHandler(service)
+	router := gin.New()
+	router.POST("/magic-link", h.RequestMagicLink)
+	router.GET("/magic-link/consume", h.ConsumeMagicLink)
+
+	// Known and unknown emails get the same answer
+	for _, address := range []string{"nobody@example.com", "handler@example.com"} {
+		w := doRequest(t, router, http.MethodPost, "/magic-link", MagicLinkRequest{Email: address}, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("request for %s: status %d, want 200", address, w.Code)
+		}
+	}
+
+	consume := "/magic-link/consume?token=" + url.QueryEscape(mail.lastToken(t))
+	if w := doRequest(t, router, http.MethodGet, consume, nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("consume: status %d, want 200: %s", w.Code, w.Body)
+	}
+	if w := doRequest(t, router, http.MethodGet, consume, nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("consume again: status %d, want 401", w.Code)
+	}
+	if w := doRequest(t, router, http.MethodGet, "/magic-link/consume", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("consume without a token: status %d, want 400", w.Code)
+	}
+}
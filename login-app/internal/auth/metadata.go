@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+// Limits on the metadata stored for a user
+const (
+	MaxMetadataKeys        = 32
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 512
+)
+
+// ErrTokenMetadataTooLarge is returned when the metadata copied into a
+// user's access tokens would be larger than the configured limit
+var ErrTokenMetadataTooLarge = errors.New("token metadata too large")
+
+// MetadataError reports metadata that breaks the storage limits
+type MetadataError struct {
+	Message string
+}
+
+func (e *MetadataError) Error() string {
+	return "invalid metadata: " + e.Message
+}
+
+// validateMetadata checks metadata against the storage limits
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataKeys {
+		return &MetadataError{Message: fmt.Sprintf("at most %d keys are allowed", MaxMetadataKeys)}
+	}
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return &MetadataError{Message: fmt.Sprintf("keys must be 1 to %d bytes long", MaxMetadataKeyLength)}
+		}
+		if len(value) > MaxMetadataValueLength {
+			return &MetadataError{Message: fmt.Sprintf("value of %q is longer than %d bytes", key, MaxMetadataValueLength)}
+		}
+	}
+	return nil
+}
+
+// filterTokenMetadata returns the metadata under the configured token keys,
+// or nil when there is none
+func (s *Service) filterTokenMetadata(metadata map[string]string) map[string]string {
+	var claims map[string]string
+	for _, key := range s.config.Auth.TokenMetadata.Keys {
+		value, ok := metadata[key]
+		if !ok {
+			continue
+		}
+		if claims == nil {
+			claims = make(map[string]string)
+		}
+		claims[key] = value
+	}
+	return claims
+}
+
+// checkTokenMetadataSize rejects token metadata that would encode to more
+// than the configured limit
+func (s *Service) checkTokenMetadataSize(claims map[string]string) error {
+	if claims == nil {
+		return nil
+	}
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return err
+	}
+	if len(data) > s.config.Auth.TokenMetadata.MaxBytes {
+		return ErrTokenMetadataTooLarge
+	}
+	return nil
+}
+
+// tokenMetadata returns the metadata to copy into a user's access tokens.
+// SetUserMetadata keeps it within the size limit, but a lower limit or
+// more keys configured since can push it over; then it is left out rather
+// than failing the login.
+func (s *Service) tokenMetadata(user *storage.User) map[string]string {
+	claims := s.filterTokenMetadata(user.Metadata)
+	if err := s.checkTokenMetadataSize(claims); err != nil {
+		slog.Warn("Leaving metadata out of access token", "user_id", user.ID, "error", err)
+		return nil
+	}
+	return claims
+}
+
+// GetUserMetadata returns a user's metadata for administrators
+func (s *Service) GetUserMetadata(ctx context.Context, userID string) (*UserMetadataResponse, error) {
+	user, err := s.adminUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.userMetadataResponse(user), nil
+}
+
+// SetUserMetadata replaces a user's metadata on an administrator's behalf.
+// Access tokens already issued keep the metadata they were issued with;
+// the change reaches the user's tokens when they next log in or refresh.
+func (s *Service) SetUserMetadata(ctx context.Context, userID string, metadata map[string]string) (*UserMetadataResponse, error) {
+	if err := validateMetadata(metadata); err != nil {
+		return nil, err
+	}
+	if err := s.checkTokenMetadataSize(s.filterTokenMetadata(metadata)); err != nil {
+		return nil, err
+	}
+
+	user, err := s.adminUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Metadata = nil
+	if len(metadata) > 0 {
+		user.Metadata = metadata
+	}
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.userMetadataResponse(user), nil
+}
+
+// userMetadataResponse describes a user's metadata, with empty maps rather
+// than nulls
+func (s *Service) userMetadataResponse(user *storage.User) *UserMetadataResponse {
+	response := &UserMetadataResponse{
+		Metadata:      user.Metadata,
+		TokenMetadata: s.filterTokenMetadata(user.Metadata),
+	}
+	if response.Metadata == nil {
+		response.Metadata = map[string]string{}
+	}
+	if response.TokenMetadata == nil {
+		response.TokenMetadata = map[string]string{}
+	}
+	return response
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+var (
+	ErrAccountLinkRejected      = errors.New("an account with this email already exists, log in with your password")
+	ErrLinkConfirmationRequired = errors.New("account link confirmation required")
+	ErrProviderEmailUnverified  = errors.New("provider email address is not verified")
+	ErrInvalidLinkToken         = errors.New("invalid or expired link token")
+)
+
+// ExternalIdentity represents a user identity asserted by an external provider
+type ExternalIdentity struct {
+	Provider       string
+	ProviderUserID string
+	Email          string
+	EmailVerified  bool
+	Username       string
+	FirstName      string
+	LastName       string
+}
+
+// LoginWithExternalIdentity logs in a user authenticated by an external provider.
+// The account already linked to the provider identity is used even if its email has changed since. Otherwise the
+// provider's email is only trusted when the provider has verified it: new emails get a fresh account unless
+// registration is invite-only, and an email that already belongs to an account is handled by the configured
+// duplicate email policy.
+func (s *Service) LoginWithExternalIdentity(ctx context.Context, identity *ExternalIdentity) (*LoginResponse, error) {
+	user, err := s.userStore.GetUserByProvider(ctx, identity.Provider, identity.ProviderUserID)
+	if err == nil {
+		if err := s.checkExternalUser(user); err != nil {
+			return nil, err
+		}
+		return s.completeExternalLogin(ctx, user)
+	}
+	if err != storage.ErrUserNotFound {
+		return nil, err
+	}
+
+	// Anyone can sign up with a provider using someone else's address, so an
+	// unverified email must neither claim a new account nor match an existing one
+	policy := s.config.OAuth.DuplicateEmailPolicy
+	if !identity.EmailVerified {
+		s.auditLinkDecision(policy, identity, "", "rejected_unverified_email")
+		return nil, ErrProviderEmailUnverified
+	}
+
+	user, err = s.userStore.GetUserByEmail(ctx, identity.Email)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			// Invites are redeemed with a code at registration only
+			if s.config.Auth.InviteOnly {
+				return nil, ErrInviteRequired
+			}
+			return s.createExternalUser(ctx, identity)
+		}
+		return nil, err
+	}
+
+	if err := s.checkExternalUser(user); err != nil {
+		return nil, err
+	}
+
+	switch policy {
+	case config.LinkPolicyAutoLink:
+		if err := s.linkProvider(ctx, user, identity.Provider, identity.ProviderUserID); err != nil {
+			return nil, err
+		}
+		s.auditLinkDecision(policy, identity, user.ID, "linked")
+		return s.completeExternalLogin(ctx, user)
+
+	case config.LinkPolicyRequireConfirmation:
+		if err := s.requestLinkConfirmation(user, identity); err != nil {
+			return nil, err
+		}
+		s.auditLinkDecision(policy, identity, user.ID, "confirmation_requested")
+		return nil, ErrLinkConfirmationRequired
+
+	default:
+		s.auditLinkDecision(policy, identity, user.ID, "rejected")
+		return nil, ErrAccountLinkRejected
+	}
+}
+
+// checkExternalUser rejects provider logins to inactive or locked accounts
+func (s *Service) checkExternalUser(user *storage.User) error {
+	if !user.IsActive {
+		return ErrInvalidCredentials
+	}
+
+	// A locked account stays locked whichever factor is presented
+	return s.checkAccountLock(user)
+}
+
+// completeExternalLogin logs in an existing user vouched for by a provider.
+// Accounts with 2FA only get a challenge until the second factor is verified.
+func (s *Service) completeExternalLogin(ctx context.Context, user *storage.User) (*LoginResponse, error) {
+	if user.TOTPEnabled {
+		return s.issueTwoFactorChallenge(user)
+	}
+	return s.issueLoginResponse(ctx, user, false)
+}
+
+// ConfirmExternalLink completes a pending provider link using the confirmation token
+func (s *Service) ConfirmExternalLink(ctx context.Context, token string) error {
+	stored, err := s.tokenStore.ConsumeToken(token, storage.TokenPurposeOAuthLink)
+	if err != nil {
+		return ErrInvalidLinkToken
+	}
+
+	var link storage.LinkedProvider
+	if err := json.Unmarshal([]byte(stored.Data), &link); err != nil {
+		return ErrInvalidLinkToken
+	}
+
+	user, err := s.userStore.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrInvalidLinkToken
+		}
+		return err
+	}
+
+	identity := &ExternalIdentity{
+		Provider:       link.Provider,
+		ProviderUserID: link.ProviderUserID,
+		Email:          user.Email,
+	}
+
+	if user.HasProvider(link.Provider, link.ProviderUserID) {
+		s.auditLinkDecision(config.LinkPolicyRequireConfirmation, identity, user.ID, "already_linked")
+		return nil
+	}
+
+	if err := s.linkProvider(ctx, user, link.Provider, link.ProviderUserID); err != nil {
+		return err
+	}
+
+	s.auditLinkDecision(config.LinkPolicyRequireConfirmation, identity, user.ID, "confirmed")
+	return nil
+}
+
+// createExternalUser creates a password-less account for a new provider
+// identity. The provider has verified the email, so the account starts out
+// verified rather than pending.
+func (s *Service) createExternalUser(ctx context.Context, identity *ExternalIdentity) (*LoginResponse, error) {
+	userID, err := s.generateID()
+	if err != nil {
+		return nil, err
+	}
+
+	username := identity.Username
+	if username == "" {
+		username = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	if _, err := s.userStore.GetUserByUsername(ctx, username); err == nil {
+		// Disambiguate with part of the user ID rather than failing the login
+		username = username + "-" + userID[:6]
+	}
+
+	user := &storage.User{
+		ID:        userID,
+		OrgID:     tenant.FromContext(ctx),
+		Email:     identity.Email,
+		Username:  username,
+		FirstName: identity.FirstName,
+		LastName:  identity.LastName,
+		LinkedProviders: []storage.LinkedProvider{{
+			Provider:       identity.Provider,
+			ProviderUserID: identity.ProviderUserID,
+			LinkedAt:       time.Now(),
+		}},
+	}
+	markEmailVerified(user)
+
+	if err := s.userStore.CreateUser(ctx, user); err != nil {
+		if err == storage.ErrUserExists {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+
+	s.auditLinkDecision("new_account", identity, user.ID, "created")
+	return s.issueLoginResponse(ctx, user, false)
+}
+
+// linkProvider attaches a provider identity to a user and persists it
+func (s *Service) linkProvider(ctx context.Context, user *storage.User, provider, providerUserID string) error {
+	user.LinkedProviders = append(user.LinkedProviders, storage.LinkedProvider{
+		Provider:       provider,
+		ProviderUserID: providerUserID,
+		LinkedAt:       time.Now(),
+	})
+	return s.userStore.UpdateUser(ctx, user)
+}
+
+// requestLinkConfirmation issues a single-use token the account owner must use to approve the link
+func (s *Service) requestLinkConfirmation(user *storage.User, identity *ExternalIdentity) error {
+	token, err := s.newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(storage.LinkedProvider{
+		Provider:       identity.Provider,
+		ProviderUserID: identity.ProviderUserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokenStore.SaveToken(&storage.VerificationToken{
+		Token:     token,
+		Purpose:   storage.TokenPurposeOAuthLink,
+		UserID:    user.ID,
+		Data:      string(data),
+		ExpiresAt: time.Now().Add(s.config.OAuth.LinkConfirmationTTL),
+	}); err != nil {
+		return err
+	}
+
+	return s.sendEmail(user.Email, "Confirm linking your "+identity.Provider+" account", "link_confirmation.html",
+		map[string]interface{}{
+			"Provider":  identity.Provider,
+			"URL":       s.linkURL("/api/auth/oauth/link/confirm", token),
+			"ExpiresIn": formatTTL(s.config.OAuth.LinkConfirmationTTL),
+		})
+}
+
+// auditLinkDecision records the outcome of every provider link decision
+func (s *Service) auditLinkDecision(policy string, identity *ExternalIdentity, userID, outcome string) {
+	slog.Info("audit: oauth_link",
+		"policy", policy,
+		"provider", identity.Provider,
+		"provider_user_id", identity.ProviderUserID,
+		"email", identity.Email,
+		"user_id", userID,
+		"outcome", outcome,
+	)
+
+	eventOutcome := events.OutcomeSuccess
+	if strings.HasPrefix(outcome, "rejected") {
+		eventOutcome = events.OutcomeFailure
+	}
+	s.publishEvent(events.Event{
+		Type:    events.TypeLinkDecision,
+		Outcome: eventOutcome,
+		UserID:  userID,
+		Email:   identity.Email,
+		Details: map[string]string{
+			"policy":   policy,
+			"provider": identity.Provider,
+			"decision": outcome,
+		},
+	})
+}
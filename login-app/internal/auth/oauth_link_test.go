@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+func autoLinkService(t *testing.T) *Service {
+	return newTestService(t, func(cfg *config.Config) {
+		cfg.OAuth.DuplicateEmailPolicy = config.LinkPolicyAutoLink
+	})
+}
+
+func githubIdentity(emailAddress string) *ExternalIdentity {
+	return &ExternalIdentity{
+		Provider:       "github",
+		ProviderUserID: "gh-42",
+		Email:          emailAddress,
+		EmailVerified:  true,
+		Username:       "octo",
+	}
+}
+
+func TestExternalLoginAutoLinkRequiresSecondFactor(t *testing.T) {
+	service := autoLinkService(t)
+	registered := registerTestUser(t, service, "totp@example.com", "totp")
+	ctx := context.Background()
+
+	user, err := service.userStore.GetUserByID(ctx, registered.User.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	user.TOTPEnabled = true
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	// Both the login that links the provider and later ones stop at the challenge
+	for _, attempt := range []string{"linking", "linked"} {
+		response, err := service.LoginWithExternalIdentity(ctx, githubIdentity("totp@example.com"))
+		if err != nil {
+			t.Fatalf("%s login: %v", attempt, err)
+		}
+		if !response.TwoFactorRequired || response.Token != "" || response.ChallengeToken == "" {
+			t.Errorf("%s login = %+v, want a two-factor challenge without tokens", attempt, response)
+		}
+	}
+
+	user, _ = service.userStore.GetUserByID(ctx, registered.User.ID)
+	if !user.HasProvider("github", "gh-42") {
+		t.Error("provider was not linked")
+	}
+}
+
+func TestExternalLoginAutoLinkWithoutSecondFactor(t *testing.T) {
+	service := autoLinkService(t)
+	registerTestUser(t, service, "plain@example.com", "plain")
+
+	response, err := service.LoginWithExternalIdentity(context.Background(), githubIdentity("plain@example.com"))
+	if err != nil {
+		t.Fatalf("LoginWithExternalIdentity: %v", err)
+	}
+	if response.TwoFactorRequired || response.Token == "" {
+		t.Errorf("response = %+v, want tokens", response)
+	}
+}
+
+func TestExternalLoginRejectsLockedAccount(t *testing.T) {
+	service := autoLinkService(t)
+	registered := registerTestUser(t, service, "locked@example.com", "locked")
+	ctx := context.Background()
+
+	user, _ := service.userStore.GetUserByID(ctx, registered.User.ID)
+	user.LockedUntil = time.Now().Add(time.Hour)
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	for _, attempt := range []string{"linking", "linked"} {
+		_, err := service.LoginWithExternalIdentity(ctx, githubIdentity("locked@example.com"))
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("%s login: got %v, want LockedError", attempt, err)
+		}
+
+		// Link the provider so the second attempt takes the linked path
+		if attempt == "linking" {
+			user, _ = service.userStore.GetUserByID(ctx, registered.User.ID)
+			if err := service.linkProvider(ctx, user, "github", "gh-42"); err != nil {
+				t.Fatalf("linkProvider: %v", err)
+			}
+		}
+	}
+}
+
+func TestExternalLoginUnverifiedEmailIsNotLinked(t *testing.T) {
+	service := autoLinkService(t)
+	registerTestUser(t, service, "unverified@example.com", "unverified")
+
+	identity := githubIdentity("unverified@example.com")
+	identity.EmailVerified = false
+	if _, err := service.LoginWithExternalIdentity(context.Background(), identity); !errors.Is(err, ErrProviderEmailUnverified) {
+		t.Errorf("got %v, want ErrProviderEmailUnverified", err)
+	}
+}
+
+func linkPolicyService(t *testing.T, policy string) *Service {
+	return newTestService(t, func(cfg *config.Config) {
+		cfg.OAuth.DuplicateEmailPolicy = policy
+	})
+}
+
+// linkDecisions returns the decisions of the published link events
+func linkDecisions(publisher *capturePublisher) []string {
+	var decisions []string
+	for _, event := range publisher.ofType(events.TypeLinkDecision) {
+		decisions = append(decisions, event.Details["decision"])
+	}
+	return decisions
+}
+
+func TestLinkPolicyAutoLinkAudited(t *testing.T) {
+	service := linkPolicyService(t, config.LinkPolicyAutoLink)
+	publisher := captureEvents(service)
+	registerTestUser(t, service, "auto@example.com", "auto")
+
+	identity := githubIdentity("auto@example.com")
+	identity.EmailVerified = false
+	if _, err := service.LoginWithExternalIdentity(context.Background(), identity); !errors.Is(err, ErrProviderEmailUnverified) {
+		t.Fatalf("unverified: got %v, want ErrProviderEmailUnverified", err)
+	}
+	if _, err := service.LoginWithExternalIdentity(context.Background(), githubIdentity("auto@example.com")); err != nil {
+		t.Fatalf("verified: %v", err)
+	}
+
+	got := linkDecisions(publisher)
+	if len(got) != 2 || got[0] != "rejected_unverified_email" || got[1] != "linked" {
+		t.Errorf("link decisions = %v, want [rejected_unverified_email linked]", got)
+	}
+}
+
+func TestLinkPolicyRequireConfirmation(t *testing.T) {
+	service := linkPolicyService(t, config.LinkPolicyRequireConfirmation)
+	mail := captureEmails(service)
+	publisher := captureEvents(service)
+	registered := registerTestUser(t, service, "confirm@example.com", "confirm")
+	ctx := context.Background()
+
+	if _, err := service.LoginWithExternalIdentity(ctx, githubIdentity("confirm@example.com")); !errors.Is(err, ErrLinkConfirmationRequired) {
+		t.Fatalf("got %v, want ErrLinkConfirmationRequired", err)
+	}
+	user, _ := service.userStore.GetUserByID(ctx, registered.User.ID)
+	if user.HasProvider("github", "gh-42") {
+		t.Fatal("provider linked before confirmation")
+	}
+
+	token := mail.lastToken(t)
+	if err := service.ConfirmExternalLink(ctx, token); err != nil {
+		t.Fatalf("ConfirmExternalLink: %v", err)
+	}
+	user, _ = service.userStore.GetUserByID(ctx, registered.User.ID)
+	if !user.HasProvider("github", "gh-42") {
+		t.Error("provider not linked after confirmation")
+	}
+	if err := service.ConfirmExternalLink(ctx, token); !errors.Is(err, ErrInvalidLinkToken) {
+		t.Errorf("reused confirmation token: got %v, want ErrInvalidLinkToken", err)
+	}
+
+	// Once linked, the provider logs straight in
+	response, err := service.LoginWithExternalIdentity(ctx, githubIdentity("confirm@example.com"))
+	if err != nil || response.Token == "" {
+		t.Errorf("login after linking = %+v, %v; want tokens", response, err)
+	}
+
+	got := linkDecisions(publisher)
+	if len(got) != 2 || got[0] != "confirmation_requested" || got[1] != "confirmed" {
+		t.Errorf("link decisions = %v, want [confirmation_requested confirmed]", got)
+	}
+}
+
+func TestLinkPolicyReject(t *testing.T) {
+	service := linkPolicyService(t, config.LinkPolicyReject)
+	publisher := captureEvents(service)
+	registered := registerTestUser(t, service, "reject@example.com", "reject")
+	ctx := context.Background()
+
+	if _, err := service.LoginWithExternalIdentity(ctx, githubIdentity("reject@example.com")); !errors.Is(err, ErrAccountLinkRejected) {
+		t.Fatalf("got %v, want ErrAccountLinkRejected", err)
+	}
+	user, _ := service.userStore.GetUserByID(ctx, registered.User.ID)
+	if user.HasProvider("github", "gh-42") {
+		t.Error("provider linked despite the reject policy")
+	}
+
+	got := publisher.ofType(events.TypeLinkDecision)
+	if len(got) != 1 || got[0].Details["decision"] != "rejected" || got[0].Outcome != events.OutcomeFailure {
+		t.Errorf("link events = %+v, want one failed rejected decision", got)
+	}
+}
+
+func TestExternalLoginNewEmailCreatesAccount(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.OAuth.DuplicateEmailPolicy = config.LinkPolicyReject
+		cfg.Auth.EmailVerification.Required = true
+	})
+	ctx := context.Background()
+
+	response, err := service.LoginWithExternalIdentity(ctx, githubIdentity("new@example.com"))
+	if err != nil {
+		t.Fatalf("LoginWithExternalIdentity: %v", err)
+	}
+	user, err := service.userStore.GetUserByEmail(ctx, "new@example.com")
+	if err != nil || user.ID != response.User.ID || !user.HasProvider("github", "gh-42") {
+		t.Errorf("created user = %+v, %v; want the provider linked", user, err)
+	}
+
+	// The provider vouched for the address, so there is nothing left to verify
+	if user.VerificationPending || user.EmailVerifiedAt == nil || response.Token == "" {
+		t.Errorf("created user pending %v, verified at %v; want a verified account with tokens", user.VerificationPending, user.EmailVerifiedAt)
+	}
+
+	inviteOnly := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.InviteOnly = true
+	})
+	if _, err := inviteOnly.LoginWithExternalIdentity(ctx, githubIdentity("new@example.com")); !errors.Is(err, ErrInviteRequired) {
+		t.Errorf("invite-only: got %v, want ErrInviteRequired", err)
+	}
+}
+
+func TestExternalLoginUnverifiedEmailCreatesNoAccount(t *testing.T) {
+	service := linkPolicyService(t, config.LinkPolicyReject)
+	publisher := captureEvents(service)
+	ctx := context.Background()
+
+	identity := githubIdentity("victim@example.com")
+	identity.EmailVerified = false
+	if _, err := service.LoginWithExternalIdentity(ctx, identity); !errors.Is(err, ErrProviderEmailUnverified) {
+		t.Fatalf("got %v, want ErrProviderEmailUnverified", err)
+	}
+	if _, err := service.userStore.GetUserByEmail(ctx, "victim@example.com"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("GetUserByEmail: got %v, want no account", err)
+	}
+
+	// The owner of the address can still register it
+	registerTestUser(t, service, "victim@example.com", "victim")
+
+	got := linkDecisions(publisher)
+	if len(got) != 1 || got[0] != "rejected_unverified_email" {
+		t.Errorf("link decisions = %v, want [rejected_unverified_email]", got)
+	}
+}
+
+func TestExternalLoginUnverifiedEmailSendsNoConfirmation(t *testing.T) {
+	service := linkPolicyService(t, config.LinkPolicyRequireConfirmation)
+	mail := captureEmails(service)
+	registerTestUser(t, service, "owner@example.com", "owner")
+	sent := len(mail.bodies)
+
+	identity := githubIdentity("owner@example.com")
+	identity.EmailVerified = false
+	if _, err := service.LoginWithExternalIdentity(context.Background(), identity); !errors.Is(err, ErrProviderEmailUnverified) {
+		t.Fatalf("got %v, want ErrProviderEmailUnverified", err)
+	}
+	if len(mail.bodies) != sent {
+		t.Errorf("sent %d emails, want none", len(mail.bodies)-sent)
+	}
+}
+
+func TestExternalLoginFindsLinkedAccountByProviderIdentity(t *testing.T) {
+	service := autoLinkService(t)
+	ctx := context.Background()
+
+	first, err := service.LoginWithExternalIdentity(ctx, githubIdentity("before@example.com"))
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	// The user changes their email here; the provider still reports the old one
+	user, _ := service.userStore.GetUserByID(ctx, first.User.ID)
+	user.Email = "after@example.com"
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	// Even unverified, the provider identity is what matches the account
+	identity := githubIdentity("before@example.com")
+	identity.EmailVerified = false
+	second, err := service.LoginWithExternalIdentity(ctx, identity)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if second.User.ID != first.User.ID {
+		t.Errorf("second login user %s, want the linked account %s", second.User.ID, first.User.ID)
+	}
+	if _, err := service.userStore.GetUserByEmail(ctx, "before@example.com"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("GetUserByEmail(old address): got %v, want no duplicate account", err)
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/oauth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tracing"
+)
+
+var (
+	ErrProviderNotConfigured = errors.New("oauth provider is not configured")
+	ErrOAuthExchangeFailed   = errors.New("oauth provider exchange failed")
+)
+
+// OAuthAuthURL starts a login with a provider, returning the consent URL
+// and the state the callback must present
+func (s *Service) OAuthAuthURL(providerName string) (string, string, error) {
+	provider, ok := s.oauthProviders.Get(providerName)
+	if !ok {
+		return "", "", ErrProviderNotConfigured
+	}
+
+	state, err := s.newSecretToken()
+	if err != nil {
+		return "", "", err
+	}
+	return provider.AuthCodeURL(state), state, nil
+}
+
+// LoginWithOAuth exchanges an authorization code for the user's profile
+// with the provider and logs them in, creating or linking an account as needed
+func (s *Service) LoginWithOAuth(ctx context.Context, providerName, code string) (response *LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "auth.LoginWithOAuth")
+	defer tracing.End(span, &err)
+
+	provider, ok := s.oauthProviders.Get(providerName)
+	if !ok {
+		return nil, ErrProviderNotConfigured
+	}
+
+	token, err := provider.Exchange(ctx, code)
+	if err != nil {
+		return nil, oauthError(err)
+	}
+
+	profile, err := provider.FetchUserInfo(ctx, token.AccessToken)
+	if err != nil {
+		return nil, oauthError(err)
+	}
+
+	return s.LoginWithExternalIdentity(ctx, &ExternalIdentity{
+		Provider:       provider.Name,
+		ProviderUserID: profile.ID,
+		Email:          storage.NormalizeEmail(profile.Email),
+		EmailVerified:  profile.EmailVerified,
+		Username:       profile.Username,
+		FirstName:      profile.FirstName,
+		LastName:       profile.LastName,
+	})
+}
+
+// OAuthProviders returns the names of the configured login providers
+func (s *Service) OAuthProviders() []string {
+	return s.oauthProviders.Names()
+}
+
+// oauthError maps provider failures to ErrOAuthExchangeFailed, keeping
+// other errors such as context cancellation intact
+func oauthError(err error) error {
+	if errors.Is(err, oauth.ErrExchangeFailed) {
+		return ErrOAuthExchangeFailed
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// newTestIdP starts an OpenID Connect provider that accepts the code
+// "good-code" and returns a fixed profile
+func newTestIdP(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "idp-access", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer idp-access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub":            "acme-1",
+			"email":          "sso@example.com",
+			"email_verified": true,
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newOAuthRouter serves the OAuth login routes of a service with the test
+// IdP registered as the acme provider
+func newOAuthRouter(t *testing.T) *gin.Engine {
+	idp := newTestIdP(t)
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.OAuth.Providers = map[string]config.OAuthProviderConfig{
+			"acme": {
+				ClientID:    "client",
+				RedirectURL: "http://localhost/api/auth/oauth/acme/callback",
+				AuthURL:     idp.URL + "/authorize",
+				TokenURL:    idp.URL + "/token",
+				UserInfoURL: idp.URL + "/userinfo",
+			},
+		}
+	})
+
+	h := NewHandler(service)
+	router := gin.New()
+	router.GET("/api/auth/oauth/:provider", h.OAuthLogin)
+	router.GET("/api/auth/oauth/:provider/callback", h.OAuthCallback)
+	return router
+}
+
+// startOAuthLogin starts a login with the acme provider and returns the
+// state cookie it set
+func startOAuthLogin(t *testing.T, router http.Handler) *http.Cookie {
+	t.Helper()
+
+	w := doRequest(t, router, http.MethodGet, "/api/auth/oauth/acme", nil, nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("start login: status %d, want 302", w.Code)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oauthStateCookie {
+			if cookie.Path != "/api/auth/oauth/acme" || !cookie.HttpOnly {
+				t.Errorf("state cookie path %q, HttpOnly %v; want the provider's path and HttpOnly", cookie.Path, cookie.HttpOnly)
+			}
+			if location.Query().Get("state") != cookie.Value {
+				t.Errorf("redirect state %q doesn't match the cookie", location.Query().Get("state"))
+			}
+			return cookie
+		}
+	}
+	t.Fatal("start login set no state cookie")
+	return nil
+}
+
+func oauthCallback(t *testing.T, router http.Handler, query url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	headers := map[string]string{}
+	if cookie != nil {
+		headers["Cookie"] = cookie.Name + "=" + cookie.Value
+	}
+	return doRequest(t, router, http.MethodGet, "/api/auth/oauth/acme/callback?"+query.Encode(), nil, headers)
+}
+
+func TestOAuthCallbackWithMatchingState(t *testing.T) {
+	router := newOAuthRouter(t)
+	cookie := startOAuthLogin(t, router)
+
+	w := oauthCallback(t, router, url.Values{"state": {cookie.Value}, "code": {"good-code"}}, cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback: status %d, want 200: %s", w.Code, w.Body)
+	}
+	var response LoginResponse
+	decodeData(t, w, &response)
+	if response.Token == "" || response.User.Email != "sso@example.com" {
+		t.Errorf("response = %+v, want tokens for sso@example.com", response)
+	}
+
+	// The state is single use, the callback clears the cookie
+	cleared := false
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oauthStateCookie && c.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Error("callback didn't clear the state cookie")
+	}
+}
+
+func TestOAuthCallbackRejectsBadState(t *testing.T) {
+	router := newOAuthRouter(t)
+	cookie := startOAuthLogin(t, router)
+	other := startOAuthLogin(t, router)
+
+	for name, tc := range map[string]struct {
+		query  url.Values
+		cookie *http.Cookie
+	}{
+		"no cookie":       {url.Values{"state": {cookie.Value}, "code": {"good-code"}}, nil},
+		"no state":        {url.Values{"code": {"good-code"}}, cookie},
+		"other state":     {url.Values{"state": {other.Value}, "code": {"good-code"}}, cookie},
+		"forged state":    {url.Values{"state": {"forged"}, "code": {"good-code"}}, &http.Cookie{Name: oauthStateCookie}},
+		"consent refused": {url.Values{"state": {cookie.Value}, "error": {"access_denied"}}, cookie},
+	} {
+		if w := oauthCallback(t, router, tc.query, tc.cookie); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, w.Code)
+		}
+	}
+}
+
+func TestOAuthCallbackProviderErrors(t *testing.T) {
+	router := newOAuthRouter(t)
+	cookie := startOAuthLogin(t, router)
+
+	if w := oauthCallback(t, router, url.Values{"state": {cookie.Value}, "code": {"bad-code"}}, cookie); w.Code != http.StatusBadGateway {
+		t.Errorf("rejected code: status %d, want 502", w.Code)
+	}
+	if w := doRequest(t, router, http.MethodGet, "/api/auth/oauth/unknown", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown provider: status %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tracing"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/webauthn"
+)
+
+var (
+	ErrInvalidPasskeySession = errors.New("invalid or expired passkey session")
+	ErrPasskeyRejected       = errors.New("passkey was rejected")
+	ErrPasskeyCloned         = errors.New("passkey may have been cloned")
+)
+
+// defaultPasskeyName labels passkeys registered without a name
+const defaultPasskeyName = "Passkey"
+
+// BeginPasskeyRegistration starts registering a passkey for a user and
+// returns the options for the browser
+func (s *Service) BeginPasskeyRegistration(ctx context.Context, userID string) (*PasskeyOptionsResponse, error) {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	ceremony, err := s.passkeys.BeginRegistration(user)
+	if err != nil {
+		return nil, err
+	}
+	return s.savePasskeyCeremony(ceremony, storage.TokenPurposePasskeyRegistration, user.ID)
+}
+
+// FinishPasskeyRegistration verifies the browser's response to a
+// registration started by the same user and stores the new passkey
+func (s *Service) FinishPasskeyRegistration(ctx context.Context, userID string, req *PasskeyRegistrationRequest) (*PasskeyInfo, error) {
+	stored, err := s.tokenStore.ConsumeToken(req.SessionID, storage.TokenPurposePasskeyRegistration)
+	if err != nil || stored.UserID != userID {
+		return nil, ErrInvalidPasskeySession
+	}
+
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	credential, err := s.passkeys.FinishRegistration(user, []byte(stored.Data), req.Credential)
+	if err != nil {
+		return nil, s.passkeyError(ctx, err)
+	}
+
+	credential.Name = req.Name
+	if credential.Name == "" {
+		credential.Name = defaultPasskeyName
+	}
+	user.WebAuthnCredentials = append(user.WebAuthnCredentials, *credential)
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypePasskeyAdded,
+		Outcome: events.OutcomeSuccess,
+		UserID:  user.ID,
+		Email:   user.Email,
+		Details: map[string]string{"name": credential.Name},
+	})
+
+	return &PasskeyInfo{
+		ID:        base64.RawURLEncoding.EncodeToString(credential.ID),
+		Name:      credential.Name,
+		CreatedAt: credential.CreatedAt,
+	}, nil
+}
+
+// BeginPasskeyLogin starts a passwordless login and returns the options for
+// the browser. The account is identified by the passkey the user picks.
+func (s *Service) BeginPasskeyLogin(ctx context.Context) (*PasskeyOptionsResponse, error) {
+	ceremony, err := s.passkeys.BeginLogin()
+	if err != nil {
+		return nil, err
+	}
+	return s.savePasskeyCeremony(ceremony, storage.TokenPurposePasskeyLogin, "")
+}
+
+// FinishPasskeyLogin verifies the browser's response to a passkey login and
+// returns a login response. Passkeys require user verification, so they
+// satisfy two-factor authentication on their own.
+func (s *Service) FinishPasskeyLogin(ctx context.Context, req *PasskeyLoginRequest) (response *LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "auth.FinishPasskeyLogin")
+	defer tracing.End(span, &err)
+
+	stored, err := s.tokenStore.ConsumeToken(req.SessionID, storage.TokenPurposePasskeyLogin)
+	if err != nil {
+		return nil, ErrInvalidPasskeySession
+	}
+
+	user, credential, err := s.passkeys.FinishLogin([]byte(stored.Data), req.Credential, func(userID string) (*storage.User, error) {
+		return s.userStore.GetUserByID(ctx, userID)
+	})
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrPasskeyRejected
+		}
+		if errors.Is(err, webauthn.ErrClonedAuthenticator) {
+			logging.FromContext(ctx).Warn("Rejected passkey whose signature counter went backwards", "user_id", user.ID)
+			return nil, ErrPasskeyCloned
+		}
+		return nil, s.passkeyError(ctx, err)
+	}
+
+	if !user.IsActive || user.OrgID != tenant.FromContext(ctx) {
+		return nil, ErrPasskeyRejected
+	}
+
+	// A locked account stays locked whichever factor is presented
+	if err := s.checkAccountLock(user); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	credential.LastUsedAt = &now
+	for i := range user.WebAuthnCredentials {
+		if bytes.Equal(user.WebAuthnCredentials[i].ID, credential.ID) {
+			user.WebAuthnCredentials[i] = *credential
+		}
+	}
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return s.issueLoginResponse(ctx, user, req.RememberMe)
+}
+
+// savePasskeyCeremony keeps a ceremony's session data until it finishes
+func (s *Service) savePasskeyCeremony(ceremony *webauthn.Ceremony, purpose, userID string) (*PasskeyOptionsResponse, error) {
+	sessionID, err := s.newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenStore.SaveToken(&storage.VerificationToken{
+		Token:     sessionID,
+		Purpose:   purpose,
+		UserID:    userID,
+		Data:      string(ceremony.Session),
+		ExpiresAt: time.Now().Add(s.config.WebAuthn.Timeout),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &PasskeyOptionsResponse{SessionID: sessionID, Options: ceremony.Options}, nil
+}
+
+// passkeyError maps verification failures to ErrPasskeyRejected, logging
+// the library's reason since clients only get a generic message
+func (s *Service) passkeyError(ctx context.Context, err error) error {
+	if errors.Is(err, webauthn.ErrVerificationFailed) {
+		logging.FromContext(ctx).Debug("Passkey verification failed", "error", err)
+		return ErrPasskeyRejected
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+)
+
+// RuleNotBreached is reported when a password appears in known data breaches
+const RuleNotBreached = "not_breached"
+
+// ErrBreachCheckUnavailable is returned for new passwords when the breach
+// check can't be completed and it isn't configured to fail open
+var ErrBreachCheckUnavailable = errors.New("password breach check unavailable")
+
+// PwnedRangeClient fetches one range of the Have I Been Pwned password API:
+// the breached hashes starting with a five character SHA-1 prefix, as
+// "SUFFIX:COUNT" lines. Only the prefix leaves the server.
+type PwnedRangeClient interface {
+	Range(ctx context.Context, prefix string) (io.ReadCloser, error)
+}
+
+// httpPwnedRangeClient queries the range API over HTTP
+type httpPwnedRangeClient struct {
+	client *http.Client
+	apiURL string
+}
+
+// newPwnedRangeClient creates the range client for the configured API, or
+// returns nil when the breach check is disabled
+func newPwnedRangeClient(cfg config.BreachCheckConfig) PwnedRangeClient {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &httpPwnedRangeClient{
+		client: &http.Client{Timeout: cfg.Timeout},
+		apiURL: strings.TrimSuffix(cfg.APIURL, "/") + "/",
+	}
+}
+
+// Range requests the hashes starting with prefix. Padding is asked for so
+// response sizes don't hint at the prefix.
+func (c *httpPwnedRangeClient) Range(ctx context.Context, prefix string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiURL+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Add-Padding", "true")
+	req.Header.Set("User-Agent", "login-app")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("password range API returned %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// breachCount returns how many breaches a password appears in according to
+// the range client
+func breachCount(ctx context.Context, client PwnedRangeClient, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	body, err := client.Range(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		// Padding entries have a count of zero
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("invalid count in password range response: %q", count)
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
+
+// validateNewPassword checks a password being set against the password
+// policy and, when enabled, the breach check. The breach check only runs
+// for passwords that pass the local rules.
+func (s *Service) validateNewPassword(ctx context.Context, password string) error {
+	policy := s.config.Auth.PasswordPolicy
+	if err := ValidatePassword(password, policy); err != nil {
+		return err
+	}
+	if s.pwnedRanges == nil {
+		return nil
+	}
+
+	count, err := breachCount(ctx, s.pwnedRanges, password)
+	if err != nil {
+		if policy.BreachCheck.FailOpen {
+			logging.FromContext(ctx).Warn("Password breach check failed, accepting password", "error", err)
+			return nil
+		}
+		logging.FromContext(ctx).Error("Password breach check failed", "error", err)
+		return ErrBreachCheckUnavailable
+	}
+
+	if count >= policy.BreachCheck.Threshold {
+		return &PasswordPolicyError{Violations: []PasswordRuleViolation{
+			{Rule: RuleNotBreached, Message: "has appeared in a data breach"},
+		}}
+	}
+	return nil
+}
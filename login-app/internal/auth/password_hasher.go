@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	// Official Go cryptography library for secure password hashing
+	// Provides the bcrypt and argon2id algorithms
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+var (
+	ErrPasswordMismatch    = errors.New("password does not match")
+	ErrUnknownHashFormat   = errors.New("unknown password hash format")
+	errMalformedArgon2Hash = errors.New("malformed argon2id hash")
+)
+
+// PasswordHasher hashes passwords with one algorithm and cost. Hashes are
+// self-describing: bcrypt hashes start with "$2", argon2id hashes use the
+// PHC string format starting with "$argon2id$", so any hash can be
+// verified whichever hasher is configured.
+type PasswordHasher interface {
+	// Hash returns a salted hash of the password
+	Hash(password string) (string, error)
+
+	// NeedsRehash reports whether a hash was made with a different
+	// algorithm or weaker parameters than this hasher uses
+	NeedsRehash(hash string) bool
+}
+
+// newPasswordHasher returns the hasher selected by the configuration
+func newPasswordHasher(cfg config.AuthConfig) PasswordHasher {
+	if cfg.PasswordHasher == config.PasswordHasherArgon2id {
+		return &argon2idHasher{
+			memory:      uint32(cfg.Argon2.MemoryKiB),
+			iterations:  uint32(cfg.Argon2.Iterations),
+			parallelism: uint8(cfg.Argon2.Parallelism),
+		}
+	}
+	return &bcryptHasher{cost: cfg.BCryptCost}
+}
+
+// verifyPasswordHash checks a password against a hash made by any supported
+// algorithm, returning ErrPasswordMismatch when it doesn't match
+func verifyPasswordHash(hash, password string) error {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		return verifyArgon2id(hash, password)
+	case strings.HasPrefix(hash, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return ErrPasswordMismatch
+		}
+		return err
+	default:
+		return ErrUnknownHashFormat
+	}
+}
+
+// bcryptHasher hashes passwords with bcrypt
+type bcryptHasher struct {
+	cost int
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedBytes), nil
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.cost
+}
+
+// argon2idPrefix starts every argon2id hash
+const argon2idPrefix = "$argon2id$"
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// argon2idHasher hashes passwords with argon2id
+type argon2idHasher struct {
+	memory      uint32 // KiB
+	iterations  uint32
+	parallelism uint8
+}
+
+// argon2Params are the parameters encoded in an argon2id hash
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *argon2idHasher) NeedsRehash(hash string) bool {
+	params, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.memory < h.memory || params.iterations < h.iterations || params.parallelism < h.parallelism
+}
+
+// verifyArgon2id recomputes the key with the hash's own parameters
+func verifyArgon2id(hash, password string) error {
+	params, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	if subtle.ConstantTimeCompare(key, params.key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// parseArgon2id parses a PHC-format argon2id hash:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func parseArgon2id(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errMalformedArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errMalformedArgon2Hash
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, errMalformedArgon2Hash
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errMalformedArgon2Hash
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, errMalformedArgon2Hash
+	}
+	if params.iterations == 0 || params.parallelism == 0 {
+		return nil, errMalformedArgon2Hash
+	}
+
+	return &params, nil
+}
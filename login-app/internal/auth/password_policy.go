@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// Password policy rule identifiers reported in PasswordPolicyError
+const (
+	RuleMinLength = "min_length"
+	RuleUppercase = "uppercase"
+	RuleLowercase = "lowercase"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleCommon    = "not_common"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords holds the lowercased embedded list of frequently used passwords
+var commonPasswords = func() map[string]bool {
+	passwords := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			passwords[strings.ToLower(line)] = true
+		}
+	}
+	return passwords
+}()
+
+// PasswordRuleViolation describes one failed password policy rule
+type PasswordRuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password failed
+type PasswordPolicyError struct {
+	Violations []PasswordRuleViolation
+}
+
+// Error implements the error interface
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return "password does not meet requirements: " + strings.Join(messages, "; ")
+}
+
+// Is lets errors.Is match a policy error against ErrWeakPassword
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// ValidatePassword checks a password against the policy and returns a
+// *PasswordPolicyError enumerating every failed rule
+func ValidatePassword(password string, policy config.PasswordPolicy) error {
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	var violations []PasswordRuleViolation
+	if len([]rune(password)) < policy.MinLength {
+		violations = append(violations, PasswordRuleViolation{
+			Rule:    RuleMinLength,
+			Message: "must be at least " + strconv.Itoa(policy.MinLength) + " characters",
+		})
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordRuleViolation{Rule: RuleUppercase, Message: "must contain an uppercase letter"})
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, PasswordRuleViolation{Rule: RuleLowercase, Message: "must contain a lowercase letter"})
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordRuleViolation{Rule: RuleDigit, Message: "must contain a digit"})
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordRuleViolation{Rule: RuleSymbol, Message: "must contain a symbol"})
+	}
+	if policy.RejectCommon && commonPasswords[strings.ToLower(password)] {
+		violations = append(violations, PasswordRuleViolation{Rule: RuleCommon, Message: "is too common"})
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// violatedRules returns the rules a ValidatePassword error reports
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("got %v, want a PasswordPolicyError", err)
+	}
+	rules := make([]string, 0, len(policyErr.Violations))
+	for _, violation := range policyErr.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestValidatePasswordRules(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy   config.PasswordPolicy
+		password string
+		want     []string
+	}{
+		"too short":       {config.PasswordPolicy{MinLength: 8}, "Ab1!", []string{RuleMinLength}},
+		"long enough":     {config.PasswordPolicy{MinLength: 8}, "abcdefgh", nil},
+		"length in runes": {config.PasswordPolicy{MinLength: 4}, "ééé", []string{RuleMinLength}},
+		"no uppercase":    {config.PasswordPolicy{RequireUpper: true}, "lower1!x", []string{RuleUppercase}},
+		"uppercase":       {config.PasswordPolicy{RequireUpper: true}, "Upper", nil},
+		"no lowercase":    {config.PasswordPolicy{RequireLower: true}, "UPPER1!X", []string{RuleLowercase}},
+		"lowercase":       {config.PasswordPolicy{RequireLower: true}, "lower", nil},
+		"no digit":        {config.PasswordPolicy{RequireDigit: true}, "NoDigits!", []string{RuleDigit}},
+		"digit":           {config.PasswordPolicy{RequireDigit: true}, "d1git", nil},
+		"no symbol":       {config.PasswordPolicy{RequireSymbol: true}, "NoSymbol1", []string{RuleSymbol}},
+		"symbol":          {config.PasswordPolicy{RequireSymbol: true}, "sym#bol", nil},
+		"space is symbol": {config.PasswordPolicy{RequireSymbol: true}, "two words", nil},
+		"every rule": {
+			config.PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true},
+			"", []string{RuleMinLength, RuleUppercase, RuleLowercase, RuleDigit, RuleSymbol},
+		},
+		"rules off": {config.PasswordPolicy{}, "", nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := violatedRules(t, ValidatePassword(tc.password, tc.policy)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ValidatePassword(%q) violations = %v, want %v", tc.password, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordRejectsCommonPasswords(t *testing.T) {
+	policy := config.PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true, RejectCommon: true}
+
+	// Passes every character rule but is on the list, in any case
+	for _, password := range []string{"Password1!", "pAsSwOrD1!"} {
+		err := ValidatePassword(password, policy)
+		if got := violatedRules(t, err); !reflect.DeepEqual(got, []string{RuleCommon}) {
+			t.Errorf("ValidatePassword(%q) violations = %v, want only %s", password, got, RuleCommon)
+		}
+		if !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("ValidatePassword(%q) doesn't match ErrWeakPassword", password)
+		}
+	}
+
+	policy.RejectCommon = false
+	if err := ValidatePassword("Password1!", policy); err != nil {
+		t.Errorf("common password with the check off: %v", err)
+	}
+}
+
+func TestRegisterReportsPolicyViolations(t *testing.T) {
+	service := newTestService(t, nil)
+	router := gin.New()
+	router.POST("/register", NewHandler(service).Register)
+
+	w := doRequest(t, router, http.MethodPost, "/register", RegisterRequest{
+		Email: "weak@example.com", Username: "weak", Password: "123456", FirstName: "Test", LastName: "User",
+	}, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Details []PasswordRuleViolation `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	rules := make(map[string]bool)
+	for _, violation := range response.Details {
+		rules[violation.Rule] = true
+	}
+	for _, rule := range []string{RuleMinLength, RuleUppercase, RuleLowercase, RuleCommon} {
+		if !rules[rule] {
+			t.Errorf("details %v don't report %s", response.Details, rule)
+		}
+	}
+
+	if _, err := service.userStore.GetUserByEmail(context.Background(), "weak@example.com"); err == nil {
+		t.Error("the account was created anyway")
+	}
+}
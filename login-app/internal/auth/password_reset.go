@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tracing"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid reset token")
+	ErrResetTokenExpired = errors.New("reset token expired")
+)
+
+// RequestPasswordReset issues a single-use password reset token for the
+// account with the given email. To avoid account enumeration it returns an
+// empty token and no error when no active account matches.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) (resetToken string, err error) {
+	ctx, span := tracing.Start(ctx, "auth.RequestPasswordReset")
+	defer tracing.End(span, &err)
+
+	user, err := s.userStore.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if !user.IsActive {
+		return "", nil
+	}
+
+	token, err := s.issueResetToken(user)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.sendEmail(user.Email, "Reset your password", "password_reset.html", map[string]interface{}{
+		"Token":     token,
+		"ExpiresIn": formatTTL(s.config.Auth.PasswordResetTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// issueResetToken issues a new password reset token for a user. Stored
+// tokens are saved and only the most recently issued one stays valid;
+// stateless ones are signed and all stay valid until they expire or the
+// password changes.
+func (s *Service) issueResetToken(user *storage.User) (string, error) {
+	if s.config.Auth.PasswordResetMode == config.PasswordResetStateless {
+		return s.issueStatelessResetToken(user), nil
+	}
+
+	if err := s.tokenStore.DeleteUserTokens(user.ID, storage.TokenPurposePasswordReset); err != nil {
+		return "", err
+	}
+
+	token, err := s.newSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.tokenStore.SaveToken(&storage.VerificationToken{
+		Token:     token,
+		Purpose:   storage.TokenPurposePasswordReset,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.config.Auth.PasswordResetTTL),
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResetPassword sets a new password using a reset token. A stored token is
+// consumed whether or not the reset succeeds; a stateless one stops working
+// once the password changes. Refresh tokens are always revoked; with
+// RevokeSessionsOnPasswordChange every session is logged out too, which it
+// reports.
+func (s *Service) ResetPassword(ctx context.Context, token, newPassword string) (revoked bool, err error) {
+	ctx, span := tracing.Start(ctx, "auth.ResetPassword")
+	defer tracing.End(span, &err)
+
+	// Check the password first so a rejected one doesn't use up the token
+	if err := s.validateNewPassword(ctx, newPassword); err != nil {
+		return false, err
+	}
+
+	user, err := s.resetTokenUser(ctx, token)
+	if err != nil {
+		return false, err
+	}
+
+	if !user.IsActive {
+		return false, ErrInvalidResetToken
+	}
+
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return false, err
+	}
+
+	user.PasswordHash = hashedPassword
+	user.MustChangePassword = false
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return false, err
+	}
+
+	// Whoever held the old password shouldn't keep a long-lived session
+	if s.config.Auth.RevokeSessionsOnPasswordChange {
+		if err := s.revokeOtherSessions(user.ID, "", "password_reset"); err != nil {
+			return false, err
+		}
+		revoked = true
+	}
+	if err := s.refreshStore.DeleteUserRefreshTokens(user.ID); err != nil {
+		return false, err
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypePasswordReset,
+		Outcome: events.OutcomeSuccess,
+		UserID:  user.ID,
+		Email:   user.Email,
+	})
+	return revoked, nil
+}
+
+// resetTokenUser checks a reset token in the configured mode and returns
+// the user it was issued to
+func (s *Service) resetTokenUser(ctx context.Context, token string) (*storage.User, error) {
+	if s.config.Auth.PasswordResetMode == config.PasswordResetStateless {
+		return s.verifyStatelessResetToken(ctx, token)
+	}
+
+	stored, err := s.tokenStore.ConsumeToken(token, storage.TokenPurposePasswordReset)
+	if err != nil {
+		if err == storage.ErrTokenExpired {
+			return nil, ErrResetTokenExpired
+		}
+		if err == storage.ErrTokenNotFound {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, err
+	}
+
+	user, err := s.userStore.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, err
+	}
+	return user, nil
+}
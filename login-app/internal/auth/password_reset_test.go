@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// resetTokenPattern matches the token shown in a password reset email
+var resetTokenPattern = regexp.MustCompile(`<code[^>]*>([^<]+)</code>`)
+
+func TestPasswordReset(t *testing.T) {
+	service := newTestService(t, nil)
+	registerTestUser(t, service, "reset@example.com", "reset")
+	ctx := context.Background()
+
+	token, err := service.RequestPasswordReset(ctx, "reset@example.com")
+	if err != nil || token == "" {
+		t.Fatalf("RequestPasswordReset: %q, %v", token, err)
+	}
+	if _, err := service.ResetPassword(ctx, token, newTestPassword); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	if _, err := service.Login(ctx, &LoginRequest{Email: "reset@example.com", Password: newTestPassword}, "192.0.2.1"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+	if _, err := service.Login(ctx, &LoginRequest{Email: "reset@example.com", Password: testPassword}, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("login with the old password: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestPasswordResetTokenIsSingleUse(t *testing.T) {
+	service := newTestService(t, nil)
+	registerTestUser(t, service, "reuse@example.com", "reuse")
+	ctx := context.Background()
+
+	token, _ := service.RequestPasswordReset(ctx, "reuse@example.com")
+	if _, err := service.ResetPassword(ctx, token, newTestPassword); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := service.ResetPassword(ctx, token, "Another3#z"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reused token: got %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestPasswordResetOnlyLatestTokenIsValid(t *testing.T) {
+	service := newTestService(t, nil)
+	registerTestUser(t, service, "latest@example.com", "latest")
+	ctx := context.Background()
+
+	first, _ := service.RequestPasswordReset(ctx, "latest@example.com")
+	second, _ := service.RequestPasswordReset(ctx, "latest@example.com")
+
+	if _, err := service.ResetPassword(ctx, first, newTestPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("superseded token: got %v, want ErrInvalidResetToken", err)
+	}
+	if _, err := service.ResetPassword(ctx, second, newTestPassword); err != nil {
+		t.Errorf("latest token: %v", err)
+	}
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.PasswordResetTTL = -time.Second
+	})
+	registerTestUser(t, service, "expired@example.com", "expired")
+
+	token, err := service.RequestPasswordReset(context.Background(), "expired@example.com")
+	if err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	if _, err := service.ResetPassword(context.Background(), token, newTestPassword); !errors.Is(err, ErrResetTokenExpired) {
+		t.Errorf("got %v, want ErrResetTokenExpired", err)
+	}
+}
+
+func TestPasswordResetRejectedPasswordKeepsToken(t *testing.T) {
+	service := newTestService(t, nil)
+	registerTestUser(t, service, "weak@example.com", "weak")
+	ctx := context.Background()
+
+	token, _ := service.RequestPasswordReset(ctx, "weak@example.com")
+	var policyErr *PasswordPolicyError
+	if _, err := service.ResetPassword(ctx, token, "short"); !errors.As(err, &policyErr) {
+		t.Fatalf("weak password: got %v, want a PasswordPolicyError", err)
+	}
+	if _, err := service.ResetPassword(ctx, token, newTestPassword); err != nil {
+		t.Errorf("token after a rejected password: %v", err)
+	}
+}
+
+func TestPasswordResetUnknownEmail(t *testing.T) {
+	service := newTestService(t, nil)
+	sent := captureEmails(service)
+
+	token, err := service.RequestPasswordReset(context.Background(), "nobody@example.com")
+	if token != "" || err != nil {
+		t.Errorf("RequestPasswordReset = %q, %v; want no token and no error", token, err)
+	}
+	if len(sent.bodies) != 0 {
+		t.Errorf("%d emails sent for an unknown address", len(sent.bodies))
+	}
+}
+
+func TestForgotPasswordRespondsTheSameForUnknownEmail(t *testing.T) {
+	service := newTestService(t, nil)
+	sent := captureEmails(service)
+	registerTestUser(t, service, "known@example.com", "known")
+	h := NewHandler(service)
+	router := gin.New()
+	router.POST("/forgot-password", h.ForgotPassword)
+	router.POST("/reset-password", h.ResetPassword)
+
+	known := doRequest(t, router, http.MethodPost, "/forgot-password", ForgotPasswordRequest{Email: "known@example.com"}, nil)
+	unknown := doRequest(t, router, http.MethodPost, "/forgot-password", ForgotPasswordRequest{Email: "unknown@example.com"}, nil)
+	if known.Code != http.StatusOK || unknown.Code != http.StatusOK {
+		t.Fatalf("statuses %d and %d, want 200 for both", known.Code, unknown.Code)
+	}
+	if known.Body.String() != unknown.Body.String() {
+		t.Errorf("responses differ:\n%s\n%s", known.Body.String(), unknown.Body.String())
+	}
+	if len(sent.bodies) != 1 {
+		t.Fatalf("%d emails sent, want 1 for the known address", len(sent.bodies))
+	}
+
+	match := resetTokenPattern.FindStringSubmatch(sent.bodies[0])
+	if match == nil {
+		t.Fatalf("no reset token in email: %s", sent.bodies[0])
+	}
+	token := match[1]
+	if w := doRequest(t, router, http.MethodPost, "/reset-password", ResetPasswordRequest{Token: token, Password: newTestPassword}, nil); w.Code != http.StatusOK {
+		t.Errorf("reset: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(t, router, http.MethodPost, "/reset-password", ResetPasswordRequest{Token: token, Password: newTestPassword}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("reused token: status %d, want 400", w.Code)
+	}
+}
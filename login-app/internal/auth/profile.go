@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+// UpdateProfile changes a user's name and username. Empty fields are left
+// unchanged. Email changes go through RequestEmailChange instead so the new
+// address is verified first.
+func (s *Service) UpdateProfile(ctx context.Context, userID string, req *UpdateProfileRequest) (*UserInfo, error) {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if req.Username != "" {
+		user.Username = req.Username
+	}
+	if req.FirstName != "" {
+		user.FirstName = req.FirstName
+	}
+	if req.LastName != "" {
+		user.LastName = req.LastName
+	}
+
+	// The store enforces username uniqueness
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		if err == storage.ErrUserExists {
+			return nil, ErrUserExists
+		}
+		if err == storage.ErrUserNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	userInfo := s.userToUserInfo(user)
+	return &userInfo, nil
+}
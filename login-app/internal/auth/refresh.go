@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tracing"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// Refresh exchanges a refresh token for a new access token. The refresh token
+// is rotated: the presented token is invalidated and a new one is issued.
+// Presenting an already-used token is treated as a replay and revokes the
+// whole rotation family.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (response *LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "auth.Refresh")
+	defer tracing.End(span, &err)
+
+	tokenHash := storage.HashToken(refreshToken)
+
+	stored, err := s.refreshStore.GetRefreshToken(tokenHash)
+	if err != nil {
+		if err == storage.ErrRefreshTokenNotFound {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if err := s.refreshStore.MarkRefreshTokenUsed(tokenHash); err != nil {
+		if err == storage.ErrRefreshTokenUsed {
+			slog.Warn("Refresh token reuse detected, revoking token family",
+				"user_id", stored.UserID, "family_id", stored.FamilyID)
+			s.publishEvent(events.Event{
+				Type:    events.TypeTokenReuse,
+				Outcome: events.OutcomeFailure,
+				UserID:  stored.UserID,
+			})
+			if err := s.refreshStore.DeleteRefreshTokenFamily(stored.FamilyID); err != nil {
+				return nil, err
+			}
+			s.publishEvent(events.Event{
+				Type:    events.TypeTokenRevoked,
+				Outcome: events.OutcomeSuccess,
+				UserID:  stored.UserID,
+				Details: map[string]string{"reason": "refresh_token_reuse", "family_id": stored.FamilyID},
+			})
+			return nil, ErrRefreshTokenReused
+		}
+		if err == storage.ErrRefreshTokenNotFound {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Make sure the account still exists and is active
+	user, err := s.userStore.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	// Refresh tokens, like access tokens, only work in the user's organization
+	if !user.IsActive || user.OrgID != tenant.FromContext(ctx) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Keep the lifetime the login was started with
+	session, err := s.sessionStore.GetSession(stored.FamilyID)
+	if err != nil {
+		if err == storage.ErrSessionNotFound {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	return s.issueTokens(user, stored.FamilyID, session.RememberMe)
+}
+
+// generateRefreshToken creates and stores a new refresh token for a user
+// in the given rotation family
+func (s *Service) generateRefreshToken(user *storage.User, familyID string) (string, error) {
+	token, err := s.newSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.refreshStore.CreateRefreshToken(&storage.RefreshToken{
+		TokenHash: storage.HashToken(token),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(s.config.Auth.RefreshTokenDuration),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
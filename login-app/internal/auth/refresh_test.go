@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+)
+
+func TestRefreshRotatesToken(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "rotate@example.com", "rotate")
+	ctx := context.Background()
+
+	refreshed, err := service.Refresh(ctx, registered.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.Token == "" || refreshed.RefreshToken == "" || refreshed.RefreshToken == registered.RefreshToken {
+		t.Fatalf("Refresh = %+v, want a new access and refresh token", refreshed)
+	}
+	if _, err := service.ValidateToken(ctx, refreshed.Token); err != nil {
+		t.Errorf("refreshed access token: %v", err)
+	}
+
+	// The rotated token keeps working down the chain
+	again, err := service.Refresh(ctx, refreshed.RefreshToken)
+	if err != nil {
+		t.Fatalf("second Refresh: %v", err)
+	}
+	if again.RefreshToken == refreshed.RefreshToken {
+		t.Error("second refresh returned the same refresh token")
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	service := newTestService(t, nil)
+	publisher := captureEvents(service)
+	registered := registerTestUser(t, service, "reuse@example.com", "reuse")
+	ctx := context.Background()
+
+	other, err := service.Login(ctx, &LoginRequest{Email: "reuse@example.com", Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+
+	refreshed, err := service.Refresh(ctx, registered.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	if _, err := service.Refresh(ctx, registered.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed token: got %v, want ErrRefreshTokenReused", err)
+	}
+	if n := len(publisher.ofType(events.TypeTokenReuse)); n != 1 {
+		t.Errorf("published %d token reuse events, want 1", n)
+	}
+
+	// The replay revokes the token the legitimate client was given too
+	if _, err := service.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("token rotated before the replay: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Other logins are separate families and keep working
+	if _, err := service.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("other login's refresh token: %v", err)
+	}
+}
+
+func TestRefreshRejectsUnknownAndInactive(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "inactive@example.com", "inactive")
+	ctx := context.Background()
+
+	if _, err := service.Refresh(ctx, "not-a-token"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	user, _ := service.userStore.GetUserByID(ctx, registered.User.ID)
+	user.IsActive = false
+	if err := service.userStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := service.Refresh(ctx, registered.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("inactive user: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "handler@example.com", "handler")
+
+	router := gin.New()
+	router.POST("/refresh", NewHandler(service).Refresh)
+
+	w := doRequest(t, router, http.MethodPost, "/refresh", RefreshRequest{RefreshToken: registered.RefreshToken}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d, want 200: %s", w.Code, w.Body)
+	}
+	var response LoginResponse
+	decodeData(t, w, &response)
+	if response.RefreshToken == "" || response.RefreshToken == registered.RefreshToken {
+		t.Errorf("refresh returned %q, want a new refresh token", response.RefreshToken)
+	}
+
+	for name, body := range map[string]any{
+		"replayed": RefreshRequest{RefreshToken: registered.RefreshToken},
+		"unknown":  RefreshRequest{RefreshToken: "not-a-token"},
+	} {
+		if w := doRequest(t, router, http.MethodPost, "/refresh", body, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s token: status %d, want 401", name, w.Code)
+		}
+	}
+	if w := doRequest(t, router, http.MethodPost, "/refresh", map[string]string{}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("missing token: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// raceRegistrations fires n registrations for the same email at once and
+// returns how many succeeded and how many got ErrUserExists
+func raceRegistrations(t *testing.T, service *Service, n int) (created, conflicts int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, err := service.Register(context.Background(), &RegisterRequest{
+				Email:     "race@example.com",
+				Username:  fmt.Sprintf("racer%d", i),
+				Password:  testPassword,
+				FirstName: "Test",
+				LastName:  "User",
+			}, "192.0.2.1")
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				created++
+			case errors.Is(err, ErrUserExists):
+				conflicts++
+			default:
+				t.Errorf("Register: %v", err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return created, conflicts
+}
+
+func TestConcurrentRegistrationsSameEmail(t *testing.T) {
+	const n = 50
+	created, conflicts := raceRegistrations(t, newTestService(t, nil), n)
+	if created != 1 || conflicts != n-1 {
+		t.Errorf("%d created and %d conflicts, want 1 and %d", created, conflicts, n-1)
+	}
+}
+
+func TestConcurrentRegistrationHandlersSameEmail(t *testing.T) {
+	const n = 50
+	h := NewHandler(newTestService(t, nil))
+	router := gin.New()
+	router.POST("/register", h.Register)
+
+	statuses := make(chan int, n)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			w := doRequest(t, router, http.MethodPost, "/register", RegisterRequest{
+				Email:     "race@example.com",
+				Username:  fmt.Sprintf("racer%d", i),
+				Password:  testPassword,
+				FirstName: "Test",
+				LastName:  "User",
+			}, nil)
+			statuses <- w.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != n-1 {
+		t.Errorf("statuses = %v, want one 201 and %d 409s", counts, n-1)
+	}
+}
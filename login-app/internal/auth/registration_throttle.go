@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// SubnetThrottledError is returned when a client's subnet has registered
+// as many accounts as allowed within the window
+type SubnetThrottledError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *SubnetThrottledError) Error() string {
+	return fmt.Sprintf("too many registrations from this network, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// subnetThrottle counts registrations per client subnet. Abusive signups
+// often rotate through the addresses of one network, which per-IP limits
+// don't catch.
+type subnetThrottle struct {
+	mu      sync.Mutex
+	entries map[netip.Prefix]*subnetThrottleEntry
+}
+
+type subnetThrottleEntry struct {
+	count       int
+	windowStart time.Time
+}
+
+func newSubnetThrottle() *subnetThrottle {
+	return &subnetThrottle{
+		entries: make(map[netip.Prefix]*subnetThrottleEntry),
+	}
+}
+
+// registrationSubnet returns the configured subnet containing a client IP.
+// IPv4-mapped IPv6 addresses count as IPv4.
+func registrationSubnet(ip string, cfg config.RegistrationThrottleConfig) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	bits := cfg.IPv6Prefix
+	if addr.Is4() {
+		bits = cfg.IPv4Prefix
+	}
+	prefix, err := addr.Prefix(bits)
+	return prefix, err == nil
+}
+
+// check returns a SubnetThrottledError while the subnet has used up its
+// registrations for the current window
+func (t *subnetThrottle) check(subnet netip.Prefix, max int, window time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	entry, exists := t.entries[subnet]
+	if !exists || now.Sub(entry.windowStart) >= window || entry.count < max {
+		return nil
+	}
+
+	retryAfter := entry.windowStart.Add(window).Sub(now)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &SubnetThrottledError{RetryAfter: retryAfter}
+}
+
+// record counts a registration from the subnet. The window starts with the
+// first registration after the previous one ended.
+func (t *subnetThrottle) record(subnet netip.Prefix, window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if len(t.entries) >= maxThrottleEntries {
+		t.prune(now, window)
+	}
+
+	entry, exists := t.entries[subnet]
+	if !exists || now.Sub(entry.windowStart) >= window {
+		entry = &subnetThrottleEntry{windowStart: now}
+		t.entries[subnet] = entry
+	}
+	entry.count++
+}
+
+// prune removes entries whose window has ended
+func (t *subnetThrottle) prune(now time.Time, window time.Duration) {
+	for subnet, entry := range t.entries {
+		if now.Sub(entry.windowStart) >= window {
+			delete(t.entries, subnet)
+		}
+	}
+}
+
+// checkRegistrationSubnet rejects registrations from a subnet that has
+// reached its limit. It returns the subnet to record a successful
+// registration against, or false when the throttle doesn't apply.
+func (s *Service) checkRegistrationSubnet(clientIP string) (netip.Prefix, bool, error) {
+	cfg := s.config.Auth.RegistrationThrottle
+	if cfg.MaxPerSubnet <= 0 {
+		return netip.Prefix{}, false, nil
+	}
+	subnet, ok := registrationSubnet(clientIP, cfg)
+	if !ok {
+		return netip.Prefix{}, false, nil
+	}
+	if err := s.subnetThrottle.check(subnet, cfg.MaxPerSubnet, cfg.Window); err != nil {
+		return netip.Prefix{}, false, err
+	}
+	return subnet, true, nil
+}
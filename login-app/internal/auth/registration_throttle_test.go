@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+func subnetThrottledService(t *testing.T, max int, window time.Duration) *Service {
+	return newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.RegistrationThrottle.MaxPerSubnet = max
+		cfg.Auth.RegistrationThrottle.Window = window
+	})
+}
+
+// registerFrom registers a new user numbered n from clientIP
+func registerFrom(service *Service, n int, clientIP string) error {
+	_, err := service.Register(context.Background(), &RegisterRequest{
+		Email:     fmt.Sprintf("user%d@example.com", n),
+		Username:  fmt.Sprintf("user%d", n),
+		Password:  testPassword,
+		FirstName: "Test",
+		LastName:  "User",
+	}, clientIP)
+	return err
+}
+
+func TestSubnetThrottleAcrossAddresses(t *testing.T) {
+	service := subnetThrottledService(t, 3, time.Hour)
+
+	// Three addresses in one /24 use up its registrations
+	for i, ip := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		if err := registerFrom(service, i, ip); err != nil {
+			t.Fatalf("registration %d from %s: %v", i, ip, err)
+		}
+	}
+
+	var throttled *SubnetThrottledError
+	if err := registerFrom(service, 3, "198.51.100.200"); !errors.As(err, &throttled) {
+		t.Fatalf("fourth registration from the subnet: got %v, want a SubnetThrottledError", err)
+	}
+	if throttled.RetryAfter <= 0 || throttled.RetryAfter > time.Hour {
+		t.Errorf("RetryAfter = %s, want within the window", throttled.RetryAfter)
+	}
+
+	// Other networks are unaffected
+	for i, ip := range []string{"198.51.101.1", "203.0.113.1"} {
+		if err := registerFrom(service, 10+i, ip); err != nil {
+			t.Errorf("registration from %s: %v", ip, err)
+		}
+	}
+}
+
+func TestSubnetThrottleIPv6(t *testing.T) {
+	service := subnetThrottledService(t, 2, time.Hour)
+
+	for i, ip := range []string{"2001:db8:1:1::1", "2001:db8:1:1:ffff::2"} {
+		if err := registerFrom(service, i, ip); err != nil {
+			t.Fatalf("registration from %s: %v", ip, err)
+		}
+	}
+	var throttled *SubnetThrottledError
+	if err := registerFrom(service, 2, "2001:db8:1:1::3"); !errors.As(err, &throttled) {
+		t.Errorf("third registration from the /64: got %v, want a SubnetThrottledError", err)
+	}
+	if err := registerFrom(service, 3, "2001:db8:1:2::1"); err != nil {
+		t.Errorf("registration from another /64: %v", err)
+	}
+}
+
+func TestSubnetThrottleCountsMappedIPv4AsIPv4(t *testing.T) {
+	service := subnetThrottledService(t, 1, time.Hour)
+
+	if err := registerFrom(service, 0, "198.51.100.1"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	var throttled *SubnetThrottledError
+	if err := registerFrom(service, 1, "::ffff:198.51.100.2"); !errors.As(err, &throttled) {
+		t.Errorf("IPv4-mapped address in the same /24: got %v, want a SubnetThrottledError", err)
+	}
+}
+
+func TestSubnetThrottleOnlyCountsRegistrations(t *testing.T) {
+	service := subnetThrottledService(t, 1, time.Hour)
+	registerTestUser(t, service, "taken@example.com", "taken")
+
+	// A rejected registration doesn't use up the subnet's allowance
+	_, err := service.Register(context.Background(), &RegisterRequest{
+		Email: "taken@example.com", Username: "other", Password: testPassword, FirstName: "Test", LastName: "User",
+	}, "198.51.100.1")
+	if !errors.Is(err, ErrUserExists) {
+		t.Fatalf("duplicate registration: got %v, want ErrUserExists", err)
+	}
+	if err := registerFrom(service, 0, "198.51.100.2"); err != nil {
+		t.Errorf("first successful registration: %v", err)
+	}
+}
+
+func TestSubnetThrottleWindowEnds(t *testing.T) {
+	service := subnetThrottledService(t, 1, 20*time.Millisecond)
+
+	if err := registerFrom(service, 0, "198.51.100.1"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	var throttled *SubnetThrottledError
+	if err := registerFrom(service, 1, "198.51.100.2"); !errors.As(err, &throttled) {
+		t.Fatalf("got %v, want a SubnetThrottledError", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := registerFrom(service, 2, "198.51.100.3"); err != nil {
+		t.Errorf("registration after the window: %v", err)
+	}
+}
+
+func TestRegisterHandlerSubnetThrottled(t *testing.T) {
+	service := subnetThrottledService(t, 2, time.Hour)
+	router := gin.New()
+	router.POST("/register", NewHandler(service).Register)
+
+	statuses := make([]int, 0, 3)
+	for i, ip := range []string{"198.51.100.10", "198.51.100.20", "198.51.100.30"} {
+		w := doRequest(t, router, http.MethodPost, "/register", RegisterRequest{
+			Email: fmt.Sprintf("user%d@example.com", i), Username: fmt.Sprintf("user%d", i), Password: testPassword, FirstName: "Test", LastName: "User",
+		}, map[string]string{"X-Forwarded-For": ip})
+		statuses = append(statuses, w.Code)
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Error("429 without Retry-After")
+		}
+	}
+	if statuses[0] != http.StatusCreated || statuses[1] != http.StatusCreated || statuses[2] != http.StatusTooManyRequests {
+		t.Errorf("statuses = %v, want [201 201 429]", statuses)
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// assertExpiresIn checks that a login response and its token both expire
+// about d from now
+func assertExpiresIn(t *testing.T, service *Service, response *LoginResponse, d time.Duration) {
+	t.Helper()
+
+	want := time.Now().Add(d)
+	if diff := response.ExpiresAt.Sub(want); diff < -time.Minute || diff > time.Minute {
+		t.Errorf("ExpiresAt = %v, want about %v", response.ExpiresAt, want)
+	}
+	claims, err := service.parseToken(response.Token)
+	if err != nil {
+		t.Fatalf("parseToken: %v", err)
+	}
+	if !claims.ExpiresAt.Time.Equal(response.ExpiresAt.Truncate(time.Second)) {
+		t.Errorf("token exp %v doesn't match ExpiresAt %v", claims.ExpiresAt.Time, response.ExpiresAt)
+	}
+}
+
+func TestRememberMeTokenLifetimes(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.TokenDuration = time.Hour
+		cfg.Auth.RememberMeDuration = 30 * 24 * time.Hour
+	})
+	registerTestUser(t, service, "remember@example.com", "remember")
+	ctx := context.Background()
+
+	normal, err := service.Login(ctx, &LoginRequest{Email: "remember@example.com", Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	remembered, err := service.Login(ctx, &LoginRequest{Email: "remember@example.com", Password: testPassword, RememberMe: true}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Login with RememberMe: %v", err)
+	}
+
+	assertExpiresIn(t, service, normal, time.Hour)
+	assertExpiresIn(t, service, remembered, 30*24*time.Hour)
+	for name, response := range map[string]*LoginResponse{"normal": normal, "remembered": remembered} {
+		if _, err := service.ValidateToken(ctx, response.Token); err != nil {
+			t.Errorf("%s token: %v", name, err)
+		}
+	}
+
+	// Refreshing keeps the lifetime the session was started with
+	refreshed, err := service.Refresh(ctx, remembered.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	assertExpiresIn(t, service, refreshed, 30*24*time.Hour)
+}
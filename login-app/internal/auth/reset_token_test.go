@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+const newTestPassword = "Changed2@y"
+
+func statelessResetService(t *testing.T, key string) *Service {
+	return newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.PasswordResetMode = config.PasswordResetStateless
+		cfg.Auth.SecretEncryptionKey = key
+	})
+}
+
+func TestStatelessResetTokenStopsWorkingAfterReset(t *testing.T) {
+	service := statelessResetService(t, "reset-signing-key")
+	registerTestUser(t, service, "stateless@example.com", "stateless")
+	ctx := context.Background()
+
+	first, err := service.RequestPasswordReset(ctx, "stateless@example.com")
+	if err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	second, _ := service.RequestPasswordReset(ctx, "stateless@example.com")
+
+	if _, err := service.ResetPassword(ctx, first, newTestPassword); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := service.Login(ctx, &LoginRequest{Email: "stateless@example.com", Password: newTestPassword}, "192.0.2.1"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+
+	// Both tokens were bound to the old password hash
+	for name, token := range map[string]string{"used": first, "unused": second} {
+		if _, err := service.ResetPassword(ctx, token, "Another3#z"); !errors.Is(err, ErrInvalidResetToken) {
+			t.Errorf("%s token after the reset: got %v, want ErrInvalidResetToken", name, err)
+		}
+	}
+}
+
+func TestStatelessResetTokenIssuedBeforePasswordChange(t *testing.T) {
+	service := statelessResetService(t, "reset-signing-key")
+	registered := registerTestUser(t, service, "changed@example.com", "changed")
+	ctx := context.Background()
+
+	token, err := service.RequestPasswordReset(ctx, "changed@example.com")
+	if err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	if _, err := service.ChangePassword(ctx, registered.User.ID, "", testPassword, newTestPassword, false); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	if _, err := service.ResetPassword(ctx, token, "Another3#z"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("got %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestStatelessResetTokenSignedWithAnotherKey(t *testing.T) {
+	service := statelessResetService(t, "reset-signing-key")
+	registerTestUser(t, service, "forged@example.com", "forged")
+	ctx := context.Background()
+
+	// A token signed with the published default JWT secret, as an attacker
+	// could compute it, doesn't verify under the configured key
+	token, err := service.RequestPasswordReset(ctx, "forged@example.com")
+	if err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	service.config.Auth.SecretEncryptionKey = ""
+	user, _ := service.userStore.GetUserByEmail(ctx, "forged@example.com")
+	forged := service.issueStatelessResetToken(user)
+	service.config.Auth.SecretEncryptionKey = "reset-signing-key"
+
+	if _, err := service.ResetPassword(ctx, forged, newTestPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("token signed with the JWT secret: got %v, want ErrInvalidResetToken", err)
+	}
+
+	// Changing any part of a genuine token breaks its signature
+	parts := strings.Split(token, ".")
+	later := parts[0] + "." + "9999999999" + "." + parts[2]
+	if _, err := service.ResetPassword(ctx, later, newTestPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("token with an extended expiry: got %v, want ErrInvalidResetToken", err)
+	}
+
+	if _, err := service.ResetPassword(ctx, token, newTestPassword); err != nil {
+		t.Errorf("genuine token: %v", err)
+	}
+}
+
+func TestStatelessResetTokenExpires(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.PasswordResetMode = config.PasswordResetStateless
+		cfg.Auth.SecretEncryptionKey = "reset-signing-key"
+		cfg.Auth.PasswordResetTTL = -time.Second
+	})
+	registerTestUser(t, service, "late@example.com", "late")
+
+	token, err := service.RequestPasswordReset(context.Background(), "late@example.com")
+	if err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	if _, err := service.ResetPassword(context.Background(), token, newTestPassword); !errors.Is(err, ErrResetTokenExpired) {
+		t.Errorf("got %v, want ErrResetTokenExpired", err)
+	}
+}
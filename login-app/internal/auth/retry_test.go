@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+// deadlockingStore fails every other GetUserByEmail call with a transient
+// error, as a database under lock contention might
+type deadlockingStore struct {
+	storage.UserStore
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *deadlockingStore) GetUserByEmail(ctx context.Context, emailAddress string) (*storage.User, error) {
+	s.mu.Lock()
+	s.calls++
+	fail := s.calls%2 == 1
+	s.mu.Unlock()
+
+	if fail {
+		return nil, fmt.Errorf("%w: deadlock detected", storage.ErrTransient)
+	}
+	return s.UserStore.GetUserByEmail(ctx, emailAddress)
+}
+
+func TestLoginRetriesTransientStoreErrors(t *testing.T) {
+	cfg, err := config.Load("test")
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	flaky := &deadlockingStore{UserStore: storage.NewMemoryUserStore()}
+	users := storage.NewRetryingUserStore(flaky, storage.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	service, err := NewService(users, storage.SessionStores{}, cfg, nil, email.LogSender{}, nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+
+	registerTestUser(t, service, "retry@example.com", "retry")
+	flaky.calls = 0
+	if _, err := service.Login(context.Background(), &LoginRequest{Email: "retry@example.com", Password: testPassword}, "192.0.2.1"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if flaky.calls < 2 {
+		t.Errorf("the store was called %d times, want the failed lookup retried", flaky.calls)
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newLogoutRouter(service *Service) *gin.Engine {
+	h := NewHandler(service)
+	router := gin.New()
+	router.POST("/logout", h.Logout)
+	router.GET("/profile", h.Middleware(), h.Profile)
+	return router
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "logout@example.com", "logout")
+	router := newLogoutRouter(service)
+
+	if w := doRequest(t, router, http.MethodGet, "/profile", nil, bearer(registered.Token)); w.Code != http.StatusOK {
+		t.Fatalf("profile before logout: status %d, want 200", w.Code)
+	}
+
+	w := doRequest(t, router, http.MethodPost, "/logout", LogoutRequest{RefreshToken: registered.RefreshToken}, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout: status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	// The token hasn't expired, but its jti is blacklisted
+	if _, err := service.ValidateToken(context.Background(), registered.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ValidateToken after logout: got %v, want ErrTokenRevoked", err)
+	}
+	if w := doRequest(t, router, http.MethodGet, "/profile", nil, bearer(registered.Token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("profile after logout: status %d, want 401", w.Code)
+	}
+	if _, err := service.Refresh(context.Background(), registered.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logout: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutOnlyRevokesPresentedToken(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "other@example.com", "other")
+	ctx := context.Background()
+
+	other, err := service.Login(ctx, &LoginRequest{Email: "other@example.com", Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+
+	if err := service.RevokeToken(registered.Token); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := service.ValidateToken(ctx, other.Token); err != nil {
+		t.Errorf("other login's token: %v", err)
+	}
+	if _, err := service.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("other login's refresh token: %v", err)
+	}
+
+	if err := service.RevokeToken("not-a-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("malformed token: got %v, want ErrInvalidToken", err)
+	}
+	if err := service.RevokeRefreshToken("not-a-token"); err != nil {
+		t.Errorf("unknown refresh token: %v", err)
+	}
+}
+
+func TestLogoutWithoutToken(t *testing.T) {
+	service := newTestService(t, nil)
+	router := newLogoutRouter(service)
+
+	// Logging out twice, or without a session, still succeeds
+	for _, headers := range []map[string]string{nil, bearer("not-a-token")} {
+		if w := doRequest(t, router, http.MethodPost, "/logout", nil, headers); w.Code != http.StatusOK {
+			t.Errorf("logout with %v: status %d, want 200", headers, w.Code)
+		}
+	}
+}
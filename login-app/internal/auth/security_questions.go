@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tracing"
+)
+
+var (
+	ErrSecurityQuestionsDisabled = errors.New("security question recovery is not enabled")
+	ErrSecurityQuestionsNotSet   = errors.New("security question recovery is not available for this account")
+	ErrInvalidSecurityQuestions  = errors.New("invalid security questions")
+	ErrWrongSecurityAnswers      = errors.New("security answers do not match")
+)
+
+// SetSecurityQuestions replaces a user's security questions after
+// re-confirming their password. Exactly the configured number of distinct
+// questions must be given; answers are stored hashed like passwords.
+func (s *Service) SetSecurityQuestions(ctx context.Context, userID string, req *SecurityQuestionsRequest) error {
+	cfg := s.config.Auth.SecurityQuestions
+	if !cfg.Enabled {
+		return ErrSecurityQuestionsDisabled
+	}
+
+	if len(req.Questions) != cfg.Count {
+		return ErrInvalidSecurityQuestions
+	}
+	seen := make(map[string]bool, len(req.Questions))
+	for _, qa := range req.Questions {
+		question := normalizeAnswer(qa.Question)
+		if question == "" || normalizeAnswer(qa.Answer) == "" || seen[question] {
+			return ErrInvalidSecurityQuestions
+		}
+		seen[question] = true
+	}
+
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	if err := s.verifyPassword(user.PasswordHash, req.Password); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	questions := make([]storage.QuestionAnswer, 0, len(req.Questions))
+	for _, qa := range req.Questions {
+		hash, err := s.hashPassword(normalizeAnswer(qa.Answer))
+		if err != nil {
+			return err
+		}
+		questions = append(questions, storage.QuestionAnswer{
+			Question:   strings.TrimSpace(qa.Question),
+			AnswerHash: hash,
+		})
+	}
+
+	user.SecurityQuestions = questions
+	user.RecoveryAttempts = 0
+	user.RecoveryLockedUntil = time.Time{}
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypeQuestionsSet,
+		Outcome: events.OutcomeSuccess,
+		UserID:  user.ID,
+		Email:   user.Email,
+	})
+	return nil
+}
+
+// SecurityQuestions returns the questions to answer to recover the account
+// with the given email. Unknown, inactive and accounts without questions
+// all get ErrSecurityQuestionsNotSet.
+func (s *Service) SecurityQuestions(ctx context.Context, email string) ([]string, error) {
+	user, err := s.recoverableUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	questions := make([]string, 0, len(user.SecurityQuestions))
+	for _, qa := range user.SecurityQuestions {
+		questions = append(questions, qa.Question)
+	}
+	return questions, nil
+}
+
+// VerifySecurityAnswers checks answers to an account's security questions,
+// in order, and issues a password reset token when all of them match.
+// Repeated wrong answers lock recovery for the account with a LockedError.
+func (s *Service) VerifySecurityAnswers(ctx context.Context, email string, answers []string) (resetToken string, err error) {
+	ctx, span := tracing.Start(ctx, "auth.VerifySecurityAnswers")
+	defer tracing.End(span, &err)
+
+	user, err := s.recoverableUser(ctx, email)
+	if err != nil {
+		return "", err
+	}
+
+	if time.Now().Before(user.RecoveryLockedUntil) {
+		return "", newLockedError(user.RecoveryLockedUntil)
+	}
+
+	// Check every answer so the time taken doesn't reveal which one failed
+	matched := len(answers) == len(user.SecurityQuestions)
+	for i, qa := range user.SecurityQuestions {
+		answer := ""
+		if i < len(answers) {
+			answer = normalizeAnswer(answers[i])
+		}
+		if verifyPasswordHash(qa.AnswerHash, answer) != nil {
+			matched = false
+		}
+	}
+	if !matched {
+		return "", s.recordFailedRecovery(ctx, user)
+	}
+
+	if user.RecoveryAttempts != 0 || !user.RecoveryLockedUntil.IsZero() {
+		user.RecoveryAttempts = 0
+		user.RecoveryLockedUntil = time.Time{}
+		if err := s.userStore.UpdateUser(ctx, user); err != nil {
+			return "", err
+		}
+	}
+
+	token, err := s.issueResetToken(user)
+	if err != nil {
+		return "", err
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypeRecoveryVerified,
+		Outcome: events.OutcomeSuccess,
+		UserID:  user.ID,
+		Email:   user.Email,
+	})
+	return token, nil
+}
+
+// recoverableUser looks up an active account with security questions set
+func (s *Service) recoverableUser(ctx context.Context, email string) (*storage.User, error) {
+	if !s.config.Auth.SecurityQuestions.Enabled {
+		return nil, ErrSecurityQuestionsDisabled
+	}
+
+	user, err := s.userStore.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrSecurityQuestionsNotSet
+		}
+		return nil, err
+	}
+
+	if !user.IsActive || len(user.SecurityQuestions) == 0 {
+		return nil, ErrSecurityQuestionsNotSet
+	}
+	return user, nil
+}
+
+// recordFailedRecovery counts a wrong set of answers and locks recovery
+// once the configured number of attempts is reached
+func (s *Service) recordFailedRecovery(ctx context.Context, user *storage.User) error {
+	user.RecoveryAttempts++
+
+	failure := ErrWrongSecurityAnswers
+	if user.RecoveryAttempts >= s.config.Auth.SecurityQuestions.MaxAttempts {
+		user.RecoveryAttempts = 0
+		user.RecoveryLockedUntil = time.Now().Add(s.config.Auth.LockoutDuration)
+		failure = newLockedError(user.RecoveryLockedUntil)
+
+		s.publishEvent(events.Event{
+			Type:    events.TypeAccountLocked,
+			Outcome: events.OutcomeFailure,
+			UserID:  user.ID,
+			Email:   user.Email,
+			Details: map[string]string{
+				"locked_until": user.RecoveryLockedUntil.UTC().Format(time.RFC3339),
+				"reason":       "security_questions",
+			},
+		})
+	}
+
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	return failure
+}
+
+// normalizeAnswer folds case and whitespace so "New  York" matches "new york"
+func normalizeAnswer(answer string) string {
+	return strings.ToLower(strings.Join(strings.Fields(answer), " "))
+}
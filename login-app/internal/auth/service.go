@@ -1,8 +1,7 @@
 package auth
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"context"
 	"errors"
 	"time"
 
@@ -10,20 +9,29 @@ import (
 	// Enterprise-grade implementation of RFC 7519 JSON Web Token standard
 	"github.com/golang-jwt/jwt/v5"
 
-	// Official Go cryptography library for secure password hashing
-	// Uses bcrypt algorithm for enterprise-grade password security
-	"golang.org/x/crypto/bcrypt"
-
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/audit"
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/ids"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/lifecycle"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/oauth"
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tokens"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tracing"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/webauthn"
 )
 
 var (
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrTokenExpired       = errors.New("token expired")
+	ErrTokenRevoked       = errors.New("token revoked")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrUserExists         = errors.New("user already exists")
+	ErrWeakPassword       = errors.New("password does not meet requirements")
 )
 
 // JWTClaims extends the basic claims with JWT standard claims
@@ -31,32 +39,206 @@ type JWTClaims struct {
 	UserID   string `json:"user_id"`
 	Email    string `json:"email"`
 	Username string `json:"username"`
+
+	// SessionID identifies the login session the token was issued for
+	SessionID string `json:"sid,omitempty"`
+
+	// OrgID is the organization the token is valid in; empty for the default
+	OrgID string `json:"org_id,omitempty"`
+
+	// Metadata is the user's metadata under the configured token keys
+	Metadata map[string]string `json:"metadata,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // Service handles authentication business logic
 type Service struct {
-	userStore storage.UserStore
-	config    *config.Config
+	userStore      storage.UserStore
+	tokenStore     storage.VerificationTokenStore
+	apiKeyStore    storage.APIKeyStore
+	inviteStore    storage.InviteStore
+	refreshStore   storage.RefreshTokenStore
+	revokedStore   storage.RevokedTokenStore
+	sessionStore   storage.SessionStore
+	ipThrottle     *ipThrottle
+	subnetThrottle *subnetThrottle
+	loginBackoff   *loginBackoff
+	loginTiming    *loginTiming
+	keys           *keyRing
+	tokens         *tokens.Generator
+	ids            *ids.Generator
+	hasher         PasswordHasher
+	pwnedRanges    PwnedRangeClient // nil unless the password breach check is enabled
+	captcha        CaptchaVerifier  // nil unless registration requires a CAPTCHA
+	events         events.Publisher
+	mailer         email.Sender
+	// oauthProviders are the external login providers
+	oauthProviders *oauth.Registry
+	passkeys       *webauthn.RelyingParty
+	auditLog       audit.AuditLog
+	config         *config.Config
 }
 
-// NewService creates a new authentication service
-func NewService(userStore storage.UserStore, cfg *config.Config) *Service {
-	return &Service{
-		userStore: userStore,
-		config:    cfg,
+// NewService creates a new authentication service. Its background workers,
+// such as the in-memory store sweepers, run on workers; with nil workers
+// none are started, which suits short-lived commands.
+func NewService(userStore storage.UserStore, stores storage.SessionStores, cfg *config.Config, publisher events.Publisher, mailer email.Sender, workers *lifecycle.Manager) (*Service, error) {
+	if publisher == nil {
+		publisher = events.NopPublisher{}
+	}
+	if mailer == nil {
+		mailer = email.LogSender{}
+	}
+
+	keys, err := newKeyRing(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenGen, err := tokens.New(cfg.Auth.Tokens.Bytes, cfg.Auth.Tokens.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	idGen, err := ids.New(cfg.Storage.IDFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	// In-memory stores expire their entries with sweepers, shared stores
+	// are expected to expire them on their own
+	sweepInterval := cfg.Auth.RevocationSweepInterval
+	if stores.RevokedTokens == nil {
+		revokedStore := storage.NewMemoryRevokedTokenStore()
+		if workers != nil {
+			workers.Go("revoked_token_sweeper", func(ctx context.Context) {
+				revokedStore.RunSweeper(ctx, sweepInterval)
+			})
+		}
+		stores.RevokedTokens = revokedStore
+	}
+	if stores.Sessions == nil {
+		sessionStore := storage.NewMemorySessionStore()
+		if workers != nil {
+			workers.Go("session_sweeper", func(ctx context.Context) {
+				sessionStore.RunSweeper(ctx, sweepInterval)
+			})
+		}
+		stores.Sessions = sessionStore
+	}
+	if stores.RefreshTokens == nil {
+		refreshStore := storage.NewMemoryRefreshTokenStore()
+		if workers != nil {
+			workers.Go("refresh_token_sweeper", func(ctx context.Context) {
+				refreshStore.RunSweeper(ctx, sweepInterval)
+			})
+		}
+		stores.RefreshTokens = refreshStore
+	}
+
+	oauthProviders, err := oauth.NewRegistry(cfg.OAuth.Providers)
+	if err != nil {
+		return nil, err
 	}
+
+	passkeys, err := webauthn.New(cfg.WebAuthn, cfg.Email.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		userStore:      userStore,
+		tokenStore:     storage.NewMemoryVerificationTokenStore(),
+		apiKeyStore:    storage.NewMemoryAPIKeyStore(),
+		inviteStore:    storage.NewMemoryInviteStore(),
+		refreshStore:   stores.RefreshTokens,
+		revokedStore:   stores.RevokedTokens,
+		sessionStore:   stores.Sessions,
+		ipThrottle:     newIPThrottle(),
+		subnetThrottle: newSubnetThrottle(),
+		loginBackoff:   newLoginBackoff(),
+		loginTiming:    &loginTiming{minDuration: cfg.Auth.LoginMinDuration},
+		keys:           keys,
+		tokens:         tokenGen,
+		ids:            idGen,
+		hasher:         newPasswordHasher(cfg.Auth),
+		pwnedRanges:    newPwnedRangeClient(cfg.Auth.PasswordPolicy.BreachCheck),
+		captcha:        newCaptchaVerifier(cfg.Auth.Captcha),
+		events:         publisher,
+		mailer:         mailer,
+		oauthProviders: oauthProviders,
+		passkeys:       passkeys,
+		auditLog:       audit.NewMemoryAuditLog(audit.DefaultMaxEntries),
+		config:         cfg,
+	}, nil
 }
 
-// Register creates a new user account
-func (s *Service) Register(req *RegisterRequest) (*LoginResponse, error) {
-	// Check if user already exists
-	if _, err := s.userStore.GetUserByEmail(req.Email); err == nil {
-		return nil, ErrUserExists
+// Register creates a new user account. While registration is invite-only
+// it uses up the request's invite code, which must belong to its email,
+// unless the email is a configured admin email.
+func (s *Service) Register(ctx context.Context, req *RegisterRequest, clientIP string) (response *LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "auth.Register")
+	defer tracing.End(span, &err)
+
+	subnet, throttled, err := s.checkRegistrationSubnet(clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCaptcha(ctx, req.CaptchaToken, clientIP); err != nil {
+		return nil, err
+	}
+
+	// Configured admin emails don't need an invite, so the first
+	// administrator can sign up
+	role := s.roleForEmail(req.Email)
+
+	var invite *storage.Invite
+	if s.config.Auth.InviteOnly && role != storage.RoleAdmin {
+		if invite, err = s.useInvite(ctx, req.InviteCode, req.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.createUser(ctx, req, role, s.config.Auth.EmailVerification.Required)
+	if err != nil {
+		// Leave the invite for a corrected attempt
+		if invite != nil {
+			if releaseErr := s.inviteStore.ReleaseInvite(invite.ID); releaseErr != nil {
+				logging.FromContext(ctx).Warn("Failed to release invite", "invite_id", invite.ID, "error", releaseErr)
+			}
+		}
+		return nil, err
+	}
+
+	// Only accounts actually created count against the subnet; concurrent
+	// registrations can overshoot the limit slightly
+	if throttled {
+		s.subnetThrottle.record(subnet, s.config.Auth.RegistrationThrottle.Window)
+	}
+
+	if user.VerificationPending {
+		// The account exists either way; the user can ask for another link
+		if _, err := s.sendEmailVerification(user); err != nil {
+			logging.FromContext(ctx).Warn("Failed to send verification email", "user_id", user.ID, "error", err)
+		}
+		if err := s.checkEmailVerification(user); err != nil {
+			userInfo := s.userToUserInfo(user)
+			return &LoginResponse{User: &userInfo, EmailVerificationRequired: true}, nil
+		}
 	}
 
-	if _, err := s.userStore.GetUserByUsername(req.Username); err == nil {
-		return nil, ErrUserExists
+	return s.issueLoginResponse(ctx, user, false)
+}
+
+// createUser validates the password, checks for duplicates and stores a new
+// account with the given role. Self-registered accounts are created with
+// their email verification pending when verification is required.
+func (s *Service) createUser(ctx context.Context, req *RegisterRequest, role string, verificationPending bool) (*storage.User, error) {
+	req.Email = storage.NormalizeEmail(req.Email)
+
+	if err := s.validateNewPassword(ctx, req.Password); err != nil {
+		return nil, err
 	}
 
 	// Hash password
@@ -74,110 +256,295 @@ func (s *Service) Register(req *RegisterRequest) (*LoginResponse, error) {
 	// Create user
 	user := &storage.User{
 		ID:           userID,
+		OrgID:        tenant.FromContext(ctx),
 		Email:        req.Email,
 		Username:     req.Username,
 		PasswordHash: hashedPassword,
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
+		Role:         role,
+
+		VerificationPending: verificationPending,
 	}
 
-	if err := s.userStore.CreateUser(user); err != nil {
+	// The store enforces unique emails and usernames atomically, so a
+	// duplicate is only detected here. Checking beforehand would race with
+	// concurrent registrations and answer faster for taken emails.
+	if err := s.userStore.CreateUser(ctx, user); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			return nil, ErrUserExists
+		}
 		return nil, err
 	}
 
-	// Generate token
-	token, expiresAt, err := s.generateToken(user)
-	if err != nil {
+	// Read the account back for the fields the store fills in, such as CreatedAt
+	return s.userStore.GetUserByID(ctx, user.ID)
+}
+
+// Login authenticates a user and returns a token. Repeated failures lock
+// the account and the client IP for a cooldown, returning a *LockedError.
+// With login backoff enabled a failure returns a *BackoffError with the
+// wait before the email may try again.
+func (s *Service) Login(ctx context.Context, req *LoginRequest, clientIP string) (response *LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "auth.Login")
+	defer tracing.End(span, &err)
+
+	if s.loginTiming.minDuration > 0 {
+		defer s.loginTiming.pad(ctx, time.Now())
+	}
+
+	if err := s.ipThrottle.check(clientIP); err != nil {
 		return nil, err
 	}
 
-	return &LoginResponse{
-		Token:     token,
-		User:      s.userToUserInfo(user),
-		ExpiresAt: expiresAt,
-	}, nil
-}
+	req.Email = storage.NormalizeEmail(req.Email)
+	if s.config.Auth.LoginBackoff.Enabled {
+		if err := s.loginBackoff.check(backoffKey(ctx, req.Email)); err != nil {
+			return nil, err
+		}
+	}
 
-// Login authenticates a user and returns a token
-func (s *Service) Login(req *LoginRequest) (*LoginResponse, error) {
 	// Get user by email
-	user, err := s.userStore.GetUserByEmail(req.Email)
+	user, err := s.userStore.GetUserByEmail(ctx, req.Email)
 	if err != nil {
 		if err == storage.ErrUserNotFound {
-			return nil, ErrInvalidCredentials
+			s.maskUnknownAccount(req.Password)
+			return nil, s.loginFailed(ctx, nil, req.Email, clientIP)
 		}
 		return nil, err
 	}
 
 	// Check if user is active
 	if !user.IsActive {
-		return nil, ErrInvalidCredentials
+		s.maskUnknownAccount(req.Password)
+		return nil, s.loginFailed(ctx, nil, req.Email, clientIP)
+	}
+
+	// A locked account is rejected even with the correct password
+	if err := s.checkAccountLock(user); err != nil {
+		return nil, err
 	}
 
 	// Verify password
 	if err := s.verifyPassword(user.PasswordHash, req.Password); err != nil {
-		return nil, ErrInvalidCredentials
+		return nil, s.loginFailed(ctx, user, req.Email, clientIP)
 	}
+	s.upgradePasswordHash(ctx, user, req.Password)
 
-	// Generate token
-	token, expiresAt, err := s.generateToken(user)
-	if err != nil {
+	// The password was right, so saying why the login failed reveals nothing
+	if err := s.checkEmailVerification(user); err != nil {
 		return nil, err
 	}
 
-	return &LoginResponse{
-		Token:     token,
-		User:      s.userToUserInfo(user),
-		ExpiresAt: expiresAt,
-	}, nil
+	s.ipThrottle.reset(clientIP)
+	s.loginBackoff.reset(backoffKey(ctx, req.Email))
+	if err := s.resetFailedLogins(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// Accounts with 2FA only get a challenge until the second factor is verified
+	if user.TOTPEnabled {
+		return s.issueTwoFactorChallenge(user)
+	}
+
+	return s.issueLoginResponse(ctx, user, req.RememberMe)
 }
 
-// ValidateToken validates a JWT token and returns the user information
-func (s *Service) ValidateToken(tokenString string) (*UserInfo, error) {
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(s.config.Auth.JWTSecret), nil
-	})
+// loginFailed records a failed login against the IP, the email and, when
+// known, the account and returns the error to report to the caller
+func (s *Service) loginFailed(ctx context.Context, user *storage.User, email, clientIP string) error {
+	cfg := s.config.Auth
+	ipErr := s.ipThrottle.recordFailure(clientIP, cfg.MaxFailedLoginsPerIP, cfg.FailedLoginWindow, cfg.LockoutDuration)
 
-	if err != nil {
-		return nil, ErrInvalidToken
+	var delay time.Duration
+	if cfg.LoginBackoff.Enabled {
+		delay = s.loginBackoff.recordFailure(backoffKey(ctx, email), cfg.LoginBackoff.BaseDelay, cfg.LoginBackoff.MaxDelay)
 	}
 
-	// Validate token
-	claims, ok := token.Claims.(*JWTClaims)
-	if !ok || !token.Valid {
-		return nil, ErrInvalidToken
+	if user != nil {
+		if err := s.recordFailedLogin(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	if ipErr != nil {
+		return ipErr
+	}
+	if delay > 0 {
+		return &BackoffError{Err: ErrInvalidCredentials, RetryAfter: delay}
+	}
+	return ErrInvalidCredentials
+}
+
+// ValidateToken validates a JWT token and returns the user information
+func (s *Service) ValidateToken(ctx context.Context, tokenString string) (*UserInfo, error) {
+	info, _, err := s.validateToken(ctx, tokenString)
+	return info, err
+}
+
+// validateToken validates a JWT token and returns the user information
+// along with the token's claims
+func (s *Service) validateToken(ctx context.Context, tokenString string) (info *UserInfo, claims *JWTClaims, err error) {
+	ctx, span := tracing.Start(ctx, "auth.ValidateToken")
+	defer tracing.End(span, &err)
+
+	claims, err = s.parseToken(tokenString)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Check expiration
-	if claims.ExpiresAt.Time.Before(time.Now()) {
-		return nil, ErrTokenExpired
+	if err := s.checkTokenRevocation(ctx, claims); err != nil {
+		return nil, nil, err
 	}
 
 	// Get user from store to ensure it still exists and is active
-	user, err := s.userStore.GetUserByID(claims.UserID)
+	user, err := s.userStore.GetUserByID(ctx, claims.UserID)
 	if err != nil {
 		if err == storage.ErrUserNotFound {
-			return nil, ErrInvalidToken
+			return nil, nil, ErrInvalidToken
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
-	if !user.IsActive {
-		return nil, ErrInvalidToken
+	if !user.IsActive || user.OrgID != claims.OrgID {
+		return nil, nil, ErrInvalidToken
 	}
 
 	userInfo := s.userToUserInfo(user)
-	return &userInfo, nil
+	userInfo.Metadata = claims.Metadata
+	return &userInfo, claims, nil
+}
+
+// checkTokenRevocation rejects a parsed token that belongs to another
+// organization with ErrInvalidToken, and one that was revoked or whose
+// session has ended with ErrTokenRevoked. It doesn't look at the user the
+// token was issued to. With sliding sessions, a token that passes counts as
+// activity on its session.
+func (s *Service) checkTokenRevocation(ctx context.Context, claims *JWTClaims) error {
+	// Tokens only work in the organization they were issued for
+	if claims.OrgID != tenant.FromContext(ctx) {
+		return ErrInvalidToken
+	}
+
+	// Reject tokens that were revoked before they expired
+	revoked, err := s.revokedStore.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	// Reject tokens whose session was revoked or has expired
+	if claims.SessionID != "" {
+		session, err := s.sessionStore.GetSession(claims.SessionID)
+		if err != nil {
+			if err == storage.ErrSessionNotFound {
+				return ErrTokenRevoked
+			}
+			return err
+		}
+		s.extendSession(ctx, session)
+	}
+	return nil
+}
+
+// ChangePassword replaces a user's password after verifying the current
+// one. The user's other sessions are logged out when revokeSessions is set
+// or RevokeSessionsOnPasswordChange is configured; currentSessionID, the
+// session the change was made from, is kept. It reports whether sessions
+// were logged out.
+func (s *Service) ChangePassword(ctx context.Context, userID, currentSessionID, oldPassword, newPassword string, revokeSessions bool) (revoked bool, err error) {
+	ctx, span := tracing.Start(ctx, "auth.ChangePassword")
+	defer tracing.End(span, &err)
+
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return false, ErrUserNotFound
+		}
+		return false, err
+	}
+
+	if err := s.verifyPassword(user.PasswordHash, oldPassword); err != nil {
+		return false, ErrInvalidCredentials
+	}
+
+	if err := s.validateNewPassword(ctx, newPassword); err != nil {
+		return false, err
+	}
+
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return false, err
+	}
+
+	user.PasswordHash = hashedPassword
+	user.MustChangePassword = false
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return false, err
+	}
+
+	if !revokeSessions && !s.config.Auth.RevokeSessionsOnPasswordChange {
+		return false, nil
+	}
+	if err := s.revokeOtherSessions(userID, currentSessionID, "password_changed"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevokeToken blacklists an access token until it expires so it can no longer be used
+func (s *Service) RevokeToken(tokenString string) error {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		// Expired tokens are already unusable
+		if err == ErrTokenExpired {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.revokedStore.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	if claims.SessionID != "" {
+		if err := s.sessionStore.DeleteSession(claims.SessionID); err != nil && err != storage.ErrSessionNotFound {
+			return err
+		}
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypeTokenRevoked,
+		Outcome: events.OutcomeSuccess,
+		UserID:  claims.UserID,
+		Details: map[string]string{"reason": "logout", "jti": claims.ID},
+	})
+	return nil
+}
+
+// RevokeRefreshToken invalidates a refresh token and every token rotated from it
+func (s *Service) RevokeRefreshToken(refreshToken string) error {
+	stored, err := s.refreshStore.GetRefreshToken(storage.HashToken(refreshToken))
+	if err != nil {
+		if err == storage.ErrRefreshTokenNotFound {
+			return nil
+		}
+		return err
+	}
+
+	return s.refreshStore.DeleteRefreshTokenFamily(stored.FamilyID)
+}
+
+// JWKS returns the public keys other services can use to verify tokens
+func (s *Service) JWKS() JWKS {
+	return s.keys.jwks()
 }
 
 // GetUserProfile returns user profile information
-func (s *Service) GetUserProfile(userID string) (*UserInfo, error) {
-	user, err := s.userStore.GetUserByID(userID)
+func (s *Service) GetUserProfile(ctx context.Context, userID string) (*UserInfo, error) {
+	user, err := s.userStore.GetUserByID(ctx, userID)
 	if err != nil {
 		if err == storage.ErrUserNotFound {
 			return nil, ErrUserNotFound
@@ -189,63 +556,214 @@ func (s *Service) GetUserProfile(userID string) (*UserInfo, error) {
 	return &userInfo, nil
 }
 
-// hashPassword hashes a password using bcrypt
-func (s *Service) hashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), s.config.Auth.BCryptCost)
+// parseToken verifies a JWT's signature and standard claims and returns its claims
+func (s *Service) parseToken(tokenString string) (*JWTClaims, error) {
+	// Only allowlisted algorithms are accepted, which also rules out "none";
+	// the key ring then picks the key by kid and checks the algorithm matches it
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(s.allowedAlgorithms()),
+		jwt.WithIssuer(s.config.Auth.Issuer),
+		jwt.WithLeeway(s.config.Auth.ClockSkewLeeway),
+	}
+	if s.config.Auth.Audience != "" {
+		options = append(options, jwt.WithAudience(s.config.Auth.Audience))
+	}
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, s.keys.keyFunc, options...)
+
 	if err != nil {
-		return "", err
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	// Every token must carry an ID so it can be revoked
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalidToken
 	}
-	return string(hashedBytes), nil
+
+	return claims, nil
+}
+
+// allowedAlgorithms returns the alg headers accepted on incoming tokens
+func (s *Service) allowedAlgorithms() []string {
+	if algs := s.config.Auth.AllowedAlgorithms; len(algs) > 0 {
+		return algs
+	}
+	return []string{s.config.Auth.SigningMethod}
+}
+
+// hashPassword hashes a password with the configured hasher
+func (s *Service) hashPassword(password string) (string, error) {
+	return s.hasher.Hash(password)
 }
 
-// verifyPassword verifies a password against its hash
+// verifyPassword verifies a password against its hash, whichever algorithm made it
 func (s *Service) verifyPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	return verifyPasswordHash(hashedPassword, password)
+}
+
+// upgradePasswordHash re-hashes a just-verified password when its stored
+// hash uses an older algorithm or weaker parameters than configured. The
+// login has already succeeded, so a failure is only logged.
+func (s *Service) upgradePasswordHash(ctx context.Context, user *storage.User, password string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hashedPassword, err := s.hashPassword(password)
+	if err == nil {
+		previous := user.PasswordHash
+		user.PasswordHash = hashedPassword
+		if err = s.userStore.UpdateUser(ctx, user); err != nil {
+			user.PasswordHash = previous
+		}
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to upgrade password hash", "user_id", user.ID, "error", err)
+		return
+	}
+	logging.FromContext(ctx).Info("Upgraded password hash", "user_id", user.ID, "hasher", s.config.Auth.PasswordHasher)
+}
+
+// issueLoginResponse generates tokens for a new login, records the login
+// time and wraps them in a login response
+func (s *Service) issueLoginResponse(ctx context.Context, user *storage.User, rememberMe bool) (*LoginResponse, error) {
+	response, err := s.issueTokens(user, "", rememberMe)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLogin(ctx, user)
+	return response, nil
+}
+
+// recordLogin stores the time of a successful login. The tokens have
+// already been issued, so a failure is only logged.
+func (s *Service) recordLogin(ctx context.Context, user *storage.User) {
+	now := time.Now()
+	if err := s.userStore.RecordLogin(ctx, user.ID, now); err != nil {
+		logging.FromContext(ctx).Warn("Failed to record login time", "user_id", user.ID, "error", err)
+		return
+	}
+	user.LastLoginAt = &now
+}
+
+// issueTokens generates an access token and a refresh token in the given
+// rotation family. An empty familyID starts a new family and session; the
+// family ID doubles as the session ID.
+func (s *Service) issueTokens(user *storage.User, familyID string, rememberMe bool) (*LoginResponse, error) {
+	if familyID == "" {
+		var err error
+		if familyID, err = s.generateID(); err != nil {
+			return nil, err
+		}
+	}
+
+	token, tokenID, expiresAt, err := s.generateToken(user, familyID, s.tokenDuration(rememberMe))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.generateRefreshToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.trackSession(user.ID, familyID, tokenID, expiresAt, rememberMe); err != nil {
+		return nil, err
+	}
+
+	userInfo := s.userToUserInfo(user)
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		SessionID:    familyID,
+		User:         &userInfo,
+		ExpiresAt:    expiresAt,
+
+		PasswordChangeRequired: user.MustChangePassword,
+		VerificationRequiredBy: s.verificationDeadline(user),
+	}, nil
 }
 
-// generateToken generates a JWT token for a user
-func (s *Service) generateToken(user *storage.User) (string, time.Time, error) {
-	expiresAt := time.Now().Add(s.config.Auth.TokenDuration)
+// tokenDuration returns the access token lifetime for a login
+func (s *Service) tokenDuration(rememberMe bool) time.Duration {
+	if rememberMe {
+		return s.config.Auth.RememberMeDuration
+	}
+	return s.config.Auth.TokenDuration
+}
+
+// generateToken generates a JWT token for a user in the given session that
+// is valid for duration, and returns it with its token ID and expiry
+func (s *Service) generateToken(user *storage.User, sessionID string, duration time.Duration) (string, string, time.Time, error) {
+	expiresAt := time.Now().Add(duration)
+
+	tokenID, err := s.generateID()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
 
 	claims := &JWTClaims{
-		UserID:   user.ID,
-		Email:    user.Email,
-		Username: user.Username,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Username:  user.Username,
+		SessionID: sessionID,
+		OrgID:     user.OrgID,
+		Metadata:  s.tokenMetadata(user),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "login-app",
+			Issuer:    s.config.Auth.Issuer,
 			Subject:   user.ID,
+			ID:        tokenID,
 		},
 	}
+	if s.config.Auth.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.config.Auth.Audience}
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.config.Auth.JWTSecret))
+	tokenString, err := s.keys.sign(claims)
 	if err != nil {
-		return "", time.Time{}, err
+		return "", "", time.Time{}, err
 	}
 
-	return tokenString, expiresAt, nil
+	return tokenString, tokenID, expiresAt, nil
+}
+
+// newSecretToken generates a random token to hand to a user. Unlike IDs,
+// these tokens are secrets; their size and encoding are configurable.
+func (s *Service) newSecretToken() (string, error) {
+	return s.tokens.Generate()
 }
 
-// generateID generates a random ID
+// generateID generates an ID for a new record in the configured format
 func (s *Service) generateID() (string, error) {
-	bytes := make([]byte, 16)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(bytes), nil
+	return s.ids.Generate()
 }
 
 // userToUserInfo converts a User to UserInfo (removes sensitive data)
 func (s *Service) userToUserInfo(user *storage.User) UserInfo {
 	return UserInfo{
 		ID:        user.ID,
+		OrgID:     user.OrgID,
 		Email:     user.Email,
 		Username:  user.Username,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		CreatedAt: user.CreatedAt,
+		Role:      user.Role,
+
+		TwoFactorEnabled: user.TOTPEnabled,
+		LastLoginAt:      user.LastLoginAt,
+		DeletedAt:        user.DeletedAt,
+
+		MustChangePassword: user.MustChangePassword,
 	}
 }
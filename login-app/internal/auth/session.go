@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/audit"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// ListSessions returns a user's active sessions, newest first. The session
+// matching currentSessionID is flagged as current.
+func (s *Service) ListSessions(userID, currentSessionID string) ([]SessionInfo, error) {
+	sessions, err := s.sessionStore.ListUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		info := SessionInfo{
+			ID:        session.ID,
+			IPAddress: session.IPAddress,
+			UserAgent: session.UserAgent,
+			Device:    session.Device,
+			LoginTime: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Current:   session.ID == currentSessionID,
+		}
+		if !session.LastActiveAt.IsZero() {
+			info.LastActiveAt = &session.LastActiveAt
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// RevokeSession ends one of a user's sessions. Its latest access token is
+// blacklisted and its refresh tokens are deleted so it cannot be renewed.
+func (s *Service) RevokeSession(userID, sessionID string) error {
+	session, err := s.sessionStore.GetSession(sessionID)
+	if err != nil {
+		if err == storage.ErrSessionNotFound {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	// Don't reveal other users' sessions
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	if err := s.revokedStore.RevokeToken(session.TokenID, session.TokenExpiresAt); err != nil {
+		return err
+	}
+	if err := s.refreshStore.DeleteRefreshTokenFamily(session.ID); err != nil {
+		return err
+	}
+	if err := s.sessionStore.DeleteSession(session.ID); err != nil && err != storage.ErrSessionNotFound {
+		return err
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypeTokenRevoked,
+		Outcome: events.OutcomeSuccess,
+		UserID:  userID,
+		Details: map[string]string{"reason": "session_revoked", "session_id": session.ID, "jti": session.TokenID},
+	})
+	return nil
+}
+
+// RevokeAllSessions logs a user out on every device. Each session's latest
+// access token is blacklisted, and the sessions and refresh tokens are
+// deleted, so no token issued before the call validates or refreshes.
+func (s *Service) RevokeAllSessions(ctx context.Context, userID string) error {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	// Administrators can only manage accounts in their own organization
+	if user.OrgID != tenant.FromContext(ctx) {
+		return ErrUserNotFound
+	}
+
+	sessions, err := s.sessionStore.ListUserSessions(userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := s.revokedStore.RevokeToken(session.TokenID, session.TokenExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	if err := s.refreshStore.DeleteUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	return s.sessionStore.DeleteUserSessions(userID)
+}
+
+// revokeOtherSessions logs a user out of every session except
+// keepSessionID, which may be empty. Each session's latest access token is
+// blacklisted and its refresh tokens deleted, as in RevokeSession.
+func (s *Service) revokeOtherSessions(userID, keepSessionID, reason string) error {
+	sessions, err := s.sessionStore.ListUserSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		if err := s.revokedStore.RevokeToken(session.TokenID, session.TokenExpiresAt); err != nil {
+			return err
+		}
+		if err := s.refreshStore.DeleteRefreshTokenFamily(session.ID); err != nil {
+			return err
+		}
+		if err := s.sessionStore.DeleteSession(session.ID); err != nil && err != storage.ErrSessionNotFound {
+			return err
+		}
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypeTokenRevoked,
+		Outcome: events.OutcomeSuccess,
+		UserID:  userID,
+		Details: map[string]string{"reason": reason},
+	})
+	return nil
+}
+
+// RecordSessionClient stores the client a session was started from
+func (s *Service) RecordSessionClient(sessionID, ipAddress, userAgent string) error {
+	session, err := s.sessionStore.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	session.IPAddress = ipAddress
+	session.UserAgent = userAgent
+	session.Device = audit.ParseUserAgent(userAgent)
+	return s.sessionStore.UpdateSession(session)
+}
+
+// SessionIDFromToken returns the session a valid access token belongs to
+func (s *Service) SessionIDFromToken(tokenString string) (string, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+	return claims.SessionID, nil
+}
+
+// trackSession creates the session for a new login or, on refresh, points
+// it at the newly issued access token. The session lasts as long as its
+// refresh token or access token, whichever expires last, or with sliding
+// sessions until the idle timeout.
+func (s *Service) trackSession(userID, sessionID, tokenID string, tokenExpiresAt time.Time, rememberMe bool) error {
+	now := time.Now()
+	expiresAt := now.Add(s.config.Auth.RefreshTokenDuration)
+	if tokenExpiresAt.After(expiresAt) {
+		expiresAt = tokenExpiresAt
+	}
+
+	session, err := s.sessionStore.GetSession(sessionID)
+	if err != nil {
+		if err == storage.ErrSessionNotFound {
+			if s.config.Auth.SlidingSessions.Enabled {
+				expiresAt = s.slidingExpiry(now, now)
+			}
+			return s.sessionStore.CreateSession(&storage.Session{
+				ID:             sessionID,
+				UserID:         userID,
+				ExpiresAt:      expiresAt,
+				LastActiveAt:   now,
+				RememberMe:     rememberMe,
+				TokenID:        tokenID,
+				TokenExpiresAt: tokenExpiresAt,
+			})
+		}
+		return err
+	}
+
+	if s.config.Auth.SlidingSessions.Enabled {
+		expiresAt = s.slidingExpiry(session.CreatedAt, now)
+	}
+	session.ExpiresAt = expiresAt
+	session.LastActiveAt = now
+	session.TokenID = tokenID
+	session.TokenExpiresAt = tokenExpiresAt
+	return s.sessionStore.UpdateSession(session)
+}
+
+// slidingExpiry returns when a sliding session started at createdAt and
+// last active at lastActive expires: after the idle timeout, but no later
+// than the maximum lifetime
+func (s *Service) slidingExpiry(createdAt, lastActive time.Time) time.Time {
+	cfg := s.config.Auth.SlidingSessions
+	expiresAt := lastActive.Add(cfg.IdleTimeout)
+	if limit := createdAt.Add(cfg.MaxLifetime); limit.Before(expiresAt) {
+		expiresAt = limit
+	}
+	return expiresAt
+}
+
+// extendSession records activity on a sliding session, pushing its expiry
+// out to the idle timeout. Activity is written at most every tenth of the
+// idle timeout, and at least every minute, so a busy client doesn't write
+// to the session store on every request; the session can therefore
+// expire up to that much early.
+func (s *Service) extendSession(ctx context.Context, session *storage.Session) {
+	cfg := s.config.Auth.SlidingSessions
+	if !cfg.Enabled {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastActiveAt) < min(cfg.IdleTimeout/10, time.Minute) {
+		return
+	}
+
+	expiresAt := s.slidingExpiry(session.CreatedAt, now)
+	if err := s.sessionStore.TouchSession(session.ID, now, expiresAt); err != nil && err != storage.ErrSessionNotFound {
+		logging.FromContext(ctx).Warn("Failed to extend session", "session_id", session.ID, "error", err)
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestListSessionsFlagsCurrentSession(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "sessions@example.com", "sessions")
+	ctx := context.Background()
+
+	other, err := service.Login(ctx, &LoginRequest{Email: "sessions@example.com", Password: testPassword}, "192.0.2.2")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	current, _ := service.SessionIDFromToken(other.Token)
+
+	sessions, err := service.ListSessions(registered.User.ID, current)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("%d sessions, want 2", len(sessions))
+	}
+	// Newest first
+	if sessions[0].ID != current || !sessions[0].Current || sessions[1].Current {
+		t.Errorf("sessions = %+v, want only the newest %s flagged current", sessions, current)
+	}
+	if sessions[0].ExpiresAt.IsZero() || sessions[0].LoginTime.IsZero() {
+		t.Errorf("session %+v has no login time or expiry", sessions[0])
+	}
+}
+
+func TestRevokeSessionInvalidatesItsToken(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "revoke@example.com", "revoke")
+	ctx := context.Background()
+
+	other, err := service.Login(ctx, &LoginRequest{Email: "revoke@example.com", Password: testPassword}, "192.0.2.2")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	otherSession, _ := service.SessionIDFromToken(other.Token)
+
+	if err := service.RevokeSession(registered.User.ID, otherSession); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := service.ValidateToken(ctx, other.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("revoked session's token: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.Refresh(ctx, other.RefreshToken); err == nil {
+		t.Error("revoked session's refresh token still works")
+	}
+	if _, err := service.ValidateToken(ctx, registered.Token); err != nil {
+		t.Errorf("other session's token: %v", err)
+	}
+
+	sessions, _ := service.ListSessions(registered.User.ID, "")
+	if len(sessions) != 1 || sessions[0].ID == otherSession {
+		t.Errorf("sessions after revoking = %+v, want only the first", sessions)
+	}
+	if err := service.RevokeSession(registered.User.ID, otherSession); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking again: got %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestRevokeSessionOfAnotherUser(t *testing.T) {
+	service := newTestService(t, nil)
+	owner := registerTestUser(t, service, "owner@example.com", "owner")
+	intruder := registerTestUser(t, service, "intruder@example.com", "intruder")
+	ownerSession, _ := service.SessionIDFromToken(owner.Token)
+
+	if err := service.RevokeSession(intruder.User.ID, ownerSession); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("got %v, want ErrSessionNotFound", err)
+	}
+	if _, err := service.ValidateToken(context.Background(), owner.Token); err != nil {
+		t.Errorf("owner's token: %v", err)
+	}
+}
+
+func TestRevokeAllSessionsRejectsEveryToken(t *testing.T) {
+	service := newTestService(t, nil)
+	first := registerTestUser(t, service, "compromised@example.com", "compromised")
+	bystander := registerTestUser(t, service, "bystander@example.com", "bystander")
+	ctx := context.Background()
+
+	second, err := service.Login(ctx, &LoginRequest{Email: "compromised@example.com", Password: testPassword}, "192.0.2.2")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	for _, token := range []string{first.Token, second.Token} {
+		if _, err := service.ValidateToken(ctx, token); err != nil {
+			t.Fatalf("ValidateToken before revoking: %v", err)
+		}
+	}
+
+	if err := service.RevokeAllSessions(ctx, first.User.ID); err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+
+	for name, response := range map[string]*LoginResponse{"first": first, "second": second} {
+		if _, err := service.ValidateToken(ctx, response.Token); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("%s token: got %v, want ErrTokenRevoked", name, err)
+		}
+		if _, err := service.Refresh(ctx, response.RefreshToken); err == nil {
+			t.Errorf("%s refresh token still works", name)
+		}
+	}
+	if sessions, _ := service.ListSessions(first.User.ID, ""); len(sessions) != 0 {
+		t.Errorf("%d sessions left, want none", len(sessions))
+	}
+	if _, err := service.ValidateToken(ctx, bystander.Token); err != nil {
+		t.Errorf("another user's token: %v", err)
+	}
+
+	// The user can still log in again afterwards
+	if _, err := service.Login(ctx, &LoginRequest{Email: "compromised@example.com", Password: testPassword}, "192.0.2.1"); err != nil {
+		t.Errorf("login after revoking: %v", err)
+	}
+	if err := service.RevokeAllSessions(ctx, "missing"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: got %v, want ErrUserNotFound", err)
+	}
+}
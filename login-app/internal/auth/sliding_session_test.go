@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+// withSlidingSessions enables sliding sessions with a 30 minute idle
+// timeout and a 2 hour maximum lifetime
+func withSlidingSessions(cfg *config.Config) {
+	cfg.Auth.SlidingSessions = config.SlidingSessionsConfig{
+		Enabled:     true,
+		IdleTimeout: 30 * time.Minute,
+		MaxLifetime: 2 * time.Hour,
+	}
+}
+
+// backdateSession moves the session a token belongs to into the past, as if
+// it logged in createdAgo and was last active lastActiveAgo, and returns it
+func backdateSession(t *testing.T, service *Service, token string, createdAgo, lastActiveAgo time.Duration) *storage.Session {
+	t.Helper()
+
+	sessionID, err := service.SessionIDFromToken(token)
+	if err != nil {
+		t.Fatalf("SessionIDFromToken: %v", err)
+	}
+	session, err := service.sessionStore.GetSession(sessionID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	now := time.Now()
+	session.CreatedAt = now.Add(-createdAgo)
+	session.LastActiveAt = now.Add(-lastActiveAgo)
+	session.ExpiresAt = service.slidingExpiry(session.CreatedAt, session.LastActiveAt)
+	if err := service.sessionStore.UpdateSession(session); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	return session
+}
+
+// currentSession returns the stored session a token belongs to
+func currentSession(t *testing.T, service *Service, token string) *storage.Session {
+	t.Helper()
+
+	sessionID, err := service.SessionIDFromToken(token)
+	if err != nil {
+		t.Fatalf("SessionIDFromToken: %v", err)
+	}
+	session, err := service.sessionStore.GetSession(sessionID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	return session
+}
+
+// assertAbout fails unless got is within a few seconds of want
+func assertAbout(t *testing.T, name string, got, want time.Time) {
+	t.Helper()
+
+	if diff := got.Sub(want); diff < -5*time.Second || diff > 5*time.Second {
+		t.Errorf("%s = %s, want about %s", name, got, want)
+	}
+}
+
+func TestSlidingSessionStartsWithIdleTimeout(t *testing.T) {
+	service := newTestService(t, withSlidingSessions)
+	registered := registerTestUser(t, service, "idle@example.com", "idle")
+
+	assertAbout(t, "ExpiresAt", currentSession(t, service, registered.Token).ExpiresAt, time.Now().Add(30*time.Minute))
+}
+
+func TestSlidingSessionExtendsOnActivity(t *testing.T) {
+	service := newTestService(t, withSlidingSessions)
+	registered := registerTestUser(t, service, "active@example.com", "active")
+	ctx := context.Background()
+
+	// Twenty minutes idle leaves ten; a request resets it to thirty
+	backdateSession(t, service, registered.Token, 20*time.Minute, 20*time.Minute)
+	if _, err := service.ValidateToken(ctx, registered.Token); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	session := currentSession(t, service, registered.Token)
+	assertAbout(t, "ExpiresAt", session.ExpiresAt, time.Now().Add(30*time.Minute))
+	assertAbout(t, "LastActiveAt", session.LastActiveAt, time.Now())
+
+	// So does a refresh
+	backdateSession(t, service, registered.Token, 20*time.Minute, 20*time.Minute)
+	refreshed, err := service.Refresh(ctx, registered.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	assertAbout(t, "ExpiresAt after refresh", currentSession(t, service, refreshed.Token).ExpiresAt, time.Now().Add(30*time.Minute))
+}
+
+func TestSlidingSessionThrottlesActivityWrites(t *testing.T) {
+	service := newTestService(t, withSlidingSessions)
+	registered := registerTestUser(t, service, "busy@example.com", "busy")
+
+	// Activity within a minute of the last write isn't written
+	before := backdateSession(t, service, registered.Token, 30*time.Second, 30*time.Second)
+	if _, err := service.ValidateToken(context.Background(), registered.Token); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if after := currentSession(t, service, registered.Token); !after.ExpiresAt.Equal(before.ExpiresAt) || !after.LastActiveAt.Equal(before.LastActiveAt) {
+		t.Errorf("session written to after 30 seconds: expires %s, last active %s", after.ExpiresAt, after.LastActiveAt)
+	}
+}
+
+func TestSlidingSessionIdleExpiry(t *testing.T) {
+	service := newTestService(t, withSlidingSessions)
+	registered := registerTestUser(t, service, "asleep@example.com", "asleep")
+	ctx := context.Background()
+
+	// The access token itself hasn't expired, but its session has
+	backdateSession(t, service, registered.Token, 31*time.Minute, 31*time.Minute)
+	if _, err := service.ValidateToken(ctx, registered.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("token after the idle timeout: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.Refresh(ctx, registered.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after the idle timeout: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestSlidingSessionMaxLifetime(t *testing.T) {
+	service := newTestService(t, withSlidingSessions)
+	registered := registerTestUser(t, service, "forever@example.com", "forever")
+	ctx := context.Background()
+
+	// Five minutes from the cap, activity only extends the session to it
+	created := backdateSession(t, service, registered.Token, 2*time.Hour-5*time.Minute, 20*time.Minute).CreatedAt
+	if _, err := service.ValidateToken(ctx, registered.Token); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	assertAbout(t, "ExpiresAt", currentSession(t, service, registered.Token).ExpiresAt, created.Add(2*time.Hour))
+
+	// Past the cap the session ends however recently it was active
+	backdateSession(t, service, registered.Token, 2*time.Hour+time.Minute, time.Minute)
+	if _, err := service.ValidateToken(ctx, registered.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("token past the maximum lifetime: got %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.Refresh(ctx, registered.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh past the maximum lifetime: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestSessionsDontSlideWhenDisabled(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "fixed@example.com", "fixed")
+
+	session := currentSession(t, service, registered.Token)
+	assertAbout(t, "ExpiresAt", session.ExpiresAt, time.Now().Add(service.config.Auth.RefreshTokenDuration))
+
+	session.LastActiveAt = time.Now().Add(-time.Hour)
+	if err := service.sessionStore.UpdateSession(session); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	if _, err := service.ValidateToken(context.Background(), registered.Token); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if after := currentSession(t, service, registered.Token); !after.LastActiveAt.Equal(session.LastActiveAt) {
+		t.Errorf("LastActiveAt moved to %s with sliding sessions off", after.LastActiveAt)
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// AdminStats returns aggregate user and session counts for the context's
+// organization. The stores count in place rather than listing every user.
+func (s *Service) AdminStats(ctx context.Context) (*AdminStatsResponse, error) {
+	now := time.Now()
+	stats, err := s.userStore.UserStats(ctx, []time.Time{
+		now.Add(-24 * time.Hour),
+		now.AddDate(0, 0, -7),
+		now.AddDate(0, 0, -30),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &AdminStatsResponse{
+		TotalUsers:      stats.Total,
+		ActiveUsers:     stats.Active,
+		InactiveUsers:   stats.Total - stats.Active,
+		VerifiedUsers:   stats.Verified,
+		UnverifiedUsers: stats.Total - stats.Verified,
+		Registrations: RegistrationStats{
+			Last24h: stats.Registered[0],
+			Last7d:  stats.Registered[1],
+			Last30d: stats.Registered[2],
+		},
+		GeneratedAt: now,
+	}
+
+	if !s.config.Tenancy.Enabled {
+		sessions, err := s.sessionStore.CountSessions()
+		if err != nil {
+			return nil, err
+		}
+		response.ActiveSessions = &sessions
+	}
+	return response, nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+func TestSameEmailInTwoOrganizations(t *testing.T) {
+	service := newTestService(t, nil)
+	acme := tenant.NewContext(context.Background(), "acme")
+	globex := tenant.NewContext(context.Background(), "globex")
+
+	register := func(ctx context.Context) *LoginResponse {
+		t.Helper()
+		response, err := service.Register(ctx, &RegisterRequest{
+			Email: "shared@example.com", Username: "shared", Password: testPassword, FirstName: "Test", LastName: "User",
+		}, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		return response
+	}
+	acmeUser := register(acme)
+	globexUser := register(globex)
+	if acmeUser.User.ID == globexUser.User.ID {
+		t.Fatal("both organizations got the same user")
+	}
+
+	if _, err := service.Register(acme, &RegisterRequest{
+		Email: "shared@example.com", Username: "another", Password: testPassword, FirstName: "Test", LastName: "User",
+	}, "192.0.2.1"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("duplicate within an organization: got %v, want ErrUserExists", err)
+	}
+
+	// Each organization's login finds its own user
+	response, err := service.Login(globex, &LoginRequest{Email: "shared@example.com", Password: testPassword}, "192.0.2.1")
+	if err != nil || response.User.ID != globexUser.User.ID {
+		t.Errorf("login in globex = %+v, %v; want the globex user", response, err)
+	}
+}
+
+func TestTokenRejectedInAnotherOrganization(t *testing.T) {
+	service := newTestService(t, nil)
+	acme := tenant.NewContext(context.Background(), "acme")
+	globex := tenant.NewContext(context.Background(), "globex")
+
+	response, err := service.Register(acme, &RegisterRequest{
+		Email: "acme@example.com", Username: "acme", Password: testPassword, FirstName: "Test", LastName: "User",
+	}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	claims, err := service.parseToken(response.Token)
+	if err != nil || claims.OrgID != "acme" {
+		t.Fatalf("token claims = %+v, %v; want org_id acme", claims, err)
+	}
+	if _, err := service.ValidateToken(acme, response.Token); err != nil {
+		t.Errorf("token in its own organization: %v", err)
+	}
+	for name, ctx := range map[string]context.Context{"globex": globex, "default": context.Background()} {
+		if _, err := service.ValidateToken(ctx, response.Token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("token in the %s organization: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
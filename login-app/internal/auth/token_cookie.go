@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// tokenCookie builds the cookie that carries an access token until it
+// expires, or returns nil when token cookies are disabled. An empty token
+// builds a cookie that deletes it.
+func (s *Service) tokenCookie(token string, expiresAt time.Time) *http.Cookie {
+	cfg := s.config.Auth.TokenCookie
+	if !cfg.Enabled {
+		return nil
+	}
+
+	maxAge := -1
+	if token != "" {
+		maxAge = int(time.Until(expiresAt).Seconds())
+	}
+
+	return &http.Cookie{
+		Name:     cfg.Name,
+		Value:    token,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: sameSiteMode(cfg.SameSite),
+	}
+}
+
+// sameSiteMode converts a configured SameSite mode to its cookie attribute
+func sameSiteMode(mode string) http.SameSite {
+	switch mode {
+	case config.SameSiteLax:
+		return http.SameSiteLaxMode
+	case config.SameSiteNone:
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// tokenFrom registers a user on a service configured by configure and
+// returns their access token
+func tokenFrom(t *testing.T, configure func(cfg *config.Config)) string {
+	t.Helper()
+	return registerTestUser(t, newTestService(t, configure), "token@example.com", "token").Token
+}
+
+func withIssuerAndAudience(issuer, audience string) func(cfg *config.Config) {
+	return func(cfg *config.Config) {
+		cfg.Auth.Issuer = issuer
+		cfg.Auth.Audience = audience
+	}
+}
+
+func TestTokenIssuer(t *testing.T) {
+	token := tokenFrom(t, nil)
+
+	claims, err := newTestService(t, nil).parseToken(token)
+	if err != nil {
+		t.Fatalf("parseToken: %v", err)
+	}
+	if claims.Issuer != "login-app" {
+		t.Errorf("iss = %q, want the default login-app", claims.Issuer)
+	}
+
+	other := newTestService(t, withIssuerAndAudience("other-app", ""))
+	if _, err := other.parseToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token from another issuer: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestTokenAudience(t *testing.T) {
+	forAPI := tokenFrom(t, withIssuerAndAudience("login-app", "api.example.com"))
+	noAudience := tokenFrom(t, withIssuerAndAudience("login-app", ""))
+
+	claims, err := newTestService(t, withIssuerAndAudience("login-app", "api.example.com")).parseToken(forAPI)
+	if err != nil {
+		t.Fatalf("matching audience: %v", err)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "api.example.com" {
+		t.Errorf("aud = %v, want [api.example.com]", claims.Audience)
+	}
+
+	for name, tc := range map[string]struct {
+		audience string
+		token    string
+		valid    bool
+	}{
+		"other audience":          {"admin.example.com", forAPI, false},
+		"token without audience":  {"api.example.com", noAudience, false},
+		"audience not configured": {"", forAPI, true},
+	} {
+		_, err := newTestService(t, withIssuerAndAudience("login-app", tc.audience)).parseToken(tc.token)
+		if tc.valid && err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+// signedToken signs claims for a user with the service's current key,
+// valid for an hour unless expiresAt or notBefore say otherwise
+func signedToken(t *testing.T, service *Service, expiresAt, notBefore time.Time) string {
+	t.Helper()
+
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(time.Hour)
+	}
+	claims := &JWTClaims{
+		UserID: "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "token-id",
+			Issuer:    service.config.Auth.Issuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	if !notBefore.IsZero() {
+		claims.NotBefore = jwt.NewNumericDate(notBefore)
+	}
+	token, err := service.keys.sign(claims)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func TestTokenClockSkewLeeway(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.ClockSkewLeeway = 30 * time.Second
+	})
+	now := time.Now()
+
+	for name, tc := range map[string]struct {
+		expiresAt, notBefore time.Time
+		want                 error
+	}{
+		"expired within the leeway":       {expiresAt: now.Add(-10 * time.Second)},
+		"expired beyond the leeway":       {expiresAt: now.Add(-2 * time.Minute), want: ErrTokenExpired},
+		"not yet valid within the leeway": {notBefore: now.Add(10 * time.Second)},
+		"not yet valid beyond the leeway": {notBefore: now.Add(2 * time.Minute), want: ErrInvalidToken},
+		"expired a day ago":               {expiresAt: now.Add(-24 * time.Hour), want: ErrTokenExpired},
+		"valid without any clock drift":   {},
+	} {
+		_, err := service.parseToken(signedToken(t, service, tc.expiresAt, tc.notBefore))
+		if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestTokenWithoutLeeway(t *testing.T) {
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.ClockSkewLeeway = 0
+	})
+	if _, err := service.parseToken(signedToken(t, service, time.Now().Add(-10*time.Second), time.Time{})); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("got %v, want ErrTokenExpired", err)
+	}
+}
+
+// forgedClaims returns valid claims for a registered user, as an attacker
+// who saw one of their tokens could reproduce them
+func forgedClaims(t *testing.T, service *Service, token string) *JWTClaims {
+	t.Helper()
+
+	claims, err := service.parseToken(token)
+	if err != nil {
+		t.Fatalf("parseToken: %v", err)
+	}
+	return claims
+}
+
+func TestTokenAlgorithmAllowlist(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "alg@example.com", "alg")
+	claims := forgedClaims(t, service, registered.Token)
+	secret := []byte(service.config.Auth.JWTSecret)
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("sign with none: %v", err)
+	}
+	forged := map[string]string{"none": unsigned}
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodHS384, jwt.SigningMethodHS512} {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = service.keys.current.kid
+		if forged[method.Alg()], err = token.SignedString(secret); err != nil {
+			t.Fatalf("sign with %s: %v", method.Alg(), err)
+		}
+	}
+
+	for alg, token := range forged {
+		if _, err := service.ValidateToken(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s token: got %v, want ErrInvalidToken", alg, err)
+		}
+	}
+	if _, err := service.ValidateToken(context.Background(), registered.Token); err != nil {
+		t.Errorf("genuine HS256 token: %v", err)
+	}
+}
+
+func TestTokenAlgorithmConfusion(t *testing.T) {
+	privatePath, publicPath := writeRSAKey(t)
+	service := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.SigningMethod = SigningMethodRS256
+		cfg.Auth.RSAPrivateKeyFile = privatePath
+		cfg.Auth.AllowedAlgorithms = []string{SigningMethodRS256, SigningMethodHS256}
+	})
+	registered := registerTestUser(t, service, "confused@example.com", "confused")
+	claims := forgedClaims(t, service, registered.Token)
+
+	// HS256 signed with the published public key, claiming the RSA key's kid;
+	// HS256 is allowed but doesn't match the key
+	publicPEM, err := os.ReadFile(publicPath)
+	if err != nil {
+		t.Fatalf("read public key: %v", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = service.keys.current.kid
+	forged, err := token.SignedString(publicPEM)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := service.ValidateToken(context.Background(), forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("HS256 token with the RSA kid: got %v, want ErrInvalidToken", err)
+	}
+
+	// Only RS256 allowed: HS256 is rejected before any key is looked at
+	rsaOnly := newTestService(t, func(cfg *config.Config) {
+		cfg.Auth.SigningMethod = SigningMethodRS256
+		cfg.Auth.RSAPrivateKeyFile = privatePath
+		cfg.Auth.AllowedAlgorithms = []string{SigningMethodRS256}
+	})
+	if _, err := rsaOnly.parseToken(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("HS256 token with only RS256 allowed: got %v, want ErrInvalidToken", err)
+	}
+	if _, err := rsaOnly.parseToken(registered.Token); err != nil {
+		t.Errorf("genuine RS256 token: %v", err)
+	}
+}
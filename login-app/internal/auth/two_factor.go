@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tracing"
+)
+
+var (
+	ErrInvalidTOTPCode        = errors.New("invalid two-factor code")
+	ErrTOTPNotEnabled         = errors.New("two-factor authentication is not enabled")
+	ErrTOTPAlreadyEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrInvalidChallenge       = errors.New("invalid or expired two-factor challenge")
+	ErrTooManyTwoFactorErrors = errors.New("too many invalid two-factor codes")
+)
+
+const (
+	// totpIssuer is shown next to the account in authenticator apps
+	totpIssuer = "login-app"
+
+	// recoveryCodeCount is the number of backup codes issued when 2FA is enabled
+	recoveryCodeCount = 10
+
+	// maxChallengeAttempts bounds how many codes can be tried per login challenge
+	maxChallengeAttempts = 5
+)
+
+// EnableTOTP generates a new TOTP secret for a user and returns it together
+// with an otpauth:// URL for authenticator apps. Two-factor login is only
+// switched on once the user proves possession with ConfirmTOTP.
+func (s *Service) EnableTOTP(ctx context.Context, userID string) (string, string, error) {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return "", "", ErrUserNotFound
+		}
+		return "", "", err
+	}
+
+	if user.TOTPEnabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	encrypted, err := s.encryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	user.TOTPSecret = encrypted
+	user.TOTPLastStep = 0
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return "", "", err
+	}
+
+	return totpEncoding.EncodeToString(secret), totpURL(totpIssuer, user.Email, secret), nil
+}
+
+// ConfirmTOTP verifies the first code from a newly provisioned authenticator,
+// switches two-factor login on and returns single-use recovery codes
+func (s *Service) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.VerifyTOTP(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, storage.HashToken(code))
+	}
+
+	user.TOTPEnabled = true
+	user.RecoveryCodeHashes = hashes
+	if err := s.userStore.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.publishEvent(events.Event{
+		Type:    events.TypeTwoFactorEnabled,
+		Outcome: events.OutcomeSuccess,
+		UserID:  user.ID,
+		Email:   user.Email,
+	})
+	return codes, nil
+}
+
+// VerifyTOTP checks a TOTP code for a user. Codes from the adjacent time
+// steps are accepted to tolerate clock drift, and a code can't be replayed.
+func (s *Service) VerifyTOTP(ctx context.Context, userID, code string) error {
+	user, err := s.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return s.verifyUserTOTP(ctx, user, code)
+}
+
+// CompleteTwoFactorLogin exchanges a login challenge and a TOTP or recovery
+// code for the full login response
+func (s *Service) CompleteTwoFactorLogin(ctx context.Context, challenge, code string, rememberMe bool) (response *LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "auth.CompleteTwoFactorLogin")
+	defer tracing.End(span, &err)
+
+	stored, err := s.tokenStore.ConsumeToken(challenge, storage.TokenPurposeTwoFactor)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userStore.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if err == storage.ErrUserNotFound {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+
+	if !user.IsActive || !user.TOTPEnabled {
+		return nil, ErrInvalidChallenge
+	}
+
+	err = s.verifyUserTOTP(ctx, user, code)
+	if err == ErrInvalidTOTPCode {
+		err = s.useRecoveryCode(ctx, user, code)
+	}
+	if err != nil {
+		if err != ErrInvalidTOTPCode {
+			return nil, err
+		}
+
+		// Keep the challenge alive for a limited number of retries
+		attempts, _ := strconv.Atoi(stored.Data)
+		attempts++
+		if attempts >= maxChallengeAttempts {
+			return nil, ErrTooManyTwoFactorErrors
+		}
+
+		stored.Token = challenge
+		stored.Data = strconv.Itoa(attempts)
+		if err := s.tokenStore.SaveToken(stored); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTOTPCode
+	}
+
+	return s.issueLoginResponse(ctx, user, rememberMe)
+}
+
+// issueTwoFactorChallenge returns a login response carrying only a
+// short-lived challenge to be completed with CompleteTwoFactorLogin
+func (s *Service) issueTwoFactorChallenge(user *storage.User) (*LoginResponse, error) {
+	challenge, err := s.newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenStore.SaveToken(&storage.VerificationToken{
+		Token:     challenge,
+		Purpose:   storage.TokenPurposeTwoFactor,
+		UserID:    user.ID,
+		Data:      "0",
+		ExpiresAt: time.Now().Add(s.config.Auth.TwoFactorChallengeTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+		ExpiresAt:         time.Now().Add(s.config.Auth.TwoFactorChallengeTTL),
+	}, nil
+}
+
+// verifyUserTOTP checks a TOTP code against a user's stored secret and
+// records the accepted time step to prevent replay
+func (s *Service) verifyUserTOTP(ctx context.Context, user *storage.User, code string) error {
+	if user.TOTPSecret == "" {
+		return ErrTOTPNotEnabled
+	}
+
+	secret, err := s.decryptSecret(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := matchTOTP(secret, code, time.Now(), s.config.Auth.TOTPSkew)
+	if !ok || step <= user.TOTPLastStep {
+		return ErrInvalidTOTPCode
+	}
+
+	user.TOTPLastStep = step
+	return s.userStore.UpdateUser(ctx, user)
+}
+
+// useRecoveryCode consumes one of the user's recovery codes
+func (s *Service) useRecoveryCode(ctx context.Context, user *storage.User, code string) error {
+	hash := storage.HashToken(normalizeRecoveryCode(code))
+	for i, stored := range user.RecoveryCodeHashes {
+		if stored == hash {
+			user.RecoveryCodeHashes = append(user.RecoveryCodeHashes[:i], user.RecoveryCodeHashes[i+1:]...)
+			return s.userStore.UpdateUser(ctx, user)
+		}
+	}
+	return ErrInvalidTOTPCode
+}
+
+// generateRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, 10)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(totpEncoding.EncodeToString(bytes))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// normalizeRecoveryCode accepts codes typed without the dash or in upper case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
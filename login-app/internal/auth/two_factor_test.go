@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// SHA1 vectors from RFC 6238 appendix B, truncated to six digits
+	secret := []byte("12345678901234567890")
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		if got := totpCode(secret, totpStep(time.Unix(tc.unix, 0))); got != tc.want {
+			t.Errorf("totpCode at %d = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestMatchTOTPSkew(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111109, 0)
+	step := totpStep(now)
+
+	if got, ok := matchTOTP(secret, totpCode(secret, step-1), now, 1); !ok || got != step-1 {
+		t.Errorf("previous step: got %d, %v; want %d, true", got, ok, step-1)
+	}
+	if _, ok := matchTOTP(secret, totpCode(secret, step+2), now, 1); ok {
+		t.Error("a code two steps ahead matched with a skew of one")
+	}
+	if _, ok := matchTOTP(secret, "12345", now, 1); ok {
+		t.Error("a short code matched")
+	}
+}
+
+// enableTestTOTP switches 2FA on for a user and returns the secret and the
+// recovery codes
+func enableTestTOTP(t *testing.T, service *Service, userID string) ([]byte, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	encoded, otpauthURL, err := service.EnableTOTP(ctx, userID)
+	if err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+	if !strings.HasPrefix(otpauthURL, "otpauth://totp/") || !strings.Contains(otpauthURL, "secret="+encoded) {
+		t.Errorf("otpauth URL %q doesn't carry the secret", otpauthURL)
+	}
+	secret, err := totpEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+
+	if _, err := service.ConfirmTOTP(ctx, userID, "000000x"); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("confirming with a bad code: got %v, want ErrInvalidTOTPCode", err)
+	}
+	codes, err := service.ConfirmTOTP(ctx, userID, totpCode(secret, totpStep(time.Now())))
+	if err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	return secret, codes
+}
+
+// lastTOTPStep returns the time step of the last code accepted from a user
+func lastTOTPStep(t *testing.T, service *Service, userID string) int64 {
+	t.Helper()
+
+	user, err := service.userStore.GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	return user.TOTPLastStep
+}
+
+// passwordLogin logs in with testPassword and returns the 2FA challenge
+func passwordLogin(t *testing.T, service *Service, emailAddress string) string {
+	t.Helper()
+
+	response, err := service.Login(context.Background(), &LoginRequest{Email: emailAddress, Password: testPassword}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !response.TwoFactorRequired || response.Token != "" || response.ChallengeToken == "" {
+		t.Fatalf("Login = %+v, want a two-factor challenge without tokens", response)
+	}
+	return response.ChallengeToken
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "totp@example.com", "totp")
+	secret, _ := enableTestTOTP(t, service, registered.User.ID)
+	ctx := context.Background()
+
+	if _, _, err := service.EnableTOTP(ctx, registered.User.ID); !errors.Is(err, ErrTOTPAlreadyEnabled) {
+		t.Errorf("enabling twice: got %v, want ErrTOTPAlreadyEnabled", err)
+	}
+
+	challenge := passwordLogin(t, service, "totp@example.com")
+	confirmed := lastTOTPStep(t, service, registered.User.ID)
+
+	// The code used to confirm setup can't be replayed to log in
+	if _, err := service.CompleteTwoFactorLogin(ctx, challenge, totpCode(secret, confirmed), false); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("replayed code: got %v, want ErrInvalidTOTPCode", err)
+	}
+
+	next := totpCode(secret, confirmed+1)
+	response, err := service.CompleteTwoFactorLogin(ctx, challenge, next, false)
+	if err != nil {
+		t.Fatalf("CompleteTwoFactorLogin: %v", err)
+	}
+	if response.Token == "" || response.User.ID != registered.User.ID {
+		t.Errorf("response = %+v, want tokens for the user", response)
+	}
+
+	if _, err := service.CompleteTwoFactorLogin(ctx, challenge, next, false); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("reused challenge: got %v, want ErrInvalidChallenge", err)
+	}
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "recovery@example.com", "recovery")
+	_, codes := enableTestTOTP(t, service, registered.User.ID)
+	ctx := context.Background()
+
+	// Recovery codes work without the dash and in upper case, but only once
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if _, err := service.CompleteTwoFactorLogin(ctx, passwordLogin(t, service, "recovery@example.com"), typed, false); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if _, err := service.CompleteTwoFactorLogin(ctx, passwordLogin(t, service, "recovery@example.com"), codes[0], false); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Errorf("reused recovery code: got %v, want ErrInvalidTOTPCode", err)
+	}
+
+	user, _ := service.userStore.GetUserByID(ctx, registered.User.ID)
+	if len(user.RecoveryCodeHashes) != recoveryCodeCount-1 {
+		t.Errorf("%d recovery codes left, want %d", len(user.RecoveryCodeHashes), recoveryCodeCount-1)
+	}
+}
+
+func TestTwoFactorChallengeAttemptLimit(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "limit@example.com", "limit")
+	enableTestTOTP(t, service, registered.User.ID)
+	ctx := context.Background()
+
+	challenge := passwordLogin(t, service, "limit@example.com")
+	for i := 1; i < maxChallengeAttempts; i++ {
+		if _, err := service.CompleteTwoFactorLogin(ctx, challenge, "000000", false); !errors.Is(err, ErrInvalidTOTPCode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidTOTPCode", i, err)
+		}
+	}
+	if _, err := service.CompleteTwoFactorLogin(ctx, challenge, "000000", false); !errors.Is(err, ErrTooManyTwoFactorErrors) {
+		t.Fatalf("last attempt: got %v, want ErrTooManyTwoFactorErrors", err)
+	}
+	if _, err := service.CompleteTwoFactorLogin(ctx, challenge, "000000", false); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("after the limit: got %v, want ErrInvalidChallenge", err)
+	}
+}
+
+func TestTwoFactorHandlers(t *testing.T) {
+	service := newTestService(t, nil)
+	registered := registerTestUser(t, service, "handler@example.com", "handler")
+
+	h := NewHandler(service)
+	router := gin.New()
+	router.POST("/login", h.Login)
+	router.POST("/login/2fa", h.LoginTwoFactor)
+	router.POST("/2fa/enable", h.Middleware(), h.EnableTOTP)
+	router.POST("/2fa/confirm", h.Middleware(), h.ConfirmTOTP)
+
+	w := doRequest(t, router, http.MethodPost, "/2fa/enable", nil, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable: status %d, want 200: %s", w.Code, w.Body)
+	}
+	var enabled EnableTOTPResponse
+	decodeData(t, w, &enabled)
+	secret, err := totpEncoding.DecodeString(enabled.Secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+
+	code := TOTPCodeRequest{Code: totpCode(secret, totpStep(time.Now()))}
+	if w := doRequest(t, router, http.MethodPost, "/2fa/confirm", code, bearer(registered.Token)); w.Code != http.StatusOK {
+		t.Fatalf("confirm: status %d, want 200: %s", w.Code, w.Body)
+	}
+	if w := doRequest(t, router, http.MethodPost, "/2fa/enable", nil, bearer(registered.Token)); w.Code != http.StatusConflict {
+		t.Errorf("enable again: status %d, want 409", w.Code)
+	}
+
+	w = doRequest(t, router, http.MethodPost, "/login", LoginRequest{Email: "handler@example.com", Password: testPassword}, nil)
+	var challenge LoginResponse
+	decodeData(t, w, &challenge)
+	if !challenge.TwoFactorRequired || challenge.Token != "" {
+		t.Fatalf("login = %+v, want a two-factor challenge", challenge)
+	}
+
+	bad := TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: "000000"}
+	if w := doRequest(t, router, http.MethodPost, "/login/2fa", bad, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("bad code: status %d, want 401", w.Code)
+	}
+
+	good := TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: totpCode(secret, lastTOTPStep(t, service, registered.User.ID)+1)}
+	w = doRequest(t, router, http.MethodPost, "/login/2fa", good, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("good code: status %d, want 200: %s", w.Code, w.Body)
+	}
+	var response LoginResponse
+	decodeData(t, w, &response)
+	if response.Token == "" {
+		t.Error("two-factor login returned no access token")
+	}
+}
@@ -1,39 +1,388 @@
 package auth
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/audit"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
 )
 
 // LoginRequest represents a login request
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=6"`
+	RememberMe bool   `json:"remember_me"`
 }
 
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
 	Email     string `json:"email" binding:"required,email"`
 	Username  string `json:"username" binding:"required,min=3,max=50"`
-	Password  string `json:"password" binding:"required,min=6"`
+	Password  string `json:"password" binding:"required,max=72"`
 	FirstName string `json:"first_name" binding:"required,min=1,max=50"`
 	LastName  string `json:"last_name" binding:"required,min=1,max=50"`
+
+	// InviteCode is required while registration is invite-only
+	InviteCode string `json:"invite_code,omitempty"`
+
+	// CaptchaToken is the token from the CAPTCHA widget, required while
+	// CAPTCHA_ENABLED is set
+	CaptchaToken string `json:"captcha_token,omitempty"`
 }
 
 // LoginResponse represents a login response
 type LoginResponse struct {
-	Token     string    `json:"token"`
-	User      UserInfo  `json:"user"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Token        string    `json:"token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	SessionID    string    `json:"session_id,omitempty"`
+	User         *UserInfo `json:"user,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+
+	// Set instead of the tokens when the account requires a second factor
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+
+	// PasswordChangeRequired means the tokens only work for changing the
+	// password until the user has chosen a new one
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+
+	// VerificationRequiredBy is when an account that hasn't verified its
+	// email address stops being able to log in. EmailVerificationRequired
+	// is set instead of the tokens when there is no grace period.
+	VerificationRequiredBy    *time.Time `json:"verification_required_by,omitempty"`
+	EmailVerificationRequired bool       `json:"email_verification_required,omitempty"`
+}
+
+// TwoFactorLoginRequest completes a login that requires a second factor
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+	RememberMe     bool   `json:"remember_me"`
+}
+
+// TOTPCodeRequest carries a TOTP code
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// EnableTOTPResponse contains the provisioning details for an authenticator app
+type EnableTOTPResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+// ConfirmTOTPResponse contains the recovery codes issued when 2FA is switched on
+type ConfirmTOTPResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// PasskeyOptionsResponse starts a passkey ceremony. Options are passed to
+// navigator.credentials.create or .get, and the session ID must be sent
+// back with the browser's response.
+type PasskeyOptionsResponse struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+// PasskeyRegistrationRequest finishes registering a passkey. Credential is
+// the PublicKeyCredential returned by navigator.credentials.create.
+type PasskeyRegistrationRequest struct {
+	SessionID  string          `json:"session_id" binding:"required"`
+	Name       string          `json:"name" binding:"max=100"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+// PasskeyLoginRequest finishes a passkey login. Credential is the
+// PublicKeyCredential returned by navigator.credentials.get.
+type PasskeyLoginRequest struct {
+	SessionID  string          `json:"session_id" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+	RememberMe bool            `json:"remember_me"`
+}
+
+// PasskeyInfo represents public passkey information
+type PasskeyInfo struct {
+	ID        string    `json:"id"` // Base64url credential ID
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ForgotPasswordRequest represents a password reset request
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// MagicLinkRequest represents a request for a passwordless login link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// EmailVerificationRequest asks for a new email verification link
+type EmailVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,max=72"`
+}
+
+// ChangePasswordRequest represents a request to change the current user's password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,max=72"`
+
+	// RevokeSessions also signs out every other device, as
+	// REVOKE_SESSIONS_ON_PASSWORD_CHANGE does for every change
+	RevokeSessions bool `json:"revoke_sessions"`
+}
+
+// PasswordChangeResponse reports whether changing or resetting a password
+// logged the user out of their sessions. A change keeps the session it was
+// made from.
+type PasswordChangeResponse struct {
+	SessionsRevoked bool `json:"sessions_revoked"`
+}
+
+// AdminResetPasswordRequest represents an administrator setting a user's
+// password. Omitting the password generates one.
+type AdminResetPasswordRequest struct {
+	Password string `json:"password,omitempty" binding:"max=72"`
+
+	// MustChangePassword makes the user choose a new password before
+	// doing anything else
+	MustChangePassword bool `json:"must_change_password"`
+}
+
+// UserMetadataRequest replaces a user's metadata; an empty map clears it
+type UserMetadataRequest struct {
+	Metadata map[string]string `json:"metadata"`
+}
+
+// UserMetadataResponse is a user's metadata and the part of it copied into
+// their access tokens
+type UserMetadataResponse struct {
+	Metadata      map[string]string `json:"metadata"`
+	TokenMetadata map[string]string `json:"token_metadata"`
+}
+
+// AdminResetPasswordResponse carries a generated password, shown only once
+type AdminResetPasswordResponse struct {
+	Password string `json:"password,omitempty"`
+}
+
+// UpdateProfileRequest represents a profile update. Omitted fields keep
+// their current value.
+type UpdateProfileRequest struct {
+	Username  string `json:"username" binding:"omitempty,min=3,max=50"`
+	FirstName string `json:"first_name" binding:"omitempty,max=50"`
+	LastName  string `json:"last_name" binding:"omitempty,max=50"`
+}
+
+// ChangeEmailRequest represents a request to change the current user's email
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+}
+
+// SecurityQuestionsRequest represents a request to set the current user's
+// security questions. Answers are hashed like passwords, so they share the
+// 72-byte limit.
+type SecurityQuestionsRequest struct {
+	Password  string                   `json:"password" binding:"required"`
+	Questions []SecurityQuestionAnswer `json:"questions" binding:"required,dive"`
+}
+
+// SecurityQuestionAnswer is one security question and its answer
+type SecurityQuestionAnswer struct {
+	Question string `json:"question" binding:"required,max=200"`
+	Answer   string `json:"answer" binding:"required,max=72"`
+}
+
+// RecoveryQuestionsRequest asks for an account's security questions
+type RecoveryQuestionsRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RecoveryQuestionsResponse lists the questions to answer, in order
+type RecoveryQuestionsResponse struct {
+	Questions []string `json:"questions"`
+}
+
+// RecoveryAnswersRequest answers an account's security questions in the
+// order they were listed
+type RecoveryAnswersRequest struct {
+	Email   string   `json:"email" binding:"required,email"`
+	Answers []string `json:"answers" binding:"required,max=10,dive,max=72"`
+}
+
+// RecoveryAnswersResponse carries a password reset token for /reset-password
+type RecoveryAnswersResponse struct {
+	ResetToken string `json:"reset_token"`
+}
+
+// DeleteAccountRequest represents a request to delete the current user's account
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// AccountExport is the downloadable copy of a user's data. Secret fields
+// such as the password hash are excluded by the storage JSON tags.
+type AccountExport struct {
+	User       *storage.User `json:"user"`
+	APIKeys    []APIKeyInfo  `json:"api_keys"`
+	ExportedAt time.Time     `json:"exported_at"`
+}
+
+// LogoutRequest represents an optional logout request body
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshRequest represents a token refresh request
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 // UserInfo represents public user information
 type UserInfo struct {
 	ID        string    `json:"id"`
+	OrgID     string    `json:"org_id,omitempty"`
 	Email     string    `json:"email"`
 	Username  string    `json:"username"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
 	CreatedAt time.Time `json:"created_at"`
+	Role      string    `json:"role"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+
+	// MustChangePassword means the user must change their password before
+	// anything but POST /api/auth/change-password is allowed
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+
+	// DeletedAt is only set on soft-deleted users in admin listings
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Metadata is the metadata carried by the access token a request was
+	// authenticated with
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// AuditLogResponse represents one page of audit log entries
+type AuditLogResponse struct {
+	Entries []audit.Entry `json:"entries"`
+	Total   int           `json:"total"`
+	Limit   int           `json:"limit"`
+	Offset  int           `json:"offset"`
+}
+
+// UserListResponse represents one page of users
+type UserListResponse struct {
+	Users  []UserInfo `json:"users"`
+	Total  int        `json:"total"`
+	Limit  int        `json:"limit"`
+	Offset int        `json:"offset"`
+}
+
+// AdminStatsResponse represents aggregate counts for the admin dashboard.
+// Soft-deleted users aren't counted.
+type AdminStatsResponse struct {
+	TotalUsers      int               `json:"total_users"`
+	ActiveUsers     int               `json:"active_users"`
+	InactiveUsers   int               `json:"inactive_users"`
+	VerifiedUsers   int               `json:"verified_users"`
+	UnverifiedUsers int               `json:"unverified_users"`
+	Registrations   RegistrationStats `json:"registrations"`
+
+	// ActiveSessions is left out when tenancy is enabled, since sessions
+	// aren't tracked per organization
+	ActiveSessions *int      `json:"active_sessions,omitempty"`
+	GeneratedAt    time.Time `json:"generated_at"`
+}
+
+// RegistrationStats counts the users registered in recent periods
+type RegistrationStats struct {
+	Last24h int `json:"last_24h"`
+	Last7d  int `json:"last_7d"`
+	Last30d int `json:"last_30d"`
+}
+
+// UserSearchResponse represents the users matching a search, best match first
+type UserSearchResponse struct {
+	Users []UserInfo `json:"users"`
+	Query string     `json:"query"`
+	Limit int        `json:"limit"`
+}
+
+// ImportRowResult reports the outcome of one row of a user import
+type ImportRowResult struct {
+	Row      int    `json:"row"`
+	Email    string `json:"email,omitempty"`
+	Status   string `json:"status"` // created, skipped or error
+	Reason   string `json:"reason,omitempty"`
+	UserID   string `json:"user_id,omitempty"`
+	Password string `json:"password,omitempty"` // Generated temporary password
+}
+
+// ImportReport summarises a user import
+type ImportReport struct {
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,min=1,max=100"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateAPIKeyResponse contains a newly created API key. The plaintext key
+// is only ever returned in this response.
+type CreateAPIKeyResponse struct {
+	Key    string   `json:"key"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyInfo represents public API key information
+type APIKeyInfo struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateInviteRequest represents a request to invite an email to register
+type CreateInviteRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// CreateInviteResponse contains a newly created invite code. The code is
+// only ever returned in this response and the invite email.
+type CreateInviteResponse struct {
+	Code  string `json:"code"`
+	Email string `json:"email"`
+}
+
+// InviteInfo represents public invite information
+type InviteInfo struct {
+	ID        string     `json:"id"`
+	Email     string     `json:"email"`
+	Status    string     `json:"status"` // pending, used, revoked or expired
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
 }
 
 // ErrorResponse represents an error response
@@ -41,6 +390,23 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
 	Code    int    `json:"code"`
+
+	// RequestID lets clients reference the failed request in bug reports
+	RequestID string `json:"request_id,omitempty"`
+
+	// Details carries structured error information, such as failed password rules
+	Details interface{} `json:"details,omitempty"`
+
+	// Fields lists the request fields that failed validation
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes one request field that failed validation. Field is
+// the JSON name and Rule the failed binding rule, e.g. "required" or "min".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 // SuccessResponse represents a success response
@@ -58,11 +424,79 @@ type Claims struct {
 	// Note: jwt.RegisteredClaims will be embedded in the service layer
 }
 
-// SessionInfo represents session information
+// SessionInfo represents an active login session
 type SessionInfo struct {
-	UserID    string    `json:"user_id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	LoginTime time.Time `json:"login_time"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID        string       `json:"id"`
+	IPAddress string       `json:"ip_address,omitempty"`
+	UserAgent string       `json:"user_agent,omitempty"`
+	Device    audit.Device `json:"device"`
+	LoginTime time.Time    `json:"login_time"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	Current   bool         `json:"current"`
+
+	// LastActiveAt is when the session last logged in, refreshed or, with
+	// sliding sessions, made an authenticated request
+	LastActiveAt *time.Time `json:"last_active_at,omitempty"`
+}
+
+// ConnectionInfo describes an external provider identity linked to an account
+type ConnectionInfo struct {
+	Provider       string    `json:"provider"`
+	ProviderUserID string    `json:"provider_user_id"`
+	LinkedAt       time.Time `json:"linked_at"`
+}
+
+// ConnectionsResponse lists an account's linked providers along with its
+// other ways to log in
+type ConnectionsResponse struct {
+	Connections []ConnectionInfo `json:"connections"`
+	HasPassword bool             `json:"has_password"`
+	Passkeys    int              `json:"passkeys"`
+}
+
+// IntrospectRequest represents a token introspection request
+type IntrospectRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// WhoAmIResponse is the identity carried by an access token. ExpiresAt is
+// left out for API keys.
+type WhoAmIResponse struct {
+	UserID    string            `json:"user_id"`
+	Username  string            `json:"username"`
+	Email     string            `json:"email"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+}
+
+// TokenValidationResponse reports how long a valid access token has left
+type TokenValidationResponse struct {
+	Valid            bool      `json:"valid"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	SecondsRemaining int64     `json:"seconds_remaining"`
+}
+
+// Reasons an access token is rejected, reported in TokenErrorDetails so
+// clients can tell whether refreshing may help
+const (
+	TokenReasonExpired = "expired"
+	TokenReasonInvalid = "invalid"
+	TokenReasonRevoked = "revoked"
+)
+
+// TokenErrorDetails is the details of a 401 for a rejected access token
+type TokenErrorDetails struct {
+	Reason string `json:"reason"`
+}
+
+// IntrospectResponse describes a token; only Active is set for tokens that
+// aren't valid
+type IntrospectResponse struct {
+	Active   bool   `json:"active"`
+	UserID   string `json:"user_id,omitempty"`
+	OrgID    string `json:"org_id,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Username string `json:"username,omitempty"`
+	Exp      int64  `json:"exp,omitempty"`
+	Iat      int64  `json:"iat,omitempty"`
 }
@@ -0,0 +1,24 @@
+package auth
+
+// WhoAmI describes the identity an authenticated request presented. For an
+// access token the answer comes from the token's claims as issued, so a
+// username or email changed since shows up only in the profile. API keys
+// carry no claims, so they report the owning user as stored and no expiry.
+func WhoAmI(userInfo *UserInfo, claims *JWTClaims) *WhoAmIResponse {
+	if claims == nil {
+		return &WhoAmIResponse{
+			UserID:   userInfo.ID,
+			Username: userInfo.Username,
+			Email:    userInfo.Email,
+		}
+	}
+
+	expiresAt := claims.ExpiresAt.Time
+	return &WhoAmIResponse{
+		UserID:    claims.UserID,
+		Username:  claims.Username,
+		Email:     claims.Email,
+		Metadata:  claims.Metadata,
+		ExpiresAt: &expiresAt,
+	}
+}
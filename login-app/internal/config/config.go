@@ -2,16 +2,34 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/ids"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tokens"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server ServerConfig `json:"server"`
-	Auth   AuthConfig   `json:"auth"`
-	Log    LogConfig    `json:"log"`
+	Environment string `json:"environment"`
+
+	Server   ServerConfig   `json:"server"`
+	Auth     AuthConfig     `json:"auth"`
+	Log      LogConfig      `json:"log"`
+	OAuth    OAuthConfig    `json:"oauth"`
+	WebAuthn WebAuthnConfig `json:"webauthn"`
+	Events   EventsConfig   `json:"events"`
+	Email    EmailConfig    `json:"email"`
+	Storage  StorageConfig  `json:"storage"`
+	Tracing  TracingConfig  `json:"tracing"`
+	Tenancy  TenancyConfig  `json:"tenancy"`
 }
 
 // ServerConfig contains server-related configuration
@@ -20,57 +38,1523 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `json:"read_timeout"`
 	WriteTimeout time.Duration `json:"write_timeout"`
 	IdleTimeout  time.Duration `json:"idle_timeout"`
+
+	// ShutdownTimeout bounds how long in-flight requests may run after a
+	// shutdown signal before they are cut off
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+
+	// DrainDelay is how long /readyz reports not ready before the listener
+	// closes, giving load balancers time to stop routing traffic
+	DrainDelay time.Duration `json:"drain_delay"`
+
+	CORS CORSConfig `json:"cors"`
+
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For
+	// header is believed when determining the client IP. When empty the
+	// header is ignored and the connection's remote address is used.
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// IPFilter applies to every request, AdminIPFilter only to admin endpoints
+	IPFilter      IPFilterConfig `json:"ip_filter"`
+	AdminIPFilter IPFilterConfig `json:"admin_ip_filter"`
+
+	RateLimits RateLimitsConfig `json:"rate_limits"`
+
+	// IdempotencyTTL is how long a registration response is kept for
+	// retries with the same Idempotency-Key; zero disables idempotency keys
+	IdempotencyTTL time.Duration `json:"idempotency_ttl"`
+
+	RequestLimits RequestLimitsConfig `json:"request_limits"`
+
+	// RequireJSON rejects API request bodies that aren't application/json
+	// with 415, apart from routes documented with another content type
+	RequireJSON bool `json:"require_json"`
+
+	// Page templates and static files are embedded in the binary. With
+	// DiskAssets they are read from TemplateDir and StaticDir instead, and
+	// templates are reloaded on every request at the debug log level.
+	DiskAssets  bool   `json:"disk_assets"`
+	TemplateDir string `json:"template_dir"`
+	StaticDir   string `json:"static_dir"`
+
+	// APIOnly serves the JSON API without the web pages and their assets
+	APIOnly bool `json:"api_only"`
+}
+
+// RequestLimitsConfig bounds request bodies. Multipart uploads such as user
+// imports get MaxUploadBytes, every other body MaxBodyBytes. MaxJSONDepth
+// caps how deeply JSON objects and arrays may nest.
+type RequestLimitsConfig struct {
+	MaxBodyBytes   int `json:"max_body_bytes"`
+	MaxUploadBytes int `json:"max_upload_bytes"`
+	MaxJSONDepth   int `json:"max_json_depth"`
+}
+
+// RateLimitsConfig contains per-client-IP request limits for endpoints that
+// are attractive to abuse. Email covers endpoints that send email, such as
+// password reset and magic links.
+type RateLimitsConfig struct {
+	Login    RateLimit `json:"login"`
+	Register RateLimit `json:"register"`
+	Email    RateLimit `json:"email"`
+}
+
+// RateLimit is a token bucket: clients may burst up to Burst requests, and
+// the bucket refills at RequestsPerSecond. A zero rate disables the limit.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// IPFilterConfig contains client IP allow and deny lists. Entries are IPs or
+// CIDRs; deny wins over allow and an empty allowlist allows every IP.
+type IPFilterConfig struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// CORSConfig contains cross-origin resource sharing configuration
+type CORSConfig struct {
+	// AllowedOrigins lists origins allowed to make cross-origin requests;
+	// "*" allows any origin but cannot be combined with AllowCredentials
+	AllowedOrigins   []string      `json:"allowed_origins"`
+	AllowedMethods   []string      `json:"allowed_methods"`
+	AllowedHeaders   []string      `json:"allowed_headers"`
+	AllowCredentials bool          `json:"allow_credentials"`
+	MaxAge           time.Duration `json:"max_age"`
 }
 
 // AuthConfig contains authentication-related configuration
 type AuthConfig struct {
-	JWTSecret      string        `json:"jwt_secret"`
-	TokenDuration  time.Duration `json:"token_duration"`
-	BCryptCost     int           `json:"bcrypt_cost"`
-	SessionTimeout time.Duration `json:"session_timeout"`
+	JWTSecret string `json:"jwt_secret"`
+
+	// RequireJWTSecret refuses to start with the built-in JWTSecret in
+	// every environment, not only those that always require a secret
+	RequireJWTSecret bool `json:"require_jwt_secret"`
+
+	// PreviousJWTSecrets are rotated-out HS256 secrets. Tokens they signed
+	// keep verifying, selected by their kid, so rotating JWTSecret doesn't
+	// log everyone out; drop them once those tokens have expired.
+	PreviousJWTSecrets []string `json:"-"`
+
+	// SigningMethod selects HS256 (shared JWTSecret) or RS256 (RSA key pair).
+	// RSAPublicKeyFiles holds public keys of rotated-out private keys that
+	// should still verify outstanding tokens.
+	SigningMethod     string   `json:"signing_method"`
+	RSAPrivateKeyFile string   `json:"rsa_private_key_file"`
+	RSAPublicKeyFiles []string `json:"rsa_public_key_files"`
+
+	// AllowedAlgorithms lists the alg headers accepted on incoming tokens;
+	// any other algorithm, including "none", is rejected. Empty accepts
+	// only SigningMethod.
+	AllowedAlgorithms []string `json:"allowed_algorithms"`
+
+	// Issuer and Audience are set as the iss and aud claims and required on
+	// incoming tokens. An empty Audience is neither set nor checked.
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+
+	// ClockSkewLeeway is how far a token's exp and nbf may be off from the
+	// local clock before it is rejected, to tolerate drift between servers
+	ClockSkewLeeway time.Duration `json:"clock_skew_leeway"`
+
+	TokenDuration        time.Duration `json:"token_duration"`
+	RefreshTokenDuration time.Duration `json:"refresh_token_duration"`
+	RememberMeDuration   time.Duration `json:"remember_me_duration"` // Access token lifetime for "remember me" logins
+	BCryptCost           int           `json:"bcrypt_cost"`
+	SessionTimeout       time.Duration `json:"session_timeout"`
+	PasswordResetTTL     time.Duration `json:"password_reset_ttl"`
+	MagicLinkTTL         time.Duration `json:"magic_link_ttl"`
+	EmailChangeTTL       time.Duration `json:"email_change_ttl"`
+
+	// PasswordResetMode is "stored" for single-use reset tokens kept in the
+	// token store, or "stateless" for signed tokens that need no storage.
+	// Stateless tokens are bound to the password hash, so they stop working
+	// once the password changes, but stay reusable until then.
+	PasswordResetMode string `json:"password_reset_mode"`
+
+	// RevokeSessionsOnPasswordChange logs users out everywhere else when
+	// they change or reset their password, so whoever knew the old one
+	// loses access. A change keeps the session it was made from.
+	RevokeSessionsOnPasswordChange bool `json:"revoke_sessions_on_password_change"`
+
+	// Brute-force protection: accounts lock after MaxFailedLogins consecutive
+	// failures and client IPs after MaxFailedLoginsPerIP failures within
+	// FailedLoginWindow. Zero disables the respective check.
+	MaxFailedLogins      int           `json:"max_failed_logins"`
+	MaxFailedLoginsPerIP int           `json:"max_failed_logins_per_ip"`
+	FailedLoginWindow    time.Duration `json:"failed_login_window"`
+	LockoutDuration      time.Duration `json:"lockout_duration"`
+
+	// SlidingSessions expire sessions after a period without activity
+	SlidingSessions SlidingSessionsConfig `json:"sliding_sessions"`
+
+	// LoginBackoff delays logins instead of, or as well as, locking accounts
+	LoginBackoff LoginBackoffConfig `json:"login_backoff"`
+
+	// LoginMinDuration pads every login to at least this long and checks
+	// passwords for unknown emails against a dummy hash, so timing doesn't
+	// reveal which emails are registered; zero disables it
+	LoginMinDuration time.Duration `json:"login_min_duration"`
+
+	// UnlockEmail emails locked accounts a single-use link that lifts the
+	// lock early. The link expires after UnlockLinkTTL.
+	UnlockEmail   bool          `json:"unlock_email"`
+	UnlockLinkTTL time.Duration `json:"unlock_link_ttl"`
+
+	// SecretEncryptionKey encrypts secrets stored at rest such as TOTP seeds.
+	// When empty a key is derived from JWTSecret.
+	SecretEncryptionKey   string        `json:"-"`
+	TwoFactorChallengeTTL time.Duration `json:"two_factor_challenge_ttl"`
+	TOTPSkew              int           `json:"totp_skew"` // Accepted time steps either side of now
+
+	// RevocationSweepInterval controls how often expired entries are evicted
+	// from the in-memory revoked token blacklist, session store and refresh
+	// token store
+	RevocationSweepInterval time.Duration `json:"revocation_sweep_interval"`
+
+	// AdminEmails are granted the admin role when they register
+	AdminEmails []string `json:"admin_emails"`
+
+	// InviteOnly limits registration to emails holding an unused invite
+	// code from an administrator. Invites expire after InviteTTL.
+	InviteOnly bool          `json:"invite_only"`
+	InviteTTL  time.Duration `json:"invite_ttl"`
+
+	EmailVerification EmailVerificationConfig `json:"email_verification"`
+
+	Captcha CaptchaConfig `json:"captcha"`
+
+	RegistrationThrottle RegistrationThrottleConfig `json:"registration_throttle"`
+
+	PasswordPolicy PasswordPolicy `json:"password_policy"`
+
+	// PasswordHasher is the algorithm new password hashes use, "bcrypt"
+	// (with BCryptCost) or "argon2id". Hashes made with another algorithm
+	// or weaker settings are upgraded when their owner logs in.
+	PasswordHasher string       `json:"password_hasher"`
+	Argon2         Argon2Config `json:"argon2"`
+
+	BCryptCalibration BCryptCalibrationConfig `json:"bcrypt_calibration"`
+
+	SecurityQuestions SecurityQuestionsConfig `json:"security_questions"`
+
+	TokenCookie TokenCookieConfig `json:"token_cookie"`
+
+	TokenMetadata TokenMetadataConfig `json:"token_metadata"`
+
+	// Tokens sets the size and encoding of the random tokens handed to
+	// users: refresh, password reset, verification and magic link tokens
+	Tokens TokensConfig `json:"tokens"`
+}
+
+// TokensConfig sets how random tokens are generated
+type TokensConfig struct {
+	Bytes    int    `json:"bytes"`
+	Encoding string `json:"encoding"` // hex or base64url
+}
+
+// TokenCookieConfig controls sending access tokens to browsers in an
+// HttpOnly cookie, out of reach of scripts injected into the page. The
+// auth middleware accepts the cookie when no Authorization header is sent.
+// With OmitFromBody the token is left out of the JSON response entirely;
+// it has no effect while the cookie is disabled. Secure and SameSite
+// default from the environment: strict and HTTPS-only everywhere except
+// development and test, which are usually served over plain HTTP.
+type TokenCookieConfig struct {
+	Enabled      bool   `json:"enabled"`
+	OmitFromBody bool   `json:"omit_from_body"`
+	Name         string `json:"name"`
+	Domain       string `json:"domain"`
+	Secure       bool   `json:"secure"`
+	SameSite     string `json:"same_site"` // strict, lax or none
+}
+
+// TokenMetadataConfig controls copying user metadata into access token
+// claims. Only the Keys listed are copied, and users can't be given
+// metadata whose copied keys encode to more than MaxBytes of JSON, which
+// keeps tokens small enough for headers and cookies.
+type TokenMetadataConfig struct {
+	Keys     []string `json:"keys"`
+	MaxBytes int      `json:"max_bytes"`
+}
+
+// SlidingSessionsConfig makes sessions expire IdleTimeout after their last
+// authenticated request or refresh instead of at a fixed time. Activity
+// extends a session, but never past MaxLifetime after its login. Access
+// tokens can't be changed once issued, so expired sessions are enforced
+// through the session store: their access tokens stop validating and their
+// refresh tokens stop working.
+type SlidingSessionsConfig struct {
+	Enabled     bool          `json:"enabled"`
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	MaxLifetime time.Duration `json:"max_lifetime"`
+}
+
+// LoginBackoffConfig controls progressive login delays. Each consecutive
+// failed login for an email makes the next attempt wait, starting at
+// BaseDelay and doubling up to MaxDelay; early attempts are rejected with
+// a Retry-After. A successful login resets the delay.
+type LoginBackoffConfig struct {
+	Enabled   bool          `json:"enabled"`
+	BaseDelay time.Duration `json:"base_delay"`
+	MaxDelay  time.Duration `json:"max_delay"`
+}
+
+// SecurityQuestionsConfig controls account recovery with security questions,
+// a fallback for deployments without reliable email. Users set Count
+// questions and must answer all of them to get a password reset token;
+// MaxAttempts wrong tries lock recovery for the lockout duration.
+type SecurityQuestionsConfig struct {
+	Enabled     bool `json:"enabled"`
+	Count       int  `json:"count"`
+	MaxAttempts int  `json:"max_attempts"`
+}
+
+// BCryptCalibrationConfig controls timing bcrypt at startup. AutoCost
+// replaces BCryptCost with the highest cost, at least MinCost, that hashes
+// within TargetDuration on this machine. A hash at the cost in use taking
+// longer than SlowWarning is logged; zero disables the check.
+type BCryptCalibrationConfig struct {
+	AutoCost       bool          `json:"auto_cost"`
+	TargetDuration time.Duration `json:"target_duration"`
+	MinCost        int           `json:"min_cost"`
+	SlowWarning    time.Duration `json:"slow_warning"`
+}
+
+// MaxClockSkewLeeway caps AuthConfig.ClockSkewLeeway
+const MaxClockSkewLeeway = 5 * time.Minute
+
+// Password hashing algorithms
+const (
+	PasswordHasherBcrypt   = "bcrypt"
+	PasswordHasherArgon2id = "argon2id"
+)
+
+// Password reset token modes
+const (
+	PasswordResetStored    = "stored"
+	PasswordResetStateless = "stateless"
+)
+
+// MaxSecurityQuestions caps SecurityQuestionsConfig.Count
+const MaxSecurityQuestions = 10
+
+// SameSite modes for the token cookie
+const (
+	SameSiteStrict = "strict"
+	SameSiteLax    = "lax"
+	SameSiteNone   = "none"
+)
+
+// Argon2Config contains argon2id cost parameters
+type Argon2Config struct {
+	MemoryKiB   int `json:"memory_kib"`
+	Iterations  int `json:"iterations"`
+	Parallelism int `json:"parallelism"`
+}
+
+// PasswordPolicy contains the rules new passwords must satisfy
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	RejectCommon  bool `json:"reject_common"` // Reject passwords from the embedded common password list
+
+	BreachCheck BreachCheckConfig `json:"breach_check"`
+}
+
+// EmailVerificationConfig controls verifying the email address of new
+// accounts. Until a new account is verified it can log in for GracePeriod
+// after registering; with no grace period it can't log in at all.
+type EmailVerificationConfig struct {
+	Required    bool          `json:"required"`
+	GracePeriod time.Duration `json:"grace_period"`
+	LinkTTL     time.Duration `json:"link_ttl"`
+}
+
+// CaptchaConfig controls requiring a CAPTCHA to register. The token the
+// client gets from the provider's widget is checked with the provider's
+// siteverify API, using the provider's standard URL unless VerifyURL is
+// set; registration is refused when it can't be checked in Timeout. The
+// stub provider accepts a fixed token and is for tests only.
+type CaptchaConfig struct {
+	Enabled   bool          `json:"enabled"`
+	Provider  string        `json:"provider"` // hcaptcha, recaptcha or stub
+	Secret    string        `json:"-"`
+	VerifyURL string        `json:"verify_url"`
+	Timeout   time.Duration `json:"timeout"`
+}
+
+// RegistrationThrottleConfig limits how many accounts one network can
+// register: at most MaxPerSubnet per Window from each IPv4 /IPv4Prefix or
+// IPv6 /IPv6Prefix block, counted from the first registration. A zero
+// MaxPerSubnet disables the limit.
+type RegistrationThrottleConfig struct {
+	MaxPerSubnet int           `json:"max_per_subnet"`
+	Window       time.Duration `json:"window"`
+	IPv4Prefix   int           `json:"ipv4_prefix"`
+	IPv6Prefix   int           `json:"ipv6_prefix"`
+}
+
+// CAPTCHA providers
+const (
+	CaptchaHCaptcha  = "hcaptcha"
+	CaptchaReCAPTCHA = "recaptcha"
+	CaptchaStub      = "stub"
+)
+
+// BreachCheckConfig controls rejecting new passwords that appear in known
+// data breaches, looked up with the Have I Been Pwned range API. Only the
+// first five hex digits of the password's SHA-1 hash are sent.
+type BreachCheckConfig struct {
+	Enabled bool   `json:"enabled"`
+	APIURL  string `json:"api_url"`
+
+	// Threshold is how many breaches a password may appear in before it
+	// is rejected; 1 rejects any breached password
+	Threshold int           `json:"threshold"`
+	Timeout   time.Duration `json:"timeout"`
+
+	// FailOpen accepts passwords when the API can't be reached instead of
+	// rejecting the request
+	FailOpen bool `json:"fail_open"`
+}
+
+// OAuthConfig contains external identity provider configuration
+type OAuthConfig struct {
+	// DuplicateEmailPolicy decides what happens when a provider login
+	// matches the email of an existing account: auto_link, require_confirmation or reject
+	DuplicateEmailPolicy string        `json:"duplicate_email_policy"`
+	LinkConfirmationTTL  time.Duration `json:"link_confirmation_ttl"`
+
+	// Providers are the enabled login providers, keyed by the name used in
+	// /api/auth/oauth/:provider
+	Providers map[string]OAuthProviderConfig `json:"providers"`
+}
+
+// OAuthProviderConfig configures one OAuth login provider. The type
+// defaults to the provider name; google and github have built-in endpoints,
+// anything else is a generic OpenID Connect IdP that needs its URLs set.
+type OAuthProviderConfig struct {
+	Type         string   `json:"type"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"-"`
+	RedirectURL  string   `json:"redirect_url"`
+	AuthURL      string   `json:"auth_url"`
+	TokenURL     string   `json:"token_url"`
+	UserInfoURL  string   `json:"userinfo_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+// Duplicate email policies for external identity logins
+const (
+	LinkPolicyAutoLink            = "auto_link"
+	LinkPolicyRequireConfirmation = "require_confirmation"
+	LinkPolicyReject              = "reject"
+)
+
+// WebAuthnConfig contains passkey (WebAuthn) relying party configuration
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are bound to, without scheme or port.
+	// When empty the host of the app's base URL is used.
+	RPID          string `json:"rp_id"`
+	RPDisplayName string `json:"rp_display_name"`
+
+	// RPOrigins are the origins allowed to run passkey ceremonies, such as
+	// https://example.com. When empty the app's base URL is used.
+	RPOrigins []string `json:"rp_origins"`
+
+	// Timeout bounds how long a registration or login ceremony may take
+	Timeout time.Duration `json:"timeout"`
+}
+
+// EventsConfig contains security event export configuration
+type EventsConfig struct {
+	// Sinks lists the active sinks: stdout, file and/or http
+	Sinks         []string      `json:"sinks"`
+	FilePath      string        `json:"file_path"`
+	HTTPEndpoint  string        `json:"http_endpoint"`
+	HTTPToken     string        `json:"-"` // Never include in JSON
+	MaxRetries    int           `json:"max_retries"`
+	BufferSize    int           `json:"buffer_size"`
+	BatchSize     int           `json:"batch_size"`
+	FlushInterval time.Duration `json:"flush_interval"`
+
+	// WebhookURLs receive account lifecycle events such as user.registered,
+	// signed with WebhookSecret; retries share MaxRetries
+	WebhookURLs   []string `json:"webhook_urls"`
+	WebhookSecret string   `json:"-"` // Never include in JSON
+}
+
+// EmailConfig contains outgoing email configuration
+type EmailConfig struct {
+	// Transport is "log", which only logs messages, or "smtp"
+	Transport string `json:"transport"`
+	From      string `json:"from"`
+
+	// BaseURL is the public address of the app, used to build links in emails
+	BaseURL string `json:"base_url"`
+
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     int    `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"-"` // Never include in JSON
+}
+
+// Email transports
+const (
+	EmailTransportLog  = "log"
+	EmailTransportSMTP = "smtp"
+)
+
+// StorageConfig contains storage backend configuration
+type StorageConfig struct {
+	// SessionBackend holds sessions, refresh tokens and revoked access
+	// tokens: "memory", or "redis" to share them between replicas
+	SessionBackend string `json:"session_backend"`
+
+	// UserStoreShards splits the in-memory user store into this many
+	// separately locked shards; 0 keeps a single lock
+	UserStoreShards int `json:"user_store_shards"`
+
+	// IDFormat is the format of new record IDs: "hex", "uuidv4", or
+	// "uuidv7" for time-ordered UUIDs. Existing IDs keep their format.
+	IDFormat string `json:"id_format"`
+
+	// UserCache caches users by ID in front of the user store
+	UserCache UserCacheConfig `json:"user_cache"`
+
+	// Retry retries user store operations that fail with transient errors
+	Retry RetryConfig `json:"retry"`
+
+	Redis RedisConfig `json:"redis"`
+
+	Database DatabaseConfig `json:"database"`
+}
+
+// UserCacheConfig sizes the in-process user cache. Users are kept for up
+// to TTL, which bounds how long changes made by other replicas take to
+// show; a MaxSize of 0 disables the cache.
+type UserCacheConfig struct {
+	MaxSize int           `json:"max_size"`
+	TTL     time.Duration `json:"ttl"`
+}
+
+// RetryConfig controls retrying store operations that fail with transient
+// errors such as deadlocks. MaxAttempts counts the first try, so 1 never
+// retries; waits start below BaseDelay and double up to MaxDelay.
+type RetryConfig struct {
+	MaxAttempts int           `json:"max_attempts"`
+	BaseDelay   time.Duration `json:"base_delay"`
+	MaxDelay    time.Duration `json:"max_delay"`
+}
+
+// DatabaseConfig selects the SQL database whose schema is managed by the
+// migrations runner. Driver must name a compiled-in database/sql driver,
+// such as sqlite3; leaving it empty keeps everything in memory.
+type DatabaseConfig struct {
+	Driver string `json:"driver"`
+	DSN    string `json:"-"` // Never include in JSON, it may hold credentials
+
+	// MigrateOnStart applies pending migrations before the server starts
+	MigrateOnStart bool `json:"migrate_on_start"`
+}
+
+// RedisConfig contains Redis connection settings
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"-"` // Never include in JSON
+	DB       int    `json:"db"`
+
+	// KeyPrefix namespaces the app's keys so a Redis can be shared
+	KeyPrefix string `json:"key_prefix"`
+
+	DialTimeout time.Duration `json:"dial_timeout"`
+}
+
+// Session storage backends
+const (
+	SessionBackendMemory = "memory"
+	SessionBackendRedis  = "redis"
+)
+
+// TracingConfig contains OpenTelemetry tracing configuration. When tracing
+// is disabled spans are still created but go to a no-op tracer.
+type TracingConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Endpoint is the OTLP/HTTP collector address as host:port
+	Endpoint string `json:"endpoint"`
+	Insecure bool   `json:"insecure"`
+
+	ServiceName string `json:"service_name"`
+
+	// SampleRatio is the fraction of new traces recorded; requests that
+	// arrive with a sampled parent are always recorded
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// TenancyConfig controls hosting several isolated organizations in one
+// deployment. A request's organization is the subdomain of BaseDomain it
+// was sent to, or else the Header value, or else DefaultOrg. Emails and
+// usernames are unique per organization and tokens only work in theirs.
+type TenancyConfig struct {
+	Enabled    bool   `json:"enabled"`
+	BaseDomain string `json:"base_domain"` // e.g. "example.com" maps acme.example.com to org "acme"
+	Header     string `json:"header"`
+	DefaultOrg string `json:"default_org"` // Empty for the default organization
 }
 
 // LogConfig contains logging configuration
 type LogConfig struct {
 	Level  string `json:"level"`
 	Format string `json:"format"`
+
+	// RequestSampleRatio is the fraction of completed requests logged.
+	// Responses in the RequestAlways status classes ("4xx", "5xx") are
+	// logged regardless, so errors are kept when the rest is sampled.
+	RequestSampleRatio float64  `json:"request_sample_ratio"`
+	RequestAlways      []string `json:"request_always"`
 }
 
+// defaultJWTSecret is the placeholder secret that must be replaced in production
+const defaultJWTSecret = "your-256-bit-secret-key-here-make-sure-its-long-enough"
+
 // Load loads configuration based on the environment
 func Load(environment string) (*Config, error) {
+	cfg := defaults(environment)
+	applyEnv(cfg)
+	if err := applySecretFiles(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// defaults returns the built-in configuration for an environment
+func defaults(environment string) *Config {
 	cfg := &Config{
+		Environment: environment,
 		Server: ServerConfig{
-			Port:         getEnv("PORT", "8080"),
+			Port:         "8080",
 			ReadTimeout:  15 * time.Second,
 			WriteTimeout: 15 * time.Second,
 			IdleTimeout:  60 * time.Second,
+
+			ShutdownTimeout: 30 * time.Second,
+
+			CORS: CORSConfig{
+				AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+				AllowedHeaders: []string{"Origin", "Content-Type", "Authorization", "X-Request-ID"},
+				MaxAge:         10 * time.Minute,
+			},
+
+			RateLimits: RateLimitsConfig{
+				Login:    RateLimit{RequestsPerSecond: 0.5, Burst: 10},
+				Register: RateLimit{RequestsPerSecond: 0.1, Burst: 5},
+				Email:    RateLimit{RequestsPerSecond: 0.05, Burst: 3},
+			},
+
+			IdempotencyTTL: 24 * time.Hour,
+
+			RequestLimits: RequestLimitsConfig{
+				MaxBodyBytes:   1 << 20,
+				MaxUploadBytes: 8 << 20,
+				MaxJSONDepth:   32,
+			},
+			RequireJSON: true,
+
+			TemplateDir: "web/templates",
+			StaticDir:   "web/static",
 		},
 		Auth: AuthConfig{
-			JWTSecret:      getEnv("JWT_SECRET", "your-256-bit-secret-key-here-make-sure-its-long-enough"),
-			TokenDuration:  24 * time.Hour,
-			BCryptCost:     getBcryptCost(),
-			SessionTimeout: 24 * time.Hour,
+			JWTSecret:            defaultJWTSecret,
+			SigningMethod:        "HS256",
+			Issuer:               "login-app",
+			ClockSkewLeeway:      30 * time.Second,
+			TokenDuration:        24 * time.Hour,
+			RefreshTokenDuration: 30 * 24 * time.Hour,
+			RememberMeDuration:   30 * 24 * time.Hour,
+			BCryptCost:           10,
+			SessionTimeout:       24 * time.Hour,
+			PasswordResetTTL:     30 * time.Minute,
+			PasswordResetMode:    PasswordResetStored,
+			InviteTTL:            7 * 24 * time.Hour,
+			MagicLinkTTL:         15 * time.Minute,
+			EmailChangeTTL:       24 * time.Hour,
+			EmailVerification: EmailVerificationConfig{
+				LinkTTL: 24 * time.Hour,
+			},
+			Captcha: CaptchaConfig{
+				Provider: CaptchaHCaptcha,
+				Timeout:  5 * time.Second,
+			},
+			RegistrationThrottle: RegistrationThrottleConfig{
+				Window:     time.Hour,
+				IPv4Prefix: 24,
+				IPv6Prefix: 64,
+			},
+			MaxFailedLogins:      5,
+			MaxFailedLoginsPerIP: 20,
+			FailedLoginWindow:    15 * time.Minute,
+			LockoutDuration:      15 * time.Minute,
+			UnlockLinkTTL:        time.Hour,
+
+			TwoFactorChallengeTTL: 5 * time.Minute,
+			TOTPSkew:              1,
+
+			RevocationSweepInterval: 5 * time.Minute,
+
+			PasswordPolicy: PasswordPolicy{
+				MinLength:    8,
+				RequireUpper: true,
+				RequireLower: true,
+				RequireDigit: true,
+				RejectCommon: true,
+				BreachCheck: BreachCheckConfig{
+					APIURL:    "https://api.pwnedpasswords.com/range/",
+					Threshold: 1,
+					Timeout:   3 * time.Second,
+					FailOpen:  true,
+				},
+			},
+
+			// Argon2 parameters follow the OWASP minimum recommendation
+			PasswordHasher: PasswordHasherBcrypt,
+			Argon2: Argon2Config{
+				MemoryKiB:   19 * 1024,
+				Iterations:  2,
+				Parallelism: 1,
+			},
+			BCryptCalibration: BCryptCalibrationConfig{
+				TargetDuration: 250 * time.Millisecond,
+				MinCost:        10,
+				SlowWarning:    time.Second,
+			},
+
+			SlidingSessions: SlidingSessionsConfig{
+				IdleTimeout: 30 * time.Minute,
+				MaxLifetime: 7 * 24 * time.Hour,
+			},
+
+			LoginBackoff: LoginBackoffConfig{
+				BaseDelay: time.Second,
+				MaxDelay:  5 * time.Minute,
+			},
+
+			SecurityQuestions: SecurityQuestionsConfig{
+				Count:       3,
+				MaxAttempts: 5,
+			},
+
+			TokenCookie: TokenCookieConfig{
+				Name:     "access_token",
+				Secure:   true,
+				SameSite: SameSiteStrict,
+			},
+
+			TokenMetadata: TokenMetadataConfig{
+				MaxBytes: 1024,
+			},
+
+			Tokens: TokensConfig{
+				Bytes:    tokens.MinBytes,
+				Encoding: tokens.EncodingHex,
+			},
 		},
 		Log: LogConfig{
-			Level:  getEnv("LOG_LEVEL", "info"),
-			Format: getEnv("LOG_FORMAT", "text"),
+			Level:              "info",
+			Format:             "text",
+			RequestSampleRatio: 1,
+			RequestAlways:      []string{"5xx"},
+		},
+		OAuth: OAuthConfig{
+			DuplicateEmailPolicy: LinkPolicyReject,
+			LinkConfirmationTTL:  30 * time.Minute,
+		},
+		WebAuthn: WebAuthnConfig{
+			RPDisplayName: "Login App",
+			Timeout:       5 * time.Minute,
+		},
+		Events: EventsConfig{
+			MaxRetries:    3,
+			BufferSize:    1024,
+			BatchSize:     100,
+			FlushInterval: time.Second,
+		},
+		Email: EmailConfig{
+			Transport: EmailTransportLog,
+			From:      "Login App <no-reply@localhost>",
+			BaseURL:   "http://localhost:8080",
+			SMTPPort:  587,
+		},
+		Storage: StorageConfig{
+			SessionBackend: SessionBackendMemory,
+			IDFormat:       ids.FormatHex,
+			UserCache: UserCacheConfig{
+				TTL: 30 * time.Second,
+			},
+			Retry: RetryConfig{
+				MaxAttempts: 3,
+				BaseDelay:   50 * time.Millisecond,
+				MaxDelay:    time.Second,
+			},
+			Redis: RedisConfig{
+				Addr:        "localhost:6379",
+				KeyPrefix:   "login-app:",
+				DialTimeout: 5 * time.Second,
+			},
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "localhost:4318",
+			ServiceName: "login-app",
+			SampleRatio: 1,
 		},
+		Tenancy: TenancyConfig{
+			Header: "X-Org-ID",
+		},
+	}
+
+	if profile, ok := environments[environment]; ok {
+		profile.apply(cfg)
+	}
+
+	return cfg
+}
+
+// environmentProfile describes how an environment differs from the shared defaults
+type environmentProfile struct {
+	// strictSecrets rejects the built-in JWT secret and requires a private
+	// key file for RS256, so the environment can't run with secrets that
+	// are published in this repository
+	strictSecrets bool
+
+	// allowDefaultSecret keeps the built-in JWT secret from being reported
+	// at startup, for environments that never hold real accounts
+	allowDefaultSecret bool
+
+	// apply overrides the shared defaults for the environment
+	apply func(cfg *Config)
+}
+
+// environments holds the recognised environments. Adding an environment
+// only needs an entry here.
+var environments = map[string]environmentProfile{
+	"production": {
+		strictSecrets: true,
+		apply: func(cfg *Config) {
+			cfg.Auth.BCryptCost = 12 // Higher cost for production
+			cfg.Log.Level = "warn"
+		},
+	},
+	"staging": {
+		strictSecrets: true,
+		apply: func(cfg *Config) {
+			cfg.Auth.BCryptCost = 12 // Same cost as production so timings match
+			cfg.Log.Level = "info"
+		},
+	},
+	"development": {
+		apply: func(cfg *Config) {
+			cfg.Auth.BCryptCost = 8 // Lower cost for development
+			cfg.Log.Level = "debug"
+			cfg.Server.CORS.AllowedOrigins = []string{"*"}
+			relaxTokenCookie(cfg)
+		},
+	},
+	"test": {
+		allowDefaultSecret: true,
+		apply: func(cfg *Config) {
+			cfg.Auth.BCryptCost = 4 // bcrypt's minimum, so test suites hash quickly
+			cfg.Log.Level = "error"
+			cfg.Server.CORS.AllowedOrigins = []string{"*"}
+			cfg.Server.RateLimits = RateLimitsConfig{} // Suites register and log in far faster than any client
+			cfg.Auth.Captcha.Provider = CaptchaStub    // Suites can't solve real CAPTCHAs
+			relaxTokenCookie(cfg)
+		},
+	},
+}
+
+// relaxTokenCookie lets the token cookie work over plain HTTP and survive
+// navigation from other local ports, for environments without TLS
+func relaxTokenCookie(cfg *Config) {
+	cfg.Auth.TokenCookie.Secure = false
+	cfg.Auth.TokenCookie.SameSite = SameSiteLax
+}
+
+// environmentNames returns the recognised environments in sorted order
+func environmentNames() []string {
+	names := make([]string, 0, len(environments))
+	for name := range environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyEnv overrides configuration with any environment variables that are set
+func applyEnv(cfg *Config) {
+	cfg.Server.Port = getEnv("PORT", cfg.Server.Port)
+	cfg.Server.ShutdownTimeout = getEnvDuration("SHUTDOWN_TIMEOUT", cfg.Server.ShutdownTimeout)
+	cfg.Server.DrainDelay = getEnvDuration("SHUTDOWN_DRAIN_DELAY", cfg.Server.DrainDelay)
+	cfg.Server.CORS.AllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", cfg.Server.CORS.AllowedOrigins)
+	cfg.Server.CORS.AllowedMethods = getEnvList("CORS_ALLOWED_METHODS", cfg.Server.CORS.AllowedMethods)
+	cfg.Server.CORS.AllowedHeaders = getEnvList("CORS_ALLOWED_HEADERS", cfg.Server.CORS.AllowedHeaders)
+	cfg.Server.CORS.AllowCredentials = getEnvBool("CORS_ALLOW_CREDENTIALS", cfg.Server.CORS.AllowCredentials)
+	cfg.Server.CORS.MaxAge = getEnvDuration("CORS_MAX_AGE", cfg.Server.CORS.MaxAge)
+	cfg.Server.TrustedProxies = getEnvList("TRUSTED_PROXIES", cfg.Server.TrustedProxies)
+	cfg.Server.IPFilter.Allow = getEnvList("IP_ALLOWLIST", cfg.Server.IPFilter.Allow)
+	cfg.Server.IPFilter.Deny = getEnvList("IP_DENYLIST", cfg.Server.IPFilter.Deny)
+	cfg.Server.AdminIPFilter.Allow = getEnvList("ADMIN_IP_ALLOWLIST", cfg.Server.AdminIPFilter.Allow)
+	cfg.Server.AdminIPFilter.Deny = getEnvList("ADMIN_IP_DENYLIST", cfg.Server.AdminIPFilter.Deny)
+	cfg.Server.RateLimits.Login.RequestsPerSecond = getEnvFloat("RATE_LIMIT_LOGIN_RPS", cfg.Server.RateLimits.Login.RequestsPerSecond)
+	cfg.Server.RateLimits.Login.Burst = getEnvInt("RATE_LIMIT_LOGIN_BURST", cfg.Server.RateLimits.Login.Burst)
+	cfg.Server.RateLimits.Register.RequestsPerSecond = getEnvFloat("RATE_LIMIT_REGISTER_RPS", cfg.Server.RateLimits.Register.RequestsPerSecond)
+	cfg.Server.RateLimits.Register.Burst = getEnvInt("RATE_LIMIT_REGISTER_BURST", cfg.Server.RateLimits.Register.Burst)
+	cfg.Server.RateLimits.Email.RequestsPerSecond = getEnvFloat("RATE_LIMIT_EMAIL_RPS", cfg.Server.RateLimits.Email.RequestsPerSecond)
+	cfg.Server.RateLimits.Email.Burst = getEnvInt("RATE_LIMIT_EMAIL_BURST", cfg.Server.RateLimits.Email.Burst)
+	cfg.Server.IdempotencyTTL = getEnvDuration("IDEMPOTENCY_TTL", cfg.Server.IdempotencyTTL)
+	cfg.Server.RequestLimits.MaxBodyBytes = getEnvInt("MAX_BODY_BYTES", cfg.Server.RequestLimits.MaxBodyBytes)
+	cfg.Server.RequestLimits.MaxUploadBytes = getEnvInt("MAX_UPLOAD_BYTES", cfg.Server.RequestLimits.MaxUploadBytes)
+	cfg.Server.RequestLimits.MaxJSONDepth = getEnvInt("MAX_JSON_DEPTH", cfg.Server.RequestLimits.MaxJSONDepth)
+	cfg.Server.RequireJSON = getEnvBool("REQUIRE_JSON_CONTENT_TYPE", cfg.Server.RequireJSON)
+	cfg.Server.DiskAssets = getEnvBool("DISK_ASSETS", cfg.Server.DiskAssets)
+	cfg.Server.TemplateDir = getEnv("TEMPLATE_DIR", cfg.Server.TemplateDir)
+	cfg.Server.StaticDir = getEnv("STATIC_DIR", cfg.Server.StaticDir)
+	cfg.Server.APIOnly = getEnvBool("API_ONLY", cfg.Server.APIOnly)
+
+	cfg.Auth.JWTSecret = getEnv("JWT_SECRET", cfg.Auth.JWTSecret)
+	cfg.Auth.RequireJWTSecret = getEnvBool("REQUIRE_JWT_SECRET", cfg.Auth.RequireJWTSecret)
+	cfg.Auth.PreviousJWTSecrets = getEnvList("JWT_PREVIOUS_SECRETS", cfg.Auth.PreviousJWTSecrets)
+	cfg.Auth.SigningMethod = getEnv("JWT_SIGNING_METHOD", cfg.Auth.SigningMethod)
+	cfg.Auth.AllowedAlgorithms = getEnvList("JWT_ALLOWED_ALGORITHMS", cfg.Auth.AllowedAlgorithms)
+	cfg.Auth.RSAPrivateKeyFile = getEnv("JWT_PRIVATE_KEY_FILE", cfg.Auth.RSAPrivateKeyFile)
+	cfg.Auth.RSAPublicKeyFiles = getEnvList("JWT_PUBLIC_KEY_FILES", cfg.Auth.RSAPublicKeyFiles)
+	cfg.Auth.Issuer = getEnv("JWT_ISSUER", cfg.Auth.Issuer)
+	cfg.Auth.Audience = getEnv("JWT_AUDIENCE", cfg.Auth.Audience)
+	cfg.Auth.ClockSkewLeeway = getEnvDuration("JWT_CLOCK_SKEW_LEEWAY", cfg.Auth.ClockSkewLeeway)
+	cfg.Auth.RememberMeDuration = getEnvDuration("REMEMBER_ME_DURATION", cfg.Auth.RememberMeDuration)
+	switch cost := os.Getenv("BCRYPT_COST"); cost {
+	case "auto":
+		cfg.Auth.BCryptCalibration.AutoCost = true
+	case "":
+	default:
+		// An explicit cost wins over calibration
+		cfg.Auth.BCryptCalibration.AutoCost = false
+	}
+	cfg.Auth.BCryptCost = getBcryptCost(cfg.Auth.BCryptCost)
+	cfg.Auth.BCryptCalibration.TargetDuration = getEnvDuration("BCRYPT_TARGET_DURATION", cfg.Auth.BCryptCalibration.TargetDuration)
+	cfg.Auth.BCryptCalibration.MinCost = getEnvInt("BCRYPT_MIN_COST", cfg.Auth.BCryptCalibration.MinCost)
+	cfg.Auth.BCryptCalibration.SlowWarning = getEnvDuration("BCRYPT_SLOW_WARNING", cfg.Auth.BCryptCalibration.SlowWarning)
+	cfg.Auth.MaxFailedLogins = getEnvInt("MAX_FAILED_LOGINS", cfg.Auth.MaxFailedLogins)
+	cfg.Auth.MaxFailedLoginsPerIP = getEnvInt("MAX_FAILED_LOGINS_PER_IP", cfg.Auth.MaxFailedLoginsPerIP)
+	cfg.Auth.LockoutDuration = getEnvDuration("LOCKOUT_DURATION", cfg.Auth.LockoutDuration)
+	cfg.Auth.SlidingSessions.Enabled = getEnvBool("SLIDING_SESSIONS_ENABLED", cfg.Auth.SlidingSessions.Enabled)
+	cfg.Auth.SlidingSessions.IdleTimeout = getEnvDuration("SESSION_IDLE_TIMEOUT", cfg.Auth.SlidingSessions.IdleTimeout)
+	cfg.Auth.SlidingSessions.MaxLifetime = getEnvDuration("SESSION_MAX_LIFETIME", cfg.Auth.SlidingSessions.MaxLifetime)
+	cfg.Auth.LoginBackoff.Enabled = getEnvBool("LOGIN_BACKOFF_ENABLED", cfg.Auth.LoginBackoff.Enabled)
+	cfg.Auth.LoginBackoff.BaseDelay = getEnvDuration("LOGIN_BACKOFF_BASE_DELAY", cfg.Auth.LoginBackoff.BaseDelay)
+	cfg.Auth.LoginBackoff.MaxDelay = getEnvDuration("LOGIN_BACKOFF_MAX_DELAY", cfg.Auth.LoginBackoff.MaxDelay)
+	cfg.Auth.LoginMinDuration = getEnvDuration("LOGIN_MIN_DURATION", cfg.Auth.LoginMinDuration)
+	cfg.Auth.UnlockEmail = getEnvBool("LOCKOUT_UNLOCK_EMAIL", cfg.Auth.UnlockEmail)
+	cfg.Auth.UnlockLinkTTL = getEnvDuration("UNLOCK_LINK_TTL", cfg.Auth.UnlockLinkTTL)
+	cfg.Auth.PasswordResetMode = getEnv("PASSWORD_RESET_MODE", cfg.Auth.PasswordResetMode)
+	cfg.Auth.RevokeSessionsOnPasswordChange = getEnvBool("REVOKE_SESSIONS_ON_PASSWORD_CHANGE", cfg.Auth.RevokeSessionsOnPasswordChange)
+	cfg.Auth.MagicLinkTTL = getEnvDuration("MAGIC_LINK_TTL", cfg.Auth.MagicLinkTTL)
+	cfg.Auth.EmailChangeTTL = getEnvDuration("EMAIL_CHANGE_TTL", cfg.Auth.EmailChangeTTL)
+	cfg.Auth.SecretEncryptionKey = getEnv("SECRET_ENCRYPTION_KEY", cfg.Auth.SecretEncryptionKey)
+	cfg.Auth.AdminEmails = getEnvList("ADMIN_EMAILS", cfg.Auth.AdminEmails)
+	cfg.Auth.InviteOnly = getEnvBool("REGISTRATION_INVITE_ONLY", cfg.Auth.InviteOnly)
+	cfg.Auth.InviteTTL = getEnvDuration("INVITE_TTL", cfg.Auth.InviteTTL)
+	cfg.Auth.EmailVerification.Required = getEnvBool("REQUIRE_EMAIL_VERIFICATION", cfg.Auth.EmailVerification.Required)
+	cfg.Auth.EmailVerification.GracePeriod = getEnvDuration("EMAIL_VERIFICATION_GRACE_PERIOD", cfg.Auth.EmailVerification.GracePeriod)
+	cfg.Auth.EmailVerification.LinkTTL = getEnvDuration("EMAIL_VERIFICATION_TTL", cfg.Auth.EmailVerification.LinkTTL)
+	cfg.Auth.Captcha.Enabled = getEnvBool("CAPTCHA_ENABLED", cfg.Auth.Captcha.Enabled)
+	cfg.Auth.Captcha.Provider = getEnv("CAPTCHA_PROVIDER", cfg.Auth.Captcha.Provider)
+	cfg.Auth.Captcha.Secret = getEnv("CAPTCHA_SECRET", cfg.Auth.Captcha.Secret)
+	cfg.Auth.Captcha.VerifyURL = getEnv("CAPTCHA_VERIFY_URL", cfg.Auth.Captcha.VerifyURL)
+	cfg.Auth.Captcha.Timeout = getEnvDuration("CAPTCHA_TIMEOUT", cfg.Auth.Captcha.Timeout)
+	cfg.Auth.RegistrationThrottle.MaxPerSubnet = getEnvInt("REGISTER_SUBNET_LIMIT", cfg.Auth.RegistrationThrottle.MaxPerSubnet)
+	cfg.Auth.RegistrationThrottle.Window = getEnvDuration("REGISTER_SUBNET_WINDOW", cfg.Auth.RegistrationThrottle.Window)
+	cfg.Auth.RegistrationThrottle.IPv4Prefix = getEnvInt("REGISTER_SUBNET_IPV4_PREFIX", cfg.Auth.RegistrationThrottle.IPv4Prefix)
+	cfg.Auth.RegistrationThrottle.IPv6Prefix = getEnvInt("REGISTER_SUBNET_IPV6_PREFIX", cfg.Auth.RegistrationThrottle.IPv6Prefix)
+	cfg.Auth.PasswordPolicy.MinLength = getEnvInt("PASSWORD_MIN_LENGTH", cfg.Auth.PasswordPolicy.MinLength)
+	cfg.Auth.PasswordPolicy.RequireUpper = getEnvBool("PASSWORD_REQUIRE_UPPER", cfg.Auth.PasswordPolicy.RequireUpper)
+	cfg.Auth.PasswordPolicy.RequireLower = getEnvBool("PASSWORD_REQUIRE_LOWER", cfg.Auth.PasswordPolicy.RequireLower)
+	cfg.Auth.PasswordPolicy.RequireDigit = getEnvBool("PASSWORD_REQUIRE_DIGIT", cfg.Auth.PasswordPolicy.RequireDigit)
+	cfg.Auth.PasswordPolicy.RequireSymbol = getEnvBool("PASSWORD_REQUIRE_SYMBOL", cfg.Auth.PasswordPolicy.RequireSymbol)
+	cfg.Auth.PasswordPolicy.RejectCommon = getEnvBool("PASSWORD_REJECT_COMMON", cfg.Auth.PasswordPolicy.RejectCommon)
+	cfg.Auth.PasswordPolicy.BreachCheck.Enabled = getEnvBool("PASSWORD_BREACH_CHECK", cfg.Auth.PasswordPolicy.BreachCheck.Enabled)
+	cfg.Auth.PasswordPolicy.BreachCheck.APIURL = getEnv("PASSWORD_BREACH_API_URL", cfg.Auth.PasswordPolicy.BreachCheck.APIURL)
+	cfg.Auth.PasswordPolicy.BreachCheck.Threshold = getEnvInt("PASSWORD_BREACH_THRESHOLD", cfg.Auth.PasswordPolicy.BreachCheck.Threshold)
+	cfg.Auth.PasswordPolicy.BreachCheck.Timeout = getEnvDuration("PASSWORD_BREACH_TIMEOUT", cfg.Auth.PasswordPolicy.BreachCheck.Timeout)
+	cfg.Auth.PasswordPolicy.BreachCheck.FailOpen = getEnvBool("PASSWORD_BREACH_FAIL_OPEN", cfg.Auth.PasswordPolicy.BreachCheck.FailOpen)
+	cfg.Auth.PasswordHasher = getEnv("PASSWORD_HASHER", cfg.Auth.PasswordHasher)
+	cfg.Auth.Argon2.MemoryKiB = getEnvInt("ARGON2_MEMORY_KIB", cfg.Auth.Argon2.MemoryKiB)
+	cfg.Auth.Argon2.Iterations = getEnvInt("ARGON2_ITERATIONS", cfg.Auth.Argon2.Iterations)
+	cfg.Auth.Argon2.Parallelism = getEnvInt("ARGON2_PARALLELISM", cfg.Auth.Argon2.Parallelism)
+	cfg.Auth.SecurityQuestions.Enabled = getEnvBool("SECURITY_QUESTIONS_ENABLED", cfg.Auth.SecurityQuestions.Enabled)
+	cfg.Auth.SecurityQuestions.Count = getEnvInt("SECURITY_QUESTIONS_COUNT", cfg.Auth.SecurityQuestions.Count)
+	cfg.Auth.SecurityQuestions.MaxAttempts = getEnvInt("SECURITY_QUESTIONS_MAX_ATTEMPTS", cfg.Auth.SecurityQuestions.MaxAttempts)
+	cfg.Auth.TokenCookie.Enabled = getEnvBool("TOKEN_COOKIE_ENABLED", cfg.Auth.TokenCookie.Enabled)
+	cfg.Auth.TokenCookie.OmitFromBody = getEnvBool("TOKEN_COOKIE_OMIT_BODY", cfg.Auth.TokenCookie.OmitFromBody)
+	cfg.Auth.TokenCookie.Name = getEnv("TOKEN_COOKIE_NAME", cfg.Auth.TokenCookie.Name)
+	cfg.Auth.TokenCookie.Domain = getEnv("TOKEN_COOKIE_DOMAIN", cfg.Auth.TokenCookie.Domain)
+	cfg.Auth.TokenCookie.Secure = getEnvBool("TOKEN_COOKIE_SECURE", cfg.Auth.TokenCookie.Secure)
+	cfg.Auth.TokenCookie.SameSite = getEnv("TOKEN_COOKIE_SAMESITE", cfg.Auth.TokenCookie.SameSite)
+	cfg.Auth.TokenMetadata.Keys = getEnvList("TOKEN_METADATA_KEYS", cfg.Auth.TokenMetadata.Keys)
+	cfg.Auth.TokenMetadata.MaxBytes = getEnvInt("TOKEN_METADATA_MAX_BYTES", cfg.Auth.TokenMetadata.MaxBytes)
+	cfg.Auth.Tokens.Bytes = getEnvInt("TOKEN_BYTES", cfg.Auth.Tokens.Bytes)
+	cfg.Auth.Tokens.Encoding = getEnv("TOKEN_ENCODING", cfg.Auth.Tokens.Encoding)
+
+	cfg.Log.Level = getEnv("LOG_LEVEL", cfg.Log.Level)
+	cfg.Log.Format = getEnv("LOG_FORMAT", cfg.Log.Format)
+	cfg.Log.RequestSampleRatio = getEnvFloat("LOG_REQUEST_SAMPLE_RATIO", cfg.Log.RequestSampleRatio)
+	cfg.Log.RequestAlways = getEnvList("LOG_REQUEST_ALWAYS", cfg.Log.RequestAlways)
+
+	cfg.OAuth.DuplicateEmailPolicy = getEnv("OAUTH_DUPLICATE_EMAIL_POLICY", cfg.OAuth.DuplicateEmailPolicy)
+	applyOAuthProviderEnv(cfg)
+
+	cfg.WebAuthn.RPID = getEnv("WEBAUTHN_RP_ID", cfg.WebAuthn.RPID)
+	cfg.WebAuthn.RPDisplayName = getEnv("WEBAUTHN_RP_NAME", cfg.WebAuthn.RPDisplayName)
+	cfg.WebAuthn.RPOrigins = getEnvList("WEBAUTHN_RP_ORIGINS", cfg.WebAuthn.RPOrigins)
+	cfg.WebAuthn.Timeout = getEnvDuration("WEBAUTHN_TIMEOUT", cfg.WebAuthn.Timeout)
+
+	cfg.Events.Sinks = getEnvList("SECURITY_EVENT_SINKS", cfg.Events.Sinks)
+	cfg.Events.FilePath = getEnv("SECURITY_EVENT_FILE", cfg.Events.FilePath)
+	cfg.Events.HTTPEndpoint = getEnv("SECURITY_EVENT_HTTP_URL", cfg.Events.HTTPEndpoint)
+	cfg.Events.HTTPToken = getEnv("SECURITY_EVENT_HTTP_TOKEN", cfg.Events.HTTPToken)
+	cfg.Events.WebhookURLs = getEnvList("WEBHOOK_URLS", cfg.Events.WebhookURLs)
+	cfg.Events.WebhookSecret = getEnv("WEBHOOK_SECRET", cfg.Events.WebhookSecret)
+
+	cfg.Email.Transport = getEnv("EMAIL_TRANSPORT", cfg.Email.Transport)
+	cfg.Email.From = getEnv("EMAIL_FROM", cfg.Email.From)
+	cfg.Email.BaseURL = getEnv("APP_BASE_URL", cfg.Email.BaseURL)
+	cfg.Email.SMTPHost = getEnv("SMTP_HOST", cfg.Email.SMTPHost)
+	cfg.Email.SMTPPort = getEnvInt("SMTP_PORT", cfg.Email.SMTPPort)
+	cfg.Email.SMTPUsername = getEnv("SMTP_USERNAME", cfg.Email.SMTPUsername)
+	cfg.Email.SMTPPassword = getEnv("SMTP_PASSWORD", cfg.Email.SMTPPassword)
+
+	cfg.Storage.SessionBackend = getEnv("SESSION_STORE", cfg.Storage.SessionBackend)
+	cfg.Storage.UserStoreShards = getEnvInt("USER_STORE_SHARDS", cfg.Storage.UserStoreShards)
+	cfg.Storage.IDFormat = getEnv("ID_FORMAT", cfg.Storage.IDFormat)
+	cfg.Storage.UserCache.MaxSize = getEnvInt("USER_CACHE_SIZE", cfg.Storage.UserCache.MaxSize)
+	cfg.Storage.UserCache.TTL = getEnvDuration("USER_CACHE_TTL", cfg.Storage.UserCache.TTL)
+	cfg.Storage.Retry.MaxAttempts = getEnvInt("STORE_RETRY_ATTEMPTS", cfg.Storage.Retry.MaxAttempts)
+	cfg.Storage.Retry.BaseDelay = getEnvDuration("STORE_RETRY_BASE_DELAY", cfg.Storage.Retry.BaseDelay)
+	cfg.Storage.Retry.MaxDelay = getEnvDuration("STORE_RETRY_MAX_DELAY", cfg.Storage.Retry.MaxDelay)
+	cfg.Storage.Redis.Addr = getEnv("REDIS_ADDR", cfg.Storage.Redis.Addr)
+	cfg.Storage.Redis.Password = getEnv("REDIS_PASSWORD", cfg.Storage.Redis.Password)
+	cfg.Storage.Redis.DB = getEnvInt("REDIS_DB", cfg.Storage.Redis.DB)
+	cfg.Storage.Redis.KeyPrefix = getEnv("REDIS_KEY_PREFIX", cfg.Storage.Redis.KeyPrefix)
+	cfg.Storage.Redis.DialTimeout = getEnvDuration("REDIS_DIAL_TIMEOUT", cfg.Storage.Redis.DialTimeout)
+	cfg.Storage.Database.Driver = getEnv("DATABASE_DRIVER", cfg.Storage.Database.Driver)
+	cfg.Storage.Database.DSN = getEnv("DATABASE_URL", cfg.Storage.Database.DSN)
+	cfg.Storage.Database.MigrateOnStart = getEnvBool("DATABASE_MIGRATE_ON_START", cfg.Storage.Database.MigrateOnStart)
+
+	cfg.Tracing.Enabled = getEnvBool("TRACING_ENABLED", cfg.Tracing.Enabled)
+	cfg.Tracing.Endpoint = getEnv("TRACING_OTLP_ENDPOINT", cfg.Tracing.Endpoint)
+	cfg.Tracing.Insecure = getEnvBool("TRACING_OTLP_INSECURE", cfg.Tracing.Insecure)
+	cfg.Tracing.ServiceName = getEnv("TRACING_SERVICE_NAME", cfg.Tracing.ServiceName)
+	cfg.Tracing.SampleRatio = getEnvFloat("TRACING_SAMPLE_RATIO", cfg.Tracing.SampleRatio)
+
+	cfg.Tenancy.Enabled = getEnvBool("TENANCY_ENABLED", cfg.Tenancy.Enabled)
+	cfg.Tenancy.BaseDomain = getEnv("TENANCY_BASE_DOMAIN", cfg.Tenancy.BaseDomain)
+	cfg.Tenancy.Header = getEnv("TENANCY_HEADER", cfg.Tenancy.Header)
+	cfg.Tenancy.DefaultOrg = getEnv("TENANCY_DEFAULT_ORG", cfg.Tenancy.DefaultOrg)
+}
+
+// UsesDefaultJWTSecret reports whether the built-in JWT secret, which is
+// published in this repository, signs tokens in an environment that isn't
+// expected to run with it
+func (cfg *Config) UsesDefaultJWTSecret() bool {
+	return cfg.Auth.JWTSecret == defaultJWTSecret && !environments[cfg.Environment].allowDefaultSecret
+}
+
+// InsecureTokenCookie reports whether the token cookie is sent without the
+// Secure attribute in an environment that defaults to it, where it would
+// let the token leak over plain HTTP
+func (cfg *Config) InsecureTokenCookie() bool {
+	cookie := cfg.Auth.TokenCookie
+	return cookie.Enabled && !cookie.Secure && defaults(cfg.Environment).Auth.TokenCookie.Secure
+}
+
+// validate checks the assembled configuration for invalid or unsafe values
+func (cfg *Config) validate() error {
+	profile, ok := environments[cfg.Environment]
+	if !ok {
+		return fmt.Errorf("invalid ENVIRONMENT %q: must be one of %s", cfg.Environment, strings.Join(environmentNames(), ", "))
+	}
+	if cfg.Auth.JWTSecret == defaultJWTSecret {
+		if profile.strictSecrets {
+			return fmt.Errorf("JWT_SECRET must be set in %s environment", cfg.Environment)
+		}
+		if cfg.Auth.RequireJWTSecret {
+			return fmt.Errorf("JWT_SECRET must be set when REQUIRE_JWT_SECRET is enabled")
+		}
+	}
+	if profile.strictSecrets {
+		if cfg.Auth.SigningMethod == "RS256" && cfg.Auth.RSAPrivateKeyFile == "" {
+			return fmt.Errorf("JWT_PRIVATE_KEY_FILE must be set in %s environment when using RS256", cfg.Environment)
+		}
+	}
+
+	if cfg.Auth.SigningMethod != "HS256" && cfg.Auth.SigningMethod != "RS256" {
+		return fmt.Errorf("invalid JWT_SIGNING_METHOD %q: must be HS256 or RS256", cfg.Auth.SigningMethod)
 	}
 
-	// Environment-specific overrides
-	switch environment {
-	case "production":
-		cfg.Auth.BCryptCost = 12 // Higher cost for production
-		cfg.Log.Level = "warn"
-		if cfg.Auth.JWTSecret == "your-256-bit-secret-key-here-make-sure-its-long-enough" {
-			return nil, fmt.Errorf("JWT_SECRET must be set in production environment")
+	if len(cfg.Auth.AllowedAlgorithms) > 0 {
+		signingAllowed := false
+		for _, alg := range cfg.Auth.AllowedAlgorithms {
+			if alg != "HS256" && alg != "RS256" {
+				return fmt.Errorf("invalid JWT_ALLOWED_ALGORITHMS entry %q: must be HS256 or RS256", alg)
+			}
+			if alg == cfg.Auth.SigningMethod {
+				signingAllowed = true
+			}
+		}
+		if !signingAllowed {
+			return fmt.Errorf("auth.allowed_algorithms: must include the signing method %s", cfg.Auth.SigningMethod)
 		}
-	case "development":
-		cfg.Auth.BCryptCost = 8 // Lower cost for development
-		cfg.Log.Level = "debug"
 	}
 
-	return cfg, nil
+	if cfg.Auth.SigningMethod == "HS256" && cfg.Auth.JWTSecret == "" {
+		return fmt.Errorf("auth.jwt_secret: must not be empty when using HS256")
+	}
+
+	switch cfg.Auth.PasswordResetMode {
+	case PasswordResetStored:
+	case PasswordResetStateless:
+		// Stateless tokens are signed with a key derived from one of these.
+		// Anyone can derive it from the published default secret and forge
+		// reset tokens, whatever the environment or signing method.
+		if cfg.Auth.SecretEncryptionKey == "" && (cfg.Auth.JWTSecret == "" || cfg.Auth.JWTSecret == defaultJWTSecret) {
+			return fmt.Errorf("SECRET_ENCRYPTION_KEY or a JWT_SECRET other than the default must be set for stateless password reset tokens")
+		}
+	default:
+		return fmt.Errorf("invalid PASSWORD_RESET_MODE %q: must be stored or stateless", cfg.Auth.PasswordResetMode)
+	}
+
+	if cfg.Auth.BCryptCost < 4 || cfg.Auth.BCryptCost > 31 {
+		return fmt.Errorf("auth.bcrypt_cost: %d is out of range, must be between 4 and 31", cfg.Auth.BCryptCost)
+	}
+	if calibration := cfg.Auth.BCryptCalibration; calibration.AutoCost {
+		if calibration.TargetDuration <= 0 {
+			return fmt.Errorf("invalid BCRYPT_TARGET_DURATION %s: must be positive", calibration.TargetDuration)
+		}
+		if calibration.MinCost < 4 || calibration.MinCost > 31 {
+			return fmt.Errorf("invalid BCRYPT_MIN_COST %d: must be between 4 and 31", calibration.MinCost)
+		}
+	}
+	if cfg.Auth.BCryptCalibration.SlowWarning < 0 {
+		return fmt.Errorf("invalid BCRYPT_SLOW_WARNING %s: must not be negative", cfg.Auth.BCryptCalibration.SlowWarning)
+	}
+
+	switch cfg.Auth.PasswordHasher {
+	case PasswordHasherBcrypt:
+	case PasswordHasherArgon2id:
+		argon := cfg.Auth.Argon2
+		if argon.Iterations < 1 {
+			return fmt.Errorf("auth.argon2.iterations: must be at least 1")
+		}
+		if argon.Parallelism < 1 || argon.Parallelism > 255 {
+			return fmt.Errorf("auth.argon2.parallelism: %d is out of range, must be between 1 and 255", argon.Parallelism)
+		}
+		if argon.MemoryKiB < 8*argon.Parallelism {
+			return fmt.Errorf("auth.argon2.memory_kib: must be at least 8 KiB per thread")
+		}
+	default:
+		return fmt.Errorf("invalid PASSWORD_HASHER %q: must be bcrypt or argon2id", cfg.Auth.PasswordHasher)
+	}
+
+	if throttle := cfg.Auth.RegistrationThrottle; throttle.MaxPerSubnet < 0 {
+		return fmt.Errorf("invalid REGISTER_SUBNET_LIMIT %d: must not be negative", throttle.MaxPerSubnet)
+	} else if throttle.MaxPerSubnet > 0 {
+		if throttle.Window <= 0 {
+			return fmt.Errorf("invalid REGISTER_SUBNET_WINDOW %s: must be positive", throttle.Window)
+		}
+		if throttle.IPv4Prefix < 1 || throttle.IPv4Prefix > 32 {
+			return fmt.Errorf("invalid REGISTER_SUBNET_IPV4_PREFIX %d: must be between 1 and 32", throttle.IPv4Prefix)
+		}
+		if throttle.IPv6Prefix < 1 || throttle.IPv6Prefix > 128 {
+			return fmt.Errorf("invalid REGISTER_SUBNET_IPV6_PREFIX %d: must be between 1 and 128", throttle.IPv6Prefix)
+		}
+	}
+
+	if sliding := cfg.Auth.SlidingSessions; sliding.Enabled {
+		if sliding.IdleTimeout <= 0 {
+			return fmt.Errorf("invalid SESSION_IDLE_TIMEOUT %s: must be positive", sliding.IdleTimeout)
+		}
+		if sliding.MaxLifetime < sliding.IdleTimeout {
+			return fmt.Errorf("invalid SESSION_MAX_LIFETIME %s: must be at least SESSION_IDLE_TIMEOUT", sliding.MaxLifetime)
+		}
+	}
+
+	if backoff := cfg.Auth.LoginBackoff; backoff.Enabled {
+		if backoff.BaseDelay <= 0 {
+			return fmt.Errorf("auth.login_backoff.base_delay: must be positive")
+		}
+		if backoff.MaxDelay < backoff.BaseDelay {
+			return fmt.Errorf("auth.login_backoff.max_delay: %s is less than the base delay %s",
+				backoff.MaxDelay, backoff.BaseDelay)
+		}
+	}
+
+	if cfg.Auth.LoginMinDuration < 0 {
+		return fmt.Errorf("auth.login_min_duration: must not be negative")
+	}
+
+	if verification := cfg.Auth.EmailVerification; verification.Required {
+		if verification.GracePeriod < 0 {
+			return fmt.Errorf("auth.email_verification.grace_period: must not be negative")
+		}
+		if verification.LinkTTL <= 0 {
+			return fmt.Errorf("auth.email_verification.link_ttl: must be positive")
+		}
+	}
+
+	if breach := cfg.Auth.PasswordPolicy.BreachCheck; breach.Enabled {
+		if u, err := url.Parse(breach.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid PASSWORD_BREACH_API_URL %q: must be an http or https URL", breach.APIURL)
+		}
+		if breach.Threshold < 1 {
+			return fmt.Errorf("invalid PASSWORD_BREACH_THRESHOLD %d: must be at least 1", breach.Threshold)
+		}
+		if breach.Timeout <= 0 {
+			return fmt.Errorf("invalid PASSWORD_BREACH_TIMEOUT %s: must be positive", breach.Timeout)
+		}
+	}
+
+	if captcha := cfg.Auth.Captcha; captcha.Enabled {
+		switch captcha.Provider {
+		case CaptchaHCaptcha, CaptchaReCAPTCHA:
+			if captcha.Secret == "" {
+				return fmt.Errorf("CAPTCHA_SECRET must be set when CAPTCHA is enabled")
+			}
+		case CaptchaStub:
+			if environments[cfg.Environment].strictSecrets {
+				return fmt.Errorf("the stub CAPTCHA provider can't be used in %s environment", cfg.Environment)
+			}
+		default:
+			return fmt.Errorf("invalid CAPTCHA_PROVIDER %q: must be hcaptcha, recaptcha or stub", captcha.Provider)
+		}
+		if captcha.VerifyURL != "" {
+			if u, err := url.Parse(captcha.VerifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid CAPTCHA_VERIFY_URL %q: must be an http or https URL", captcha.VerifyURL)
+			}
+		}
+		if captcha.Timeout <= 0 {
+			return fmt.Errorf("invalid CAPTCHA_TIMEOUT %s: must be positive", captcha.Timeout)
+		}
+	}
+
+	if questions := cfg.Auth.SecurityQuestions; questions.Enabled {
+		if questions.Count < 1 || questions.Count > MaxSecurityQuestions {
+			return fmt.Errorf("auth.security_questions.count: %d is out of range, must be between 1 and %d",
+				questions.Count, MaxSecurityQuestions)
+		}
+		if questions.MaxAttempts < 1 {
+			return fmt.Errorf("auth.security_questions.max_attempts: must be at least 1")
+		}
+	}
+
+	if cookie := cfg.Auth.TokenCookie; cookie.Enabled {
+		if cookie.Name == "" {
+			return fmt.Errorf("TOKEN_COOKIE_NAME must be set when the token cookie is enabled")
+		}
+		switch cookie.SameSite {
+		case SameSiteStrict, SameSiteLax, SameSiteNone:
+		default:
+			return fmt.Errorf("invalid TOKEN_COOKIE_SAMESITE %q: must be strict, lax or none", cookie.SameSite)
+		}
+		// Browsers drop SameSite=None cookies that aren't Secure
+		if cookie.SameSite == SameSiteNone && !cookie.Secure {
+			return fmt.Errorf("TOKEN_COOKIE_SAMESITE none requires TOKEN_COOKIE_SECURE")
+		}
+	}
+
+	if metadata := cfg.Auth.TokenMetadata; len(metadata.Keys) > 0 && metadata.MaxBytes < 1 {
+		return fmt.Errorf("auth.token_metadata.max_bytes: must be positive when TOKEN_METADATA_KEYS is set")
+	}
+
+	if n := cfg.Auth.Tokens.Bytes; n < tokens.MinBytes || n > tokens.MaxBytes {
+		return fmt.Errorf("auth.tokens.bytes: %d is out of range, must be between %d and %d",
+			n, tokens.MinBytes, tokens.MaxBytes)
+	}
+	switch cfg.Auth.Tokens.Encoding {
+	case tokens.EncodingHex, tokens.EncodingBase64URL:
+	default:
+		return fmt.Errorf("invalid TOKEN_ENCODING %q: must be hex or base64url", cfg.Auth.Tokens.Encoding)
+	}
+
+	// The leeway only absorbs clock drift; a large one would keep expired
+	// and revoked-by-expiry tokens usable
+	if cfg.Auth.ClockSkewLeeway > MaxClockSkewLeeway {
+		return fmt.Errorf("auth.clock_skew_leeway: %s is too large, must be at most %s",
+			cfg.Auth.ClockSkewLeeway, MaxClockSkewLeeway)
+	}
+
+	// bcrypt only uses the first 72 bytes of a password
+	if cfg.Auth.PasswordPolicy.MinLength < 1 || cfg.Auth.PasswordPolicy.MinLength > 72 {
+		return fmt.Errorf("auth.password_policy.min_length: %d is out of range, must be between 1 and 72",
+			cfg.Auth.PasswordPolicy.MinLength)
+	}
+
+	if err := validateDurations(reflect.ValueOf(cfg).Elem(), ""); err != nil {
+		return err
+	}
+	for name, d := range map[string]time.Duration{
+		"auth.token_duration":         cfg.Auth.TokenDuration,
+		"auth.refresh_token_duration": cfg.Auth.RefreshTokenDuration,
+		"auth.remember_me_duration":   cfg.Auth.RememberMeDuration,
+		"server.read_timeout":         cfg.Server.ReadTimeout,
+		"server.write_timeout":        cfg.Server.WriteTimeout,
+		"server.shutdown_timeout":     cfg.Server.ShutdownTimeout,
+		"webauthn.timeout":            cfg.WebAuthn.Timeout,
+	} {
+		if d == 0 {
+			return fmt.Errorf("%s: must be greater than zero", name)
+		}
+	}
+
+	if cfg.Server.CORS.AllowCredentials {
+		for _, origin := range cfg.Server.CORS.AllowedOrigins {
+			if origin == "*" {
+				return fmt.Errorf("server.cors.allowed_origins: \"*\" cannot be used when credentials are allowed, list origins explicitly")
+			}
+		}
+	}
+
+	for name, limit := range map[string]RateLimit{
+		"server.rate_limits.login":    cfg.Server.RateLimits.Login,
+		"server.rate_limits.register": cfg.Server.RateLimits.Register,
+		"server.rate_limits.email":    cfg.Server.RateLimits.Email,
+	} {
+		if limit.RequestsPerSecond < 0 {
+			return fmt.Errorf("%s.requests_per_second must not be negative", name)
+		}
+		if limit.RequestsPerSecond > 0 && limit.Burst < 1 {
+			return fmt.Errorf("%s.burst must be at least 1 when the limit is enabled", name)
+		}
+	}
+
+	for name, limit := range map[string]int{
+		"server.request_limits.max_body_bytes":   cfg.Server.RequestLimits.MaxBodyBytes,
+		"server.request_limits.max_upload_bytes": cfg.Server.RequestLimits.MaxUploadBytes,
+		"server.request_limits.max_json_depth":   cfg.Server.RequestLimits.MaxJSONDepth,
+	} {
+		if limit < 1 {
+			return fmt.Errorf("%s: must be at least 1", name)
+		}
+	}
+
+	for name, list := range map[string][]string{
+		"server.trusted_proxies":       cfg.Server.TrustedProxies,
+		"server.ip_filter.allow":       cfg.Server.IPFilter.Allow,
+		"server.ip_filter.deny":        cfg.Server.IPFilter.Deny,
+		"server.admin_ip_filter.allow": cfg.Server.AdminIPFilter.Allow,
+		"server.admin_ip_filter.deny":  cfg.Server.AdminIPFilter.Deny,
+	} {
+		if _, err := ParsePrefixes(list); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	switch cfg.OAuth.DuplicateEmailPolicy {
+	case LinkPolicyAutoLink, LinkPolicyRequireConfirmation, LinkPolicyReject:
+	default:
+		return fmt.Errorf("invalid OAUTH_DUPLICATE_EMAIL_POLICY %q: must be one of %s, %s, %s",
+			cfg.OAuth.DuplicateEmailPolicy, LinkPolicyAutoLink, LinkPolicyRequireConfirmation, LinkPolicyReject)
+	}
+
+	for name, provider := range cfg.OAuth.Providers {
+		if provider.ClientID == "" || provider.ClientSecret == "" || provider.RedirectURL == "" {
+			prefix := oauthProviderEnvPrefix(name)
+			return fmt.Errorf("oauth provider %q needs %sCLIENT_ID, %sCLIENT_SECRET and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+	}
+
+	if cfg.WebAuthn.RPDisplayName == "" {
+		return fmt.Errorf("webauthn.rp_display_name: must not be empty")
+	}
+
+	for _, sink := range cfg.Events.Sinks {
+		switch sink {
+		case "stdout":
+		case "file":
+			if cfg.Events.FilePath == "" {
+				return fmt.Errorf("SECURITY_EVENT_FILE must be set when the file sink is enabled")
+			}
+		case "http":
+			if cfg.Events.HTTPEndpoint == "" {
+				return fmt.Errorf("SECURITY_EVENT_HTTP_URL must be set when the http sink is enabled")
+			}
+		default:
+			return fmt.Errorf("invalid SECURITY_EVENT_SINKS entry %q: must be stdout, file or http", sink)
+		}
+	}
+	if len(cfg.Events.WebhookURLs) > 0 && cfg.Events.WebhookSecret == "" {
+		return fmt.Errorf("WEBHOOK_SECRET must be set when WEBHOOK_URLS is set")
+	}
+	for _, webhookURL := range cfg.Events.WebhookURLs {
+		if u, err := url.Parse(webhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid WEBHOOK_URLS entry %q: must be an http or https URL", webhookURL)
+		}
+	}
+
+	switch cfg.Email.Transport {
+	case EmailTransportLog:
+	case EmailTransportSMTP:
+		if cfg.Email.SMTPHost == "" {
+			return fmt.Errorf("SMTP_HOST must be set when the smtp email transport is enabled")
+		}
+		if cfg.Email.From == "" {
+			return fmt.Errorf("EMAIL_FROM must be set when the smtp email transport is enabled")
+		}
+	default:
+		return fmt.Errorf("invalid EMAIL_TRANSPORT %q: must be log or smtp", cfg.Email.Transport)
+	}
+
+	switch cfg.Storage.SessionBackend {
+	case SessionBackendMemory:
+	case SessionBackendRedis:
+		if cfg.Storage.Redis.Addr == "" {
+			return fmt.Errorf("REDIS_ADDR must be set when the redis session store is enabled")
+		}
+	default:
+		return fmt.Errorf("invalid SESSION_STORE %q: must be memory or redis", cfg.Storage.SessionBackend)
+	}
+
+	if cfg.Server.IdempotencyTTL < 0 {
+		return fmt.Errorf("server.idempotency_ttl: must not be negative")
+	}
+
+	if cfg.Storage.UserStoreShards < 0 {
+		return fmt.Errorf("storage.user_store_shards: must not be negative")
+	}
+	if cache := cfg.Storage.UserCache; cache.MaxSize < 0 {
+		return fmt.Errorf("storage.user_cache.max_size: must not be negative")
+	} else if cache.MaxSize > 0 && cache.TTL <= 0 {
+		return fmt.Errorf("invalid USER_CACHE_TTL %s: must be positive when USER_CACHE_SIZE is set", cache.TTL)
+	}
+	if retry := cfg.Storage.Retry; retry.MaxAttempts < 1 {
+		return fmt.Errorf("invalid STORE_RETRY_ATTEMPTS %d: must be at least 1", retry.MaxAttempts)
+	} else if retry.BaseDelay < 0 || retry.MaxDelay < retry.BaseDelay {
+		return fmt.Errorf("storage.retry: delays must not be negative and STORE_RETRY_MAX_DELAY must be at least STORE_RETRY_BASE_DELAY")
+	}
+	switch cfg.Storage.IDFormat {
+	case ids.FormatHex, ids.FormatUUIDv4, ids.FormatUUIDv7:
+	default:
+		return fmt.Errorf("invalid ID_FORMAT %q: must be hex, uuidv4 or uuidv7", cfg.Storage.IDFormat)
+	}
+	if cfg.Storage.Database.Driver != "" && cfg.Storage.Database.DSN == "" {
+		return fmt.Errorf("DATABASE_URL must be set when DATABASE_DRIVER is set")
+	}
+	if cfg.Storage.Database.MigrateOnStart && cfg.Storage.Database.Driver == "" {
+		return fmt.Errorf("storage.database.migrate_on_start: DATABASE_DRIVER must be set")
+	}
+
+	if cfg.Log.RequestSampleRatio < 0 || cfg.Log.RequestSampleRatio > 1 {
+		return fmt.Errorf("log.request_sample_ratio: %g is out of range, must be between 0 and 1", cfg.Log.RequestSampleRatio)
+	}
+	for _, class := range cfg.Log.RequestAlways {
+		if len(class) != 3 || class[0] < '1' || class[0] > '5' || class[1:] != "xx" {
+			return fmt.Errorf("invalid LOG_REQUEST_ALWAYS entry %q: must be a status class from 1xx to 5xx", class)
+		}
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio: %g is out of range, must be between 0 and 1", cfg.Tracing.SampleRatio)
+	}
+	if cfg.Tracing.Enabled && cfg.Tracing.Endpoint == "" {
+		return fmt.Errorf("TRACING_OTLP_ENDPOINT must be set when tracing is enabled")
+	}
+
+	if tenancy := cfg.Tenancy; tenancy.Enabled {
+		if tenancy.BaseDomain == "" && tenancy.Header == "" {
+			return fmt.Errorf("TENANCY_BASE_DOMAIN or TENANCY_HEADER must be set when tenancy is enabled")
+		}
+		if strings.HasPrefix(tenancy.BaseDomain, ".") {
+			return fmt.Errorf("invalid TENANCY_BASE_DOMAIN %q: must not start with a dot", tenancy.BaseDomain)
+		}
+		if tenancy.DefaultOrg != "" && !tenant.ValidOrgID(tenancy.DefaultOrg) {
+			return fmt.Errorf("invalid TENANCY_DEFAULT_ORG %q: must be lowercase letters, digits and hyphens", tenancy.DefaultOrg)
+		}
+	}
+
+	return nil
+}
+
+// applyOAuthProviderEnv enables the providers listed in OAUTH_PROVIDERS and
+// reads each provider's OAUTH_<NAME>_* settings, including the client
+// secret of providers defined in a config file
+func applyOAuthProviderEnv(cfg *Config) {
+	names := getEnvList("OAUTH_PROVIDERS", nil)
+	if cfg.OAuth.Providers == nil && len(names) > 0 {
+		cfg.OAuth.Providers = make(map[string]OAuthProviderConfig)
+	}
+	for _, name := range names {
+		if _, ok := cfg.OAuth.Providers[name]; !ok {
+			cfg.OAuth.Providers[name] = OAuthProviderConfig{}
+		}
+	}
+
+	for name, provider := range cfg.OAuth.Providers {
+		prefix := oauthProviderEnvPrefix(name)
+		provider.Type = getEnv(prefix+"TYPE", provider.Type)
+		provider.ClientID = getEnv(prefix+"CLIENT_ID", provider.ClientID)
+		provider.ClientSecret = getEnv(prefix+"CLIENT_SECRET", provider.ClientSecret)
+		provider.RedirectURL = getEnv(prefix+"REDIRECT_URL", provider.RedirectURL)
+		provider.AuthURL = getEnv(prefix+"AUTH_URL", provider.AuthURL)
+		provider.TokenURL = getEnv(prefix+"TOKEN_URL", provider.TokenURL)
+		provider.UserInfoURL = getEnv(prefix+"USERINFO_URL", provider.UserInfoURL)
+		provider.Scopes = getEnvList(prefix+"SCOPES", provider.Scopes)
+		cfg.OAuth.Providers[name] = provider
+	}
+}
+
+// oauthProviderEnvPrefix returns the environment variable prefix for a
+// provider, e.g. OAUTH_GOOGLE_ for google
+func oauthProviderEnvPrefix(name string) string {
+	return "OAUTH_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name) + "_"
+}
+
+// ParsePrefixes parses a list of IPs and CIDRs. A bare IP becomes a
+// single-address prefix.
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", value)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q", value)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
 }
 
 // getEnv gets an environment variable with a default value
@@ -81,12 +1565,68 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvInt gets an integer environment variable with a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil && i >= 0 {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloat gets a non-negative float environment variable with a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f >= 0 {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration gets a duration environment variable with a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvBool gets a boolean environment variable with a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList gets a comma-separated environment variable as a list with a default value
+func getEnvList(key string, defaultValue []string) []string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // getBcryptCost gets the bcrypt cost from environment or returns default
-func getBcryptCost() int {
+func getBcryptCost(defaultValue int) int {
 	if cost := os.Getenv("BCRYPT_COST"); cost != "" {
 		if c, err := strconv.Atoi(cost); err == nil && c >= 4 && c <= 31 {
 			return c
 		}
 	}
-	return 10 // Default bcrypt cost
+	return defaultValue
 }
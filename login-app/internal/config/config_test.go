@@ -0,0 +1,72 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatelessPasswordResetRequiresSigningSecret(t *testing.T) {
+	for name, tc := range map[string]struct {
+		env     map[string]string
+		wantErr bool
+	}{
+		"default JWT secret":    {map[string]string{}, true},
+		"default under RS256":   {map[string]string{"JWT_SIGNING_METHOD": "RS256"}, true},
+		"own JWT secret":        {map[string]string{"JWT_SECRET": "a-secret-that-is-not-the-default"}, false},
+		"secret encryption key": {map[string]string{"SECRET_ENCRYPTION_KEY": "reset-signing-key"}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("PASSWORD_RESET_MODE", PasswordResetStateless)
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Load("test")
+			if tc.wantErr && (err == nil || !strings.Contains(err.Error(), "stateless password reset")) {
+				t.Errorf("Load: got %v, want the stateless reset secret error", err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("Load: %v", err)
+			}
+		})
+	}
+}
+
+func TestStoredPasswordResetAllowsDefaultSecret(t *testing.T) {
+	t.Setenv("PASSWORD_RESET_MODE", PasswordResetStored)
+	if _, err := Load("test"); err != nil {
+		t.Errorf("Load: %v", err)
+	}
+}
+
+func TestClockSkewLeewayIsCapped(t *testing.T) {
+	t.Setenv("JWT_CLOCK_SKEW_LEEWAY", MaxClockSkewLeeway.String())
+	if _, err := Load("test"); err != nil {
+		t.Errorf("leeway at the cap: %v", err)
+	}
+
+	t.Setenv("JWT_CLOCK_SKEW_LEEWAY", "1h")
+	if _, err := Load("test"); err == nil || !strings.Contains(err.Error(), "clock_skew_leeway") {
+		t.Errorf("leeway of an hour: got %v, want a clock_skew_leeway error", err)
+	}
+}
+
+func TestAllowedAlgorithms(t *testing.T) {
+	for name, tc := range map[string]struct {
+		algorithms string
+		wantErr    bool
+	}{
+		"signing method":         {"HS256", false},
+		"both":                   {"HS256,RS256", false},
+		"none":                   {"HS256,none", true},
+		"unsupported":            {"HS256,HS384", true},
+		"without signing method": {"RS256", true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("JWT_ALLOWED_ALGORITHMS", tc.algorithms)
+			if _, err := Load("test"); (err != nil) != tc.wantErr {
+				t.Errorf("Load: got %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
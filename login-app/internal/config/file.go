@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	// YAML parser; JSON documents are valid YAML so it reads both formats
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFromFile loads configuration from a YAML or JSON file and layers
+// environment variables on top. Keys follow the JSON field names, e.g.
+// auth.token_duration, and ${VAR} references are expanded from the
+// environment. The environment comes from ENVIRONMENT, then the file's
+// environment key, and defaults to development.
+func LoadFromFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := map[string]any{}
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &values); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	environment := "development"
+	if env, ok := values["environment"].(string); ok && env != "" {
+		environment = env
+	}
+	environment = getEnv("ENVIRONMENT", environment)
+
+	cfg := defaults(environment)
+	if err := applyValues(reflect.ValueOf(cfg).Elem(), values, ""); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	cfg.Environment = environment
+	applyEnv(cfg)
+	if err := applySecretFiles(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyValues copies parsed file values onto the struct v, matching keys
+// against JSON field names. Fields tagged json:"-" are secrets that can
+// only be set from the environment.
+func applyValues(v reflect.Value, values map[string]any, path string) error {
+	fields := make(map[string]int)
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+
+	for _, key := range sortedKeys(values) {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+
+		i, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown field", fieldPath)
+		}
+		if err := setValue(v.Field(i), values[key], fieldPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys returns a section's keys in order so errors are deterministic
+func sortedKeys(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setValue assigns a single parsed value to a config field
+func setValue(field reflect.Value, raw any, path string) error {
+	if field.Type() == durationType {
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a duration such as \"15m\", got %v", path, raw)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: invalid duration %q", path, s)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Struct:
+		values, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a section, got %v", path, raw)
+		}
+		return applyValues(field, values, path)
+
+	case reflect.String:
+		switch value := raw.(type) {
+		case string:
+			field.SetString(value)
+		case int, float64:
+			// Allow unquoted values such as port: 8080
+			field.SetString(fmt.Sprint(value))
+		default:
+			return fmt.Errorf("%s: expected a string, got %v", path, raw)
+		}
+
+	case reflect.Int:
+		value, ok := raw.(int)
+		if !ok {
+			return fmt.Errorf("%s: expected an integer, got %v", path, raw)
+		}
+		field.SetInt(int64(value))
+
+	case reflect.Float64:
+		switch value := raw.(type) {
+		case float64:
+			field.SetFloat(value)
+		case int:
+			field.SetFloat(float64(value))
+		default:
+			return fmt.Errorf("%s: expected a number, got %v", path, raw)
+		}
+
+	case reflect.Bool:
+		value, ok := raw.(bool)
+		if !ok {
+			return fmt.Errorf("%s: expected true or false, got %v", path, raw)
+		}
+		field.SetBool(value)
+
+	case reflect.Map:
+		// Named sections, e.g. oauth.providers.google
+		values, ok := raw.(map[string]any)
+		if !ok || field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("%s: expected named sections, got %v", path, raw)
+		}
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+		for _, name := range sortedKeys(values) {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if existing := field.MapIndex(reflect.ValueOf(name)); existing.IsValid() {
+				elem.Set(existing)
+			}
+			if err := setValue(elem, values[name], path+"."+name); err != nil {
+				return err
+			}
+			field.SetMapIndex(reflect.ValueOf(name), elem)
+		}
+
+	case reflect.Slice:
+		items, ok := raw.([]any)
+		if !ok || field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s: expected a list of strings, got %v", path, raw)
+		}
+		list := make([]string, 0, len(items))
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("%s: expected a list of strings, got %v", path, raw)
+			}
+			list = append(list, s)
+		}
+		field.Set(reflect.ValueOf(list))
+
+	default:
+		return fmt.Errorf("%s: unsupported field type %s", path, field.Type())
+	}
+
+	return nil
+}
+
+// validateDurations rejects negative durations anywhere in the config
+func validateDurations(v reflect.Value, path string) error {
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+
+		field := v.Field(i)
+		switch {
+		case field.Type() == durationType:
+			if field.Int() < 0 {
+				return fmt.Errorf("%s: must not be negative", name)
+			}
+		case field.Kind() == reflect.Struct:
+			if err := validateDurations(field, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// WriteReport writes the effective configuration to w, one key per line
+// using the same dotted names as config files. Secrets are reported only as
+// set or not set.
+func (cfg *Config) WriteReport(w io.Writer) error {
+	var lines []string
+	reportValues(reflect.ValueOf(cfg).Elem(), "", &lines)
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportValues appends a "key = value" line for every field of v
+func reportValues(v reflect.Value, path string, lines *[]string) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		secret := name == "-" || strings.HasSuffix(field.Name, "Secret")
+		if name == "" || name == "-" {
+			// Environment-only secrets have no file key
+			name = snakeCase(field.Name)
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+
+		value := v.Field(i)
+		switch {
+		case secret && value.Kind() == reflect.Slice:
+			*lines = append(*lines, fmt.Sprintf("%s = %s", name, redactList(value.Len())))
+		case secret:
+			*lines = append(*lines, fmt.Sprintf("%s = %s", name, redact(value.String())))
+		case value.Type() == durationType:
+			*lines = append(*lines, fmt.Sprintf("%s = %s", name, value.Interface()))
+		case value.Kind() == reflect.Struct:
+			reportValues(value, name, lines)
+		case value.Kind() == reflect.Map:
+			keys := make([]string, 0, value.Len())
+			for _, key := range value.MapKeys() {
+				keys = append(keys, key.String())
+			}
+			sort.Strings(keys)
+			if len(keys) == 0 {
+				*lines = append(*lines, fmt.Sprintf("%s = (none)", name))
+			}
+			for _, key := range keys {
+				reportValues(value.MapIndex(reflect.ValueOf(key)), name+"."+key, lines)
+			}
+		case value.Kind() == reflect.Slice:
+			*lines = append(*lines, fmt.Sprintf("%s = [%s]", name, strings.Join(value.Interface().([]string), ", ")))
+		default:
+			*lines = append(*lines, fmt.Sprintf("%s = %v", name, value.Interface()))
+		}
+	}
+}
+
+// redact hides a secret's value, keeping only whether it is set
+func redact(secret string) string {
+	switch secret {
+	case "":
+		return "(not set)"
+	case defaultJWTSecret:
+		return "(built-in default)"
+	default:
+		return "(set, redacted)"
+	}
+}
+
+// redactList hides a list of secrets, keeping only how many are set
+func redactList(n int) string {
+	if n == 0 {
+		return "(not set)"
+	}
+	return fmt.Sprintf("(%d set, redacted)", n)
+}
+
+// snakeCase converts a Go field name such as SMTPPassword to smtp_password
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a word at a lower-to-upper change, or at the last
+			// capital of an acronym followed by a lowercase letter
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
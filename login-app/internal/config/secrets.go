@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secretFileSuffix turns a secret's environment variable into the one naming
+// a file that holds it, e.g. JWT_SECRET_FILE
+const secretFileSuffix = "_FILE"
+
+// applySecretFiles reads secrets from the files named by *_FILE variables,
+// as mounted by Docker and Kubernetes secrets, so they stay out of process
+// listings and manifests. A file takes precedence over the inline variable.
+func applySecretFiles(cfg *Config) error {
+	secrets := []struct {
+		env   string
+		value *string
+	}{
+		{"JWT_SECRET", &cfg.Auth.JWTSecret},
+		{"SECRET_ENCRYPTION_KEY", &cfg.Auth.SecretEncryptionKey},
+		{"CAPTCHA_SECRET", &cfg.Auth.Captcha.Secret},
+		{"SECURITY_EVENT_HTTP_TOKEN", &cfg.Events.HTTPToken},
+		{"WEBHOOK_SECRET", &cfg.Events.WebhookSecret},
+		{"SMTP_PASSWORD", &cfg.Email.SMTPPassword},
+		{"REDIS_PASSWORD", &cfg.Storage.Redis.Password},
+		{"DATABASE_URL", &cfg.Storage.Database.DSN},
+	}
+	for _, secret := range secrets {
+		if err := readSecretFile(secret.env, secret.value); err != nil {
+			return err
+		}
+	}
+
+	for name, provider := range cfg.OAuth.Providers {
+		if err := readSecretFile(oauthProviderEnvPrefix(name)+"CLIENT_SECRET", &provider.ClientSecret); err != nil {
+			return err
+		}
+		cfg.OAuth.Providers[name] = provider
+	}
+	return nil
+}
+
+// readSecretFile replaces value with the contents of the file named by
+// env's _FILE variable, if set. Trailing newlines, which editors and
+// "echo" add, are trimmed.
+func readSecretFile(env string, value *string) error {
+	path := os.Getenv(env + secretFileSuffix)
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid %s%s: %w", env, secretFileSuffix, err)
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return fmt.Errorf("invalid %s%s: %s is empty", env, secretFileSuffix, path)
+	}
+
+	*value = secret
+	return nil
+}
@@ -0,0 +1,55 @@
+// Package email sends transactional email such as password reset and
+// login links through a pluggable transport.
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// Sender delivers an HTML email message
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// New creates the sender for the configured transport
+func New(cfg config.EmailConfig) (Sender, error) {
+	switch cfg.Transport {
+	case config.EmailTransportSMTP:
+		return NewSMTPSender(cfg), nil
+	case config.EmailTransportLog, "":
+		return LogSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown email transport %q", cfg.Transport)
+	}
+}
+
+// LogSender logs messages instead of delivering them. Bodies contain live
+// tokens, so they are only logged at debug level.
+type LogSender struct{}
+
+// Send logs the message
+func (LogSender) Send(to, subject, body string) error {
+	slog.Info("Email not delivered, log transport in use", "to", to, "subject", subject)
+	slog.Debug("Email body", "to", to, "body", body)
+	return nil
+}
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// Render executes the named message template, e.g. "password_reset.html"
+func Render(name string, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
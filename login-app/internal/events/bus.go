@@ -0,0 +1,179 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options controls buffering and batching on the Bus
+type Options struct {
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Bus fans security events out to every configured sink. Publish never
+// blocks: events are queued on a bounded buffer and dropped with a warning
+// when the buffer is full.
+type Bus struct {
+	sinks         []Sink
+	queue         chan Event
+	batchSize     int
+	flushInterval time.Duration
+
+	dropped   atomic.Uint64
+	closed    atomic.Bool
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBus creates a bus delivering to the given sinks and starts its worker
+func NewBus(sinks []Sink, opts Options) *Bus {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1024
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+
+	b := &Bus{
+		sinks:         sinks,
+		queue:         make(chan Event, opts.BufferSize),
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+
+	go b.run()
+
+	return b
+}
+
+// Publish queues an event for delivery without blocking
+func (b *Bus) Publish(event Event) {
+	if b.closed.Load() {
+		return
+	}
+
+	if event.SchemaVersion == "" {
+		event.SchemaVersion = SchemaVersion
+	}
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	select {
+	case b.queue <- event:
+	default:
+		b.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Close stops accepting events, flushes what is queued and closes all sinks
+func (b *Bus) Close() error {
+	b.closeOnce.Do(func() {
+		b.closed.Store(true)
+		close(b.done)
+	})
+	<-b.stopped
+
+	var firstErr error
+	for _, sink := range b.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// run batches queued events and delivers them until the bus is closed
+func (b *Bus) run() {
+	defer close(b.stopped)
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, b.batchSize)
+	var reportedDrops uint64
+
+	for {
+		select {
+		case event := <-b.queue:
+			batch = append(batch, event)
+			if len(batch) >= b.batchSize {
+				b.deliver(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.deliver(batch)
+				batch = batch[:0]
+			}
+
+			// Warn at most once per flush interval about overflow
+			if dropped := b.dropped.Load(); dropped > reportedDrops {
+				slog.Warn("Security event buffer full, dropping events",
+					"dropped", dropped-reportedDrops, "total_dropped", dropped)
+				reportedDrops = dropped
+			}
+
+		case <-b.done:
+			// Drain whatever is still queued
+			for {
+				select {
+				case event := <-b.queue:
+					batch = append(batch, event)
+					if len(batch) >= b.batchSize {
+						b.deliver(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						b.deliver(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver writes a batch to every sink; one failing sink doesn't affect the others
+func (b *Bus) deliver(batch []Event) {
+	for _, sink := range b.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := sink.Write(ctx, batch); err != nil {
+			slog.Warn("Security event sink failed to write events",
+				"sink", sink.Name(), "events", len(batch), "error", err)
+		}
+		cancel()
+	}
+}
+
+// newEventID generates a random event ID
+func newEventID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(bytes)
+}
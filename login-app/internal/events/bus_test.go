@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps every batch written to it
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]Event
+	closed  bool
+	err     error
+	block   chan struct{} // When set, writes wait for it to close
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Write(ctx context.Context, batch []Event) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]Event(nil), batch...))
+	return s.err
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *recordingSink) events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var all []Event
+	for _, batch := range s.batches {
+		all = append(all, batch...)
+	}
+	return all
+}
+
+func TestBusFillsInSchemaFields(t *testing.T) {
+	sink := &recordingSink{}
+	bus := NewBus([]Sink{sink}, Options{})
+
+	bus.Publish(Event{Type: TypeLoginSucceeded, Source: "auth", Outcome: OutcomeSuccess})
+	bus.Publish(Event{Type: TypeLogout, Source: "auth", Outcome: OutcomeSuccess, ID: "given", SchemaVersion: "0.9"})
+	if err := bus.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got := sink.events()
+	if len(got) != 2 {
+		t.Fatalf("sink got %d events, want 2", len(got))
+	}
+	if got[0].SchemaVersion != SchemaVersion || got[0].ID == "" || got[0].Timestamp.IsZero() {
+		t.Errorf("event = %+v, want the schema version, an ID and a timestamp filled in", got[0])
+	}
+	if got[1].ID != "given" || got[1].SchemaVersion != "0.9" {
+		t.Errorf("event = %+v, want the given ID and version kept", got[1])
+	}
+	if !sink.closed {
+		t.Error("Close didn't close the sink")
+	}
+}
+
+func TestBusBatches(t *testing.T) {
+	sink := &recordingSink{}
+	bus := NewBus([]Sink{sink}, Options{BatchSize: 2, FlushInterval: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(Event{Type: TypeLoginFailed})
+	}
+	bus.Close()
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sizes := make([]int, 0, len(sink.batches))
+	for _, batch := range sink.batches {
+		sizes = append(sizes, len(batch))
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("batch sizes = %v, want [2 2 1]", sizes)
+	}
+}
+
+func TestBusDropsWhenBufferIsFull(t *testing.T) {
+	sink := &recordingSink{block: make(chan struct{})}
+	bus := NewBus([]Sink{sink}, Options{BufferSize: 1, BatchSize: 1, FlushInterval: time.Hour})
+
+	// The worker takes the first event and blocks delivering it, the second
+	// fills the buffer and the rest are dropped without blocking Publish
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			bus.Publish(Event{Type: TypeLoginFailed})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full buffer")
+	}
+
+	if dropped := bus.Dropped(); dropped < 8 {
+		t.Errorf("Dropped() = %d, want at least 8", dropped)
+	}
+	close(sink.block)
+	bus.Close()
+
+	if got := len(sink.events()) + int(bus.Dropped()); got != 10 {
+		t.Errorf("delivered plus dropped = %d, want 10", got)
+	}
+
+	// Events published after Close are ignored
+	bus.Publish(Event{Type: TypeLoginFailed})
+	if got := len(sink.events()) + int(bus.Dropped()); got != 10 {
+		t.Errorf("event published after Close was counted")
+	}
+}
+
+func TestBusFailingSinkDoesNotAffectOthers(t *testing.T) {
+	failing := &recordingSink{err: errors.New("unreachable")}
+	working := &recordingSink{}
+	bus := NewBus([]Sink{failing, working}, Options{})
+
+	bus.Publish(Event{Type: TypeAccountLocked})
+	bus.Close()
+
+	if len(working.events()) != 1 {
+		t.Errorf("working sink got %d events, want 1", len(working.events()))
+	}
+}
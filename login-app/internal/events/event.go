@@ -0,0 +1,78 @@
+package events
+
+import (
+	"time"
+)
+
+// SchemaVersion is the version of the Event wire format. It only changes
+// when fields are removed or change meaning; new optional fields keep it.
+const SchemaVersion = "1.0"
+
+// Security event types
+const (
+	TypeLoginSucceeded   = "auth.login.succeeded"
+	TypeLoginFailed      = "auth.login.failed"
+	TypeAccountLocked    = "auth.account.locked"
+	TypeAccountUnlocked  = "auth.account.unlocked"
+	TypeLogout           = "auth.logout"
+	TypeRegistered       = "auth.user.registered"
+	TypeTokenRefreshed   = "auth.token.refreshed"
+	TypeTokenRevoked     = "auth.token.revoked"
+	TypeTokenReuse       = "auth.token.reuse_detected"
+	TypePasswordReset    = "auth.password.reset"
+	TypePasswordChanged  = "auth.password.changed"
+	TypeTwoFactorEnabled = "auth.two_factor.enabled"
+	TypeQuestionsSet     = "auth.security_questions.set"
+	TypeRecoveryVerified = "auth.security_questions.verified"
+	TypePasskeyAdded     = "auth.passkey.added"
+	TypeAPIKeyCreated    = "auth.api_key.created"
+	TypeAPIKeyRevoked    = "auth.api_key.revoked"
+	TypeAPIKeyRotated    = "auth.api_key.rotated"
+	TypeLinkDecision     = "auth.account.link_decision"
+	TypeProviderUnlinked = "auth.account.provider_unlinked"
+	TypeRoleChanged      = "auth.user.role_changed"
+	TypeUserDeactivated  = "auth.user.deactivated"
+	TypeUserReactivated  = "auth.user.reactivated"
+	TypeUserDeleted      = "auth.user.deleted"
+	TypeUserRestored     = "auth.user.restored"
+	TypeProfileUpdated   = "auth.user.profile_updated"
+	TypeEmailChanged     = "auth.user.email_changed"
+	TypeEmailVerified    = "auth.user.email_verified"
+	TypeMetadataUpdated  = "auth.user.metadata_updated"
+	TypeUsersImported    = "auth.user.imported"
+	TypeDataExported     = "auth.user.data_exported"
+	TypeAccessDenied     = "access.denied"
+	TypeUnauthenticated  = "access.unauthenticated"
+)
+
+// Event outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event represents a security event in the stable export schema
+type Event struct {
+	SchemaVersion string            `json:"schema_version"`
+	ID            string            `json:"id"`
+	Type          string            `json:"type"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Source        string            `json:"source"`
+	Outcome       string            `json:"outcome"`
+	UserID        string            `json:"user_id,omitempty"`
+	Email         string            `json:"email,omitempty"`
+	IP            string            `json:"ip,omitempty"`
+	UserAgent     string            `json:"user_agent,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+}
+
+// Publisher is implemented by anything security events can be published to
+type Publisher interface {
+	Publish(event Event)
+}
+
+// NopPublisher discards all events
+type NopPublisher struct{}
+
+// Publish discards the event
+func (NopPublisher) Publish(Event) {}
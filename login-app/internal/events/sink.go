@@ -0,0 +1,219 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// Sink names accepted in configuration
+const (
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+	SinkHTTP   = "http"
+)
+
+// Sink receives batches of security events
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+
+	// Write delivers a batch of events
+	Write(ctx context.Context, batch []Event) error
+
+	// Close releases any resources held by the sink
+	Close() error
+}
+
+// NewSinks builds the sinks selected in configuration
+func NewSinks(cfg config.EventsConfig) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for _, name := range cfg.Sinks {
+		switch name {
+		case SinkStdout:
+			sinks = append(sinks, NewWriterSink(SinkStdout, os.Stdout))
+		case SinkFile:
+			sink, err := NewFileSink(cfg.FilePath)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case SinkHTTP:
+			sinks = append(sinks, NewHTTPSink(cfg.HTTPEndpoint, cfg.HTTPToken, cfg.MaxRetries))
+		default:
+			return nil, fmt.Errorf("unknown security event sink %q", name)
+		}
+	}
+
+	// Webhooks aren't a SIEM export, so they're enabled by configuring URLs
+	if len(cfg.WebhookURLs) > 0 {
+		sinks = append(sinks, NewDispatcher(cfg.WebhookURLs, cfg.WebhookSecret, cfg.MaxRetries))
+	}
+	return sinks, nil
+}
+
+// WriterSink writes events as JSON lines to an io.Writer
+type WriterSink struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+// NewWriterSink creates a sink writing JSON lines to w
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{name: name, w: w}
+}
+
+// Name identifies the sink
+func (s *WriterSink) Name() string {
+	return s.name
+}
+
+// Write writes one JSON object per line
+func (s *WriterSink) Write(ctx context.Context, batch []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoder := json.NewEncoder(s.w)
+	for _, event := range batch {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op; the writer is owned by the caller
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink appends events as JSON lines to a file
+type FileSink struct {
+	*WriterSink
+	file *os.File
+}
+
+// NewFileSink opens (or creates) the file at path for appending
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open security event file: %w", err)
+	}
+
+	return &FileSink{
+		WriterSink: NewWriterSink(SinkFile, file),
+		file:       file,
+	}, nil
+}
+
+// Close closes the underlying file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// HTTPSink posts batches of events as a JSON array to an HTTP endpoint,
+// retrying failed deliveries with exponential backoff
+type HTTPSink struct {
+	endpoint   string
+	token      string
+	maxRetries int
+	client     *http.Client
+	backoff    time.Duration
+}
+
+// NewHTTPSink creates a sink posting to endpoint. A non-empty token is sent
+// as a bearer token.
+func NewHTTPSink(endpoint, token string, maxRetries int) *HTTPSink {
+	return &HTTPSink{
+		endpoint:   endpoint,
+		token:      token,
+		maxRetries: maxRetries,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		backoff:    500 * time.Millisecond,
+	}
+}
+
+// Name identifies the sink
+func (s *HTTPSink) Name() string {
+	return SinkHTTP
+}
+
+// Write posts the batch, retrying on network errors and 5xx/429 responses
+func (s *HTTPSink) Write(ctx context.Context, batch []Event) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	return retryDelivery(ctx, s.maxRetries, s.backoff, func() (bool, error) {
+		return s.post(ctx, body)
+	})
+}
+
+// retryDelivery calls deliver until it succeeds, reports a failure that
+// isn't worth retrying or has been retried maxRetries times, doubling the
+// wait between attempts from backoff
+func retryDelivery(ctx context.Context, maxRetries int, backoff time.Duration, deliver func() (retry bool, err error)) error {
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		retry, err := deliver()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+
+	return lastErr
+}
+
+// post sends one request and reports whether a failure is worth retrying
+func (s *HTTPSink) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("security event endpoint returned %s", resp.Status)
+}
+
+// Close is a no-op for the HTTP sink
+func (s *HTTPSink) Close() error {
+	return nil
+}
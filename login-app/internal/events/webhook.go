@@ -0,0 +1,162 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook event names, the account lifecycle events downstream systems
+// can react to
+const (
+	WebhookUserRegistered = "user.registered"
+	WebhookUserLogin      = "user.login"
+	WebhookUserDeleted    = "user.deleted"
+)
+
+// webhookEvents maps the security events that trigger webhooks to the
+// webhook event names. Only successful events are sent.
+var webhookEvents = map[string]string{
+	TypeRegistered:     WebhookUserRegistered,
+	TypeLoginSucceeded: WebhookUserLogin,
+	TypeUserDeleted:    WebhookUserDeleted,
+}
+
+// Webhook request headers
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookPayload is the JSON body of a webhook request
+type WebhookPayload struct {
+	ID        string            `json:"id"` // Same for every retry, for deduplication
+	Event     string            `json:"event"`
+	Timestamp time.Time         `json:"timestamp"`
+	UserID    string            `json:"user_id,omitempty"`
+	Email     string            `json:"email,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// Dispatcher is a sink that posts account lifecycle events to webhook
+// URLs, one request per event and URL. Each request is signed with HMAC
+// SHA-256 over the timestamp and body so receivers can verify it came
+// from this service and reject replays; failed deliveries are retried with
+// exponential backoff. Like every sink it runs on the event bus, so
+// requests never wait for webhooks.
+type Dispatcher struct {
+	urls       []string
+	secret     []byte
+	maxRetries int
+	client     *http.Client
+	backoff    time.Duration
+}
+
+// NewDispatcher creates a dispatcher posting to urls, signing with secret
+func NewDispatcher(urls []string, secret string, maxRetries int) *Dispatcher {
+	return &Dispatcher{
+		urls:       urls,
+		secret:     []byte(secret),
+		maxRetries: maxRetries,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		backoff:    500 * time.Millisecond,
+	}
+}
+
+// Name identifies the sink
+func (d *Dispatcher) Name() string {
+	return "webhook"
+}
+
+// Write posts every lifecycle event in the batch to every URL. A failing
+// URL doesn't stop delivery to the others; the first error is returned.
+func (d *Dispatcher) Write(ctx context.Context, batch []Event) error {
+	var firstErr error
+	for _, event := range batch {
+		name, ok := webhookEvents[event.Type]
+		if !ok || event.Outcome != OutcomeSuccess {
+			continue
+		}
+
+		body, err := json.Marshal(WebhookPayload{
+			ID:        event.ID,
+			Event:     name,
+			Timestamp: event.Timestamp,
+			UserID:    event.UserID,
+			Email:     event.Email,
+			Details:   event.Details,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, url := range d.urls {
+			err := retryDelivery(ctx, d.maxRetries, d.backoff, func() (bool, error) {
+				return d.post(ctx, url, name, event.ID, body)
+			})
+			if err != nil {
+				slog.Warn("Webhook delivery failed", "url", url, "event", name, "id", event.ID, "error", err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+// post sends one signed request and reports whether a failure is worth retrying
+func (d *Dispatcher) post(ctx context.Context, url, name, id string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	// Each attempt is signed afresh so its timestamp is current
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, name)
+	req.Header.Set(WebhookIDHeader, id)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(d.secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook endpoint returned %s", resp.Status)
+}
+
+// Close is a no-op for the dispatcher
+func (d *Dispatcher) Close() error {
+	return nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" under
+// secret, the signature receivers recompute to verify a webhook
+func SignWebhook(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,128 @@
+// Package ids generates the identifiers of stored records: users,
+// sessions, API keys, invites, audit entries and token IDs. Unlike the
+// secrets from the tokens package, IDs are not secret, but they are still
+// random so they can't be guessed or enumerated.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ID formats
+const (
+	// FormatHex is 32 lowercase hex digits of random data
+	FormatHex = "hex"
+	// FormatUUIDv4 is a random UUID
+	FormatUUIDv4 = "uuidv4"
+	// FormatUUIDv7 is a UUID that starts with its creation time in
+	// milliseconds, so IDs sort by age and index well in databases
+	FormatUUIDv7 = "uuidv7"
+)
+
+// Generator creates IDs in one format
+type Generator struct {
+	generate func() (string, error)
+}
+
+// New creates a generator of IDs in the given format
+func New(format string) (*Generator, error) {
+	switch format {
+	case FormatHex:
+		return &Generator{generate: newHex}, nil
+	case FormatUUIDv4:
+		return &Generator{generate: newUUIDv4}, nil
+	case FormatUUIDv7:
+		return &Generator{generate: newUUIDv7}, nil
+	}
+	return nil, fmt.Errorf("unknown ID format %q", format)
+}
+
+// Generate returns a new ID
+func (g *Generator) Generate() (string, error) {
+	return g.generate()
+}
+
+func newHex() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func newUUIDv4() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return formatUUID(b, 4), nil
+}
+
+// newUUIDv7 fills the first 48 bits with the Unix time in milliseconds and
+// the rest, apart from the version and variant, with random data. IDs made
+// in the same millisecond are unique but not ordered among themselves.
+func newUUIDv7() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(b[:6], ms[2:])
+	return formatUUID(b, 7), nil
+}
+
+// formatUUID sets the version and RFC 9562 variant bits and writes the
+// UUID in its canonical lowercase form
+func formatUUID(b [16]byte, version byte) string {
+	b[6] = b[6]&0x0f | version<<4
+	b[8] = b[8]&0x3f | 0x80
+
+	var out [36]byte
+	hex.Encode(out[0:8], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:])
+}
+
+// Valid reports whether id has the shape of an ID in any of the formats,
+// so IDs issued before the format was changed stay valid. It checks shape
+// only; whether a record with the ID exists is up to the store.
+func Valid(id string) bool {
+	switch len(id) {
+	case 32:
+		return isLowerHex(id)
+	case 36:
+		for i := 0; i < len(id); i++ {
+			if i == 8 || i == 13 || i == 18 || i == 23 {
+				if id[i] != '-' {
+					return false
+				}
+			} else if !isLowerHex(id[i : i+1]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
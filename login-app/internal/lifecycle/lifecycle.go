@@ -0,0 +1,97 @@
+// Package lifecycle runs the application's background workers and stops
+// them together on shutdown.
+package lifecycle
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Manager runs named background workers. Shutdown cancels the context every
+// worker was given and waits for them to return.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	running  map[string]int // worker name -> goroutines still running
+	stopping bool
+}
+
+// New creates a manager with no workers
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]int),
+	}
+}
+
+// Go runs fn in a background goroutine. fn should return soon after its
+// context is done, finishing or handing off any work it holds. Workers
+// started once Shutdown has been called are not run.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopping {
+		return
+	}
+	m.running[name]++
+	m.wg.Add(1)
+
+	go func() {
+		defer m.wg.Done()
+		defer m.finished(name)
+		fn(m.ctx)
+	}()
+}
+
+// finished records that one goroutine of a worker has returned
+func (m *Manager) finished(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running[name]--; m.running[name] == 0 {
+		delete(m.running, name)
+	}
+}
+
+// Shutdown signals every worker to stop and waits until they have all
+// returned or ctx is done. It returns the names of the workers still
+// running, which is empty when they all finished in time.
+func (m *Manager) Shutdown(ctx context.Context) []string {
+	m.mu.Lock()
+	m.stopping = true
+	m.mu.Unlock()
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return m.Running()
+	}
+}
+
+// Running returns the names of the workers still running, sorted
+func (m *Manager) Running() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.running))
+	for name := range m.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
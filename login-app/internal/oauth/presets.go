@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider types with built-in endpoints and profile mappings. Any other
+// provider is treated as a generic OpenID Connect IdP.
+const (
+	TypeGoogle = "google"
+	TypeGitHub = "github"
+	TypeOIDC   = "oidc"
+)
+
+// preset holds the defaults for a known provider type
+type preset struct {
+	authURL     string
+	tokenURL    string
+	userInfoURL string
+	scopes      []string
+	normalize   func(map[string]interface{}) UserInfo
+}
+
+var presets = map[string]preset{
+	TypeGoogle: {
+		authURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+		tokenURL:    "https://oauth2.googleapis.com/token",
+		userInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		scopes:      []string{"openid", "email", "profile"},
+		normalize:   normalizeOIDC,
+	},
+	TypeGitHub: {
+		authURL:     "https://github.com/login/oauth/authorize",
+		tokenURL:    "https://github.com/login/oauth/access_token",
+		userInfoURL: "https://api.github.com/user",
+		scopes:      []string{"read:user", "user:email"},
+		normalize:   normalizeGitHub,
+	},
+	TypeOIDC: {
+		scopes:    []string{"openid", "email", "profile"},
+		normalize: normalizeOIDC,
+	},
+}
+
+// normalizeOIDC maps standard OpenID Connect userinfo claims
+func normalizeOIDC(claims map[string]interface{}) UserInfo {
+	verified, _ := claims["email_verified"].(bool)
+	return UserInfo{
+		ID:            claimString(claims, "sub"),
+		Email:         claimString(claims, "email"),
+		EmailVerified: verified,
+		Username:      claimString(claims, "preferred_username"),
+		FirstName:     claimString(claims, "given_name"),
+		LastName:      claimString(claims, "family_name"),
+	}
+}
+
+// normalizeGitHub maps GitHub's user API response. GitHub only returns the
+// public profile email and doesn't say whether it is verified, so it is
+// never treated as verified.
+func normalizeGitHub(claims map[string]interface{}) UserInfo {
+	first, last, _ := strings.Cut(claimString(claims, "name"), " ")
+	return UserInfo{
+		ID:        claimString(claims, "id"),
+		Email:     claimString(claims, "email"),
+		Username:  claimString(claims, "login"),
+		FirstName: first,
+		LastName:  last,
+	}
+}
+
+// claimString returns a claim as a string, formatting numeric IDs
+func claimString(claims map[string]interface{}, key string) string {
+	switch value := claims[key].(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
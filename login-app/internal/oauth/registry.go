@@ -0,0 +1,88 @@
+package oauth
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// Registry holds the configured providers by name
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry builds providers from configuration. A provider's type
+// defaults to its name, so one called google or github needs only client
+// credentials; other providers must supply their endpoint URLs.
+func NewRegistry(cfgs map[string]config.OAuthProviderConfig) (*Registry, error) {
+	r := &Registry{providers: make(map[string]*Provider, len(cfgs))}
+
+	for name, cfg := range cfgs {
+		providerType := cfg.Type
+		if providerType == "" {
+			providerType = name
+		}
+		defaults, ok := presets[providerType]
+		if !ok {
+			defaults = presets[TypeOIDC]
+		}
+
+		provider := &Provider{
+			Name:         name,
+			AuthURL:      firstNonEmpty(cfg.AuthURL, defaults.authURL),
+			TokenURL:     firstNonEmpty(cfg.TokenURL, defaults.tokenURL),
+			UserInfoURL:  firstNonEmpty(cfg.UserInfoURL, defaults.userInfoURL),
+			Scopes:       cfg.Scopes,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Normalize:    defaults.normalize,
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = defaults.scopes
+		}
+
+		if provider.AuthURL == "" || provider.TokenURL == "" || provider.UserInfoURL == "" {
+			return nil, fmt.Errorf("oauth provider %q: auth, token and userinfo URLs are required", name)
+		}
+
+		r.Register(provider)
+	}
+
+	return r, nil
+}
+
+// Register adds or replaces a provider
+func (r *Registry) Register(provider *Provider) {
+	if provider.client == nil {
+		provider.client = newHTTPClient()
+	}
+	r.providers[provider.Name] = provider
+}
+
+// Get returns the provider with the given name
+func (r *Registry) Get(name string) (*Provider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Names returns the registered provider names in order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
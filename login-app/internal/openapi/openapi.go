@@ -0,0 +1,526 @@
+// Package openapi builds an OpenAPI 3 document from route descriptions,
+// deriving request and response schemas from Go types so the document
+// cannot drift from the structs the handlers actually bind and return.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the OpenAPI version documents are generated for
+const Version = "3.0.3"
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Security   []Requirement       `json:"security,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	Tags       []Tag               `json:"tags,omitempty"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is a base URL the API is served from
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to operations
+type PathItem map[string]*Operation
+
+// Requirement names the security schemes an operation accepts
+type Requirement map[string][]string
+
+// Operation describes a single API operation
+type Operation struct {
+	Tags        []string            `json:"tags,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	OperationID string              `json:"operationId,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+
+	// Security is nil to inherit the document default; an empty slice
+	// marks a public operation
+	Security *[]Requirement `json:"security,omitempty"`
+}
+
+// Parameter describes a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes an operation's request body
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes an operation response
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a request or response body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how clients authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+}
+
+// Schema is a JSON schema as used by OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+// Route describes one API operation in terms of the Go types it uses
+type Route struct {
+	Method      string
+	Path        string // Gin syntax, path parameters as :name
+	Tag         string
+	Summary     string
+	Description string
+
+	// Auth marks operations that need a bearer token or API key
+	Auth bool
+
+	// Request is a value of the JSON body type, or a *Schema for other content
+	Request            interface{}
+	RequestContentType string // Defaults to application/json
+
+	// Response is a value of the type returned in the success envelope's
+	// data field, or nil when there is none. Raw responses are returned
+	// without the envelope, and may be a *Schema for other content.
+	Response interface{}
+	Raw      bool
+	Status   int // Defaults to 200
+
+	// ResponseContentTypes lists the media types a raw response may be
+	// sent as. Defaults to application/json.
+	ResponseContentTypes []string
+
+	// Errors lists the error statuses the operation can return
+	Errors []int
+
+	Query []Parameter
+}
+
+// Generator accumulates routes into a document
+type Generator struct {
+	doc *Document
+
+	envelope     interface{}
+	envelopeData string
+	errorSchema  *Schema
+}
+
+// NewGenerator creates a generator. Success responses are wrapped in
+// envelope with the payload in its dataField; errors use errorType.
+func NewGenerator(info Info, envelope interface{}, dataField string, errorType interface{}) *Generator {
+	g := &Generator{
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   make(map[string]PathItem),
+			Components: Components{
+				Schemas:         make(map[string]*Schema),
+				SecuritySchemes: make(map[string]SecurityScheme),
+			},
+		},
+		envelope:     envelope,
+		envelopeData: dataField,
+	}
+	g.errorSchema = g.Schema(errorType)
+	return g
+}
+
+// AddServer adds a base URL
+func (g *Generator) AddServer(url, description string) {
+	g.doc.Servers = append(g.doc.Servers, Server{URL: url, Description: description})
+}
+
+// AddTag adds a tag description
+func (g *Generator) AddTag(name, description string) {
+	g.doc.Tags = append(g.doc.Tags, Tag{Name: name, Description: description})
+}
+
+// AddSecurityScheme registers a scheme that authenticated routes accept
+func (g *Generator) AddSecurityScheme(name string, scheme SecurityScheme) {
+	g.doc.Components.SecuritySchemes[name] = scheme
+	g.doc.Security = append(g.doc.Security, Requirement{name: {}})
+}
+
+// Add documents a route
+func (g *Generator) Add(route Route) {
+	path, params := convertPath(route.Path)
+
+	op := &Operation{
+		Summary:     route.Summary,
+		Description: route.Description,
+		OperationID: operationID(route.Method, path),
+		Parameters:  append(params, route.Query...),
+		Responses:   make(map[string]Response),
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if !route.Auth {
+		public := []Requirement{}
+		op.Security = &public
+	}
+
+	if route.Request != nil {
+		contentType := route.RequestContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		schema, ok := route.Request.(*Schema)
+		if !ok {
+			schema = g.Schema(route.Request)
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{contentType: {Schema: schema}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	// Raw routes without a response type, such as redirects, have no body
+	if !route.Raw || route.Response != nil {
+		contentTypes := route.ResponseContentTypes
+		if len(contentTypes) == 0 {
+			contentTypes = []string{"application/json"}
+		}
+		schema := g.responseSchema(route)
+		success.Content = make(map[string]MediaType, len(contentTypes))
+		for _, contentType := range contentTypes {
+			success.Content[contentType] = MediaType{Schema: schema}
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	errors := append([]int{}, route.Errors...)
+	if route.Auth {
+		errors = append(errors, http.StatusUnauthorized)
+	}
+	errors = append(errors, http.StatusInternalServerError)
+	for _, code := range errors {
+		op.Responses[strconv.Itoa(code)] = Response{
+			Description: http.StatusText(code),
+			Content:     map[string]MediaType{"application/json": {Schema: g.errorSchema}},
+		}
+	}
+
+	item, ok := g.doc.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		g.doc.Paths[path] = item
+	}
+	item[strings.ToLower(route.Method)] = op
+}
+
+// Document returns the generated document
+func (g *Generator) Document() *Document {
+	return g.doc
+}
+
+// YAML renders the document as YAML with the keys in the order of its JSON form
+func (d *Document) YAML() ([]byte, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is YAML, so parsing it keeps the order; only the style changes
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blockStyle clears the flow and quoting styles of parsed JSON so nodes are
+// written in plain block style, quoted only where YAML requires it
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// responseSchema returns the schema of a route's success response
+func (g *Generator) responseSchema(route Route) *Schema {
+	if route.Raw {
+		if schema, ok := route.Response.(*Schema); ok {
+			return schema
+		}
+		return g.Schema(route.Response)
+	}
+
+	envelope := g.Schema(g.envelope)
+	if route.Response == nil {
+		return envelope
+	}
+
+	return &Schema{AllOf: []*Schema{
+		envelope,
+		{
+			Type:       "object",
+			Properties: map[string]*Schema{g.envelopeData: g.Schema(route.Response)},
+		},
+	}}
+}
+
+// Schema returns the schema for a Go value. Named structs are registered as
+// components and referenced.
+func (g *Generator) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return g.schemaFor(reflect.TypeOf(v))
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schemaFor returns the schema for a Go type
+func (g *Generator) schemaFor(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		schema := g.schemaFor(t.Elem())
+		if schema.Ref != "" {
+			// Siblings of $ref are ignored, so wrap it to mark it nullable
+			return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+		}
+		schema.Nullable = true
+		return schema
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t == rawMessageType {
+		// Embedded JSON documents accept any value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		if t == reflect.TypeOf(time.Duration(0)) {
+			return &Schema{Type: "integer", Format: "int64", Description: "Duration in nanoseconds"}
+		}
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := g.doc.Components.Schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			g.doc.Components.Schemas[name] = &Schema{}
+			g.doc.Components.Schemas[name] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		// interface{} and anything else accepts any value
+		return &Schema{}
+	}
+}
+
+// structSchema builds an object schema from a struct's JSON and binding tags
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		// Embedded structs without a JSON name are flattened like encoding/json does
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := g.structSchema(embedded)
+				for prop, propSchema := range inner.Properties {
+					schema.Properties[prop] = propSchema
+				}
+				schema.Required = append(schema.Required, inner.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		propSchema := g.schemaFor(field.Type)
+		if applyBinding(propSchema, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = propSchema
+	}
+
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// jsonName returns a field's JSON name and whether it is skipped
+func jsonName(field reflect.StructField) (name string, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ = strings.Cut(tag, ",")
+	return name, false
+}
+
+// applyBinding copies Gin binding rules onto a schema and reports whether
+// the field is required
+func applyBinding(schema *Schema, binding string) bool {
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "min", "max":
+			n, err := strconv.Atoi(value)
+			if err != nil || schema.Type != "string" {
+				continue
+			}
+			if key == "min" {
+				schema.MinLength = &n
+			} else {
+				schema.MaxLength = &n
+			}
+		}
+	}
+	return required
+}
+
+// convertPath turns Gin path parameters into OpenAPI templates and parameters
+func convertPath(path string) (string, []Parameter) {
+	var params []Parameter
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives a stable operation ID such as postApiAuthLogin
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '{' || r == '}' || r == '.' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
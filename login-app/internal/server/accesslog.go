@@ -0,0 +1,38 @@
+package server
+
+import (
+	"math/rand"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// accessLogSampler decides which completed requests are logged: responses
+// in an always-logged status class, and a sampled fraction of the rest
+type accessLogSampler struct {
+	ratio  float64
+	always [6]bool // indexed by status class, 1 through 5
+
+	// random returns a number in [0, 1); replaceable for deterministic sampling
+	random func() float64
+}
+
+func newAccessLogSampler(cfg config.LogConfig) *accessLogSampler {
+	s := &accessLogSampler{
+		ratio:  cfg.RequestSampleRatio,
+		random: rand.Float64,
+	}
+	for _, class := range cfg.RequestAlways {
+		if len(class) > 0 && class[0] >= '1' && class[0] <= '5' {
+			s.always[class[0]-'0'] = true
+		}
+	}
+	return s
+}
+
+// shouldLog reports whether a request that completed with status is logged
+func (s *accessLogSampler) shouldLog(status int) bool {
+	if class := status / 100; class >= 1 && class <= 5 && s.always[class] {
+		return true
+	}
+	return s.random() < s.ratio
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+func TestDeactivateAndReactivateRoutes(t *testing.T) {
+	users := storage.NewMemoryUserStore()
+	handler := newTestServerWith(t, users, nil)
+	admin := registerUser(t, handler, "admin@example.com", "admin")
+	promoteToAdmin(t, users, "admin@example.com")
+	member := registerUser(t, handler, "member@example.com", "member")
+
+	if w := request(t, handler, http.MethodPost, "/api/auth/deactivate", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("deactivate without a token: status %d, want 401", w.Code)
+	}
+	if w := request(t, handler, http.MethodPost, "/api/auth/deactivate", nil, bearer(member.Token)); w.Code != http.StatusOK {
+		t.Fatalf("deactivate: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(member.Token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("profile after deactivating: status %d, want 401", w.Code)
+	}
+
+	login := auth.LoginRequest{Email: "member@example.com", Password: testPassword}
+	if w := request(t, handler, http.MethodPost, "/api/auth/login", login, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("login while deactivated: status %d, want 401", w.Code)
+	}
+
+	reactivate := "/api/admin/users/" + member.User.ID + "/reactivate"
+	if w := request(t, handler, http.MethodPost, reactivate, nil, bearer(member.Token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("reactivate with the deactivated user's token: status %d, want 401", w.Code)
+	}
+	if w := request(t, handler, http.MethodPost, reactivate, nil, bearer(admin.Token)); w.Code != http.StatusOK {
+		t.Fatalf("reactivate: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	w := request(t, handler, http.MethodPost, "/api/auth/login", login, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login after reactivation: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var loggedIn auth.LoginResponse
+	decodeData(t, w, &loggedIn)
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(loggedIn.Token)); w.Code != http.StatusOK {
+		t.Errorf("profile after reactivation: status %d, want 200", w.Code)
+	}
+}
+
+func TestReactivateRequiresAdmin(t *testing.T) {
+	handler := newTestServer(t)
+	member := registerUser(t, handler, "member@example.com", "member")
+	other := registerUser(t, handler, "other@example.com", "other")
+
+	if w := request(t, handler, http.MethodPost, "/api/admin/users/"+other.User.ID+"/reactivate", nil, bearer(member.Token)); w.Code != http.StatusForbidden {
+		t.Errorf("status %d, want 403", w.Code)
+	}
+}
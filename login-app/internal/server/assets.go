@@ -0,0 +1,80 @@
+package server
+
+import (
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/web"
+)
+
+// setupAssets loads the page templates and serves static files, from the
+// copies embedded in the binary or, with DiskAssets, from the configured
+// directories, and records whether web pages can be served. With APIOnly
+// nothing is loaded, and a template directory with no templates doesn't
+// stop the server; either way only the JSON API is served.
+func (s *Server) setupAssets() error {
+	cfg := s.config.Server
+
+	if cfg.APIOnly {
+		slog.Info("Serving the API only, web pages are disabled")
+		return nil
+	}
+
+	if !cfg.DiskAssets {
+		templates, err := template.New("").Funcs(s.router.FuncMap).ParseFS(web.Assets, "templates/*.html")
+		if err != nil {
+			return err
+		}
+		s.router.SetHTMLTemplate(templates)
+
+		static, err := fs.Sub(web.Assets, "static")
+		if err != nil {
+			return err
+		}
+		s.router.StaticFS("/static", onlyFilesFS{http.FS(static)})
+		s.pages = true
+		return nil
+	}
+
+	pattern := filepath.Join(cfg.TemplateDir, "*.html")
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		slog.Warn("No page templates found, serving the API only", "dir", cfg.TemplateDir)
+		return nil
+	}
+
+	// Parse once up front so a broken template is reported as an error
+	// instead of a panic in Gin
+	if _, err := template.New("").Funcs(s.router.FuncMap).ParseGlob(pattern); err != nil {
+		return err
+	}
+	s.router.LoadHTMLGlob(pattern)
+	s.router.Static("/static", cfg.StaticDir)
+	s.pages = true
+	return nil
+}
+
+// onlyFilesFS serves files but not directory listings, like gin.Static does
+// for directories on disk
+type onlyFilesFS struct {
+	fs http.FileSystem
+}
+
+func (o onlyFilesFS) Open(name string) (http.File, error) {
+	f, err := o.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
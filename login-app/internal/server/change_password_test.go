@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+func TestChangePasswordRoute(t *testing.T) {
+	handler := newTestServer(t)
+	registered := registerUser(t, handler, "route@example.com", "route")
+
+	for name, tc := range map[string]struct {
+		request auth.ChangePasswordRequest
+		headers map[string]string
+		status  int
+	}{
+		"no token":           {auth.ChangePasswordRequest{OldPassword: testPassword, NewPassword: "Changed2@y"}, nil, http.StatusUnauthorized},
+		"wrong old password": {auth.ChangePasswordRequest{OldPassword: "Wrong1!pass", NewPassword: "Changed2@y"}, bearer(registered.Token), http.StatusUnauthorized},
+		"weak new password":  {auth.ChangePasswordRequest{OldPassword: testPassword, NewPassword: "short"}, bearer(registered.Token), http.StatusBadRequest},
+	} {
+		if w := request(t, handler, http.MethodPost, "/api/auth/change-password", tc.request, tc.headers); w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", name, w.Code, tc.status, w.Body.String())
+		}
+	}
+
+	w := request(t, handler, http.MethodPost, "/api/auth/change-password",
+		auth.ChangePasswordRequest{OldPassword: testPassword, NewPassword: "Changed2@y"}, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("change: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if w := request(t, handler, http.MethodPost, "/api/auth/login", auth.LoginRequest{Email: "route@example.com", Password: "Changed2@y"}, nil); w.Code != http.StatusOK {
+		t.Errorf("login with the new password: status %d, want 200", w.Code)
+	}
+}
+
+func TestChangePasswordRouteReportsRevokedSessions(t *testing.T) {
+	handler := newTestServerWith(t, storage.NewMemoryUserStore(), func(cfg *config.Config) {
+		cfg.Auth.RevokeSessionsOnPasswordChange = true
+	})
+	registered := registerUser(t, handler, "everywhere@example.com", "everywhere")
+	w := request(t, handler, http.MethodPost, "/api/auth/login", auth.LoginRequest{Email: "everywhere@example.com", Password: testPassword}, nil)
+	var other auth.LoginResponse
+	decodeData(t, w, &other)
+
+	w = request(t, handler, http.MethodPost, "/api/auth/change-password",
+		auth.ChangePasswordRequest{OldPassword: testPassword, NewPassword: "Changed2@y"}, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("change: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var changed auth.PasswordChangeResponse
+	decodeData(t, w, &changed)
+	if !changed.SessionsRevoked {
+		t.Error("response doesn't report the revoked sessions")
+	}
+
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(other.Token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("other session's token: status %d, want 401", w.Code)
+	}
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(registered.Token)); w.Code != http.StatusOK {
+		t.Errorf("current session's token: status %d, want 200", w.Code)
+	}
+}
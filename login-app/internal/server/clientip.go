@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIP returns the address of the client that made the request.
+//
+// X-Forwarded-For and X-Real-IP are client-controlled headers, so they are
+// only believed when the request arrives from a proxy listed in
+// TRUSTED_PROXIES; Gin then walks the forwarded chain from the right and
+// stops at the first untrusted hop. With no trusted proxies the headers are
+// ignored and the TCP peer address is used, so a client cannot forge its IP
+// to dodge rate limits, IP filters or audit records. Trusting a proxy that
+// doesn't overwrite or append to X-Forwarded-For reopens that hole.
+//
+// IPv4-mapped IPv6 addresses are unmapped so the same client always
+// produces the same string.
+func clientIP(c *gin.Context) string {
+	ip := c.ClientIP()
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		return addr.Unmap().String()
+	}
+	return ip
+}
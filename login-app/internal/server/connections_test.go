@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+func TestConnectionRoutes(t *testing.T) {
+	users := storage.NewMemoryUserStore()
+	handler := newTestServerWith(t, users, nil)
+	registered := registerUser(t, handler, "connected@example.com", "connected")
+
+	// Leave the account with GitHub and Google as its only ways to log in
+	user, err := users.GetUserByEmail(context.Background(), "connected@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	user.PasswordHash = ""
+	user.LinkedProviders = []storage.LinkedProvider{
+		{Provider: "github", ProviderUserID: "gh-1"},
+		{Provider: "google", ProviderUserID: "g-1"},
+	}
+	if err := users.UpdateUser(context.Background(), user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	if w := request(t, handler, http.MethodGet, "/api/auth/connections", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("list without a token: status %d, want 401", w.Code)
+	}
+	w := request(t, handler, http.MethodGet, "/api/auth/connections", nil, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: status %d: %s", w.Code, w.Body.String())
+	}
+	var connections auth.ConnectionsResponse
+	decodeData(t, w, &connections)
+	if len(connections.Connections) != 2 || connections.HasPassword {
+		t.Errorf("connections = %+v, want github and google and no password", connections)
+	}
+
+	for _, tc := range []struct {
+		provider string
+		status   int
+	}{
+		{"github", http.StatusOK},
+		{"github", http.StatusNotFound},
+		{"google", http.StatusConflict},
+	} {
+		if w := request(t, handler, http.MethodDelete, "/api/auth/connections/"+tc.provider, nil, bearer(registered.Token)); w.Code != tc.status {
+			t.Errorf("unlink %s: status %d, want %d: %s", tc.provider, w.Code, tc.status, w.Body.String())
+		}
+	}
+
+	w = request(t, handler, http.MethodGet, "/api/auth/connections", nil, bearer(registered.Token))
+	connections = auth.ConnectionsResponse{}
+	decodeData(t, w, &connections)
+	if len(connections.Connections) != 1 || connections.Connections[0].Provider != "google" {
+		t.Errorf("connections after unlinking = %+v, want only google", connections.Connections)
+	}
+}
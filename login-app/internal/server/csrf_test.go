@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCSRFRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(csrf())
+	router.GET("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("csrf_token"))
+	})
+	router.POST("/form", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+// csrfCookieFrom returns the CSRF cookie set by a response, or nil
+func csrfCookieFrom(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == csrfCookie {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func postForm(router http.Handler, cookie string, form url.Values, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: cookie})
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCSRFIssuesToken(t *testing.T) {
+	router := newCSRFRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET: status %d, want 200", w.Code)
+	}
+	cookie := csrfCookieFrom(w)
+	if cookie == nil {
+		t.Fatal("GET set no CSRF cookie")
+	}
+	if !validCSRFToken(cookie.Value) || w.Body.String() != cookie.Value {
+		t.Errorf("page token %q, cookie %q; want the same issued token", w.Body.String(), cookie.Value)
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("cookie HttpOnly %v, SameSite %v; want HttpOnly and Strict", cookie.HttpOnly, cookie.SameSite)
+	}
+
+	// A browser that already has a token keeps it
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if csrfCookieFrom(w) != nil || w.Body.String() != cookie.Value {
+		t.Errorf("second GET replaced the token")
+	}
+}
+
+func TestCSRFAcceptsMatchingToken(t *testing.T) {
+	router := newCSRFRouter()
+	token, err := newCSRFToken()
+	if err != nil {
+		t.Fatalf("newCSRFToken: %v", err)
+	}
+
+	if w := postForm(router, token, url.Values{csrfFormField: {token}}, nil); w.Code != http.StatusNoContent {
+		t.Errorf("form field: status %d, want 204", w.Code)
+	}
+	if w := postForm(router, token, nil, map[string]string{csrfHeader: token}); w.Code != http.StatusNoContent {
+		t.Errorf("header: status %d, want 204", w.Code)
+	}
+}
+
+func TestCSRFRejectsMissingOrMismatchedToken(t *testing.T) {
+	router := newCSRFRouter()
+	token, _ := newCSRFToken()
+	other, _ := newCSRFToken()
+
+	for name, tc := range map[string]struct {
+		cookie    string
+		submitted string
+	}{
+		"no cookie":        {"", token},
+		"nothing echoed":   {token, ""},
+		"other token":      {token, other},
+		"malformed cookie": {"forged", "forged"},
+	} {
+		form := url.Values{}
+		if tc.submitted != "" {
+			form.Set(csrfFormField, tc.submitted)
+		}
+		if w := postForm(router, tc.cookie, form, nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403", name, w.Code)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+// mockSink keeps the JSON encoding of every event written to it, as a SIEM
+// would receive it
+type mockSink struct {
+	mu     sync.Mutex
+	events []map[string]any
+}
+
+func (s *mockSink) Name() string { return "mock" }
+
+func (s *mockSink) Write(ctx context.Context, batch []events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range batch {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		var decoded map[string]any
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return err
+		}
+		s.events = append(s.events, decoded)
+	}
+	return nil
+}
+
+func (s *mockSink) Close() error { return nil }
+
+func TestSecurityEventsReachSink(t *testing.T) {
+	cfg, err := config.Load("test")
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	cfg.Auth.MaxFailedLogins = 2
+
+	sink := &mockSink{}
+	bus := events.NewBus([]events.Sink{sink}, events.Options{FlushInterval: time.Hour})
+	srv, err := New(cfg, storage.NewMemoryUserStore(), storage.SessionStores{}, bus, email.LogSender{}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	handler := srv.Handler()
+
+	// Events from registration, login, lockout, logout, the auth middleware
+	// and the server's access denied reporter
+	registered := registerUser(t, handler, "siem@example.com", "siem")
+	request(t, handler, http.MethodPost, "/api/auth/login", auth.LoginRequest{Email: "siem@example.com", Password: testPassword}, nil)
+	request(t, handler, http.MethodGet, "/api/admin/users", nil, bearer(registered.Token))
+	request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer("not-a-token"))
+	request(t, handler, http.MethodPost, "/api/auth/logout", nil, bearer(registered.Token))
+	for i := 0; i < cfg.Auth.MaxFailedLogins; i++ {
+		request(t, handler, http.MethodPost, "/api/auth/login", auth.LoginRequest{Email: "siem@example.com", Password: "Wrong1!pass"}, nil)
+	}
+
+	// Closing the bus flushes everything queued to the sink
+	if err := bus.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	seen := make(map[string]string) // type -> source
+	for _, event := range sink.events {
+		for _, field := range []string{"schema_version", "id", "type", "timestamp", "source", "outcome"} {
+			if value, _ := event[field].(string); value == "" {
+				t.Errorf("event %v has no %s", event, field)
+			}
+		}
+		if event["schema_version"] != events.SchemaVersion {
+			t.Errorf("event %v has schema version %v, want %s", event["type"], event["schema_version"], events.SchemaVersion)
+		}
+		if timestamp, _ := event["timestamp"].(string); timestamp != "" {
+			if _, err := time.Parse(time.RFC3339Nano, timestamp); err != nil {
+				t.Errorf("event %v timestamp %q isn't RFC 3339", event["type"], timestamp)
+			}
+		}
+		if outcome := event["outcome"]; outcome != events.OutcomeSuccess && outcome != events.OutcomeFailure {
+			t.Errorf("event %v has outcome %v", event["type"], outcome)
+		}
+		seen[event["type"].(string)], _ = event["source"].(string)
+	}
+
+	for eventType, source := range map[string]string{
+		events.TypeRegistered:      "auth",
+		events.TypeLoginSucceeded:  "auth",
+		events.TypeLoginFailed:     "auth",
+		events.TypeAccountLocked:   "auth",
+		events.TypeLogout:          "auth",
+		events.TypeUnauthenticated: "auth",
+		events.TypeAccessDenied:    "server",
+	} {
+		if got, ok := seen[eventType]; !ok || got != source {
+			t.Errorf("%s: source %q, seen %v; want from %s", eventType, got, ok, source)
+		}
+	}
+}
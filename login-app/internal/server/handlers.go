@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
 )
 
 // Auth API handlers
@@ -22,6 +23,56 @@ func (s *Server) handleLogin(c *gin.Context) {
 	handler.Login(c)
 }
 
+func (s *Server) handleLoginTwoFactor(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.LoginTwoFactor(c)
+}
+
+func (s *Server) handleEnableTOTP(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.EnableTOTP(c)
+}
+
+func (s *Server) handleConfirmTOTP(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ConfirmTOTP(c)
+}
+
+func (s *Server) handleBeginPasskeyRegistration(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.BeginPasskeyRegistration(c)
+}
+
+func (s *Server) handleFinishPasskeyRegistration(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.FinishPasskeyRegistration(c)
+}
+
+func (s *Server) handleBeginPasskeyLogin(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.BeginPasskeyLogin(c)
+}
+
+func (s *Server) handleFinishPasskeyLogin(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.FinishPasskeyLogin(c)
+}
+
+func (s *Server) handleRefresh(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.Refresh(c)
+}
+
+func (s *Server) handleForgotPassword(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ForgotPassword(c)
+}
+
+func (s *Server) handleResetPassword(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ResetPassword(c)
+}
+
 func (s *Server) handleLogout(c *gin.Context) {
 	handler := auth.NewHandler(s.authService)
 	handler.Logout(c)
@@ -32,28 +83,274 @@ func (s *Server) handleProfile(c *gin.Context) {
 	handler.Profile(c)
 }
 
+func (s *Server) handleWhoAmI(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.WhoAmI(c)
+}
+
+func (s *Server) handleOAuthLogin(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.OAuthLogin(c)
+}
+
+func (s *Server) handleOAuthCallback(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.OAuthCallback(c)
+}
+
+func (s *Server) handleConfirmOAuthLink(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ConfirmOAuthLink(c)
+}
+
+func (s *Server) handleChangePassword(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ChangePassword(c)
+}
+
+func (s *Server) handleCreateAPIKey(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.CreateAPIKey(c)
+}
+
+func (s *Server) handleListAPIKeys(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ListAPIKeys(c)
+}
+
+func (s *Server) handleRevokeAPIKey(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RevokeAPIKey(c)
+}
+
+func (s *Server) handleRotateAPIKey(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RotateAPIKey(c)
+}
+
+func (s *Server) handleJWKS(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.JWKS(c)
+}
+
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	handler := auth.NewHandler(s.authService)
 	return handler.Middleware()
 }
 
+func (s *Server) requireScope(scope string) gin.HandlerFunc {
+	handler := auth.NewHandler(s.authService)
+	return handler.RequireScope(scope)
+}
+
+func (s *Server) requireAPIKey() gin.HandlerFunc {
+	handler := auth.NewHandler(s.authService)
+	return handler.RequireAPIKey()
+}
+
+func (s *Server) handleIntrospectToken(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.IntrospectToken(c)
+}
+
+func (s *Server) requireAdmin() gin.HandlerFunc {
+	handler := auth.NewHandler(s.authService)
+	return handler.RequireRole(storage.RoleAdmin)
+}
+
+func (s *Server) handleAdminListUsers(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ListUsers(c)
+}
+
+func (s *Server) handleAdminStats(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.AdminStats(c)
+}
+
+func (s *Server) handleAdminAuditLog(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.AuditLog(c)
+}
+
+func (s *Server) handleValidateToken(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ValidateToken(c)
+}
+
+func (s *Server) handleVerifyEmail(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.VerifyEmail(c)
+}
+
+func (s *Server) handleResendEmailVerification(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ResendEmailVerification(c)
+}
+
+func (s *Server) handleAdminAuditExport(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.AuditExport(c)
+}
+
+func (s *Server) handleAdminReactivateUser(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ReactivateUser(c)
+}
+
+func (s *Server) handleAdminRevokeUserSessions(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RevokeUserSessions(c)
+}
+
+func (s *Server) handleAdminSearchUsers(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.SearchUsers(c)
+}
+
+func (s *Server) handleAdminResetPassword(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.AdminResetPassword(c)
+}
+
+func (s *Server) handleAdminGetUserMetadata(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.AdminGetUserMetadata(c)
+}
+
+func (s *Server) handleAdminSetUserMetadata(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.AdminSetUserMetadata(c)
+}
+
+func (s *Server) handleAdminCreateInvite(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.CreateInvite(c)
+}
+
+func (s *Server) handleAdminListInvites(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ListInvites(c)
+}
+
+func (s *Server) handleAdminRevokeInvite(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RevokeInvite(c)
+}
+
+func (s *Server) handleAdminDeleteUser(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.DeleteUser(c)
+}
+
+func (s *Server) handleAdminRestoreUser(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RestoreUser(c)
+}
+
+func (s *Server) handleAdminImportUsers(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ImportUsers(c)
+}
+
+func (s *Server) handleRequestMagicLink(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RequestMagicLink(c)
+}
+
+func (s *Server) handleConsumeMagicLink(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ConsumeMagicLink(c)
+}
+
+func (s *Server) handleUnlockAccount(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.UnlockAccount(c)
+}
+
+func (s *Server) handleSetSecurityQuestions(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.SetSecurityQuestions(c)
+}
+
+func (s *Server) handleRecoveryQuestions(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RecoveryQuestions(c)
+}
+
+func (s *Server) handleRecoveryAnswers(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RecoveryAnswers(c)
+}
+
+func (s *Server) handleUpdateProfile(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.UpdateProfile(c)
+}
+
+func (s *Server) handleRequestEmailChange(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RequestEmailChange(c)
+}
+
+func (s *Server) handleConfirmEmailChange(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ConfirmEmailChange(c)
+}
+
+func (s *Server) handleDeleteAccount(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.DeleteAccount(c)
+}
+
+func (s *Server) handleExportAccount(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ExportAccount(c)
+}
+
+func (s *Server) handleDeactivate(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.Deactivate(c)
+}
+
+func (s *Server) handleListConnections(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ListConnections(c)
+}
+
+func (s *Server) handleUnlinkProvider(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.UnlinkProvider(c)
+}
+
+func (s *Server) handleListSessions(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.ListSessions(c)
+}
+
+func (s *Server) handleRevokeSession(c *gin.Context) {
+	handler := auth.NewHandler(s.authService)
+	handler.RevokeSession(c)
+}
+
 // Web page handlers
 
 func (s *Server) handleHome(c *gin.Context) {
-	c.HTML(http.StatusOK, "index.html", gin.H{
+	renderPage(c, http.StatusOK, "index.html", gin.H{
 		"title": "Welcome to Login App",
 	})
 }
 
 func (s *Server) handleLoginPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "login.html", gin.H{
+	renderPage(c, http.StatusOK, "login.html", gin.H{
 		"title": "Login",
 	})
 }
 
 func (s *Server) handleRegisterPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "register.html", gin.H{
-		"title": "Register",
+	renderPage(c, http.StatusOK, "register.html", gin.H{
+		"title":      "Register",
+		"inviteOnly": s.config.Auth.InviteOnly,
 	})
 }
 
@@ -64,7 +361,7 @@ func (s *Server) handleDashboard(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "dashboard.html", gin.H{
+	renderPage(c, http.StatusOK, "dashboard.html", gin.H{
 		"title": "Dashboard",
 		"user":  userInfo,
 	})
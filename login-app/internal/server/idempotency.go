@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+// Idempotency-Key handling
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength bounds the memory a key can take
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyPruneInterval is how often expired responses are evicted
+	idempotencyPruneInterval = time.Minute
+)
+
+// idempotencyCache remembers responses by idempotency key until they expire
+type idempotencyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*idempotentResponse
+	lastPrune time.Time
+}
+
+// idempotentResponse is a stored response, or a placeholder while the
+// first request with its key is still being handled
+type idempotentResponse struct {
+	bodyHash  [sha256.Size]byte // Hash of the request body the key was first used with
+	pending   bool
+	status    int
+	header    http.Header
+	body      []byte
+	expiresAt time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:       ttl,
+		entries:   make(map[string]*idempotentResponse),
+		lastPrune: time.Now(),
+	}
+}
+
+// begin returns the stored response for a key, or claims the key with a
+// pending entry and returns nil when there is none
+func (c *idempotencyCache) begin(key string, bodyHash [sha256.Size]byte, now time.Time) *idempotentResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastPrune) >= idempotencyPruneInterval {
+		for k, entry := range c.entries {
+			if !entry.pending && now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	if entry, ok := c.entries[key]; ok && (entry.pending || now.Before(entry.expiresAt)) {
+		return entry
+	}
+	c.entries[key] = &idempotentResponse{bodyHash: bodyHash, pending: true}
+	return nil
+}
+
+// finish stores the response to a claimed key, or releases the key when
+// the response shouldn't be replayed
+func (c *idempotencyCache) finish(key string, response *idempotentResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if response == nil {
+		delete(c.entries, key)
+		return
+	}
+	response.expiresAt = time.Now().Add(c.ttl)
+	c.entries[key] = response
+}
+
+// capturingWriter copies the response body as it is written
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent creates middleware that lets clients safely retry a request
+// by sending the same Idempotency-Key header: the first response is stored
+// for ttl and replayed to retries with the key instead of running the
+// handler again, so a retried registration gets the original success
+// rather than a conflict. Keys are scoped to the organization and route.
+// Reusing a key with a different body gets 422, and a retry that arrives
+// while the first request is still running gets 409. Server errors and
+// rate limiting aren't stored, so those can be retried with the same key.
+// A zero ttl disables it.
+func idempotent(ttl time.Duration) gin.HandlerFunc {
+	if ttl <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	cache := newIdempotencyCache(ttl)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, auth.ErrorResponse{
+				Error:     "validation_error",
+				Message:   "Idempotency-Key is too long",
+				Code:      http.StatusBadRequest,
+				RequestID: c.GetString("request_id"),
+			})
+			return
+		}
+
+		// The body was already buffered by limitRequestBody
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		bodyHash := sha256.Sum256(body)
+
+		cacheKey := tenant.FromContext(c.Request.Context()) + "\x00" + c.FullPath() + "\x00" + key
+		if stored := cache.begin(cacheKey, bodyHash, time.Now()); stored != nil {
+			replayIdempotent(c, stored, bodyHash)
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			cache.finish(cacheKey, nil)
+			return
+		}
+
+		header := make(http.Header)
+		for _, name := range []string{"Content-Type", "Set-Cookie"} {
+			if values := writer.Header().Values(name); len(values) > 0 {
+				header[name] = append([]string(nil), values...)
+			}
+		}
+		cache.finish(cacheKey, &idempotentResponse{
+			bodyHash: bodyHash,
+			status:   status,
+			header:   header,
+			body:     writer.body.Bytes(),
+		})
+	}
+}
+
+// replayIdempotent answers a request whose key has been seen before
+func replayIdempotent(c *gin.Context, stored *idempotentResponse, bodyHash [sha256.Size]byte) {
+	switch {
+	case stored.bodyHash != bodyHash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, auth.ErrorResponse{
+			Error:     "idempotency_key_reused",
+			Message:   "Idempotency-Key was already used with a different request",
+			Code:      http.StatusUnprocessableEntity,
+			RequestID: c.GetString("request_id"),
+		})
+	case stored.pending:
+		c.AbortWithStatusJSON(http.StatusConflict, auth.ErrorResponse{
+			Error:     "idempotency_in_progress",
+			Message:   "A request with this Idempotency-Key is still being processed",
+			Code:      http.StatusConflict,
+			RequestID: c.GetString("request_id"),
+		})
+	default:
+		for name, values := range stored.header {
+			for _, value := range values {
+				c.Writer.Header().Add(name, value)
+			}
+		}
+		c.Header(idempotencyReplayedHeader, "true")
+		c.Status(stored.status)
+		c.Writer.Write(stored.body)
+		c.Abort()
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+func idempotentRegister(t *testing.T, handler http.Handler, key string, req auth.RegisterRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	return request(t, handler, http.MethodPost, "/api/auth/register", req, map[string]string{idempotencyKeyHeader: key})
+}
+
+func newRegisterRequest(emailAddress, username string) auth.RegisterRequest {
+	return auth.RegisterRequest{
+		Email: emailAddress, Username: username, Password: testPassword, FirstName: "Test", LastName: "User",
+	}
+}
+
+func TestIdempotentRegisterReplaysOriginalResponse(t *testing.T) {
+	handler := newTestServer(t)
+	req := newRegisterRequest("retry@example.com", "retry")
+
+	first := idempotentRegister(t, handler, "key-1", req)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first attempt: status %d, want 201: %s", first.Code, first.Body.String())
+	}
+	if first.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Error("first attempt was marked as replayed")
+	}
+
+	retry := idempotentRegister(t, handler, "key-1", req)
+	if retry.Code != http.StatusCreated || retry.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Fatalf("retry: status %d, %s %q; want the replayed 201", retry.Code, idempotencyReplayedHeader, retry.Header().Get(idempotencyReplayedHeader))
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("retry body differs from the original:\n%s\n%s", retry.Body.String(), first.Body.String())
+	}
+	if retry.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("retry Content-Type %q, want %q", retry.Header().Get("Content-Type"), first.Header().Get("Content-Type"))
+	}
+}
+
+func TestIdempotentRegisterKeyReusedWithDifferentBody(t *testing.T) {
+	handler := newTestServer(t)
+
+	if w := idempotentRegister(t, handler, "key-1", newRegisterRequest("first@example.com", "first")); w.Code != http.StatusCreated {
+		t.Fatalf("first attempt: status %d, want 201: %s", w.Code, w.Body.String())
+	}
+	w := idempotentRegister(t, handler, "key-1", newRegisterRequest("second@example.com", "second"))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: status %d, want 422: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestIdempotentRegisterDifferentKeyRunsNormally(t *testing.T) {
+	handler := newTestServer(t)
+	req := newRegisterRequest("twice@example.com", "twice")
+
+	if w := idempotentRegister(t, handler, "key-1", req); w.Code != http.StatusCreated {
+		t.Fatalf("first attempt: status %d, want 201: %s", w.Code, w.Body.String())
+	}
+	w := idempotentRegister(t, handler, "key-2", req)
+	if w.Code != http.StatusConflict || w.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("different key: status %d, replayed %q; want a fresh 409", w.Code, w.Header().Get(idempotencyReplayedHeader))
+	}
+	if w := request(t, handler, http.MethodPost, "/api/auth/register", req, nil); w.Code != http.StatusConflict {
+		t.Errorf("no key: status %d, want 409", w.Code)
+	}
+}
+
+func TestIdempotentRegisterExpiredKeyRunsNormally(t *testing.T) {
+	handler := newTestServerWith(t, storage.NewMemoryUserStore(), func(cfg *config.Config) {
+		cfg.Server.IdempotencyTTL = 20 * time.Millisecond
+	})
+	req := newRegisterRequest("expired@example.com", "expired")
+
+	if w := idempotentRegister(t, handler, "key-1", req); w.Code != http.StatusCreated {
+		t.Fatalf("first attempt: status %d, want 201: %s", w.Code, w.Body.String())
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	w := idempotentRegister(t, handler, "key-1", req)
+	if w.Code != http.StatusConflict || w.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("expired key: status %d, replayed %q; want a fresh 409", w.Code, w.Header().Get(idempotencyReplayedHeader))
+	}
+}
+
+func TestIdempotentRegisterRejectsLongKey(t *testing.T) {
+	handler := newTestServer(t)
+
+	key := strings.Repeat("k", maxIdempotencyKeyLength+1)
+	if w := idempotentRegister(t, handler, key, newRegisterRequest("long@example.com", "long")); w.Code != http.StatusBadRequest {
+		t.Errorf("long key: status %d, want 400", w.Code)
+	}
+}
+
+func TestIdempotentDoesNotStoreServerErrors(t *testing.T) {
+	calls := 0
+	router := gin.New()
+	router.POST("/flaky", idempotent(time.Hour), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	for attempt, want := range []int{http.StatusInternalServerError, http.StatusCreated, http.StatusCreated} {
+		w := request(t, router, http.MethodPost, "/flaky", nil, map[string]string{idempotencyKeyHeader: "key-1"})
+		if w.Code != want {
+			t.Errorf("attempt %d: status %d, want %d", attempt+1, w.Code, want)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
+
+func TestIdempotencyCachePendingAndPruning(t *testing.T) {
+	cache := newIdempotencyCache(time.Minute)
+	hash := sha256.Sum256([]byte("body"))
+	now := time.Now()
+
+	if cache.begin("key-1", hash, now) != nil {
+		t.Fatal("new key returned an entry")
+	}
+	if stored := cache.begin("key-1", hash, now); stored == nil || !stored.pending {
+		t.Fatalf("key in flight = %+v, want the pending entry", stored)
+	}
+
+	cache.finish("key-1", &idempotentResponse{bodyHash: hash, status: http.StatusCreated})
+	cache.begin("key-2", hash, now)
+
+	// Once expired, finished responses are pruned but requests in flight aren't
+	cache.begin("key-3", hash, now.Add(time.Minute+idempotencyPruneInterval))
+	if _, ok := cache.entries["key-1"]; ok {
+		t.Error("expired response wasn't pruned")
+	}
+	if _, ok := cache.entries["key-2"]; !ok {
+		t.Error("pending entry was pruned")
+	}
+}
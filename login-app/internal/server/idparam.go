@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/ids"
+)
+
+// validIDParam creates middleware that answers 404 for routes whose :id
+// parameter can't be an ID this service issued, so malformed IDs never
+// reach the stores
+func validIDParam() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := c.Param("id"); id != "" && !ids.Valid(id) {
+			c.AbortWithStatusJSON(http.StatusNotFound, auth.ErrorResponse{
+				Error:     "not_found",
+				Message:   "No resource has this ID",
+				Code:      http.StatusNotFound,
+				RequestID: c.GetString("request_id"),
+			})
+			return
+		}
+		c.Next()
+	}
+}
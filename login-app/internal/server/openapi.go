@@ -0,0 +1,300 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/openapi"
+)
+
+// apiRoutes documents every API route. Request and response schemas are
+// derived from the auth types, so only the route list needs maintaining;
+// undocumented routes are reported at startup.
+var apiRoutes = []openapi.Route{
+	{Method: http.MethodPost, Path: "/api/auth/register", Tag: "Authentication", Summary: "Register a new user",
+		Request: auth.RegisterRequest{}, Response: auth.LoginResponse{}, Status: http.StatusCreated,
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+	{Method: http.MethodPost, Path: "/api/auth/login", Tag: "Authentication", Summary: "Log in",
+		Description: "Returns tokens, or a two-factor challenge when the account has 2FA enabled",
+		Request:     auth.LoginRequest{}, Response: auth.LoginResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests}},
+	{Method: http.MethodPost, Path: "/api/auth/login/2fa", Tag: "Authentication", Summary: "Complete a two-factor login",
+		Request: auth.TwoFactorLoginRequest{}, Response: auth.LoginResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests}},
+	{Method: http.MethodPost, Path: "/api/auth/refresh", Tag: "Authentication", Summary: "Exchange a refresh token for new tokens",
+		Request: auth.RefreshRequest{}, Response: auth.LoginResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	{Method: http.MethodPost, Path: "/api/auth/logout", Tag: "Authentication", Summary: "Log out",
+		Description: "Revokes the bearer token, if any, and the refresh token in the optional body",
+		Request:     auth.LogoutRequest{}},
+	{Method: http.MethodPost, Path: "/api/auth/forgot-password", Tag: "Authentication", Summary: "Request a password reset",
+		Request: auth.ForgotPasswordRequest{}, Errors: []int{http.StatusBadRequest, http.StatusTooManyRequests}},
+	{Method: http.MethodPost, Path: "/api/auth/reset-password", Tag: "Authentication", Summary: "Reset a password with a reset token",
+		Request: auth.ResetPasswordRequest{}, Response: auth.PasswordChangeResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+	{Method: http.MethodPost, Path: "/api/auth/recovery/questions", Tag: "Authentication", Summary: "Get an account's security questions",
+		Request: auth.RecoveryQuestionsRequest{}, Response: auth.RecoveryQuestionsResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests}},
+	{Method: http.MethodPost, Path: "/api/auth/recovery/answers", Tag: "Authentication", Summary: "Answer security questions for a reset token",
+		Description: "Returns a password reset token for /api/auth/reset-password when every answer matches",
+		Request:     auth.RecoveryAnswersRequest{}, Response: auth.RecoveryAnswersResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusTooManyRequests}},
+	{Method: http.MethodPost, Path: "/api/auth/magic-link", Tag: "Authentication", Summary: "Request a passwordless login link",
+		Request: auth.MagicLinkRequest{}, Errors: []int{http.StatusBadRequest, http.StatusTooManyRequests}},
+	{Method: http.MethodGet, Path: "/api/auth/magic-link/consume", Tag: "Authentication", Summary: "Log in with a magic link",
+		Query:    []openapi.Parameter{{Name: "token", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Response: auth.LoginResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests}},
+	{Method: http.MethodGet, Path: "/api/auth/unlock", Tag: "Authentication", Summary: "Unlock a locked account",
+		Description: "Lifts a lockout with the token from the unlock email sent when the account was locked",
+		Query:       []openapi.Parameter{{Name: "token", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusTooManyRequests}},
+	{Method: http.MethodPost, Path: "/api/auth/passkeys/login/begin", Tag: "Authentication", Summary: "Start a passkey login",
+		Description: "Returns options for navigator.credentials.get and a session ID to send back with the result",
+		Response:    auth.PasskeyOptionsResponse{}, Errors: []int{http.StatusTooManyRequests}},
+	{Method: http.MethodPost, Path: "/api/auth/passkeys/login/finish", Tag: "Authentication", Summary: "Log in with a passkey",
+		Request: auth.PasskeyLoginRequest{}, Response: auth.LoginResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests}},
+	{Method: http.MethodGet, Path: "/api/auth/oauth/:provider", Tag: "Authentication", Summary: "Start an external provider login",
+		Description: "Redirects to the provider's consent page and sets a short-lived state cookie",
+		Raw:         true, Status: http.StatusFound, Errors: []int{http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/api/auth/oauth/:provider/callback", Tag: "Authentication", Summary: "Complete an external provider login",
+		Description: "Returns tokens, a two-factor challenge, or 202 when the account link must be confirmed by email",
+		Query: []openapi.Parameter{
+			{Name: "code", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			{Name: "state", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+		},
+		Response: auth.LoginResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusBadGateway}},
+	{Method: http.MethodGet, Path: "/api/auth/oauth/link/confirm", Tag: "Authentication", Summary: "Confirm linking an external provider",
+		Query:  []openapi.Parameter{{Name: "token", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Errors: []int{http.StatusBadRequest}},
+
+	{Method: http.MethodGet, Path: "/api/auth/profile", Tag: "Account", Summary: "Get the current user's profile", Auth: true,
+		Response: auth.UserInfo{}, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/api/auth/whoami", Tag: "Account", Summary: "Identify the holder of an access token", Auth: true,
+		Description: "Answers from the token's claims, so it reflects the token as issued rather than the current profile. API keys get the owning user without expires_at.",
+		Response:    auth.WhoAmIResponse{}},
+	{Method: http.MethodGet, Path: "/api/auth/validate", Tag: "Account", Summary: "Check an access token and how long it has left", Auth: true,
+		Description: "Has no side effects. A rejected token gets a 401 whose details give the reason: expired, invalid or revoked. Only an expired token is worth refreshing.",
+		Response:    auth.TokenValidationResponse{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodPut, Path: "/api/auth/profile", Tag: "Account", Summary: "Update the current user's name and username", Auth: true,
+		Request: auth.UpdateProfileRequest{}, Response: auth.UserInfo{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict}},
+	{Method: http.MethodPost, Path: "/api/auth/change-password", Tag: "Account", Summary: "Change password", Auth: true,
+		Description: "With revoke_sessions, or REVOKE_SESSIONS_ON_PASSWORD_CHANGE configured, every other session is logged out",
+		Request:     auth.ChangePasswordRequest{}, Response: auth.PasswordChangeResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusServiceUnavailable}},
+	{Method: http.MethodPut, Path: "/api/auth/security-questions", Tag: "Account", Summary: "Set security questions for account recovery", Auth: true,
+		Request: auth.SecurityQuestionsRequest{},
+		Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/api/auth/change-email", Tag: "Account", Summary: "Request an email address change", Auth: true,
+		Description: "Sends a confirmation link to the new address; the change applies once it is followed",
+		Request:     auth.ChangeEmailRequest{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict, http.StatusTooManyRequests}},
+	{Method: http.MethodGet, Path: "/api/auth/change-email/confirm", Tag: "Account", Summary: "Confirm an email address change",
+		Query:  []openapi.Parameter{{Name: "token", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{Method: http.MethodGet, Path: "/api/auth/verify-email", Tag: "Account", Summary: "Verify an email address",
+		Query:  []openapi.Parameter{{Name: "token", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodPost, Path: "/api/auth/verify-email/resend", Tag: "Account", Summary: "Send a new email verification link",
+		Description: "The response is the same whether or not the email belongs to an unverified account",
+		Request:     auth.EmailVerificationRequest{}, Errors: []int{http.StatusBadRequest, http.StatusTooManyRequests}},
+	{Method: http.MethodPost, Path: "/api/auth/deactivate", Tag: "Account", Summary: "Deactivate the current account", Auth: true,
+		Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/api/auth/account", Tag: "Account", Summary: "Permanently delete the current account", Auth: true,
+		Request: auth.DeleteAccountRequest{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/api/auth/export", Tag: "Account", Summary: "Export everything stored about the current account", Auth: true,
+		Response: auth.AccountExport{}, Raw: true, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/api/auth/sessions", Tag: "Account", Summary: "List active sessions", Auth: true,
+		Response: []auth.SessionInfo{}, Errors: []int{http.StatusForbidden}},
+	{Method: http.MethodDelete, Path: "/api/auth/sessions/:id", Tag: "Account", Summary: "Revoke a session", Auth: true,
+		Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/api/auth/connections", Tag: "Account", Summary: "List linked external providers", Auth: true,
+		Response: auth.ConnectionsResponse{}, Errors: []int{http.StatusForbidden}},
+	{Method: http.MethodDelete, Path: "/api/auth/connections/:provider", Tag: "Account", Summary: "Unlink an external provider", Auth: true,
+		Description: "Refused with 409 when the provider is the account's only way to log in",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+	{Method: http.MethodPost, Path: "/api/auth/2fa/enable", Tag: "Account", Summary: "Start enabling two-factor authentication", Auth: true,
+		Response: auth.EnableTOTPResponse{}, Errors: []int{http.StatusForbidden, http.StatusConflict}},
+	{Method: http.MethodPost, Path: "/api/auth/2fa/confirm", Tag: "Account", Summary: "Confirm a TOTP code to switch on two-factor authentication", Auth: true,
+		Request: auth.TOTPCodeRequest{}, Response: auth.ConfirmTOTPResponse{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodPost, Path: "/api/auth/passkeys/register/begin", Tag: "Account", Summary: "Start registering a passkey", Auth: true,
+		Description: "Returns options for navigator.credentials.create and a session ID to send back with the result",
+		Response:    auth.PasskeyOptionsResponse{}, Errors: []int{http.StatusForbidden}},
+	{Method: http.MethodPost, Path: "/api/auth/passkeys/register/finish", Tag: "Account", Summary: "Finish registering a passkey", Auth: true,
+		Request: auth.PasskeyRegistrationRequest{}, Response: auth.PasskeyInfo{}, Status: http.StatusCreated,
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+
+	{Method: http.MethodPost, Path: "/api/auth/api-keys", Tag: "API Keys", Summary: "Create an API key", Auth: true,
+		Request: auth.CreateAPIKeyRequest{}, Response: auth.CreateAPIKeyResponse{}, Status: http.StatusCreated,
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/api/auth/api-keys", Tag: "API Keys", Summary: "List API keys", Auth: true,
+		Response: []auth.APIKeyInfo{}, Errors: []int{http.StatusForbidden}},
+	{Method: http.MethodDelete, Path: "/api/auth/api-keys/:id", Tag: "API Keys", Summary: "Revoke an API key", Auth: true,
+		Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/api/auth/api-keys/:id/rotate", Tag: "API Keys", Summary: "Rotate an API key", Auth: true,
+		Description: "Creates a key with the same name and scopes and revokes the old one; the new key is shown only once",
+		Response:    auth.CreateAPIKeyResponse{}, Status: http.StatusCreated, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+
+	{Method: http.MethodGet, Path: "/api/admin/stats", Tag: "Administration", Summary: "Get aggregate user and session counts", Auth: true,
+		Description: "Soft-deleted users aren't counted; active_sessions is left out when tenancy is enabled",
+		Response:    auth.AdminStatsResponse{}, Errors: []int{http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/api/admin/users", Tag: "Administration", Summary: "List users", Auth: true,
+		Query: []openapi.Parameter{
+			{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "offset", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "q", In: "query", Description: "Matches email or username", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "sort", In: "query", Description: "created_at, email or username", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "order", In: "query", Description: "asc or desc", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "include_deleted", In: "query", Description: "Also list soft-deleted users", Schema: &openapi.Schema{Type: "boolean"}},
+		},
+		Response: auth.UserListResponse{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/api/admin/users/search", Tag: "Administration", Summary: "Search users by partial email or username", Auth: true,
+		Description: "Exact matches come first, then prefix matches, then other matches",
+		Query: []openapi.Parameter{
+			{Name: "q", In: "query", Required: true, Description: "Case-insensitive part of an email or username", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Response: auth.UserSearchResponse{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodPost, Path: "/api/admin/users/import", Tag: "Administration", Summary: "Import users from CSV", Auth: true,
+		Request: &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"file":               {Type: "string", Format: "binary", Description: "CSV with email, username, first_name, last_name and password columns"},
+				"generate_passwords": {Type: "boolean", Description: "Generate passwords for rows without one"},
+			},
+			Required: []string{"file"},
+		},
+		RequestContentType: "multipart/form-data",
+		Response:           auth.ImportReport{},
+		Errors:             []int{http.StatusBadRequest, http.StatusForbidden, http.StatusRequestEntityTooLarge}},
+	{Method: http.MethodPost, Path: "/api/admin/users/:id/reactivate", Tag: "Administration", Summary: "Reactivate a user", Auth: true,
+		Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/api/admin/users/:id/revoke-sessions", Tag: "Administration", Summary: "Log a user out on every device", Auth: true,
+		Description: "Revokes the user's access tokens, sessions and refresh tokens",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/api/admin/users/:id/reset-password", Tag: "Administration", Summary: "Set a user's password", Auth: true,
+		Description: "Sets the given password, or generates one that is returned once, unlocks the account and logs the user out everywhere",
+		Request:     auth.AdminResetPasswordRequest{}, Response: auth.AdminResetPasswordResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusServiceUnavailable}},
+	{Method: http.MethodGet, Path: "/api/admin/users/:id/metadata", Tag: "Administration", Summary: "Get a user's metadata", Auth: true,
+		Description: "token_metadata is the part under the configured token keys, copied into the user's access tokens",
+		Response:    auth.UserMetadataResponse{}, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodPut, Path: "/api/admin/users/:id/metadata", Tag: "Administration", Summary: "Replace a user's metadata", Auth: true,
+		Description: "Tokens already issued keep their metadata; the change reaches the user's tokens at their next login or refresh",
+		Request:     auth.UserMetadataRequest{}, Response: auth.UserMetadataResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/api/admin/users/:id", Tag: "Administration", Summary: "Soft-delete a user", Auth: true,
+		Description: "Purges the user's credentials and hides the account, keeping the record so it can be restored",
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/api/admin/users/:id/restore", Tag: "Administration", Summary: "Restore a soft-deleted user", Auth: true,
+		Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+	{Method: http.MethodGet, Path: "/api/admin/audit", Tag: "Administration", Summary: "Query the authentication audit log", Auth: true,
+		Query: []openapi.Parameter{
+			{Name: "user_id", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "type", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "since", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "offset", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Response: auth.AuditLogResponse{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/api/admin/audit/export", Tag: "Administration", Summary: "Download the authentication audit log", Auth: true,
+		Description: "Streams every matching entry, newest first, as CSV with a header row or as JSON Lines with one entry per line. `until` is exclusive",
+		Query: []openapi.Parameter{
+			{Name: "format", In: "query", Description: "jsonl (default) or csv", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "user_id", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "type", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "since", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "until", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		},
+		Response: &openapi.Schema{Type: "string"}, Raw: true, ResponseContentTypes: []string{"application/x-ndjson", "text/csv"},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodPost, Path: "/api/admin/invites", Tag: "Administration", Summary: "Invite an email address to register", Auth: true,
+		Description: "The invite code is only returned in this response and emailed to the invitee",
+		Request:     auth.CreateInviteRequest{}, Response: auth.CreateInviteResponse{}, Status: http.StatusCreated,
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/api/admin/invites", Tag: "Administration", Summary: "List invites", Auth: true,
+		Response: []auth.InviteInfo{}, Errors: []int{http.StatusForbidden}},
+	{Method: http.MethodDelete, Path: "/api/admin/invites/:id", Tag: "Administration", Summary: "Revoke an invite", Auth: true,
+		Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+
+	{Method: http.MethodGet, Path: "/.well-known/jwks.json", Tag: "Token Verification", Summary: "Public keys for verifying tokens",
+		Response: auth.JWKS{}, Raw: true},
+	{Method: http.MethodPost, Path: "/api/auth/token/introspect", Tag: "Token Verification", Summary: "Introspect an access token", Auth: true,
+		Description: "For backend services; requires an API key with the tokens:introspect scope. Invalid or expired tokens are reported with active set to false.",
+		Request:     auth.IntrospectRequest{}, Response: auth.IntrospectResponse{}, Raw: true, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+}
+
+// buildOpenAPI generates the API document and warns about API routes
+// registered on the router that apiRoutes doesn't describe
+func (s *Server) buildOpenAPI() *openapi.Document {
+	gen := openapi.NewGenerator(openapi.Info{
+		Title:       "Login App API",
+		Description: "User registration, authentication and account management.",
+		Version:     "1.0.0",
+	}, auth.SuccessResponse{}, "data", auth.ErrorResponse{})
+
+	gen.AddServer("/", "This server")
+	gen.AddSecurityScheme("BearerAuth", openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "Access token from login, register or refresh",
+	})
+	gen.AddSecurityScheme("ApiKeyAuth", openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "Authorization",
+		Description: `API key sent as "ApiKey <key>"`,
+	})
+	gen.AddSecurityScheme("ApiKeyHeader", openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        auth.APIKeyHeader,
+		Description: "API key sent on its own header",
+	})
+	if cookie := s.config.Auth.TokenCookie; cookie.Enabled {
+		gen.AddSecurityScheme("CookieAuth", openapi.SecurityScheme{
+			Type:        "apiKey",
+			In:          "cookie",
+			Name:        cookie.Name,
+			Description: "Access token cookie set by login, register or refresh",
+		})
+	}
+	gen.AddTag("Authentication", "Registration, login and token lifecycle")
+	gen.AddTag("Account", "Operations on the authenticated user's account")
+	gen.AddTag("API Keys", "Long-lived credentials for scripts and services")
+	gen.AddTag("Administration", "Admin-only user management")
+	gen.AddTag("Token Verification", "Keys and introspection for verifying tokens in other services")
+
+	documented := make(map[string]bool, len(apiRoutes))
+	for _, route := range apiRoutes {
+		gen.Add(route)
+		documented[route.Method+" "+route.Path] = true
+	}
+
+	for _, route := range s.router.Routes() {
+		if strings.HasPrefix(route.Path, "/api/") && !documented[route.Method+" "+route.Path] {
+			slog.Warn("API route missing from OpenAPI document", "method", route.Method, "path", route.Path)
+		}
+	}
+
+	return gen.Document()
+}
+
+// handleOpenAPI serves the OpenAPI document
+func (s *Server) handleOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, s.openAPI)
+}
+
+// handleDocs serves Swagger UI for the OpenAPI document
+func (s *Server) handleDocs(c *gin.Context) {
+	c.HTML(http.StatusOK, "docs.html", gin.H{
+		"title": "API Documentation",
+	})
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+var updateOpenAPI = flag.Bool("update", false, "rewrite api/openapi.yaml from the generated document")
+
+// openAPIFile is the generated document checked in for readers and client
+// generators that don't run the server
+const openAPIFile = "../../api/openapi.yaml"
+
+const openAPIFileHeader = `# OpenAPI document for the login-app API, generated from the route list in
+# internal/server/openapi.go. Don't edit it by hand; after changing routes run
+#
+#   go test ./internal/server -run TestOpenAPIFile -update
+#
+# The running server serves the same document at /openapi.json.
+
+`
+
+// newOpenAPITestServer returns a server with every optional auth scheme
+// enabled, so the document describes them all
+func newOpenAPITestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg, err := config.Load("test")
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	cfg.Auth.TokenCookie.Enabled = true
+
+	srv, err := New(cfg, storage.NewMemoryUserStore(), storage.SessionStores{}, nil, email.LogSender{}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return srv
+}
+
+func TestOpenAPIFileIsCurrent(t *testing.T) {
+	generated, err := newOpenAPITestServer(t).openAPI.YAML()
+	if err != nil {
+		t.Fatalf("render YAML: %v", err)
+	}
+	want := append([]byte(openAPIFileHeader), generated...)
+
+	if *updateOpenAPI {
+		if err := os.WriteFile(openAPIFile, want, 0o644); err != nil {
+			t.Fatalf("write %s: %v", openAPIFile, err)
+		}
+		return
+	}
+
+	got, err := os.ReadFile(openAPIFile)
+	if err != nil {
+		t.Fatalf("read %s: %v", openAPIFile, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("api/openapi.yaml is out of date, regenerate it with: go test ./internal/server -run TestOpenAPIFile -update")
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(got, &doc); err != nil {
+		t.Fatalf("parse %s: %v", openAPIFile, err)
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("openapi = %v, want 3.0.3", doc["openapi"])
+	}
+}
+
+func TestOpenAPIDocumentIsValid(t *testing.T) {
+	w := request(t, newTestServer(t), http.MethodGet, "/openapi.json", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || len(doc.Paths) == 0 {
+		t.Fatalf("openapi %q with %d paths, want 3.0.3 with paths", doc.OpenAPI, len(doc.Paths))
+	}
+
+	operationIDs := make(map[string]string)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			name := strings.ToUpper(method) + " " + path
+			if responses, _ := op["responses"].(map[string]interface{}); len(responses) == 0 {
+				t.Errorf("%s has no responses", name)
+			}
+			id, _ := op["operationId"].(string)
+			if other, taken := operationIDs[id]; id == "" || taken {
+				t.Errorf("%s has operationId %q, also used by %s", name, id, other)
+			}
+			operationIDs[id] = name
+		}
+	}
+
+	// Every schema reference must resolve to a component
+	for _, ref := range schemaRefs(w.Body.Bytes()) {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if _, ok := doc.Components.Schemas[name]; !ok || name == ref {
+			t.Errorf("unresolved reference %q", ref)
+		}
+	}
+}
+
+func TestEveryAPIRouteIsDocumented(t *testing.T) {
+	srv := newOpenAPITestServer(t)
+
+	documented := make(map[string]bool, len(apiRoutes))
+	for _, route := range apiRoutes {
+		documented[route.Method+" "+route.Path] = true
+	}
+	for _, route := range srv.router.Routes() {
+		if strings.HasPrefix(route.Path, "/api/") && !documented[route.Method+" "+route.Path] {
+			t.Errorf("%s %s is missing from apiRoutes", route.Method, route.Path)
+		}
+	}
+}
+
+// schemaRefs returns every $ref in a JSON document
+func schemaRefs(data []byte) []string {
+	var refs []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				if ref, ok := value.(string); ok && key == "$ref" {
+					refs = append(refs, ref)
+				}
+				walk(value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+
+	var doc interface{}
+	json.Unmarshal(data, &doc)
+	walk(doc)
+	return refs
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+)
+
+// recovery turns a panic in a later handler into a 500 ErrorResponse. The
+// panic value and stack are logged with the request ID but never sent to
+// the client.
+func recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			// net/http uses this panic to abort a response silently
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+
+			requestID := c.GetString("request_id")
+			slog.Error("Recovered from panic",
+				"request_id", requestID,
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"panic", recovered,
+				"stack", string(debug.Stack()),
+			)
+
+			// Part of a response may already be on the wire
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			c.AbortWithStatusJSON(http.StatusInternalServerError, auth.ErrorResponse{
+				Error:     "internal_error",
+				Message:   "An unexpected error occurred",
+				Code:      http.StatusInternalServerError,
+				RequestID: requestID,
+			})
+		}()
+
+		c.Next()
+	}
+}
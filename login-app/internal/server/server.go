@@ -1,7 +1,15 @@
 package server
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
 	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	// Gin HTTP web framework for REST API and web page serving
 	// Provides routing, middleware, input validation, and security features
@@ -9,18 +17,41 @@ import (
 
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/lifecycle"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/openapi"
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
 )
 
+// Version is the build version reported by the health check. main sets it
+// from the version stamped in at compile time.
+var Version = "dev"
+
+// healthCheckTimeout bounds how long the health check waits on the store
+const healthCheckTimeout = 2 * time.Second
+
 // Server represents the HTTP server
 type Server struct {
 	router      *gin.Engine
 	authService *auth.Service
+	userStore   storage.UserStore
+	events      events.Publisher
 	config      *config.Config
+	openAPI     *openapi.Document
+	startedAt   time.Time
+
+	// pages is set when templates were loaded and the web routes are served
+	pages bool
+
+	// draining is set once shutdown begins so /readyz stops admitting traffic
+	draining atomic.Bool
+	inFlight atomic.Int64
 }
 
-// New creates a new server instance
-func New(cfg *config.Config, userStore storage.UserStore) (*Server, error) {
+// New creates a new server instance whose background workers run on workers
+func New(cfg *config.Config, userStore storage.UserStore, sessionStores storage.SessionStores, publisher events.Publisher, mailer email.Sender, workers *lifecycle.Manager) (*Server, error) {
 	// Set Gin mode based on environment
 	if cfg.Log.Level == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -30,53 +61,93 @@ func New(cfg *config.Config, userStore storage.UserStore) (*Server, error) {
 
 	router := gin.New()
 
+	// Gin trusts every proxy by default, which lets any client spoof its IP
+	// with X-Forwarded-For. Only configured proxies are trusted; an empty
+	// list makes Gin ignore forwarding headers entirely.
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, err
+	}
+
 	// Create auth service
-	authService := auth.NewService(userStore, cfg)
+	if publisher == nil {
+		publisher = events.NopPublisher{}
+	}
+	authService, err := auth.NewService(userStore, sessionStores, cfg, publisher, mailer, workers)
+	if err != nil {
+		return nil, err
+	}
 
 	server := &Server{
 		router:      router,
 		authService: authService,
+		userStore:   userStore,
+		events:      publisher,
 		config:      cfg,
+		startedAt:   time.Now(),
 	}
 
 	// Setup middleware
 	server.setupMiddleware()
 
+	// Page templates and static files
+	if err := server.setupAssets(); err != nil {
+		return nil, err
+	}
+
 	// Setup routes
 	server.setupRoutes()
+	server.openAPI = server.buildOpenAPI()
 
 	return server, nil
 }
 
+// Drain marks the server as not ready so load balancers stop routing new
+// traffic to it. Liveness (/health) is unaffected.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// InFlight returns the number of requests currently being handled
+func (s *Server) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
 // Handler returns the HTTP handler
 func (s *Server) Handler() http.Handler {
 	return s.router
 }
 
-// setupMiddleware configures global middleware
+// setupMiddleware configures the global middleware, which runs for every
+// request including unmatched ones. Middleware only some routes need
+// belongs in groupMiddleware instead.
 func (s *Server) setupMiddleware() {
-	// Recovery middleware
-	s.router.Use(gin.Recovery())
-
-	// Logger middleware (conditional)
-	if s.config.Log.Level == "debug" {
-		s.router.Use(gin.Logger())
-	}
+	// Panics become a logged 500 ErrorResponse; the request ID is set
+	// further down the chain but is read once the panic unwinds to here
+	s.router.Use(recovery())
 
-	// CORS middleware (basic implementation)
+	// In-flight request tracking for graceful shutdown
 	s.router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
 		c.Next()
 	})
 
+	// Correlation ID for logs and error responses
+	s.router.Use(requestID())
+
+	// Request spans; a no-op unless tracing is enabled
+	s.router.Use(traceRequests())
+
+	// Structured request logging
+	s.router.Use(s.requestLogger())
+
+	// CORS middleware; browsers need permission to send the organization header
+	corsConfig := s.config.Server.CORS
+	if tenancy := s.config.Tenancy; tenancy.Enabled && tenancy.Header != "" {
+		corsConfig.AllowedHeaders = append(append([]string(nil), corsConfig.AllowedHeaders...), tenancy.Header)
+	}
+	s.router.Use(cors(corsConfig))
+
 	// Security headers
 	s.router.Use(func(c *gin.Context) {
 		c.Header("X-Content-Type-Options", "nosniff")
@@ -84,41 +155,323 @@ func (s *Server) setupMiddleware() {
 		c.Header("X-XSS-Protection", "1; mode=block")
 		c.Next()
 	})
+
+	// Report every forbidden response to the security event stream
+	s.router.Use(func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() == http.StatusForbidden {
+			s.events.Publish(events.Event{
+				Type:      events.TypeAccessDenied,
+				Source:    "server",
+				Outcome:   events.OutcomeFailure,
+				UserID:    c.GetString("user_id"),
+				IP:        clientIP(c),
+				UserAgent: c.Request.UserAgent(),
+				Details: map[string]string{
+					"method": c.Request.Method,
+					"path":   c.FullPath(),
+				},
+			})
+		}
+	})
+
+	// Client IP allow and deny lists, after the reporter so rejections are recorded
+	s.router.Use(ipFilter(s.config.Server.IPFilter))
+
+	// Body size and JSON nesting limits, checked before any handler reads the body
+	s.router.Use(limitRequestBody(s.config.Server.RequestLimits))
+}
+
+// routeGroup identifies a set of routes that share a middleware chain
+type routeGroup int
+
+const (
+	// groupPublic holds the probes, the JWKS and the API docs, which
+	// belong to no organization
+	groupPublic routeGroup = iota
+
+	// groupAPI holds every /api route; groupAuth and groupAdmin are nested
+	// in it and run its chain first
+	groupAPI
+	groupAuth
+	groupAdmin
+
+	// groupWeb holds the HTML pages
+	groupWeb
+)
+
+// groupMiddleware returns the middleware a group of routes runs after the
+// global chain. Per-route middleware, such as rate limits on individual
+// auth endpoints, is added where the route is registered.
+func (s *Server) groupMiddleware(group routeGroup) []gin.HandlerFunc {
+	var chain []gin.HandlerFunc
+
+	switch group {
+	case groupAPI:
+		chain = append(chain, s.tenantMiddleware()...)
+		chain = append(chain, validIDParam())
+		if s.config.Server.RequireJSON {
+			chain = append(chain, requireJSON())
+		}
+	case groupAdmin:
+		chain = append(chain, ipFilter(s.config.Server.AdminIPFilter), s.authMiddleware(), s.requireAdmin())
+	case groupWeb:
+		chain = append(chain, s.tenantMiddleware()...)
+		// CSRF protection for the pages' forms
+		chain = append(chain, csrf())
+	}
+
+	return chain
+}
+
+// tenantMiddleware resolves the organization a request belongs to, for
+// multi-tenant deployments
+func (s *Server) tenantMiddleware() []gin.HandlerFunc {
+	if !s.config.Tenancy.Enabled {
+		return nil
+	}
+	return []gin.HandlerFunc{resolveTenant(s.config.Tenancy)}
 }
 
 // setupRoutes configures all routes
 func (s *Server) setupRoutes() {
-	// Load HTML templates
-	s.router.LoadHTMLGlob("web/templates/*")
+	public := s.router.Group("", s.groupMiddleware(groupPublic)...)
+	{
+		// Health check (liveness) and readiness
+		public.GET("/health", s.healthCheck)
+		public.GET("/readyz", s.readinessCheck)
 
-	// Health check
-	s.router.GET("/health", s.healthCheck)
+		// Public keys for verifying tokens issued by this service
+		public.GET("/.well-known/jwks.json", s.handleJWKS)
 
-	// Static files
-	s.router.Static("/static", "./web/static")
+		// API documentation; the interactive docs are a web page
+		public.GET("/openapi.json", s.handleOpenAPI)
+		if s.pages {
+			public.GET("/docs", s.handleDocs)
+		}
+	}
 
 	// API routes
-	api := s.router.Group("/api")
+	api := s.router.Group("/api", s.groupMiddleware(groupAPI)...)
 	{
+		// Per-IP limits for endpoints that can be abused to guess
+		// credentials, create accounts in bulk or send spam email
+		loginLimit := rateLimit(s.config.Server.RateLimits.Login)
+		registerLimit := rateLimit(s.config.Server.RateLimits.Register)
+		emailLimit := rateLimit(s.config.Server.RateLimits.Email)
+
 		// Auth routes
-		authGroup := api.Group("/auth")
+		authGroup := api.Group("/auth", s.groupMiddleware(groupAuth)...)
 		{
-			authGroup.POST("/register", s.handleRegister)
-			authGroup.POST("/login", s.handleLogin)
+			authGroup.POST("/register", idempotent(s.config.Server.IdempotencyTTL), registerLimit, s.handleRegister)
+			authGroup.POST("/login", loginLimit, s.handleLogin)
+			authGroup.POST("/login/2fa", loginLimit, s.handleLoginTwoFactor)
+			authGroup.POST("/refresh", s.handleRefresh)
 			authGroup.POST("/logout", s.handleLogout)
-			authGroup.GET("/profile", s.authMiddleware(), s.handleProfile)
+			authGroup.POST("/forgot-password", emailLimit, s.handleForgotPassword)
+			authGroup.POST("/reset-password", loginLimit, s.handleResetPassword)
+			authGroup.POST("/recovery/questions", loginLimit, s.handleRecoveryQuestions)
+			authGroup.POST("/recovery/answers", loginLimit, s.handleRecoveryAnswers)
+			authGroup.POST("/magic-link", emailLimit, s.handleRequestMagicLink)
+			authGroup.GET("/magic-link/consume", loginLimit, s.handleConsumeMagicLink)
+			authGroup.GET("/unlock", loginLimit, s.handleUnlockAccount)
+			authGroup.GET("/profile", s.authMiddleware(), s.requireScope(auth.ScopeProfileRead), s.handleProfile)
+			authGroup.GET("/whoami", s.authMiddleware(), s.handleWhoAmI)
+			authGroup.GET("/validate", s.authMiddleware(), s.handleValidateToken)
+			authGroup.PUT("/profile", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleUpdateProfile)
+			authGroup.POST("/change-password", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleChangePassword)
+			authGroup.PUT("/security-questions", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleSetSecurityQuestions)
+			authGroup.POST("/change-email", emailLimit, s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleRequestEmailChange)
+			authGroup.GET("/change-email/confirm", s.handleConfirmEmailChange)
+			authGroup.GET("/verify-email", s.handleVerifyEmail)
+			authGroup.POST("/verify-email/resend", emailLimit, s.handleResendEmailVerification)
+			authGroup.POST("/deactivate", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleDeactivate)
+			authGroup.DELETE("/account", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleDeleteAccount)
+			authGroup.GET("/export", s.authMiddleware(), s.requireScope(auth.ScopeProfileRead), s.handleExportAccount)
+			authGroup.GET("/sessions", s.authMiddleware(), s.requireScope(auth.ScopeProfileRead), s.handleListSessions)
+			authGroup.DELETE("/sessions/:id", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleRevokeSession)
+			authGroup.GET("/connections", s.authMiddleware(), s.requireScope(auth.ScopeProfileRead), s.handleListConnections)
+			authGroup.DELETE("/connections/:provider", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleUnlinkProvider)
+			authGroup.GET("/oauth/link/confirm", s.handleConfirmOAuthLink)
+			authGroup.GET("/oauth/:provider", s.handleOAuthLogin)
+			authGroup.GET("/oauth/:provider/callback", s.handleOAuthCallback)
+			authGroup.POST("/2fa/enable", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleEnableTOTP)
+			authGroup.POST("/2fa/confirm", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleConfirmTOTP)
+			authGroup.POST("/passkeys/register/begin", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleBeginPasskeyRegistration)
+			authGroup.POST("/passkeys/register/finish", s.authMiddleware(), s.requireScope(auth.ScopeAccountWrite), s.handleFinishPasskeyRegistration)
+			authGroup.POST("/passkeys/login/begin", loginLimit, s.handleBeginPasskeyLogin)
+			authGroup.POST("/passkeys/login/finish", loginLimit, s.handleFinishPasskeyLogin)
+			authGroup.POST("/token/introspect", s.authMiddleware(), s.requireAPIKey(), s.requireScope(auth.ScopeTokensIntrospect), s.handleIntrospectToken)
+
+			// API key management
+			apiKeys := authGroup.Group("/api-keys", s.authMiddleware(), s.requireScope(auth.ScopeAPIKeysManage))
+			{
+				apiKeys.POST("", s.handleCreateAPIKey)
+				apiKeys.GET("", s.handleListAPIKeys)
+				apiKeys.DELETE("/:id", s.handleRevokeAPIKey)
+				apiKeys.POST("/:id/rotate", s.handleRotateAPIKey)
+			}
+		}
+
+		// Admin routes
+		admin := api.Group("/admin", s.groupMiddleware(groupAdmin)...)
+		{
+			admin.GET("/stats", s.requireScope(auth.ScopeUsersRead), s.handleAdminStats)
+			admin.GET("/users", s.requireScope(auth.ScopeUsersRead), s.handleAdminListUsers)
+			admin.GET("/users/search", s.requireScope(auth.ScopeUsersRead), s.handleAdminSearchUsers)
+			admin.POST("/users/import", s.requireScope(auth.ScopeUsersWrite), s.handleAdminImportUsers)
+			admin.POST("/users/:id/reactivate", s.requireScope(auth.ScopeUsersWrite), s.handleAdminReactivateUser)
+			admin.POST("/users/:id/revoke-sessions", s.requireScope(auth.ScopeUsersWrite), s.handleAdminRevokeUserSessions)
+			admin.POST("/users/:id/reset-password", s.requireScope(auth.ScopeUsersWrite), s.handleAdminResetPassword)
+			admin.GET("/users/:id/metadata", s.requireScope(auth.ScopeUsersRead), s.handleAdminGetUserMetadata)
+			admin.PUT("/users/:id/metadata", s.requireScope(auth.ScopeUsersWrite), s.handleAdminSetUserMetadata)
+			admin.DELETE("/users/:id", s.requireScope(auth.ScopeUsersWrite), s.handleAdminDeleteUser)
+			admin.POST("/users/:id/restore", s.requireScope(auth.ScopeUsersWrite), s.handleAdminRestoreUser)
+			admin.GET("/audit", s.requireScope(auth.ScopeAuditRead), s.handleAdminAuditLog)
+			admin.GET("/audit/export", s.requireScope(auth.ScopeAuditRead), s.handleAdminAuditExport)
+			admin.POST("/invites", s.requireScope(auth.ScopeUsersWrite), s.handleAdminCreateInvite)
+			admin.GET("/invites", s.requireScope(auth.ScopeUsersRead), s.handleAdminListInvites)
+			admin.DELETE("/invites/:id", s.requireScope(auth.ScopeUsersWrite), s.handleAdminRevokeInvite)
 		}
 	}
 
 	// Web routes (will serve HTML pages)
-	s.router.GET("/", s.handleHome)
-	s.router.GET("/login", s.handleLoginPage)
-	s.router.GET("/register", s.handleRegisterPage)
-	s.router.GET("/dashboard", s.authMiddleware(), s.handleDashboard)
-} // healthCheck returns the service health status
-func (s *Server) healthCheck(c *gin.Context) {
+	if !s.pages {
+		return
+	}
+	web := s.router.Group("", s.groupMiddleware(groupWeb)...)
+	{
+		web.GET("/", s.handleHome)
+		web.GET("/login", s.handleLoginPage)
+		web.GET("/register", s.handleRegisterPage)
+		web.GET("/dashboard", s.authMiddleware(), s.handleDashboard)
+	}
+}
+
+// requestLogger attaches a request-scoped logger to each request and logs
+// the request once it completes, subject to access log sampling
+func (s *Server) requestLogger() gin.HandlerFunc {
+	sampler := newAccessLogSampler(s.config.Log)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		logger := slog.Default().With(
+			"request_id", c.GetString("request_id"),
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+		)
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if !sampler.shouldLog(status) {
+			return
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []any{
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", clientIP(c),
+		}
+		if userID := c.GetString("user_id"); userID != "" {
+			attrs = append(attrs, "user_id", userID)
+		}
+		if orgID := c.GetString("org_id"); orgID != "" {
+			attrs = append(attrs, "org_id", orgID)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+		logger.Log(c.Request.Context(), level, "request completed", attrs...)
+	}
+}
+
+// requestIDHeader carries the correlation ID in requests and responses
+const requestIDHeader = "X-Request-ID"
+
+// requestID reuses a well-formed incoming X-Request-ID or generates a new
+// one, stores it in the context as "request_id" and echoes it back
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set("request_id", id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID accepts short IDs made of characters that are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random identifier for correlating a request's log lines
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// readinessCheck reports whether the server should receive new traffic
+func (s *Server) readinessCheck(c *gin.Context) {
+	if s.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "draining",
+			"service": "login-app",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
+		"status":  "ready",
 		"service": "login-app",
 	})
 }
+
+// healthCheck returns the service health status, reporting unhealthy when
+// the user store can't be reached
+func (s *Server) healthCheck(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	body := gin.H{
+		"status":         "healthy",
+		"service":        "login-app",
+		"version":        Version,
+		"go_version":     runtime.Version(),
+		"uptime_seconds": int64(time.Since(s.startedAt).Seconds()),
+		"storage":        "ok",
+	}
+
+	if err := s.userStore.Ping(ctx); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Health check storage ping failed", "error", err)
+		status = http.StatusServiceUnavailable
+		body["status"] = "unhealthy"
+		body["storage"] = "unreachable"
+	}
+
+	c.JSON(status, body)
+}
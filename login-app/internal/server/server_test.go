@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+const testPassword = "Secret1!x"
+
+func newTestServer(t *testing.T) http.Handler {
+	return newTestServerWith(t, storage.NewMemoryUserStore(), nil)
+}
+
+// newTestServerWith returns a server on the given user store with the test
+// configuration, after applying configure if it's not nil
+func newTestServerWith(t *testing.T, users storage.UserStore, configure func(cfg *config.Config)) http.Handler {
+	t.Helper()
+
+	cfg, err := config.Load("test")
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	srv, err := New(cfg, users, storage.SessionStores{}, nil, email.LogSender{}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return srv.Handler()
+}
+
+// registerUser registers a user with testPassword through the API and
+// returns the login response
+func registerUser(t *testing.T, handler http.Handler, emailAddress, username string) auth.LoginResponse {
+	t.Helper()
+
+	w := request(t, handler, http.MethodPost, "/api/auth/register", auth.RegisterRequest{
+		Email: emailAddress, Username: username, Password: testPassword, FirstName: "Test", LastName: "User",
+	}, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register %s: status %d: %s", emailAddress, w.Code, w.Body.String())
+	}
+	var registered auth.LoginResponse
+	decodeData(t, w, &registered)
+	return registered
+}
+
+// promoteToAdmin gives the user with the given email the admin role
+func promoteToAdmin(t *testing.T, users storage.UserStore, emailAddress string) {
+	t.Helper()
+
+	user, err := users.GetUserByEmail(context.Background(), emailAddress)
+	if err != nil {
+		t.Fatalf("GetUserByEmail(%s): %v", emailAddress, err)
+	}
+	user.Role = storage.RoleAdmin
+	if err := users.UpdateUser(context.Background(), user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+}
+
+// decodeData decodes the data field of a success response into v
+func decodeData(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	response := struct {
+		Data any `json:"data"`
+	}{Data: v}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v: %s", err, w.Body.String())
+	}
+}
+
+func bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+func request(t *testing.T, handler http.Handler, method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestTwoFactorSetupRequiresAccountWriteScope(t *testing.T) {
+	handler := newTestServer(t)
+
+	w := request(t, handler, http.MethodPost, "/api/auth/register", auth.RegisterRequest{
+		Email: "scoped@example.com", Username: "scoped", Password: "Secret1!x", FirstName: "Test", LastName: "User",
+	}, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", w.Code, w.Body.String())
+	}
+	var registered struct {
+		Data auth.LoginResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &registered); err != nil {
+		t.Fatalf("decode register response: %v", err)
+	}
+	bearer := map[string]string{"Authorization": "Bearer " + registered.Data.Token}
+
+	w = request(t, handler, http.MethodPost, "/api/auth/api-keys", auth.CreateAPIKeyRequest{
+		Name: "read-only", Scopes: []string{auth.ScopeProfileRead},
+	}, bearer)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create API key: status %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Data auth.CreateAPIKeyResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode API key response: %v", err)
+	}
+	apiKey := map[string]string{auth.APIKeyHeader: created.Data.Key}
+
+	for _, path := range []string{"/api/auth/2fa/enable", "/api/auth/2fa/confirm"} {
+		if w := request(t, handler, http.MethodPost, path, auth.TOTPCodeRequest{Code: "123456"}, apiKey); w.Code != http.StatusForbidden {
+			t.Errorf("%s with a profile:read key: status %d, want 403", path, w.Code)
+		}
+	}
+
+	if w := request(t, handler, http.MethodPost, "/api/auth/2fa/enable", nil, bearer); w.Code != http.StatusOK {
+		t.Errorf("/api/auth/2fa/enable with a session token: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
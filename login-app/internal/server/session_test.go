@@ -0,0 +1,128 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+func TestSessionRoutes(t *testing.T) {
+	handler := newTestServer(t)
+	registered := registerUser(t, handler, "devices@example.com", "devices")
+
+	w := request(t, handler, http.MethodPost, "/api/auth/login", auth.LoginRequest{Email: "devices@example.com", Password: testPassword},
+		map[string]string{"User-Agent": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148 Safari/604.1"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body.String())
+	}
+	var phone auth.LoginResponse
+	decodeData(t, w, &phone)
+
+	if w := request(t, handler, http.MethodGet, "/api/auth/sessions", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("list without a token: status %d, want 401", w.Code)
+	}
+
+	w = request(t, handler, http.MethodGet, "/api/auth/sessions", nil, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: status %d: %s", w.Code, w.Body.String())
+	}
+	var sessions []auth.SessionInfo
+	decodeData(t, w, &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("%d sessions, want 2", len(sessions))
+	}
+	// Listed from the registration session, so the phone's isn't current
+	if sessions[0].Current == sessions[1].Current {
+		t.Fatalf("sessions = %+v, want exactly one current", sessions)
+	}
+	phoneSession := sessions[0]
+	if phoneSession.Current {
+		phoneSession = sessions[1]
+	}
+	if phoneSession.UserAgent == "" {
+		t.Errorf("phone session %+v has no user agent", phoneSession)
+	}
+
+	if w := request(t, handler, http.MethodDelete, "/api/auth/sessions/"+phoneSession.ID, nil, bearer(registered.Token)); w.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(phone.Token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked session's token: status %d, want 401", w.Code)
+	}
+	if w := request(t, handler, http.MethodDelete, "/api/auth/sessions/"+phoneSession.ID, nil, bearer(registered.Token)); w.Code != http.StatusNotFound {
+		t.Errorf("revoking again: status %d, want 404", w.Code)
+	}
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(registered.Token)); w.Code != http.StatusOK {
+		t.Errorf("current session's token: status %d, want 200", w.Code)
+	}
+}
+
+func TestAdminRevokeSessionsRoute(t *testing.T) {
+	users := storage.NewMemoryUserStore()
+	handler := newTestServerWith(t, users, nil)
+	admin := registerUser(t, handler, "admin@example.com", "admin")
+	promoteToAdmin(t, users, "admin@example.com")
+	member := registerUser(t, handler, "member@example.com", "member")
+
+	w := request(t, handler, http.MethodPost, "/api/auth/login", auth.LoginRequest{Email: "member@example.com", Password: testPassword}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body.String())
+	}
+	var second auth.LoginResponse
+	decodeData(t, w, &second)
+
+	path := "/api/admin/users/" + member.User.ID + "/revoke-sessions"
+	if w := request(t, handler, http.MethodPost, path, nil, bearer(member.Token)); w.Code != http.StatusForbidden {
+		t.Errorf("revoke as a non-admin: status %d, want 403", w.Code)
+	}
+	if w := request(t, handler, http.MethodPost, path, nil, bearer(admin.Token)); w.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d: %s", w.Code, w.Body.String())
+	}
+
+	for name, token := range map[string]string{"first": member.Token, "second": second.Token} {
+		if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(token)); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s token after revoking: status %d, want 401", name, w.Code)
+		}
+	}
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(admin.Token)); w.Code != http.StatusOK {
+		t.Errorf("admin's own token: status %d, want 200", w.Code)
+	}
+	if w := request(t, handler, http.MethodPost, "/api/admin/users/missing/revoke-sessions", nil, bearer(admin.Token)); w.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status %d, want 404", w.Code)
+	}
+}
+
+func TestSlidingSessionRoutes(t *testing.T) {
+	handler := newTestServerWith(t, storage.NewMemoryUserStore(), func(cfg *config.Config) {
+		cfg.Auth.SlidingSessions = config.SlidingSessionsConfig{Enabled: true, IdleTimeout: 300 * time.Millisecond, MaxLifetime: time.Hour}
+	})
+	registered := registerUser(t, handler, "sliding@example.com", "sliding")
+
+	// Requests closer together than the idle timeout keep the session going
+	// well past it
+	for i := 0; i < 6; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(registered.Token)); w.Code != http.StatusOK {
+			t.Fatalf("request %d while active: status %d, want 200: %s", i, w.Code, w.Body.String())
+		}
+	}
+
+	w := request(t, handler, http.MethodGet, "/api/auth/sessions", nil, bearer(registered.Token))
+	var sessions []auth.SessionInfo
+	decodeData(t, w, &sessions)
+	if len(sessions) != 1 || sessions[0].LastActiveAt == nil || time.Since(*sessions[0].LastActiveAt) > 200*time.Millisecond {
+		t.Errorf("sessions = %+v, want one recently active", sessions)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(registered.Token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("after the idle timeout: status %d, want 401", w.Code)
+	}
+	if w := request(t, handler, http.MethodPost, "/api/auth/refresh", auth.RefreshRequest{RefreshToken: registered.RefreshToken}, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after the idle timeout: status %d, want 401", w.Code)
+	}
+}
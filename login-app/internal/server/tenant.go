@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+// resolveTenant creates middleware that works out which organization a
+// request belongs to and stores it in the request context, where the user
+// store and token checks pick it up, and as "org_id" for handlers. A
+// subdomain and header naming different organizations are rejected.
+func resolveTenant(cfg config.TenancyConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := subdomainOrg(c.Request.Host, cfg.BaseDomain)
+
+		if cfg.Header != "" {
+			if header := strings.ToLower(strings.TrimSpace(c.GetHeader(cfg.Header))); header != "" {
+				if orgID != "" && header != orgID {
+					abortInvalidOrg(c, "Organization header does not match the host")
+					return
+				}
+				orgID = header
+			}
+		}
+
+		if orgID == "" {
+			orgID = cfg.DefaultOrg
+		}
+		if orgID != "" && !tenant.ValidOrgID(orgID) {
+			abortInvalidOrg(c, "Invalid organization")
+			return
+		}
+
+		c.Set("org_id", orgID)
+		c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), orgID))
+		c.Next()
+	}
+}
+
+// subdomainOrg returns the label in front of baseDomain in host, or "" when
+// the host isn't a subdomain of it. Deeper subdomains are returned whole so
+// they fail validation rather than silently picking an organization.
+func subdomainOrg(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	suffix := "." + strings.ToLower(baseDomain)
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+	return strings.TrimSuffix(host, suffix)
+}
+
+// abortInvalidOrg rejects a request whose organization can't be determined
+func abortInvalidOrg(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, auth.ErrorResponse{
+		Error:     "invalid_org",
+		Message:   message,
+		Code:      http.StatusBadRequest,
+		RequestID: c.GetString("request_id"),
+	})
+}
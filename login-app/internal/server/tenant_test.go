@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+func withTenancy(cfg *config.Config) {
+	cfg.Tenancy.Enabled = true
+	cfg.Tenancy.BaseDomain = "example.test"
+	cfg.Tenancy.Header = "X-Org-ID"
+}
+
+// registerInOrg registers a user with testPassword in an organization
+func registerInOrg(t *testing.T, handler http.Handler, orgID, emailAddress, username string) auth.LoginResponse {
+	t.Helper()
+
+	w := request(t, handler, http.MethodPost, "/api/auth/register", auth.RegisterRequest{
+		Email: emailAddress, Username: username, Password: testPassword, FirstName: "Test", LastName: "User",
+	}, map[string]string{"X-Org-ID": orgID})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register %s in %s: status %d: %s", emailAddress, orgID, w.Code, w.Body.String())
+	}
+	var registered auth.LoginResponse
+	decodeData(t, w, &registered)
+	return registered
+}
+
+// inOrg returns headers with a bearer token for an organization
+func inOrg(orgID, token string) map[string]string {
+	headers := bearer(token)
+	headers["X-Org-ID"] = orgID
+	return headers
+}
+
+func TestOrganizationsAreIsolated(t *testing.T) {
+	users := storage.NewMemoryUserStore()
+	handler := newTestServerWith(t, users, withTenancy)
+
+	acme := registerInOrg(t, handler, "acme", "shared@example.com", "shared")
+	globex := registerInOrg(t, handler, "globex", "shared@example.com", "shared")
+	if acme.User.ID == globex.User.ID {
+		t.Fatal("both organizations got the same user")
+	}
+	w := request(t, handler, http.MethodPost, "/api/auth/register", auth.RegisterRequest{
+		Email: "shared@example.com", Username: "other", Password: testPassword, FirstName: "Test", LastName: "User",
+	}, map[string]string{"X-Org-ID": "acme"})
+	if w.Code != http.StatusConflict {
+		t.Errorf("duplicate within an organization: status %d, want 409", w.Code)
+	}
+
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, inOrg("acme", acme.Token)); w.Code != http.StatusOK {
+		t.Errorf("token in its own organization: status %d, want 200", w.Code)
+	}
+	for _, orgID := range []string{"globex", ""} {
+		if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, inOrg(orgID, acme.Token)); w.Code != http.StatusUnauthorized {
+			t.Errorf("acme token in organization %q: status %d, want 401", orgID, w.Code)
+		}
+	}
+
+	// An admin only sees users in their own organization
+	registerInOrg(t, handler, "acme", "colleague@example.com", "colleague")
+	admin, err := users.GetUserByID(tenant.NewContext(context.Background(), "acme"), acme.User.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	admin.Role = storage.RoleAdmin
+	if err := users.UpdateUser(tenant.NewContext(context.Background(), "acme"), admin); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	w = request(t, handler, http.MethodGet, "/api/admin/users", nil, inOrg("acme", acme.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list users: status %d: %s", w.Code, w.Body.String())
+	}
+	var list auth.UserListResponse
+	decodeData(t, w, &list)
+	if list.Total != 2 {
+		t.Errorf("admin sees %d users, want the 2 in acme", list.Total)
+	}
+	for _, user := range list.Users {
+		if user.ID == globex.User.ID {
+			t.Errorf("acme admin sees the globex user")
+		}
+	}
+	if w := request(t, handler, http.MethodPost, "/api/admin/users/"+globex.User.ID+"/revoke-sessions", nil, inOrg("acme", acme.Token)); w.Code != http.StatusNotFound {
+		t.Errorf("acme admin revoking a globex user's sessions: status %d, want 404", w.Code)
+	}
+	if w := request(t, handler, http.MethodGet, "/api/auth/profile", nil, inOrg("globex", globex.Token)); w.Code != http.StatusOK {
+		t.Errorf("globex user's token: status %d, want 200", w.Code)
+	}
+}
+
+func TestOrganizationFromSubdomain(t *testing.T) {
+	handler := newTestServerWith(t, storage.NewMemoryUserStore(), withTenancy)
+	acme := registerInOrg(t, handler, "acme", "sub@example.com", "sub")
+
+	profile := func(host string, headers map[string]string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/profile", nil)
+		req.Host = host
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if status := profile("acme.example.test", bearer(acme.Token)); status != http.StatusOK {
+		t.Errorf("acme subdomain: status %d, want 200", status)
+	}
+	if status := profile("globex.example.test", bearer(acme.Token)); status != http.StatusUnauthorized {
+		t.Errorf("globex subdomain: status %d, want 401", status)
+	}
+	if status := profile("acme.example.test", inOrg("globex", acme.Token)); status != http.StatusBadRequest {
+		t.Errorf("subdomain and header disagree: status %d, want 400", status)
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+func TestWhoAmIMatchesProfile(t *testing.T) {
+	handler := newTestServer(t)
+	registered := registerUser(t, handler, "whoami@example.com", "whoami")
+
+	w := request(t, handler, http.MethodGet, "/api/auth/whoami", nil, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("whoami: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var fields map[string]any
+	decodeData(t, w, &fields)
+	for _, field := range []string{"user_id", "username", "email", "expires_at"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("whoami response has no %s: %v", field, fields)
+		}
+	}
+	var whoami auth.WhoAmIResponse
+	decodeData(t, w, &whoami)
+	if whoami.ExpiresAt == nil || !whoami.ExpiresAt.Equal(registered.ExpiresAt.Truncate(time.Second)) {
+		t.Errorf("expires_at = %v, want the token's %v to the second", whoami.ExpiresAt, registered.ExpiresAt)
+	}
+
+	w = request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("profile: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var profile auth.UserInfo
+	decodeData(t, w, &profile)
+	if whoami.UserID != profile.ID || whoami.Username != profile.Username || whoami.Email != profile.Email {
+		t.Errorf("whoami %+v doesn't match profile %+v", whoami, profile)
+	}
+}
+
+func TestWhoAmIReflectsTheToken(t *testing.T) {
+	handler := newTestServer(t)
+	registered := registerUser(t, handler, "renamed@example.com", "before")
+
+	w := request(t, handler, http.MethodPut, "/api/auth/profile", auth.UpdateProfileRequest{Username: "after"}, bearer(registered.Token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update profile: status %d: %s", w.Code, w.Body.String())
+	}
+
+	// The profile is live, whoami answers from the token issued before the rename
+	var whoami auth.WhoAmIResponse
+	decodeData(t, request(t, handler, http.MethodGet, "/api/auth/whoami", nil, bearer(registered.Token)), &whoami)
+	var profile auth.UserInfo
+	decodeData(t, request(t, handler, http.MethodGet, "/api/auth/profile", nil, bearer(registered.Token)), &profile)
+	if whoami.Username != "before" || profile.Username != "after" {
+		t.Errorf("whoami username %q, profile username %q; want before and after", whoami.Username, profile.Username)
+	}
+}
+
+func TestWhoAmIRequiresAuthentication(t *testing.T) {
+	handler := newTestServer(t)
+
+	for name, headers := range map[string]map[string]string{
+		"no token":      nil,
+		"invalid token": bearer("not-a-token"),
+		"wrong scheme":  {"Authorization": "Basic dXNlcjpwYXNz"},
+	} {
+		if w := request(t, handler, http.MethodGet, "/api/auth/whoami", nil, headers); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want 401", name, w.Code)
+		}
+	}
+}
+
+func TestWhoAmIWithAPIKey(t *testing.T) {
+	handler := newTestServer(t)
+	registered := registerUser(t, handler, "key@example.com", "key")
+
+	w := request(t, handler, http.MethodPost, "/api/auth/api-keys", auth.CreateAPIKeyRequest{
+		Name: "script", Scopes: []string{auth.ScopeProfileRead},
+	}, bearer(registered.Token))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create API key: status %d: %s", w.Code, w.Body.String())
+	}
+	var created auth.CreateAPIKeyResponse
+	decodeData(t, w, &created)
+
+	w = request(t, handler, http.MethodGet, "/api/auth/whoami", nil, map[string]string{auth.APIKeyHeader: created.Key})
+	if w.Code != http.StatusOK {
+		t.Fatalf("whoami with an API key: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var whoami auth.WhoAmIResponse
+	decodeData(t, w, &whoami)
+	if whoami.UserID != registered.User.ID || whoami.ExpiresAt != nil {
+		t.Errorf("whoami = %+v, want the key's owner without an expiry", whoami)
+	}
+}
+
+func TestWhoAmIRequiresPasswordChange(t *testing.T) {
+	users := storage.NewMemoryUserStore()
+	handler := newTestServerWith(t, users, nil)
+	registered := registerUser(t, handler, "reset@example.com", "reset")
+
+	ctx := context.Background()
+	user, err := users.GetUserByID(ctx, registered.User.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	user.MustChangePassword = true
+	if err := users.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	w := request(t, handler, http.MethodGet, "/api/auth/whoami", nil, bearer(registered.Token))
+	var response auth.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if w.Code != http.StatusForbidden || response.Error != "password_change_required" {
+		t.Errorf("whoami: status %d, error %q; want 403 password_change_required", w.Code, response.Error)
+	}
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// APIKey represents a long-lived API credential owned by a user
+type APIKey struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	KeyHash   string     `json:"-"` // Never include in JSON
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	// LastUsedAt is when the key last authenticated a request
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// IsRevoked reports whether the key has been revoked
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// APIKeyStore defines the interface for API key storage operations
+type APIKeyStore interface {
+	// CreateAPIKey stores a new API key
+	CreateAPIKey(key *APIKey) error
+
+	// GetAPIKeyByHash retrieves an API key by the hash of its plaintext value
+	GetAPIKeyByHash(keyHash string) (*APIKey, error)
+
+	// ListAPIKeys returns all API keys owned by a user
+	ListAPIKeys(userID string) ([]*APIKey, error)
+
+	// RevokeAPIKey marks a user's API key as revoked
+	RevokeAPIKey(userID, id string) error
+
+	// TouchAPIKey records that a key was used at the given time
+	TouchAPIKey(id string, usedAt time.Time) error
+
+	// DeleteUserAPIKeys deletes every API key owned by a user
+	DeleteUserAPIKeys(userID string) error
+}
+
+// MemoryAPIKeyStore implements APIKeyStore using in-memory storage
+type MemoryAPIKeyStore struct {
+	mu      sync.RWMutex
+	keys    map[string]*APIKey // id -> key
+	hashIdx map[string]string  // key hash -> id mapping
+}
+
+// NewMemoryAPIKeyStore creates a new in-memory API key store
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{
+		keys:    make(map[string]*APIKey),
+		hashIdx: make(map[string]string),
+	}
+}
+
+// CreateAPIKey stores a new API key
+func (s *MemoryAPIKeyStore) CreateAPIKey(key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keyCopy := copyAPIKey(key)
+	keyCopy.CreatedAt = time.Now()
+
+	s.keys[key.ID] = keyCopy
+	s.hashIdx[key.KeyHash] = key.ID
+
+	return nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its plaintext value
+func (s *MemoryAPIKeyStore) GetAPIKeyByHash(keyHash string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.hashIdx[keyHash]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+	key, exists := s.keys[id]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return copyAPIKey(key), nil
+}
+
+// ListAPIKeys returns all API keys owned by a user
+func (s *MemoryAPIKeyStore) ListAPIKeys(userID string) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*APIKey, 0)
+	for _, key := range s.keys {
+		if key.UserID == userID {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks a user's API key as revoked
+func (s *MemoryAPIKeyStore) RevokeAPIKey(userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists || key.UserID != userID {
+		return ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that a key was used at the given time
+func (s *MemoryAPIKeyStore) TouchAPIKey(id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return ErrAPIKeyNotFound
+	}
+
+	key.LastUsedAt = &usedAt
+	return nil
+}
+
+// DeleteUserAPIKeys deletes every API key owned by a user
+func (s *MemoryAPIKeyStore) DeleteUserAPIKeys(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, key := range s.keys {
+		if key.UserID == userID {
+			delete(s.hashIdx, key.KeyHash)
+			delete(s.keys, id)
+		}
+	}
+
+	return nil
+}
+
+// copyAPIKey returns a deep copy of an API key
+func copyAPIKey(key *APIKey) *APIKey {
+	keyCopy := *key
+	keyCopy.Scopes = append([]string(nil), key.Scopes...)
+	if key.RevokedAt != nil {
+		revokedAt := *key.RevokedAt
+		keyCopy.RevokedAt = &revokedAt
+	}
+	if key.LastUsedAt != nil {
+		lastUsedAt := *key.LastUsedAt
+		keyCopy.LastUsedAt = &lastUsedAt
+	}
+	return &keyCopy
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeleteUserAPIKeysRemovesHashIndex(t *testing.T) {
+	store := NewMemoryAPIKeyStore()
+	for _, key := range []*APIKey{
+		{ID: "k1", UserID: "u1", KeyHash: "h1"},
+		{ID: "k2", UserID: "u1", KeyHash: "h2"},
+		{ID: "k3", UserID: "u2", KeyHash: "h3"},
+	} {
+		if err := store.CreateAPIKey(key); err != nil {
+			t.Fatalf("CreateAPIKey(%s): %v", key.ID, err)
+		}
+	}
+
+	if err := store.DeleteUserAPIKeys("u1"); err != nil {
+		t.Fatalf("DeleteUserAPIKeys: %v", err)
+	}
+
+	for _, hash := range []string{"h1", "h2"} {
+		if _, err := store.GetAPIKeyByHash(hash); !errors.Is(err, ErrAPIKeyNotFound) {
+			t.Errorf("GetAPIKeyByHash(%s) after delete: got %v, want ErrAPIKeyNotFound", hash, err)
+		}
+	}
+	if key, err := store.GetAPIKeyByHash("h3"); err != nil || key.ID != "k3" {
+		t.Errorf("GetAPIKeyByHash(h3) = %v, %v; want other user's key to remain", key, err)
+	}
+}
+
+func TestGetAPIKeyByHashMissingKey(t *testing.T) {
+	store := NewMemoryAPIKeyStore()
+	// An index entry without its key must not panic
+	store.hashIdx["orphan"] = "missing"
+
+	if _, err := store.GetAPIKeyByHash("orphan"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("got %v, want ErrAPIKeyNotFound", err)
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInviteNotFound = errors.New("invite not found")
+)
+
+// Invite lets one email address register while registration is invite-only
+type Invite struct {
+	ID        string     `json:"id"`
+	OrgID     string     `json:"org_id,omitempty"`
+	Email     string     `json:"email"` // Normalized
+	CodeHash  string     `json:"-"`     // Never include in JSON
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Usable reports whether the invite can still be used to register
+func (i *Invite) Usable(now time.Time) bool {
+	return i.UsedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// InviteStore defines the interface for invite storage operations
+type InviteStore interface {
+	// CreateInvite stores a new invite
+	CreateInvite(invite *Invite) error
+
+	// UseInvite marks the invite with the given code hash as used by email.
+	// It fails with ErrInviteNotFound unless the invite exists, is usable
+	// and was issued to email in the organization, so a code can only be
+	// used once.
+	UseInvite(orgID, codeHash, email string) (*Invite, error)
+
+	// ReleaseInvite makes a used invite usable again, for registrations
+	// that fail after the invite was used
+	ReleaseInvite(id string) error
+
+	// ListInvites returns all invites of an organization
+	ListInvites(orgID string) ([]*Invite, error)
+
+	// RevokeInvite marks an organization's invite as revoked
+	RevokeInvite(orgID, id string) error
+}
+
+// MemoryInviteStore implements InviteStore using in-memory storage
+type MemoryInviteStore struct {
+	mu      sync.Mutex
+	invites map[string]*Invite // id -> invite
+	codeIdx map[string]string  // code hash -> id mapping
+}
+
+// NewMemoryInviteStore creates a new in-memory invite store
+func NewMemoryInviteStore() *MemoryInviteStore {
+	return &MemoryInviteStore{
+		invites: make(map[string]*Invite),
+		codeIdx: make(map[string]string),
+	}
+}
+
+// CreateInvite stores a new invite
+func (s *MemoryInviteStore) CreateInvite(invite *Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inviteCopy := copyInvite(invite)
+	inviteCopy.Email = NormalizeEmail(invite.Email)
+	inviteCopy.CreatedAt = time.Now()
+
+	s.invites[invite.ID] = inviteCopy
+	s.codeIdx[invite.CodeHash] = invite.ID
+
+	return nil
+}
+
+// UseInvite marks a usable invite issued to email as used
+func (s *MemoryInviteStore) UseInvite(orgID, codeHash, email string) (*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, exists := s.codeIdx[codeHash]
+	if !exists {
+		return nil, ErrInviteNotFound
+	}
+
+	invite := s.invites[id]
+	now := time.Now()
+	if !invite.Usable(now) || invite.OrgID != orgID || invite.Email != NormalizeEmail(email) {
+		return nil, ErrInviteNotFound
+	}
+
+	invite.UsedAt = &now
+	return copyInvite(invite), nil
+}
+
+// ReleaseInvite makes a used invite usable again
+func (s *MemoryInviteStore) ReleaseInvite(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, exists := s.invites[id]
+	if !exists {
+		return ErrInviteNotFound
+	}
+
+	invite.UsedAt = nil
+	return nil
+}
+
+// ListInvites returns all invites of an organization
+func (s *MemoryInviteStore) ListInvites(orgID string) ([]*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invites := make([]*Invite, 0)
+	for _, invite := range s.invites {
+		if invite.OrgID == orgID {
+			invites = append(invites, copyInvite(invite))
+		}
+	}
+
+	return invites, nil
+}
+
+// RevokeInvite marks an organization's invite as revoked
+func (s *MemoryInviteStore) RevokeInvite(orgID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, exists := s.invites[id]
+	if !exists || invite.OrgID != orgID {
+		return ErrInviteNotFound
+	}
+
+	if invite.RevokedAt == nil {
+		now := time.Now()
+		invite.RevokedAt = &now
+	}
+
+	return nil
+}
+
+// copyInvite returns a deep copy of an invite
+func copyInvite(invite *Invite) *Invite {
+	inviteCopy := *invite
+	if invite.UsedAt != nil {
+		usedAt := *invite.UsedAt
+		inviteCopy.UsedAt = &usedAt
+	}
+	if invite.RevokedAt != nil {
+		revokedAt := *invite.RevokedAt
+		inviteCopy.RevokedAt = &revokedAt
+	}
+	return &inviteCopy
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMigrationDrift        = errors.New("applied migration does not match its file")
+	ErrIrreversibleMigration = errors.New("migration has no down file")
+)
+
+// migrationFS holds the SQL migrations, named "<version>_<name>.sql", and
+// the optional "<version>_<name>.down.sql" files that revert them
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migration is one schema change
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Checksum string // SHA-256 of SQL, to detect edits after it was applied
+
+	// DownSQL reverts SQL; empty when the migration can't be rolled back
+	DownSQL string
+}
+
+// MigrationStatus reports the result of a migration run
+type MigrationStatus struct {
+	Applied    []Migration // Applied by this run
+	RolledBack []Migration // Rolled back by this run, latest first
+	Version    int         // Schema version after the run
+}
+
+// Migrations returns the embedded migrations in version order
+func Migrations() ([]Migration, error) {
+	return LoadMigrations(migrationFS, "migrations")
+}
+
+// LoadMigrations reads the migration files in dir of fsys in version order.
+// Versions must be unique positive integers, and a down file must have the
+// same version and name as its migration.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	downs := make(map[string]string) // base name -> down SQL
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		base, down := strings.CutSuffix(base, ".down")
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s: file name must start with a positive version number", entry.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if down {
+			downs[base] = string(data)
+			continue
+		}
+
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     name,
+			SQL:      string(data),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	for i, migration := range migrations {
+		base := strings.TrimSuffix(seen[migration.Version], ".sql")
+		if downSQL, ok := downs[base]; ok {
+			migrations[i].DownSQL = downSQL
+			delete(downs, base)
+		}
+	}
+	for base := range downs {
+		return nil, fmt.Errorf("migration %s.down.sql has no matching %s.sql", base, base)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrate applies the embedded migrations that db hasn't seen yet
+func Migrate(ctx context.Context, db *sql.DB, driver string) (*MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return ApplyMigrations(ctx, db, driver, migrations)
+}
+
+// Rollback reverts the latest steps embedded migrations applied to db
+func Rollback(ctx context.Context, db *sql.DB, driver string, steps int) (*MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return RollbackMigrations(ctx, db, driver, migrations, steps)
+}
+
+// ApplyMigrations brings db up to the latest of migrations. Applied
+// versions are recorded with their checksums in the schema_migrations
+// table; an applied migration whose file has changed since, or that is no
+// longer known, fails the run with ErrMigrationDrift before anything new
+// is applied. Each migration runs in its own transaction.
+func ApplyMigrations(ctx context.Context, db *sql.DB, driver string, migrations []Migration) (*MigrationStatus, error) {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{}
+	if status.Version, err = checkDrift(applied, migrations); err != nil {
+		return nil, err
+	}
+
+	insert := fmt.Sprintf("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (%s, %s, %s, %s)",
+		placeholder(driver, 1), placeholder(driver, 2), placeholder(driver, 3), placeholder(driver, 4))
+	for _, migration := range migrations {
+		if _, done := applied[migration.Version]; done {
+			continue
+		}
+		if err := runMigration(ctx, db, migration.SQL, insert,
+			migration.Version, migration.Name, migration.Checksum, time.Now().UTC()); err != nil {
+			return status, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		status.Applied = append(status.Applied, migration)
+		status.Version = migration.Version
+	}
+	return status, nil
+}
+
+// RollbackMigrations reverts the latest steps applied migrations, newest
+// first, with their down files. Like ApplyMigrations it checks for drift
+// first, and it stops without changes if any of them has no down file
+// (ErrIrreversibleMigration). Each rollback runs in its own transaction.
+func RollbackMigrations(ctx context.Context, db *sql.DB, driver string, migrations []Migration, steps int) (*MigrationStatus, error) {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{}
+	if status.Version, err = checkDrift(applied, migrations); err != nil {
+		return nil, err
+	}
+
+	var revert []Migration
+	for i := len(migrations) - 1; i >= 0 && len(revert) < steps; i-- {
+		if _, done := applied[migrations[i].Version]; done {
+			revert = append(revert, migrations[i])
+		}
+	}
+	for _, migration := range revert {
+		if migration.DownSQL == "" {
+			return nil, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, ErrIrreversibleMigration)
+		}
+	}
+
+	remove := "DELETE FROM schema_migrations WHERE version = " + placeholder(driver, 1)
+	for _, migration := range revert {
+		if err := runMigration(ctx, db, migration.DownSQL, remove, migration.Version); err != nil {
+			return status, fmt.Errorf("rollback of migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		status.RolledBack = append(status.RolledBack, migration)
+		delete(applied, migration.Version)
+		status.Version = latestVersion(applied)
+	}
+	return status, nil
+}
+
+// appliedMigrations returns the checksums of the applied migrations by
+// version, creating the schema_migrations table on first use
+func appliedMigrations(ctx context.Context, db *sql.DB) (map[int]string, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    name       TEXT NOT NULL,
+    checksum   TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, checksum FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = checksum
+	}
+	return applied, rows.Err()
+}
+
+// checkDrift returns ErrMigrationDrift if an applied migration has no file
+// or its file has changed, and otherwise the current schema version
+func checkDrift(applied map[int]string, migrations []Migration) (int, error) {
+	known := make(map[int]Migration, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, checksum := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return 0, fmt.Errorf("migration %d is applied but has no file: %w", version, ErrMigrationDrift)
+		}
+		if migration.Checksum != checksum {
+			return 0, fmt.Errorf("migration %d (%s) was changed after it was applied: %w",
+				version, migration.Name, ErrMigrationDrift)
+		}
+	}
+	return latestVersion(applied), nil
+}
+
+// latestVersion returns the highest applied version, or 0 if none is
+func latestVersion(applied map[int]string) int {
+	latest := 0
+	for version := range applied {
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// runMigration runs a migration's SQL and the statement recording it in a
+// single transaction
+func runMigration(ctx context.Context, db *sql.DB, script, record string, args ...any) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// placeholder returns the nth bind parameter in the driver's syntax
+func placeholder(driver string, n int) string {
+	switch driver {
+	case "postgres", "pgx":
+		return "$" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	if err != nil {
+		t.Fatalf("query sqlite_master: %v", err)
+	}
+	return count > 0
+}
+
+func testMigrations(t *testing.T, files fstest.MapFS) []Migration {
+	t.Helper()
+
+	migrations, err := LoadMigrations(files, "migrations")
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	return migrations
+}
+
+var testMigrationFiles = fstest.MapFS{
+	"migrations/0001_widgets.sql":      {Data: []byte("CREATE TABLE widgets (id TEXT PRIMARY KEY);")},
+	"migrations/0001_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+	"migrations/0002_gadgets.sql":      {Data: []byte("CREATE TABLE gadgets (id TEXT PRIMARY KEY);")},
+	"migrations/0002_gadgets.down.sql": {Data: []byte("DROP TABLE gadgets;")},
+}
+
+func TestMigrateEmbeddedUpAndDown(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	status, err := Migrate(ctx, db, "sqlite3")
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(status.Applied) == 0 || !tableExists(t, db, "users") {
+		t.Fatalf("Migrate applied %d migrations, want the users table", len(status.Applied))
+	}
+
+	status, err = Rollback(ctx, db, "sqlite3", len(status.Applied))
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if status.Version != 0 || tableExists(t, db, "users") {
+		t.Errorf("after rollback: version %d, users table exists %v; want 0 and no table",
+			status.Version, tableExists(t, db, "users"))
+	}
+}
+
+func TestApplyMigrationsIsIdempotent(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	migrations := testMigrations(t, testMigrationFiles)
+
+	status, err := ApplyMigrations(ctx, db, "sqlite3", migrations)
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if len(status.Applied) != 2 || status.Version != 2 {
+		t.Fatalf("first run applied %d up to version %d, want 2 up to 2", len(status.Applied), status.Version)
+	}
+
+	status, err = ApplyMigrations(ctx, db, "sqlite3", migrations)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if len(status.Applied) != 0 || status.Version != 2 {
+		t.Errorf("second run applied %d up to version %d, want nothing at version 2", len(status.Applied), status.Version)
+	}
+}
+
+func TestRollbackMigrations(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	migrations := testMigrations(t, testMigrationFiles)
+
+	if _, err := ApplyMigrations(ctx, db, "sqlite3", migrations); err != nil {
+		t.Fatalf("ApplyMigrations: %v", err)
+	}
+
+	status, err := RollbackMigrations(ctx, db, "sqlite3", migrations, 1)
+	if err != nil {
+		t.Fatalf("RollbackMigrations: %v", err)
+	}
+	if len(status.RolledBack) != 1 || status.RolledBack[0].Version != 2 || status.Version != 1 {
+		t.Errorf("rolled back %+v to version %d, want only version 2, leaving 1", status.RolledBack, status.Version)
+	}
+	if tableExists(t, db, "gadgets") || !tableExists(t, db, "widgets") {
+		t.Error("want gadgets dropped and widgets kept")
+	}
+
+	// Applying again restores the rolled back migration
+	status, err = ApplyMigrations(ctx, db, "sqlite3", migrations)
+	if err != nil {
+		t.Fatalf("reapply: %v", err)
+	}
+	if len(status.Applied) != 1 || status.Applied[0].Version != 2 {
+		t.Errorf("reapply applied %+v, want version 2", status.Applied)
+	}
+}
+
+func TestRollbackWithoutDownFile(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	migrations := testMigrations(t, fstest.MapFS{
+		"migrations/0001_widgets.sql":      {Data: []byte("CREATE TABLE widgets (id TEXT PRIMARY KEY);")},
+		"migrations/0001_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+		"migrations/0002_gadgets.sql":      {Data: []byte("CREATE TABLE gadgets (id TEXT PRIMARY KEY);")},
+	})
+
+	if _, err := ApplyMigrations(ctx, db, "sqlite3", migrations); err != nil {
+		t.Fatalf("ApplyMigrations: %v", err)
+	}
+	if _, err := RollbackMigrations(ctx, db, "sqlite3", migrations, 2); !errors.Is(err, ErrIrreversibleMigration) {
+		t.Fatalf("got %v, want ErrIrreversibleMigration", err)
+	}
+	if !tableExists(t, db, "widgets") || !tableExists(t, db, "gadgets") {
+		t.Error("a refused rollback must not revert anything")
+	}
+}
+
+func TestApplyMigrationsChecksumMismatch(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	if _, err := ApplyMigrations(ctx, db, "sqlite3", testMigrations(t, testMigrationFiles)); err != nil {
+		t.Fatalf("ApplyMigrations: %v", err)
+	}
+
+	edited := fstest.MapFS{
+		"migrations/0001_widgets.sql": {Data: []byte("CREATE TABLE widgets (id TEXT PRIMARY KEY, name TEXT);")},
+		"migrations/0002_gadgets.sql": testMigrationFiles["migrations/0002_gadgets.sql"],
+		"migrations/0003_gizmos.sql":  {Data: []byte("CREATE TABLE gizmos (id TEXT PRIMARY KEY);")},
+	}
+	if _, err := ApplyMigrations(ctx, db, "sqlite3", testMigrations(t, edited)); !errors.Is(err, ErrMigrationDrift) {
+		t.Fatalf("edited migration: got %v, want ErrMigrationDrift", err)
+	}
+	if tableExists(t, db, "gizmos") {
+		t.Error("drift must stop the run before new migrations are applied")
+	}
+
+	missing := fstest.MapFS{
+		"migrations/0002_gadgets.sql": testMigrationFiles["migrations/0002_gadgets.sql"],
+	}
+	if _, err := ApplyMigrations(ctx, db, "sqlite3", testMigrations(t, missing)); !errors.Is(err, ErrMigrationDrift) {
+		t.Errorf("missing migration: got %v, want ErrMigrationDrift", err)
+	}
+}
+
+func TestLoadMigrationsRejectsOrphanDownFile(t *testing.T) {
+	_, err := LoadMigrations(fstest.MapFS{
+		"migrations/0001_widgets.sql":     {Data: []byte("CREATE TABLE widgets (id TEXT);")},
+		"migrations/0001_gizmos.down.sql": {Data: []byte("DROP TABLE gizmos;")},
+	}, "migrations")
+	if err == nil {
+		t.Error("want an error for a down file without a matching migration")
+	}
+}
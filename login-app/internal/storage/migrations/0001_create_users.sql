@@ -0,0 +1,20 @@
+-- Users, mirroring storage.User. Emails and usernames are unique within
+-- an organization; the default organization's ID is empty.
+CREATE TABLE users (
+    id                  TEXT PRIMARY KEY,
+    org_id              TEXT NOT NULL DEFAULT '',
+    email               TEXT NOT NULL,
+    username            TEXT NOT NULL,
+    normalized_username TEXT NOT NULL,
+    password_hash       TEXT NOT NULL DEFAULT '',
+    first_name          TEXT NOT NULL DEFAULT '',
+    last_name           TEXT NOT NULL DEFAULT '',
+    role                TEXT NOT NULL DEFAULT 'user',
+    is_active           BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at          TIMESTAMP NOT NULL,
+    updated_at          TIMESTAMP NOT NULL,
+    deleted_at          TIMESTAMP
+);
+
+CREATE UNIQUE INDEX users_org_email ON users (org_id, email);
+CREATE UNIQUE INDEX users_org_username ON users (org_id, normalized_username);
@@ -0,0 +1,450 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// NewRedisClient connects to Redis and checks that it is reachable, so a
+// misconfigured address fails at startup rather than on the first login
+func NewRedisClient(cfg config.RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:        cfg.Addr,
+		Password:    cfg.Password,
+		DB:          cfg.DB,
+		DialTimeout: cfg.DialTimeout,
+	})
+
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis at %s is unreachable: %w", cfg.Addr, err)
+	}
+	return client, nil
+}
+
+// Redis keys expire together with the record they hold, so unlike the
+// memory stores these need no sweeper. Per-user and per-family sets index
+// the records for bulk deletes and may briefly list IDs that have already
+// expired; readers skip those.
+
+// RedisRevokedTokenStore implements RevokedTokenStore using Redis
+type RedisRevokedTokenStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisRevokedTokenStore creates a revoked token store whose keys start with prefix
+func NewRedisRevokedTokenStore(client *redis.Client, prefix string) *RedisRevokedTokenStore {
+	return &RedisRevokedTokenStore{client: client, prefix: prefix}
+}
+
+// RevokeToken blacklists a token ID until the given expiry
+func (s *RedisRevokedTokenStore) RevokeToken(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// Already expired, so it can't be used anyway
+		return nil
+	}
+	return s.client.Set(s.prefix+"revoked:"+jti, 1, ttl).Err()
+}
+
+// IsTokenRevoked reports whether a token ID is blacklisted
+func (s *RedisRevokedTokenStore) IsTokenRevoked(jti string) (bool, error) {
+	n, err := s.client.Exists(s.prefix + "revoked:" + jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RedisRefreshTokenStore implements RefreshTokenStore using Redis
+type RedisRefreshTokenStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisRefreshTokenStore creates a refresh token store whose keys start with prefix
+func NewRedisRefreshTokenStore(client *redis.Client, prefix string) *RedisRefreshTokenStore {
+	return &RedisRefreshTokenStore{client: client, prefix: prefix}
+}
+
+func (s *RedisRefreshTokenStore) tokenKey(tokenHash string) string {
+	return s.prefix + "refresh:" + tokenHash
+}
+
+// usedKey marks a token as used. It is separate from the token so marking
+// can be an atomic SETNX.
+func (s *RedisRefreshTokenStore) usedKey(tokenHash string) string {
+	return s.prefix + "refresh_used:" + tokenHash
+}
+
+func (s *RedisRefreshTokenStore) familyKey(familyID string) string {
+	return s.prefix + "refresh_family:" + familyID
+}
+
+func (s *RedisRefreshTokenStore) userKey(userID string) string {
+	return s.prefix + "refresh_user:" + userID
+}
+
+// CreateRefreshToken stores a new refresh token
+func (s *RedisRefreshTokenStore) CreateRefreshToken(token *RefreshToken) error {
+	ttl := time.Until(token.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	tokenCopy := *token
+	tokenCopy.CreatedAt = time.Now()
+	tokenCopy.UsedAt = nil
+
+	data, err := json.Marshal(&tokenCopy)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(s.tokenKey(token.TokenHash), data, ttl)
+		pipe.Del(s.usedKey(token.TokenHash))
+		// Tokens are issued with the same lifetime, so the newest one
+		// always outlives the rest of the set
+		pipe.SAdd(s.familyKey(token.FamilyID), token.TokenHash)
+		pipe.PExpire(s.familyKey(token.FamilyID), ttl)
+		pipe.SAdd(s.userKey(token.UserID), token.TokenHash)
+		pipe.PExpire(s.userKey(token.UserID), ttl)
+		return nil
+	})
+	return err
+}
+
+// GetRefreshToken retrieves a refresh token by its hash
+func (s *RedisRefreshTokenStore) GetRefreshToken(tokenHash string) (*RefreshToken, error) {
+	values, err := s.client.MGet(s.tokenKey(tokenHash), s.usedKey(tokenHash)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := values[0].(string)
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+
+	var token RefreshToken
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		return nil, err
+	}
+	token.TokenHash = tokenHash
+
+	if used, ok := values[1].(string); ok {
+		usedAt, err := time.Parse(time.RFC3339Nano, used)
+		if err != nil {
+			return nil, err
+		}
+		token.UsedAt = &usedAt
+	}
+
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed atomically marks a token as used
+func (s *RedisRefreshTokenStore) MarkRefreshTokenUsed(tokenHash string) error {
+	ttl, err := s.client.PTTL(s.tokenKey(tokenHash)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		// Missing keys report a negative TTL
+		return ErrRefreshTokenNotFound
+	}
+
+	marked, err := s.client.SetNX(s.usedKey(tokenHash), time.Now().Format(time.RFC3339Nano), ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return ErrRefreshTokenUsed
+	}
+	return nil
+}
+
+// DeleteRefreshTokenFamily deletes every token in a rotation family
+func (s *RedisRefreshTokenStore) DeleteRefreshTokenFamily(familyID string) error {
+	return s.deleteIndexed(s.familyKey(familyID))
+}
+
+// DeleteUserRefreshTokens deletes every refresh token owned by a user
+func (s *RedisRefreshTokenStore) DeleteUserRefreshTokens(userID string) error {
+	return s.deleteIndexed(s.userKey(userID))
+}
+
+// deleteIndexed deletes the tokens listed in an index set, and the set
+func (s *RedisRefreshTokenStore) deleteIndexed(indexKey string) error {
+	hashes, err := s.client.SMembers(indexKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{indexKey}
+	for _, hash := range hashes {
+		keys = append(keys, s.tokenKey(hash), s.usedKey(hash))
+	}
+	return s.client.Del(keys...).Err()
+}
+
+// RedisSessionStore implements SessionStore using Redis
+type RedisSessionStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisSessionStore creates a session store whose keys start with prefix
+func NewRedisSessionStore(client *redis.Client, prefix string) *RedisSessionStore {
+	return &RedisSessionStore{client: client, prefix: prefix}
+}
+
+// redisSession is a session as stored in Redis, including the fields that
+// are hidden from API responses
+type redisSession struct {
+	Session
+	TokenID        string    `json:"token_id"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+}
+
+func (s *RedisSessionStore) sessionKey(id string) string {
+	return s.prefix + "session:" + id
+}
+
+func (s *RedisSessionStore) userKey(userID string) string {
+	return s.prefix + "session_user:" + userID
+}
+
+// CreateSession stores a new session
+func (s *RedisSessionStore) CreateSession(session *Session) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	sessionCopy := *session
+	sessionCopy.CreatedAt = time.Now()
+
+	data, err := marshalSession(&sessionCopy)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(s.sessionKey(session.ID), data, ttl)
+		pipe.SAdd(s.userKey(session.UserID), session.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remember-me sessions last longer, so only ever extend the index
+	return s.extendTTL(s.userKey(session.UserID), ttl)
+}
+
+// GetSession retrieves a session by ID
+func (s *RedisSessionStore) GetSession(id string) (*Session, error) {
+	data, err := s.client.Get(s.sessionKey(id)).Result()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalSession(data)
+}
+
+// UpdateSession updates an existing session
+func (s *RedisSessionStore) UpdateSession(session *Session) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return ErrSessionNotFound
+	}
+
+	data, err := marshalSession(session)
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.client.SetXX(s.sessionKey(session.ID), data, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrSessionNotFound
+	}
+	return s.extendTTL(s.userKey(session.UserID), ttl)
+}
+
+// TouchSession sets an unexpired session's LastActiveAt and ExpiresAt. The
+// read and write are one optimistic transaction; when another write to the
+// session wins the race, the touch is dropped, as the session was just
+// written anyway.
+func (s *RedisSessionStore) TouchSession(id string, lastActiveAt, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return ErrSessionNotFound
+	}
+
+	key := s.sessionKey(id)
+	err := s.client.Watch(func(tx *redis.Tx) error {
+		data, err := tx.Get(key).Result()
+		if err == redis.Nil {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		session, err := unmarshalSession(data)
+		if err != nil {
+			return err
+		}
+		session.LastActiveAt = lastActiveAt
+		session.ExpiresAt = expiresAt
+
+		updated, err := marshalSession(session)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.Set(key, updated, ttl)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return s.extendTTL(s.userKey(session.UserID), ttl)
+	}, key)
+	if err == redis.TxFailedErr {
+		return nil
+	}
+	return err
+}
+
+// ListUserSessions returns all unexpired sessions owned by a user
+func (s *RedisSessionStore) ListUserSessions(userID string) ([]*Session, error) {
+	ids, err := s.client.SMembers(s.userKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	if len(ids) == 0 {
+		return sessions, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.sessionKey(id)
+	}
+	values, err := s.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []interface{}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		session, err := unmarshalSession(data)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	// Tidy the index; a failure only leaves stale IDs for the next call
+	if len(expired) > 0 {
+		s.client.SRem(s.userKey(userID), expired...)
+	}
+
+	return sessions, nil
+}
+
+// DeleteSession deletes a session by ID
+func (s *RedisSessionStore) DeleteSession(id string) error {
+	session, err := s.GetSession(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(s.sessionKey(id))
+		pipe.SRem(s.userKey(session.UserID), id)
+		return nil
+	})
+	return err
+}
+
+// DeleteUserSessions deletes every session owned by a user
+func (s *RedisSessionStore) DeleteUserSessions(userID string) error {
+	ids, err := s.client.SMembers(s.userKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{s.userKey(userID)}
+	for _, id := range ids {
+		keys = append(keys, s.sessionKey(id))
+	}
+	return s.client.Del(keys...).Err()
+}
+
+// CountSessions returns the number of unexpired sessions of all users.
+// Redis expires session keys itself, so it counts the keys, scanning in
+// batches rather than blocking the server with KEYS.
+func (s *RedisSessionStore) CountSessions() (int, error) {
+	count := 0
+	iter := s.client.Scan(0, s.sessionKey("*"), 1000).Iterator()
+	for iter.Next() {
+		count++
+	}
+	if err := iter.Err(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// extendTTL sets a key's expiry to ttl unless it already expires later
+func (s *RedisSessionStore) extendTTL(key string, ttl time.Duration) error {
+	current, err := s.client.PTTL(key).Result()
+	if err != nil {
+		return err
+	}
+	if current >= ttl {
+		return nil
+	}
+	return s.client.PExpire(key, ttl).Err()
+}
+
+func marshalSession(session *Session) ([]byte, error) {
+	return json.Marshal(&redisSession{
+		Session:        *session,
+		TokenID:        session.TokenID,
+		TokenExpiresAt: session.TokenExpiresAt,
+	})
+}
+
+func unmarshalSession(data string) (*Session, error) {
+	var stored redisSession
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, err
+	}
+
+	session := stored.Session
+	session.TokenID = stored.TokenID
+	session.TokenExpiresAt = stored.TokenExpiresAt
+	return &session, nil
+}
@@ -0,0 +1,278 @@
+//go:build integration
+
+// Tests against a real Redis, at REDIS_ADDR or localhost:6379:
+//
+//	go test -tags integration ./internal/storage
+//
+// Keys are written under a prefix unique to each test and removed
+// afterwards, but use a Redis that holds nothing of value.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+)
+
+// newTestRedis connects to the test Redis and returns a client and a key
+// prefix for the test
+func newTestRedis(t *testing.T) (*redis.Client, string) {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client, err := NewRedisClient(config.RedisConfig{
+		Addr:        addr,
+		Password:    os.Getenv("REDIS_PASSWORD"),
+		DialTimeout: 2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("integration tests need Redis: %v", err)
+	}
+
+	prefix := fmt.Sprintf("login-app-test:%s:%d:", t.Name(), time.Now().UnixNano())
+	t.Cleanup(func() {
+		iter := client.Scan(0, prefix+"*", 100).Iterator()
+		for iter.Next() {
+			client.Del(iter.Val())
+		}
+		client.Close()
+	})
+	return client, prefix
+}
+
+// assertTTL checks that a key expires within (0, max]
+func assertTTL(t *testing.T, client *redis.Client, key string, max time.Duration) {
+	t.Helper()
+
+	ttl, err := client.PTTL(key).Result()
+	if err != nil {
+		t.Fatalf("PTTL %s: %v", key, err)
+	}
+	if ttl <= 0 || ttl > max {
+		t.Errorf("TTL of %s = %v, want within (0, %v]", key, ttl, max)
+	}
+}
+
+func TestRedisSessionExpiresWithTTL(t *testing.T) {
+	client, prefix := newTestRedis(t)
+	store := NewRedisSessionStore(client, prefix)
+
+	session := &Session{ID: "s1", UserID: "u1", ExpiresAt: time.Now().Add(time.Second)}
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	assertTTL(t, client, store.sessionKey("s1"), time.Second)
+	assertTTL(t, client, store.userKey("u1"), time.Second)
+
+	if _, err := store.GetSession("s1"); err != nil {
+		t.Fatalf("GetSession before expiry: %v", err)
+	}
+	if n, err := store.CountSessions(); err != nil || n != 1 {
+		t.Errorf("CountSessions = %d, %v; want 1", n, err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	if _, err := store.GetSession("s1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession after expiry: got %v, want ErrSessionNotFound", err)
+	}
+	if n, err := store.CountSessions(); err != nil || n != 0 {
+		t.Errorf("CountSessions after expiry = %d, %v; want 0", n, err)
+	}
+}
+
+func TestRedisSessionUserIndexOutlivesSessions(t *testing.T) {
+	client, prefix := newTestRedis(t)
+	store := NewRedisSessionStore(client, prefix)
+
+	long := &Session{ID: "long", UserID: "u1", ExpiresAt: time.Now().Add(time.Hour), RememberMe: true}
+	short := &Session{ID: "short", UserID: "u1", ExpiresAt: time.Now().Add(time.Second)}
+	for _, session := range []*Session{long, short} {
+		if err := store.CreateSession(session); err != nil {
+			t.Fatalf("CreateSession(%s): %v", session.ID, err)
+		}
+	}
+
+	// The shorter session must not shorten the index of the longer one
+	ttl, err := client.PTTL(store.userKey("u1")).Result()
+	if err != nil {
+		t.Fatalf("PTTL: %v", err)
+	}
+	if ttl < 59*time.Minute {
+		t.Errorf("user index TTL = %v, want the remember-me session's hour", ttl)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	sessions, err := store.ListUserSessions("u1")
+	if err != nil {
+		t.Fatalf("ListUserSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "long" {
+		t.Errorf("ListUserSessions = %v, want only the unexpired session", sessions)
+	}
+	if members, _ := client.SMembers(store.userKey("u1")).Result(); len(members) != 1 {
+		t.Errorf("user index = %v, want the expired ID tidied away", members)
+	}
+}
+
+func TestRedisSessionTouchAndUpdateExtendTTL(t *testing.T) {
+	client, prefix := newTestRedis(t)
+	store := NewRedisSessionStore(client, prefix)
+
+	session := &Session{ID: "s1", UserID: "u1", ExpiresAt: time.Now().Add(time.Second)}
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	now := time.Now()
+	if err := store.TouchSession("s1", now, now.Add(time.Minute)); err != nil {
+		t.Fatalf("TouchSession: %v", err)
+	}
+	assertTTL(t, client, store.sessionKey("s1"), time.Minute)
+	if ttl, _ := client.PTTL(store.sessionKey("s1")).Result(); ttl < 50*time.Second {
+		t.Errorf("TTL after touch = %v, want about a minute", ttl)
+	}
+	if ttl, _ := client.PTTL(store.userKey("u1")).Result(); ttl < 50*time.Second {
+		t.Errorf("user index TTL after touch = %v, want about a minute", ttl)
+	}
+
+	stored, err := store.GetSession("s1")
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if !stored.LastActiveAt.Equal(now) {
+		t.Errorf("LastActiveAt = %v, want %v", stored.LastActiveAt, now)
+	}
+
+	stored.ExpiresAt = time.Now().Add(2 * time.Minute)
+	if err := store.UpdateSession(stored); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	if ttl, _ := client.PTTL(store.sessionKey("s1")).Result(); ttl < 110*time.Second {
+		t.Errorf("TTL after update = %v, want about two minutes", ttl)
+	}
+
+	if err := store.TouchSession("missing", now, now.Add(time.Minute)); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("touching a missing session: got %v, want ErrSessionNotFound", err)
+	}
+	stored.ExpiresAt = time.Now().Add(-time.Second)
+	if err := store.UpdateSession(stored); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("updating with a past expiry: got %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestRedisRefreshTokenTTLAndReuse(t *testing.T) {
+	client, prefix := newTestRedis(t)
+	store := NewRedisRefreshTokenStore(client, prefix)
+
+	token := &RefreshToken{TokenHash: "h1", UserID: "u1", FamilyID: "f1", ExpiresAt: time.Now().Add(time.Second)}
+	if err := store.CreateRefreshToken(token); err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	for _, key := range []string{store.tokenKey("h1"), store.familyKey("f1"), store.userKey("u1")} {
+		assertTTL(t, client, key, time.Second)
+	}
+
+	if err := store.MarkRefreshTokenUsed("h1"); err != nil {
+		t.Fatalf("MarkRefreshTokenUsed: %v", err)
+	}
+	// The used marker must not outlive the token
+	assertTTL(t, client, store.usedKey("h1"), time.Second)
+	if err := store.MarkRefreshTokenUsed("h1"); !errors.Is(err, ErrRefreshTokenUsed) {
+		t.Errorf("second use: got %v, want ErrRefreshTokenUsed", err)
+	}
+	stored, err := store.GetRefreshToken("h1")
+	if err != nil {
+		t.Fatalf("GetRefreshToken: %v", err)
+	}
+	if stored.UsedAt == nil {
+		t.Error("UsedAt not set after use")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	if _, err := store.GetRefreshToken("h1"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("GetRefreshToken after expiry: got %v, want ErrRefreshTokenNotFound", err)
+	}
+	if err := store.MarkRefreshTokenUsed("h1"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("MarkRefreshTokenUsed after expiry: got %v, want ErrRefreshTokenNotFound", err)
+	}
+	if n, _ := client.Exists(store.usedKey("h1"), store.familyKey("f1"), store.userKey("u1")).Result(); n != 0 {
+		t.Errorf("%d marker or index keys outlived the token", n)
+	}
+}
+
+func TestRedisRefreshTokenFamilyDelete(t *testing.T) {
+	client, prefix := newTestRedis(t)
+	store := NewRedisRefreshTokenStore(client, prefix)
+
+	expiresAt := time.Now().Add(time.Minute)
+	for _, token := range []*RefreshToken{
+		{TokenHash: "h1", UserID: "u1", FamilyID: "f1", ExpiresAt: expiresAt},
+		{TokenHash: "h2", UserID: "u1", FamilyID: "f1", ExpiresAt: expiresAt},
+		{TokenHash: "h3", UserID: "u1", FamilyID: "f2", ExpiresAt: expiresAt},
+	} {
+		if err := store.CreateRefreshToken(token); err != nil {
+			t.Fatalf("CreateRefreshToken(%s): %v", token.TokenHash, err)
+		}
+	}
+
+	if err := store.DeleteRefreshTokenFamily("f1"); err != nil {
+		t.Fatalf("DeleteRefreshTokenFamily: %v", err)
+	}
+	for _, hash := range []string{"h1", "h2"} {
+		if _, err := store.GetRefreshToken(hash); !errors.Is(err, ErrRefreshTokenNotFound) {
+			t.Errorf("%s after family delete: got %v, want ErrRefreshTokenNotFound", hash, err)
+		}
+	}
+	if _, err := store.GetRefreshToken("h3"); err != nil {
+		t.Errorf("other family's token: %v", err)
+	}
+
+	// A token created already expired is not stored
+	if err := store.CreateRefreshToken(&RefreshToken{TokenHash: "old", UserID: "u1", FamilyID: "f3",
+		ExpiresAt: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatalf("CreateRefreshToken(expired): %v", err)
+	}
+	if n, _ := client.Exists(store.tokenKey("old")).Result(); n != 0 {
+		t.Error("expired token was stored")
+	}
+}
+
+func TestRedisRevocationExpiresWithToken(t *testing.T) {
+	client, prefix := newTestRedis(t)
+	store := NewRedisRevokedTokenStore(client, prefix)
+
+	if err := store.RevokeToken("jti-1", time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	assertTTL(t, client, prefix+"revoked:jti-1", time.Second)
+	if revoked, err := store.IsTokenRevoked("jti-1"); err != nil || !revoked {
+		t.Errorf("IsTokenRevoked = %v, %v; want true", revoked, err)
+	}
+
+	// Revoking a token that has already expired stores nothing
+	if err := store.RevokeToken("jti-2", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("RevokeToken(expired): %v", err)
+	}
+	if revoked, _ := store.IsTokenRevoked("jti-2"); revoked {
+		t.Error("expired token was blacklisted")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	if revoked, err := store.IsTokenRevoked("jti-1"); err != nil || revoked {
+		t.Errorf("IsTokenRevoked after expiry = %v, %v; want false", revoked, err)
+	}
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenUsed     = errors.New("refresh token already used")
+)
+
+// RefreshToken represents a long-lived opaque token used to obtain new access tokens.
+// Tokens issued by rotating the same login share a FamilyID.
+type RefreshToken struct {
+	TokenHash string     `json:"-"` // Never include in JSON
+	UserID    string     `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// RefreshTokenStore defines the interface for refresh token storage operations
+type RefreshTokenStore interface {
+	// CreateRefreshToken stores a new refresh token
+	CreateRefreshToken(token *RefreshToken) error
+
+	// GetRefreshToken retrieves a refresh token by its hash
+	GetRefreshToken(tokenHash string) (*RefreshToken, error)
+
+	// MarkRefreshTokenUsed atomically marks a token as used, returning
+	// ErrRefreshTokenUsed if it was already used
+	MarkRefreshTokenUsed(tokenHash string) error
+
+	// DeleteRefreshTokenFamily deletes every token in a rotation family
+	DeleteRefreshTokenFamily(familyID string) error
+
+	// DeleteUserRefreshTokens deletes every refresh token owned by a user
+	DeleteUserRefreshTokens(userID string) error
+}
+
+// MemoryRefreshTokenStore implements RefreshTokenStore using in-memory storage
+type MemoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshToken // token hash -> token
+}
+
+// NewMemoryRefreshTokenStore creates a new in-memory refresh token store
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		tokens: make(map[string]*RefreshToken),
+	}
+}
+
+// CreateRefreshToken stores a new refresh token
+func (s *MemoryRefreshTokenStore) CreateRefreshToken(token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenCopy := *token
+	tokenCopy.CreatedAt = time.Now()
+	tokenCopy.UsedAt = nil
+
+	s.tokens[token.TokenHash] = &tokenCopy
+	return nil
+}
+
+// GetRefreshToken retrieves a refresh token by its hash
+func (s *MemoryRefreshTokenStore) GetRefreshToken(tokenHash string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.tokens[tokenHash]
+	if !exists {
+		return nil, ErrRefreshTokenNotFound
+	}
+
+	tokenCopy := *token
+	return &tokenCopy, nil
+}
+
+// MarkRefreshTokenUsed atomically marks a token as used
+func (s *MemoryRefreshTokenStore) MarkRefreshTokenUsed(tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.tokens[tokenHash]
+	if !exists {
+		return ErrRefreshTokenNotFound
+	}
+
+	if token.UsedAt != nil {
+		return ErrRefreshTokenUsed
+	}
+
+	now := time.Now()
+	token.UsedAt = &now
+	return nil
+}
+
+// DeleteRefreshTokenFamily deletes every token in a rotation family
+func (s *MemoryRefreshTokenStore) DeleteRefreshTokenFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.FamilyID == familyID {
+			delete(s.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+// DeleteUserRefreshTokens deletes every refresh token owned by a user
+func (s *MemoryRefreshTokenStore) DeleteUserRefreshTokens(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.UserID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+// Sweep deletes expired and already-used tokens and returns how many were
+// removed. Rotation leaves a used token behind on every refresh, so without
+// sweeping the store grows for as long as the process runs. A used token
+// replayed after it has been swept is rejected as unknown instead of
+// revoking its family.
+func (s *MemoryRefreshTokenStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for hash, token := range s.tokens {
+		if token.UsedAt != nil || now.After(token.ExpiresAt) {
+			delete(s.tokens, hash)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// RunSweeper runs Sweep every interval until ctx is done
+func (s *MemoryRefreshTokenStore) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenSweepRemovesExpiredAndUsedTokens(t *testing.T) {
+	store := NewMemoryRefreshTokenStore()
+	for hash, expiresAt := range map[string]time.Time{
+		"live":    time.Now().Add(time.Hour),
+		"used":    time.Now().Add(time.Hour),
+		"expired": time.Now().Add(-time.Second),
+	} {
+		if err := store.CreateRefreshToken(&RefreshToken{TokenHash: hash, UserID: "user", FamilyID: "family", ExpiresAt: expiresAt}); err != nil {
+			t.Fatalf("CreateRefreshToken(%s): %v", hash, err)
+		}
+	}
+	if err := store.MarkRefreshTokenUsed("used"); err != nil {
+		t.Fatalf("MarkRefreshTokenUsed: %v", err)
+	}
+
+	if removed := store.Sweep(); removed != 2 {
+		t.Errorf("Sweep removed %d tokens, want 2", removed)
+	}
+	if _, err := store.GetRefreshToken("live"); err != nil {
+		t.Errorf("live token: %v", err)
+	}
+	for _, hash := range []string{"used", "expired"} {
+		if _, err := store.GetRefreshToken(hash); !errors.Is(err, ErrRefreshTokenNotFound) {
+			t.Errorf("%s token: got %v, want ErrRefreshTokenNotFound", hash, err)
+		}
+	}
+}
+
+func TestRefreshTokenSweeperRunsUntilCancelled(t *testing.T) {
+	store := NewMemoryRefreshTokenStore()
+	if err := store.CreateRefreshToken(&RefreshToken{TokenHash: "expired", ExpiresAt: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.RunSweeper(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := store.GetRefreshToken("expired"); errors.Is(err, ErrRefreshTokenNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sweeper didn't remove the expired token")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper didn't stop after cancel")
+	}
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// ErrTransient marks store errors that may go away on their own, such as a
+// deadlock or a dropped connection. Stores wrap such errors with it, for
+// example fmt.Errorf("%w: %w", ErrTransient, err), so they are retried.
+var ErrTransient = errors.New("transient storage error")
+
+// IsTransient reports whether an operation that failed with err may
+// succeed if retried: errors marked with ErrTransient, a database driver's
+// bad connection, temporary errors and network timeouts. Not-found and
+// conflict errors, and cancelled contexts, never are.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrUserNotFound),
+		errors.Is(err, ErrUserExists),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrTransient), errors.Is(err, driver.ErrBadConn):
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) {
+		return temporary.Temporary()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// RetryPolicy retries operations that fail with transient errors up to
+// MaxAttempts times in all. The wait before each retry is random, up to
+// BaseDelay doubled for every earlier retry and capped at MaxDelay, so
+// clients that collided don't collide again.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+
+	// IsTransient classifies errors; nil uses the package's IsTransient
+	IsTransient func(error) bool
+}
+
+// Do runs fn until it succeeds, fails with an error that isn't transient,
+// runs out of attempts or the context is done, and returns its last error
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	isTransient := p.IsTransient
+	if isTransient == nil {
+		isTransient = IsTransient
+	}
+
+	delay := p.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !isTransient(err) {
+			return err
+		}
+
+		wait := time.Duration(0)
+		if delay > 0 {
+			wait = rand.N(delay) + 1
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		if delay *= 2; delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+// RetryingUserStore wraps a UserStore and retries its operations on
+// transient errors. Writes are retried too: a write whose reply was lost
+// may have been applied, so a retried CreateUser can report ErrUserExists.
+// Ping isn't retried, so health checks see the store as it is.
+type RetryingUserStore struct {
+	next   UserStore
+	policy RetryPolicy
+}
+
+// NewRetryingUserStore retries store's operations according to policy
+func NewRetryingUserStore(store UserStore, policy RetryPolicy) *RetryingUserStore {
+	return &RetryingUserStore{next: store, policy: policy}
+}
+
+func (s *RetryingUserStore) CreateUser(ctx context.Context, user *User) error {
+	return s.policy.Do(ctx, func() error {
+		return s.next.CreateUser(ctx, user)
+	})
+}
+
+func (s *RetryingUserStore) GetUserByID(ctx context.Context, id string) (user *User, err error) {
+	err = s.policy.Do(ctx, func() error {
+		user, err = s.next.GetUserByID(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+func (s *RetryingUserStore) GetUserByEmail(ctx context.Context, email string) (user *User, err error) {
+	err = s.policy.Do(ctx, func() error {
+		user, err = s.next.GetUserByEmail(ctx, email)
+		return err
+	})
+	return user, err
+}
+
+func (s *RetryingUserStore) GetUserByUsername(ctx context.Context, username string) (user *User, err error) {
+	err = s.policy.Do(ctx, func() error {
+		user, err = s.next.GetUserByUsername(ctx, username)
+		return err
+	})
+	return user, err
+}
+
+func (s *RetryingUserStore) GetUserByProvider(ctx context.Context, provider, providerUserID string) (user *User, err error) {
+	err = s.policy.Do(ctx, func() error {
+		user, err = s.next.GetUserByProvider(ctx, provider, providerUserID)
+		return err
+	})
+	return user, err
+}
+
+func (s *RetryingUserStore) UpdateUser(ctx context.Context, user *User) error {
+	return s.policy.Do(ctx, func() error {
+		return s.next.UpdateUser(ctx, user)
+	})
+}
+
+func (s *RetryingUserStore) RecordLogin(ctx context.Context, id string, at time.Time) error {
+	return s.policy.Do(ctx, func() error {
+		return s.next.RecordLogin(ctx, id, at)
+	})
+}
+
+func (s *RetryingUserStore) DeleteUser(ctx context.Context, id string) error {
+	return s.policy.Do(ctx, func() error {
+		return s.next.DeleteUser(ctx, id)
+	})
+}
+
+func (s *RetryingUserStore) SoftDeleteUser(ctx context.Context, id string) error {
+	return s.policy.Do(ctx, func() error {
+		return s.next.SoftDeleteUser(ctx, id)
+	})
+}
+
+func (s *RetryingUserStore) RestoreUser(ctx context.Context, id string) error {
+	return s.policy.Do(ctx, func() error {
+		return s.next.RestoreUser(ctx, id)
+	})
+}
+
+func (s *RetryingUserStore) ListUsers(ctx context.Context) (users []*User, err error) {
+	err = s.policy.Do(ctx, func() error {
+		users, err = s.next.ListUsers(ctx)
+		return err
+	})
+	return users, err
+}
+
+func (s *RetryingUserStore) ListUsersPaged(ctx context.Context, opts ListUsersOptions) (users []*User, total int, err error) {
+	err = s.policy.Do(ctx, func() error {
+		users, total, err = s.next.ListUsersPaged(ctx, opts)
+		return err
+	})
+	return users, total, err
+}
+
+func (s *RetryingUserStore) SearchUsers(ctx context.Context, query string, limit int) (users []*User, err error) {
+	err = s.policy.Do(ctx, func() error {
+		users, err = s.next.SearchUsers(ctx, query, limit)
+		return err
+	})
+	return users, err
+}
+
+func (s *RetryingUserStore) UserStats(ctx context.Context, registeredSince []time.Time) (stats *UserStats, err error) {
+	err = s.policy.Do(ctx, func() error {
+		stats, err = s.next.UserStats(ctx, registeredSince)
+		return err
+	})
+	return stats, err
+}
+
+func (s *RetryingUserStore) Ping(ctx context.Context) error {
+	return s.next.Ping(ctx)
+}
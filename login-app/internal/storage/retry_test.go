@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// flakyStore fails the first failures calls to GetUserByID and
+// CreateUser with err
+type flakyStore struct {
+	UserStore
+	err      error
+	failures int
+	calls    int
+}
+
+func (s *flakyStore) fail() error {
+	s.calls++
+	if s.calls <= s.failures {
+		return s.err
+	}
+	return nil
+}
+
+func (s *flakyStore) GetUserByID(ctx context.Context, id string) (*User, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.UserStore.GetUserByID(ctx, id)
+}
+
+func (s *flakyStore) CreateUser(ctx context.Context, user *User) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.UserStore.CreateUser(ctx, user)
+}
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestRetryingUserStoreSucceedsOnSecondAttempt(t *testing.T) {
+	deadlock := fmt.Errorf("%w: deadlock detected", ErrTransient)
+	flaky := &flakyStore{UserStore: NewMemoryUserStore(), err: deadlock, failures: 1}
+	store := NewRetryingUserStore(flaky, testRetryPolicy)
+	ctx := context.Background()
+
+	if err := store.CreateUser(ctx, &User{ID: "alice", Email: "alice@example.com", Username: "alice"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if flaky.calls != 2 {
+		t.Errorf("CreateUser made %d calls, want 2", flaky.calls)
+	}
+
+	flaky.calls = 0
+	user, err := store.GetUserByID(ctx, "alice")
+	if err != nil || user.ID != "alice" {
+		t.Fatalf("GetUserByID = %+v, %v", user, err)
+	}
+	if flaky.calls != 2 {
+		t.Errorf("GetUserByID made %d calls, want 2", flaky.calls)
+	}
+}
+
+func TestRetryingUserStoreGivesUp(t *testing.T) {
+	deadlock := fmt.Errorf("%w: deadlock detected", ErrTransient)
+	flaky := &flakyStore{UserStore: NewMemoryUserStore(), err: deadlock, failures: 10}
+	store := NewRetryingUserStore(flaky, testRetryPolicy)
+
+	if _, err := store.GetUserByID(context.Background(), "alice"); !errors.Is(err, ErrTransient) {
+		t.Errorf("got %v, want the transient error", err)
+	}
+	if flaky.calls != testRetryPolicy.MaxAttempts {
+		t.Errorf("made %d calls, want %d", flaky.calls, testRetryPolicy.MaxAttempts)
+	}
+}
+
+func TestRetryingUserStoreDoesNotRetryPermanentErrors(t *testing.T) {
+	for _, err := range []error{ErrUserNotFound, ErrUserExists, errors.New("syntax error")} {
+		flaky := &flakyStore{UserStore: NewMemoryUserStore(), err: err, failures: 10}
+		store := NewRetryingUserStore(flaky, testRetryPolicy)
+
+		if _, got := store.GetUserByID(context.Background(), "alice"); !errors.Is(got, err) {
+			t.Errorf("got %v, want %v", got, err)
+		}
+		if flaky.calls != 1 {
+			t.Errorf("%v: made %d calls, want 1", err, flaky.calls)
+		}
+	}
+}
+
+func TestRetryStopsWhenContextIsDone(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := policy.Do(ctx, func() error {
+		calls++
+		return ErrTransient
+	})
+	if !errors.Is(err, ErrTransient) || calls != 1 {
+		t.Errorf("Do = %v after %d calls, want the transient error after 1", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do waited %s after the context was done", elapsed)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for err, want := range map[error]bool{
+		nil:                                  false,
+		ErrUserNotFound:                      false,
+		ErrUserExists:                        false,
+		context.Canceled:                     false,
+		fmt.Errorf("%w: lock", ErrTransient): true,
+		&net.OpError{Op: "read", Err: timeoutError{}}: true,
+		errors.New("constraint violation"):            false,
+	} {
+		if got := IsTransient(err); got != want {
+			t.Errorf("IsTransient(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
+
+// timeoutError is a network error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevokedTokenStore defines the interface for the access token blacklist.
+// Entries only need to live until the revoked token would have expired anyway.
+type RevokedTokenStore interface {
+	// RevokeToken blacklists a token ID until the given expiry
+	RevokeToken(jti string, expiresAt time.Time) error
+
+	// IsTokenRevoked reports whether a token ID is blacklisted
+	IsTokenRevoked(jti string) (bool, error)
+}
+
+// MemoryRevokedTokenStore implements RevokedTokenStore using in-memory storage
+type MemoryRevokedTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time // jti -> token expiry
+}
+
+// NewMemoryRevokedTokenStore creates a new in-memory revoked token store
+func NewMemoryRevokedTokenStore() *MemoryRevokedTokenStore {
+	return &MemoryRevokedTokenStore{
+		tokens: make(map[string]time.Time),
+	}
+}
+
+// RevokeToken blacklists a token ID until the given expiry
+func (s *MemoryRevokedTokenStore) RevokeToken(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+// IsTokenRevoked reports whether a token ID is blacklisted
+func (s *MemoryRevokedTokenStore) IsTokenRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, revoked := s.tokens[jti]
+	return revoked, nil
+}
+
+// Sweep evicts entries whose tokens have expired and returns how many were removed
+func (s *MemoryRevokedTokenStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for jti, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, jti)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// RunSweeper runs Sweep every interval until ctx is done
+func (s *MemoryRevokedTokenStore) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/audit"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// Session represents a login on one device. Every access token issued from
+// the login, including ones obtained by refreshing, carries the session ID.
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// LastActiveAt is when the session was last used, as far as it is
+	// tracked; only sliding sessions record requests between refreshes
+	LastActiveAt time.Time `json:"last_active_at"`
+
+	// Device is the client parsed from UserAgent
+	Device audit.Device `json:"device"`
+
+	// RememberMe sessions get long-lived access tokens, also when refreshed
+	RememberMe bool `json:"remember_me"`
+
+	// The most recently issued access token
+	TokenID        string    `json:"-"`
+	TokenExpiresAt time.Time `json:"-"`
+}
+
+// SessionStore defines the interface for session storage operations
+type SessionStore interface {
+	// CreateSession stores a new session
+	CreateSession(session *Session) error
+
+	// GetSession retrieves a session by ID
+	GetSession(id string) (*Session, error)
+
+	// UpdateSession updates an existing session
+	UpdateSession(session *Session) error
+
+	// TouchSession sets an unexpired session's LastActiveAt and ExpiresAt
+	// without touching its other fields, so it can't undo a concurrent
+	// update
+	TouchSession(id string, lastActiveAt, expiresAt time.Time) error
+
+	// ListUserSessions returns all sessions owned by a user
+	ListUserSessions(userID string) ([]*Session, error)
+
+	// DeleteSession deletes a session by ID
+	DeleteSession(id string) error
+
+	// DeleteUserSessions deletes every session owned by a user
+	DeleteUserSessions(userID string) error
+
+	// CountSessions returns the number of unexpired sessions of all users
+	CountSessions() (int, error)
+}
+
+// SessionStores groups the stores holding login state. Replicas behind a
+// load balancer must share them; a nil store is replaced by an in-memory one.
+type SessionStores struct {
+	Sessions      SessionStore
+	RefreshTokens RefreshTokenStore
+	RevokedTokens RevokedTokenStore
+}
+
+// MemorySessionStore implements SessionStore using in-memory storage
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+// NewMemorySessionStore creates a new in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*Session),
+	}
+}
+
+// CreateSession stores a new session
+func (s *MemorySessionStore) CreateSession(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessionCopy := *session
+	sessionCopy.CreatedAt = time.Now()
+
+	s.sessions[session.ID] = &sessionCopy
+	return nil
+}
+
+// GetSession retrieves a session by ID
+func (s *MemorySessionStore) GetSession(id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[id]
+	if !exists || time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+
+	sessionCopy := *session
+	return &sessionCopy, nil
+}
+
+// UpdateSession updates an existing session
+func (s *MemorySessionStore) UpdateSession(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[session.ID]; !exists {
+		return ErrSessionNotFound
+	}
+
+	sessionCopy := *session
+	s.sessions[session.ID] = &sessionCopy
+	return nil
+}
+
+// TouchSession sets an unexpired session's LastActiveAt and ExpiresAt
+func (s *MemorySessionStore) TouchSession(id string, lastActiveAt, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists || time.Now().After(session.ExpiresAt) {
+		return ErrSessionNotFound
+	}
+
+	sessionCopy := *session
+	sessionCopy.LastActiveAt = lastActiveAt
+	sessionCopy.ExpiresAt = expiresAt
+	s.sessions[id] = &sessionCopy
+	return nil
+}
+
+// ListUserSessions returns all unexpired sessions owned by a user
+func (s *MemorySessionStore) ListUserSessions(userID string) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	sessions := make([]*Session, 0)
+	for _, session := range s.sessions {
+		if session.UserID == userID && now.Before(session.ExpiresAt) {
+			sessionCopy := *session
+			sessions = append(sessions, &sessionCopy)
+		}
+	}
+
+	return sessions, nil
+}
+
+// DeleteSession deletes a session by ID
+func (s *MemorySessionStore) DeleteSession(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[id]; !exists {
+		return ErrSessionNotFound
+	}
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// DeleteUserSessions deletes every session owned by a user
+func (s *MemorySessionStore) DeleteUserSessions(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+// CountSessions returns the number of unexpired sessions of all users
+func (s *MemorySessionStore) CountSessions() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, session := range s.sessions {
+		if now.Before(session.ExpiresAt) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Sweep evicts expired sessions and returns how many were removed
+func (s *MemorySessionStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for id, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// RunSweeper runs Sweep every interval until ctx is done
+func (s *MemorySessionStore) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
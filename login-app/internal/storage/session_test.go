@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionSweepRemovesExpiredSessions(t *testing.T) {
+	store := NewMemorySessionStore()
+	for id, expiresAt := range map[string]time.Time{
+		"live":    time.Now().Add(time.Hour),
+		"expired": time.Now().Add(-time.Second),
+	} {
+		if err := store.CreateSession(&Session{ID: id, UserID: "user", CreatedAt: time.Now(), ExpiresAt: expiresAt}); err != nil {
+			t.Fatalf("CreateSession(%s): %v", id, err)
+		}
+	}
+
+	// Expired sessions are hidden before they are swept
+	if sessions, _ := store.ListUserSessions("user"); len(sessions) != 1 || sessions[0].ID != "live" {
+		t.Errorf("ListUserSessions = %v, want only the live session", sessions)
+	}
+
+	if removed := store.Sweep(); removed != 1 {
+		t.Errorf("Sweep removed %d sessions, want 1", removed)
+	}
+	if _, err := store.GetSession("expired"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expired session: got %v, want ErrSessionNotFound", err)
+	}
+	if _, err := store.GetSession("live"); err != nil {
+		t.Errorf("live session: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenExpired  = errors.New("token expired")
+)
+
+// Token purposes used by the single-use token flows
+const (
+	TokenPurposeEmailChange   = "email_change"
+	TokenPurposeEmailVerify   = "email_verify"
+	TokenPurposeMagicLink     = "magic_link"
+	TokenPurposeOAuthLink     = "oauth_link"
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeTwoFactor     = "two_factor"
+	TokenPurposeUnlock        = "unlock"
+
+	// Passkey ceremonies keep the WebAuthn session data in Data
+	TokenPurposePasskeyLogin        = "passkey_login"
+	TokenPurposePasskeyRegistration = "passkey_registration"
+)
+
+// VerificationToken represents a single-use, time-limited token bound to a user
+type VerificationToken struct {
+	Token     string    `json:"-"` // Plaintext is only held by the caller
+	Purpose   string    `json:"purpose"`
+	UserID    string    `json:"user_id"`
+	Data      string    `json:"data,omitempty"` // Purpose-specific payload
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// VerificationTokenStore defines the interface for single-use token storage
+type VerificationTokenStore interface {
+	// SaveToken stores a new token
+	SaveToken(token *VerificationToken) error
+
+	// ConsumeToken looks up a token for the given purpose and deletes it,
+	// so a token can only ever be used once
+	ConsumeToken(token, purpose string) (*VerificationToken, error)
+
+	// DeleteUserTokens removes all tokens of a purpose issued to a user.
+	// An empty purpose removes tokens of every purpose.
+	DeleteUserTokens(userID, purpose string) error
+}
+
+// MemoryVerificationTokenStore implements VerificationTokenStore using in-memory storage
+type MemoryVerificationTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*VerificationToken // token hash -> token
+}
+
+// NewMemoryVerificationTokenStore creates a new in-memory verification token store
+func NewMemoryVerificationTokenStore() *MemoryVerificationTokenStore {
+	return &MemoryVerificationTokenStore{
+		tokens: make(map[string]*VerificationToken),
+	}
+}
+
+// SaveToken stores a new token
+func (s *MemoryVerificationTokenStore) SaveToken(token *VerificationToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only the hash of the token is kept as the lookup key
+	tokenCopy := *token
+	tokenCopy.Token = ""
+	if tokenCopy.CreatedAt.IsZero() {
+		tokenCopy.CreatedAt = time.Now()
+	}
+
+	s.tokens[HashToken(token.Token)] = &tokenCopy
+	return nil
+}
+
+// ConsumeToken looks up and deletes a token
+func (s *MemoryVerificationTokenStore) ConsumeToken(token, purpose string) (*VerificationToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := HashToken(token)
+	stored, exists := s.tokens[key]
+	if !exists || stored.Purpose != purpose {
+		return nil, ErrTokenNotFound
+	}
+
+	delete(s.tokens, key)
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	tokenCopy := *stored
+	tokenCopy.Token = token
+	return &tokenCopy, nil
+}
+
+// DeleteUserTokens removes all tokens of a purpose issued to a user
+func (s *MemoryVerificationTokenStore) DeleteUserTokens(userID, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, stored := range s.tokens {
+		if stored.UserID == userID && (purpose == "" || stored.Purpose == purpose) {
+			delete(s.tokens, key)
+		}
+	}
+
+	return nil
+}
+
+// HashToken returns the SHA-256 hex digest of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
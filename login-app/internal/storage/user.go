@@ -1,9 +1,15 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"maps"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
 )
 
 var (
@@ -15,46 +21,248 @@ var (
 // User represents a user in the system
 type User struct {
 	ID           string    `json:"id"`
+	OrgID        string    `json:"org_id,omitempty"` // Organization the account belongs to; empty for the default
 	Email        string    `json:"email"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"` // Never include in JSON
+	Username     string    `json:"username"` // Display casing as entered
+	PasswordHash string    `json:"-"`        // Never include in JSON
 	FirstName    string    `json:"first_name"`
 	LastName     string    `json:"last_name"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	IsActive     bool      `json:"is_active"`
+	Role         string    `json:"role"`
+
+	// DeletedAt is set when the account is soft-deleted. Deleted users are
+	// hidden from lookups and their email and username may be reused.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// LastLoginAt is when the user last logged in; only RecordLogin sets it
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+
+	// NormalizedUsername is the case-folded username used for uniqueness
+	NormalizedUsername string `json:"-"`
+
+	// MustChangePassword is set when an administrator resets the password
+	// and cleared once the user chooses a new one
+	MustChangePassword bool `json:"must_change_password"`
+
+	// VerificationPending is set on accounts registered while email
+	// verification is required, and cleared with EmailVerifiedAt set once
+	// the address is verified. Accounts created before then are unaffected.
+	VerificationPending bool       `json:"verification_pending,omitempty"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at,omitempty"`
+
+	// Brute-force protection
+	FailedAttempts int       `json:"-"`
+	LockedUntil    time.Time `json:"-"`
+
+	// Two-factor authentication
+	TOTPSecret         string   `json:"-"` // Encrypted at rest
+	TOTPEnabled        bool     `json:"totp_enabled"`
+	TOTPLastStep       int64    `json:"-"` // Last accepted time step, prevents code replay
+	RecoveryCodeHashes []string `json:"-"`
+
+	// Security questions for account recovery, and brute-force protection
+	// for answering them
+	SecurityQuestions   []QuestionAnswer `json:"-"`
+	RecoveryAttempts    int              `json:"-"`
+	RecoveryLockedUntil time.Time        `json:"-"`
+
+	// LinkedProviders lists external identity providers attached to the account
+	LinkedProviders []LinkedProvider `json:"linked_providers,omitempty"`
+
+	// WebAuthnCredentials are the passkeys registered for passwordless login
+	WebAuthnCredentials []WebAuthnCredential `json:"-"`
+
+	// Metadata is application data set by administrators, such as a plan
+	// tier or feature flags. Allowlisted keys are copied into access tokens.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// NormalizeEmail returns the canonical form of an email address used for storage and lookups
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeUsername returns the case-folded form of a username used for uniqueness
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// orgKey scopes a normalized email or username to an organization for the
+// uniqueness indexes, so the same email may exist once per organization
+func orgKey(orgID, value string) string {
+	return orgID + "\x00" + value
+}
+
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Sort fields accepted by ListUsersOptions.SortBy
+const (
+	SortByCreatedAt = "created_at"
+	SortByEmail     = "email"
+	SortByUsername  = "username"
+)
+
+// ListUsersOptions controls paging, filtering and ordering for ListUsersPaged
+type ListUsersOptions struct {
+	Limit    int    // Maximum number of users to return; zero means no limit
+	Offset   int    // Number of matching users to skip
+	Query    string // Case-insensitive substring matched against email and username
+	SortBy   string // One of the SortBy constants; defaults to SortByCreatedAt
+	SortDesc bool
+
+	IncludeDeleted bool // Also list soft-deleted users
+}
+
+// UserStats are aggregate counts over an organization's users
+type UserStats struct {
+	Total    int
+	Active   int
+	Verified int // Users with EmailVerifiedAt set
+
+	// Registered[i] counts the users created at or after registeredSince[i]
+	Registered []int
+}
+
+// add counts a user towards the stats unless it belongs to another
+// organization or is soft-deleted
+func (st *UserStats) add(user *User, orgID string, registeredSince []time.Time) {
+	if user.OrgID != orgID || user.DeletedAt != nil {
+		return
+	}
+	st.Total++
+	if user.IsActive {
+		st.Active++
+	}
+	if user.EmailVerifiedAt != nil {
+		st.Verified++
+	}
+	for i, since := range registeredSince {
+		if !user.CreatedAt.Before(since) {
+			st.Registered[i]++
+		}
+	}
+}
+
+// QuestionAnswer is a security question and the hash of its answer
+type QuestionAnswer struct {
+	Question   string `json:"question"`
+	AnswerHash string `json:"-"`
+}
+
+// LinkedProvider records an external identity provider linked to a user
+type LinkedProvider struct {
+	Provider       string    `json:"provider"`
+	ProviderUserID string    `json:"provider_user_id"`
+	LinkedAt       time.Time `json:"linked_at"`
+}
+
+// WebAuthnCredential is a passkey registered to a user
+type WebAuthnCredential struct {
+	ID              []byte   `json:"id"`
+	PublicKey       []byte   `json:"public_key"` // COSE-encoded
+	AttestationType string   `json:"attestation_type"`
+	Transports      []string `json:"transports,omitempty"`
+	AAGUID          []byte   `json:"aaguid"`
+
+	// SignCount is the authenticator's signature counter from the last
+	// login; a counter that goes backwards suggests a cloned authenticator
+	SignCount      uint32 `json:"sign_count"`
+	BackupEligible bool   `json:"backup_eligible"`
+	BackupState    bool   `json:"backup_state"`
+
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
 }
 
-// UserStore defines the interface for user storage operations
+// HasProvider reports whether the user is linked to the given provider identity
+func (u *User) HasProvider(provider, providerUserID string) bool {
+	for _, p := range u.LinkedProviders {
+		if p.Provider == provider && p.ProviderUserID == providerUserID {
+			return true
+		}
+	}
+	return false
+}
+
+// UserStore defines the interface for user storage operations. Every method
+// takes the caller's context so implementations backed by a database can
+// honour cancellation and deadlines.
+//
+// Emails and usernames are unique within an organization. Lookups by email
+// or username, listings, soft deletes and restores only see users of the
+// organization in the context (tenant.FromContext); IDs are unique across
+// organizations, so lookups by ID see every user.
 type UserStore interface {
 	// CreateUser creates a new user
-	CreateUser(user *User) error
+	CreateUser(ctx context.Context, user *User) error
 
 	// GetUserByID retrieves a user by ID
-	GetUserByID(id string) (*User, error)
+	GetUserByID(ctx context.Context, id string) (*User, error)
 
 	// GetUserByEmail retrieves a user by email
-	GetUserByEmail(email string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
 
 	// GetUserByUsername retrieves a user by username
-	GetUserByUsername(username string) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+
+	// GetUserByProvider retrieves the user linked to an external provider
+	// identity
+	GetUserByProvider(ctx context.Context, provider, providerUserID string) (*User, error)
+
+	// UpdateUser updates an existing user. It keeps the stored LastLoginAt,
+	// so writing back a copy read before a login doesn't undo it.
+	UpdateUser(ctx context.Context, user *User) error
+
+	// RecordLogin sets a user's LastLoginAt without touching other fields
+	RecordLogin(ctx context.Context, id string, at time.Time) error
 
-	// UpdateUser updates an existing user
-	UpdateUser(user *User) error
+	// DeleteUser permanently deletes a user by ID, including a
+	// soft-deleted one
+	DeleteUser(ctx context.Context, id string) error
 
-	// DeleteUser deletes a user by ID
-	DeleteUser(id string) error
+	// SoftDeleteUser marks a user as deleted, keeping the record but
+	// releasing its email and username
+	SoftDeleteUser(ctx context.Context, id string) error
 
-	// ListUsers returns all users (for admin purposes)
-	ListUsers() ([]*User, error)
+	// RestoreUser undoes a soft delete. It returns ErrUserExists when the
+	// email or username has been taken since.
+	RestoreUser(ctx context.Context, id string) error
+
+	// ListUsers returns all users that aren't soft-deleted (for admin purposes)
+	ListUsers(ctx context.Context) ([]*User, error)
+
+	// ListUsersPaged returns one page of users matching opts along with the
+	// total number of matching users
+	ListUsersPaged(ctx context.Context, opts ListUsersOptions) ([]*User, int, error)
+
+	// SearchUsers returns up to limit users whose email or username
+	// contains query, ignoring case, most relevant first: exact matches,
+	// then prefix matches, then other substring matches. No matches is an
+	// empty slice, not an error.
+	SearchUsers(ctx context.Context, query string, limit int) ([]*User, error)
+
+	// UserStats counts the users that aren't soft-deleted, including how
+	// many were created at or after each of registeredSince
+	UserStats(ctx context.Context, registeredSince []time.Time) (*UserStats, error)
+
+	// Ping reports whether the store is reachable
+	Ping(ctx context.Context) error
 }
 
-// MemoryUserStore implements UserStore using in-memory storage
+// MemoryUserStore implements UserStore using in-memory storage. Operations
+// are instant, so the context is only checked for prior cancellation.
 type MemoryUserStore struct {
 	mu          sync.RWMutex
 	users       map[string]*User
-	emailIdx    map[string]string // email -> user_id mapping
-	usernameIdx map[string]string // username -> user_id mapping
+	emailIdx    map[string]string // normalized email -> user_id mapping
+	usernameIdx map[string]string // normalized username -> user_id mapping
 }
 
 // NewMemoryUserStore creates a new in-memory user store
@@ -66,8 +274,17 @@ func NewMemoryUserStore() *MemoryUserStore {
 	}
 }
 
+// Ping always succeeds for the in-memory store unless the context is done
+func (s *MemoryUserStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
 // CreateUser creates a new user
-func (s *MemoryUserStore) CreateUser(user *User) error {
+func (s *MemoryUserStore) CreateUser(ctx context.Context, user *User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -76,114 +293,209 @@ func (s *MemoryUserStore) CreateUser(user *User) error {
 		return ErrUserExists
 	}
 
+	email := NormalizeEmail(user.Email)
+	username := NormalizeUsername(user.Username)
+
 	// Check if email already exists
-	if _, exists := s.emailIdx[user.Email]; exists {
+	if _, exists := s.emailIdx[orgKey(user.OrgID, email)]; exists {
 		return ErrUserExists
 	}
 
 	// Check if username already exists
-	if _, exists := s.usernameIdx[user.Username]; exists {
+	if _, exists := s.usernameIdx[orgKey(user.OrgID, username)]; exists {
 		return ErrUserExists
 	}
 
 	// Create user
-	userCopy := *user
+	userCopy := copyUser(user)
+	userCopy.Email = email
+	userCopy.NormalizedUsername = username
 	userCopy.CreatedAt = time.Now()
 	userCopy.UpdatedAt = time.Now()
 	userCopy.IsActive = true
+	if userCopy.Role == "" {
+		userCopy.Role = RoleUser
+	}
 
-	s.users[user.ID] = &userCopy
-	s.emailIdx[user.Email] = user.ID
-	s.usernameIdx[user.Username] = user.ID
+	s.users[user.ID] = userCopy
+	s.emailIdx[orgKey(user.OrgID, email)] = user.ID
+	s.usernameIdx[orgKey(user.OrgID, username)] = user.ID
 
 	return nil
 }
 
 // GetUserByID retrieves a user by ID
-func (s *MemoryUserStore) GetUserByID(id string) (*User, error) {
+func (s *MemoryUserStore) GetUserByID(ctx context.Context, id string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	user, exists := s.users[id]
-	if !exists {
+	if !exists || user.DeletedAt != nil {
 		return nil, ErrUserNotFound
 	}
 
 	// Return a copy to prevent external modification
-	userCopy := *user
-	return &userCopy, nil
+	userCopy := copyUser(user)
+	return userCopy, nil
 }
 
 // GetUserByEmail retrieves a user by email
-func (s *MemoryUserStore) GetUserByEmail(email string) (*User, error) {
+func (s *MemoryUserStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	userID, exists := s.emailIdx[email]
+	userID, exists := s.emailIdx[orgKey(tenant.FromContext(ctx), NormalizeEmail(email))]
 	if !exists {
 		return nil, ErrUserNotFound
 	}
 
 	user := s.users[userID]
-	userCopy := *user
-	return &userCopy, nil
+	userCopy := copyUser(user)
+	return userCopy, nil
 }
 
 // GetUserByUsername retrieves a user by username
-func (s *MemoryUserStore) GetUserByUsername(username string) (*User, error) {
+func (s *MemoryUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	userID, exists := s.usernameIdx[username]
+	userID, exists := s.usernameIdx[orgKey(tenant.FromContext(ctx), NormalizeUsername(username))]
 	if !exists {
 		return nil, ErrUserNotFound
 	}
 
 	user := s.users[userID]
-	userCopy := *user
-	return &userCopy, nil
+	userCopy := copyUser(user)
+	return userCopy, nil
+}
+
+// GetUserByProvider retrieves the user linked to a provider identity.
+// Provider logins are rare next to other lookups, so this scans the users
+// rather than keeping another index.
+func (s *MemoryUserStore) GetUserByProvider(ctx context.Context, provider, providerUserID string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if user := findByProvider(s.users, tenant.FromContext(ctx), provider, providerUserID); user != nil {
+		return copyUser(user), nil
+	}
+	return nil, ErrUserNotFound
+}
+
+// findByProvider returns the organization's user, not soft-deleted, that is
+// linked to a provider identity, or nil
+func findByProvider(all map[string]*User, orgID, provider, providerUserID string) *User {
+	for _, user := range all {
+		if user.OrgID == orgID && user.DeletedAt == nil && user.HasProvider(provider, providerUserID) {
+			return user
+		}
+	}
+	return nil
 }
 
 // UpdateUser updates an existing user
-func (s *MemoryUserStore) UpdateUser(user *User) error {
+func (s *MemoryUserStore) UpdateUser(ctx context.Context, user *User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	existingUser, exists := s.users[user.ID]
-	if !exists {
+	if !exists || existingUser.DeletedAt != nil {
 		return ErrUserNotFound
 	}
 
-	// Check if email changed and if new email already exists
-	if user.Email != existingUser.Email {
-		if _, exists := s.emailIdx[user.Email]; exists {
+	email := NormalizeEmail(user.Email)
+	username := NormalizeUsername(user.Username)
+
+	// Users never move between organizations
+	orgID := existingUser.OrgID
+
+	// Check both indexes before changing either, so a username conflict
+	// doesn't leave the email index pointing at an email the user never got.
+	// Stored emails and usernames are already normalized, so changes that
+	// only differ in case leave the indexes alone.
+	if email != existingUser.Email {
+		if _, exists := s.emailIdx[orgKey(orgID, email)]; exists {
 			return ErrUserExists
 		}
-		// Update email index
-		delete(s.emailIdx, existingUser.Email)
-		s.emailIdx[user.Email] = user.ID
 	}
-
-	// Check if username changed and if new username already exists
-	if user.Username != existingUser.Username {
-		if _, exists := s.usernameIdx[user.Username]; exists {
+	if username != existingUser.NormalizedUsername {
+		if _, exists := s.usernameIdx[orgKey(orgID, username)]; exists {
 			return ErrUserExists
 		}
-		// Update username index
-		delete(s.usernameIdx, existingUser.Username)
-		s.usernameIdx[user.Username] = user.ID
 	}
 
-	// Update user
-	userCopy := *user
+	// Move the index entries
+	if email != existingUser.Email {
+		delete(s.emailIdx, orgKey(orgID, existingUser.Email))
+		s.emailIdx[orgKey(orgID, email)] = user.ID
+	}
+	if username != existingUser.NormalizedUsername {
+		delete(s.usernameIdx, orgKey(orgID, existingUser.NormalizedUsername))
+		s.usernameIdx[orgKey(orgID, username)] = user.ID
+	}
+
+	// Update user; only SoftDeleteUser and RestoreUser change DeletedAt,
+	// and only RecordLogin changes LastLoginAt
+	userCopy := copyUser(user)
+	userCopy.OrgID = orgID
+	userCopy.Email = email
+	userCopy.NormalizedUsername = username
 	userCopy.UpdatedAt = time.Now()
-	s.users[user.ID] = &userCopy
+	userCopy.DeletedAt = nil
+	userCopy.LastLoginAt = copyTime(existingUser.LastLoginAt)
+	s.users[user.ID] = userCopy
+
+	return nil
+}
+
+// RecordLogin sets a user's last login time. Logging in isn't an edit, so
+// UpdatedAt is left alone.
+func (s *MemoryUserStore) RecordLogin(ctx context.Context, id string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists || user.DeletedAt != nil {
+		return ErrUserNotFound
+	}
+
+	userCopy := copyUser(user)
+	userCopy.LastLoginAt = &at
+	s.users[id] = userCopy
 
 	return nil
 }
 
 // DeleteUser deletes a user by ID
-func (s *MemoryUserStore) DeleteUser(id string) error {
+func (s *MemoryUserStore) DeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -192,24 +504,295 @@ func (s *MemoryUserStore) DeleteUser(id string) error {
 		return ErrUserNotFound
 	}
 
-	// Remove from all indexes
+	// Remove from all indexes. A soft-deleted user's email and username
+	// may belong to someone else by now.
 	delete(s.users, id)
-	delete(s.emailIdx, user.Email)
-	delete(s.usernameIdx, user.Username)
+	s.unindex(user)
+
+	return nil
+}
+
+// SoftDeleteUser marks a user as deleted and frees its email and username
+func (s *MemoryUserStore) SoftDeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists || user.DeletedAt != nil || user.OrgID != tenant.FromContext(ctx) {
+		return ErrUserNotFound
+	}
+
+	now := time.Now()
+	userCopy := copyUser(user)
+	userCopy.DeletedAt = &now
+	userCopy.UpdatedAt = now
+	s.users[id] = userCopy
+	s.unindex(user)
+
+	return nil
+}
+
+// RestoreUser brings back a soft-deleted user if its email and username are still free
+func (s *MemoryUserStore) RestoreUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists || user.DeletedAt == nil || user.OrgID != tenant.FromContext(ctx) {
+		return ErrUserNotFound
+	}
+
+	emailKey := orgKey(user.OrgID, user.Email)
+	usernameKey := orgKey(user.OrgID, user.NormalizedUsername)
+	if _, taken := s.emailIdx[emailKey]; taken {
+		return ErrUserExists
+	}
+	if _, taken := s.usernameIdx[usernameKey]; taken {
+		return ErrUserExists
+	}
+
+	userCopy := copyUser(user)
+	userCopy.DeletedAt = nil
+	userCopy.UpdatedAt = time.Now()
+	s.users[id] = userCopy
+	s.emailIdx[emailKey] = id
+	s.usernameIdx[usernameKey] = id
 
 	return nil
 }
 
-// ListUsers returns all users
-func (s *MemoryUserStore) ListUsers() ([]*User, error) {
+// unindex removes a user's email and username index entries if they still
+// point at the user
+func (s *MemoryUserStore) unindex(user *User) {
+	if emailKey := orgKey(user.OrgID, user.Email); s.emailIdx[emailKey] == user.ID {
+		delete(s.emailIdx, emailKey)
+	}
+	if usernameKey := orgKey(user.OrgID, user.NormalizedUsername); s.usernameIdx[usernameKey] == user.ID {
+		delete(s.usernameIdx, usernameKey)
+	}
+}
+
+// ListUsers returns all users of the context's organization
+func (s *MemoryUserStore) ListUsers(ctx context.Context) ([]*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	orgID := tenant.FromContext(ctx)
 	users := make([]*User, 0, len(s.users))
 	for _, user := range s.users {
-		userCopy := *user
-		users = append(users, &userCopy)
+		if user.DeletedAt != nil || user.OrgID != orgID {
+			continue
+		}
+		userCopy := copyUser(user)
+		users = append(users, userCopy)
 	}
 
 	return users, nil
 }
+
+// ListUsersPaged returns one page of users matching opts and the total match count
+func (s *MemoryUserStore) ListUsersPaged(ctx context.Context, opts ListUsersOptions) ([]*User, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users, total := pageUsers(s.users, tenant.FromContext(ctx), opts)
+	return users, total, nil
+}
+
+// pageUsers filters, sorts and pages an organization's users for
+// ListUsersPaged, returning copies of one page and the total number of matches
+func pageUsers(all map[string]*User, orgID string, opts ListUsersOptions) ([]*User, int) {
+	query := strings.ToLower(opts.Query)
+	matches := make([]*User, 0, len(all))
+	for _, user := range all {
+		if user.OrgID != orgID {
+			continue
+		}
+		if user.DeletedAt != nil && !opts.IncludeDeleted {
+			continue
+		}
+		if query != "" &&
+			!strings.Contains(strings.ToLower(user.Email), query) &&
+			!strings.Contains(strings.ToLower(user.Username), query) {
+			continue
+		}
+		matches = append(matches, user)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if opts.SortDesc {
+			a, b = b, a
+		}
+		switch opts.SortBy {
+		case SortByEmail:
+			return a.Email < b.Email
+		case SortByUsername:
+			return a.Username < b.Username
+		default:
+			if a.CreatedAt.Equal(b.CreatedAt) {
+				return a.ID < b.ID
+			}
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	})
+
+	total := len(matches)
+	if opts.Offset > 0 {
+		if opts.Offset >= total {
+			return []*User{}, total
+		}
+		matches = matches[opts.Offset:]
+	}
+	if opts.Limit > 0 && len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+	}
+
+	users := make([]*User, 0, len(matches))
+	for _, user := range matches {
+		users = append(users, copyUser(user))
+	}
+
+	return users, total
+}
+
+// SearchUsers returns the users of the context's organization best matching query
+func (s *MemoryUserStore) SearchUsers(ctx context.Context, query string, limit int) ([]*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return searchUsers(s.users, tenant.FromContext(ctx), query, limit), nil
+}
+
+// UserStats counts the context organization's users in place, without
+// copying them
+func (s *MemoryUserStore) UserStats(ctx context.Context, registeredSince []time.Time) (*UserStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orgID := tenant.FromContext(ctx)
+	stats := &UserStats{Registered: make([]int, len(registeredSince))}
+	for _, user := range s.users {
+		stats.add(user, orgID, registeredSince)
+	}
+	return stats, nil
+}
+
+// Search relevance ranks, best first
+const (
+	matchExact = iota
+	matchPrefix
+	matchSubstring
+	matchNone
+)
+
+// matchRank ranks how well a normalized value matches a lowercase query
+func matchRank(value, query string) int {
+	switch {
+	case value == query:
+		return matchExact
+	case strings.HasPrefix(value, query):
+		return matchPrefix
+	case strings.Contains(value, query):
+		return matchSubstring
+	default:
+		return matchNone
+	}
+}
+
+// searchUsers ranks the organization's users that aren't soft-deleted by
+// their best match on email or username and returns copies of the top
+// limit. Equally relevant users are ordered by email.
+func searchUsers(all map[string]*User, orgID, query string, limit int) []*User {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return []*User{}
+	}
+
+	type match struct {
+		user *User
+		rank int
+	}
+	matches := make([]match, 0)
+	for _, user := range all {
+		if user.OrgID != orgID || user.DeletedAt != nil {
+			continue
+		}
+		rank := min(matchRank(user.Email, query), matchRank(user.NormalizedUsername, query))
+		if rank == matchNone {
+			continue
+		}
+		matches = append(matches, match{user: user, rank: rank})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank < matches[j].rank
+		}
+		return matches[i].user.Email < matches[j].user.Email
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	users := make([]*User, 0, len(matches))
+	for _, m := range matches {
+		users = append(users, copyUser(m.user))
+	}
+	return users
+}
+
+// copyUser returns a deep copy of a user so callers can't modify stored state
+func copyUser(user *User) *User {
+	userCopy := *user
+	if user.LinkedProviders != nil {
+		userCopy.LinkedProviders = append([]LinkedProvider(nil), user.LinkedProviders...)
+	}
+	if user.RecoveryCodeHashes != nil {
+		userCopy.RecoveryCodeHashes = append([]string(nil), user.RecoveryCodeHashes...)
+	}
+	if user.SecurityQuestions != nil {
+		userCopy.SecurityQuestions = append([]QuestionAnswer(nil), user.SecurityQuestions...)
+	}
+	if user.WebAuthnCredentials != nil {
+		userCopy.WebAuthnCredentials = append([]WebAuthnCredential(nil), user.WebAuthnCredentials...)
+	}
+	userCopy.Metadata = maps.Clone(user.Metadata)
+	userCopy.DeletedAt = copyTime(user.DeletedAt)
+	userCopy.LastLoginAt = copyTime(user.LastLoginAt)
+	userCopy.EmailVerifiedAt = copyTime(user.EmailVerifiedAt)
+	return &userCopy
+}
+
+// copyTime returns a copy of an optional timestamp
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	tCopy := *t
+	return &tCopy
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// CachedUserStore wraps a UserStore with a size-bounded LRU cache of users
+// by ID, for stores where every lookup is a round trip. ValidateToken loads
+// the user on every authenticated request, so that lookup dominates.
+// Writes through this store evict the user; changes made elsewhere, such
+// as by another replica, show up once the entry's TTL runs out.
+type CachedUserStore struct {
+	UserStore
+
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used at the front
+	// gen counts evictions, so a lookup that raced with a write doesn't
+	// cache the user as it was before the write
+	gen uint64
+}
+
+// cachedUser is one cache entry
+type cachedUser struct {
+	id        string
+	user      *User
+	expiresAt time.Time
+}
+
+// NewCachedUserStore caches up to maxSize users from store for ttl each
+func NewCachedUserStore(store UserStore, maxSize int, ttl time.Duration) *CachedUserStore {
+	return &CachedUserStore{
+		UserStore: store,
+		ttl:       ttl,
+		maxSize:   maxSize,
+		entries:   make(map[string]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// GetUserByID returns a copy of the cached user, loading it from the
+// wrapped store on a miss. Lookup errors aren't cached.
+func (s *CachedUserStore) GetUserByID(ctx context.Context, id string) (*User, error) {
+	s.mu.Lock()
+	if elem, ok := s.entries[id]; ok {
+		entry := elem.Value.(*cachedUser)
+		if time.Now().Before(entry.expiresAt) {
+			s.order.MoveToFront(elem)
+			s.mu.Unlock()
+			return copyUser(entry.user), nil
+		}
+		s.remove(id)
+	}
+	gen := s.gen
+	s.mu.Unlock()
+
+	user, err := s.UserStore.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.gen == gen {
+		s.add(id, copyUser(user))
+	}
+	s.mu.Unlock()
+	return user, nil
+}
+
+// UpdateUser updates the user in the wrapped store and evicts it
+func (s *CachedUserStore) UpdateUser(ctx context.Context, user *User) error {
+	defer s.evict(user.ID)
+	return s.UserStore.UpdateUser(ctx, user)
+}
+
+// RecordLogin records the login in the wrapped store and evicts the user
+func (s *CachedUserStore) RecordLogin(ctx context.Context, id string, at time.Time) error {
+	defer s.evict(id)
+	return s.UserStore.RecordLogin(ctx, id, at)
+}
+
+// DeleteUser deletes the user from the wrapped store and evicts it
+func (s *CachedUserStore) DeleteUser(ctx context.Context, id string) error {
+	defer s.evict(id)
+	return s.UserStore.DeleteUser(ctx, id)
+}
+
+// SoftDeleteUser soft-deletes the user in the wrapped store and evicts it
+func (s *CachedUserStore) SoftDeleteUser(ctx context.Context, id string) error {
+	defer s.evict(id)
+	return s.UserStore.SoftDeleteUser(ctx, id)
+}
+
+// RestoreUser restores the user in the wrapped store and evicts it
+func (s *CachedUserStore) RestoreUser(ctx context.Context, id string) error {
+	defer s.evict(id)
+	return s.UserStore.RestoreUser(ctx, id)
+}
+
+// evict drops a user from the cache after a write, whether or not the
+// write succeeded, since a failed write may still have changed the store
+func (s *CachedUserStore) evict(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	s.remove(id)
+}
+
+// add caches a user, dropping the least recently used entry when full.
+// The caller holds mu.
+func (s *CachedUserStore) add(id string, user *User) {
+	s.remove(id)
+	s.entries[id] = s.order.PushFront(&cachedUser{id: id, user: user, expiresAt: time.Now().Add(s.ttl)})
+	for s.order.Len() > s.maxSize {
+		s.remove(s.order.Back().Value.(*cachedUser).id)
+	}
+}
+
+// remove drops an entry if it is cached. The caller holds mu.
+func (s *CachedUserStore) remove(id string) {
+	if elem, ok := s.entries[id]; ok {
+		s.order.Remove(elem)
+		delete(s.entries, id)
+	}
+}
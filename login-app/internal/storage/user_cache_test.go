@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore counts the GetUserByID calls that reach the wrapped store
+type countingStore struct {
+	UserStore
+	lookups atomic.Int32
+}
+
+func (s *countingStore) GetUserByID(ctx context.Context, id string) (*User, error) {
+	s.lookups.Add(1)
+	return s.UserStore.GetUserByID(ctx, id)
+}
+
+// newCachedTestStore returns a cache over a counting memory store holding
+// users with the given IDs
+func newCachedTestStore(t *testing.T, maxSize int, ttl time.Duration, ids ...string) (*CachedUserStore, *countingStore) {
+	t.Helper()
+
+	backing := &countingStore{UserStore: NewMemoryUserStore()}
+	for _, id := range ids {
+		if err := backing.CreateUser(context.Background(), &User{ID: id, Email: id + "@example.com", Username: id}); err != nil {
+			t.Fatalf("CreateUser(%s): %v", id, err)
+		}
+	}
+	return NewCachedUserStore(backing, maxSize, ttl), backing
+}
+
+func TestCachedUserStoreHitsAvoidTheStore(t *testing.T) {
+	cache, backing := newCachedTestStore(t, 10, time.Minute, "alice")
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		user, err := cache.GetUserByID(ctx, "alice")
+		if err != nil || user.ID != "alice" {
+			t.Fatalf("GetUserByID = %+v, %v", user, err)
+		}
+	}
+	if lookups := backing.lookups.Load(); lookups != 1 {
+		t.Errorf("%d lookups reached the store, want 1", lookups)
+	}
+}
+
+func TestCachedUserStoreUpdateInvalidates(t *testing.T) {
+	cache, backing := newCachedTestStore(t, 10, time.Minute, "alice")
+	ctx := context.Background()
+
+	user, _ := cache.GetUserByID(ctx, "alice")
+	user.FirstName = "Updated"
+	if err := cache.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	got, err := cache.GetUserByID(ctx, "alice")
+	if err != nil || got.FirstName != "Updated" {
+		t.Errorf("GetUserByID after update = %+v, %v; want the update", got, err)
+	}
+	if lookups := backing.lookups.Load(); lookups != 2 {
+		t.Errorf("%d lookups reached the store, want 2", lookups)
+	}
+
+	if err := cache.DeleteUser(ctx, "alice"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := cache.GetUserByID(ctx, "alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByID after delete: got %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestCachedUserStoreReturnsCopies(t *testing.T) {
+	cache, _ := newCachedTestStore(t, 10, time.Minute, "alice")
+	ctx := context.Background()
+
+	first, _ := cache.GetUserByID(ctx, "alice")
+	first.Role = RoleAdmin
+	first.Metadata = map[string]string{"plan": "free"}
+
+	second, _ := cache.GetUserByID(ctx, "alice")
+	if second.Role == RoleAdmin || second.Metadata["plan"] == "free" {
+		t.Errorf("changing a returned user changed the cached one: %+v", second)
+	}
+}
+
+func TestCachedUserStoreExpiresAndEvicts(t *testing.T) {
+	cache, backing := newCachedTestStore(t, 2, 20*time.Millisecond, "a", "b", "c")
+	ctx := context.Background()
+
+	cache.GetUserByID(ctx, "a")
+	time.Sleep(30 * time.Millisecond)
+	cache.GetUserByID(ctx, "a")
+	if lookups := backing.lookups.Load(); lookups != 2 {
+		t.Errorf("%d lookups after the entry expired, want 2", lookups)
+	}
+
+	// With room for two, loading c drops a, the least recently used
+	cache, backing = newCachedTestStore(t, 2, time.Minute, "a", "b", "c")
+	for _, id := range []string{"a", "b", "c", "b", "c"} {
+		cache.GetUserByID(ctx, id)
+	}
+	if lookups := backing.lookups.Load(); lookups != 3 {
+		t.Errorf("%d lookups, want 3", lookups)
+	}
+	cache.GetUserByID(ctx, "a")
+	if lookups := backing.lookups.Load(); lookups != 4 {
+		t.Errorf("evicted user wasn't reloaded: %d lookups, want 4", lookups)
+	}
+}
+
+func TestCachedUserStoreDoesNotCacheMisses(t *testing.T) {
+	cache, backing := newCachedTestStore(t, 10, time.Minute)
+	ctx := context.Background()
+
+	if _, err := cache.GetUserByID(ctx, "late"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("got %v, want ErrUserNotFound", err)
+	}
+	if err := backing.CreateUser(ctx, &User{ID: "late", Email: "late@example.com", Username: "late"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user, err := cache.GetUserByID(ctx, "late"); err != nil || user.ID != "late" {
+		t.Errorf("GetUserByID after creating = %+v, %v", user, err)
+	}
+}
@@ -0,0 +1,516 @@
+package storage
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+// ShardedMemoryUserStore implements UserStore in memory like
+// MemoryUserStore, but spreads users across shards with a lock each so
+// concurrent lookups by ID don't contend on a single lock. The email and
+// username indexes are split into stripes by key, each with its own lock,
+// so uniqueness holds across shards while writes to unrelated emails and
+// usernames run in parallel. Writes lock the stripes of every key they
+// touch in stripe order, then the user's shard.
+type ShardedMemoryUserStore struct {
+	shards  []*userShard
+	stripes []*indexStripe
+}
+
+// userShard holds the users whose IDs hash to it
+type userShard struct {
+	mu    sync.RWMutex
+	users map[string]*User
+}
+
+// indexStripe holds the email and username index entries whose keys hash to it
+type indexStripe struct {
+	mu        sync.RWMutex
+	emails    map[string]string // org-scoped normalized email -> user_id mapping
+	usernames map[string]string // org-scoped normalized username -> user_id mapping
+}
+
+// NewShardedMemoryUserStore creates an in-memory user store with the given
+// number of shards, at least one. The indexes get as many stripes.
+func NewShardedMemoryUserStore(shards int) *ShardedMemoryUserStore {
+	if shards < 1 {
+		shards = 1
+	}
+
+	s := &ShardedMemoryUserStore{
+		shards:  make([]*userShard, shards),
+		stripes: make([]*indexStripe, shards),
+	}
+	for i := range s.shards {
+		s.shards[i] = &userShard{users: make(map[string]*User)}
+		s.stripes[i] = &indexStripe{
+			emails:    make(map[string]string),
+			usernames: make(map[string]string),
+		}
+	}
+	return s
+}
+
+// shard returns the shard holding a user ID
+func (s *ShardedMemoryUserStore) shard(id string) *userShard {
+	return s.shards[hashIndex(id, len(s.shards))]
+}
+
+// stripe returns the index stripe holding an org-scoped email or username
+func (s *ShardedMemoryUserStore) stripe(key string) *indexStripe {
+	return s.stripes[hashIndex(key, len(s.stripes))]
+}
+
+// hashIndex maps a key to one of n buckets
+func hashIndex(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// lockIndexes write-locks the stripes holding keys, each once and in
+// stripe order so that writers with overlapping keys can't deadlock, and
+// returns a function that unlocks them
+func (s *ShardedMemoryUserStore) lockIndexes(keys ...string) func() {
+	locked := make([]int, 0, len(keys))
+	for _, key := range keys {
+		i := hashIndex(key, len(s.stripes))
+		if !slices.Contains(locked, i) {
+			locked = append(locked, i)
+		}
+	}
+	slices.Sort(locked)
+
+	for _, i := range locked {
+		s.stripes[i].mu.Lock()
+	}
+	return func() {
+		for _, i := range locked {
+			s.stripes[i].mu.Unlock()
+		}
+	}
+}
+
+// lockUser locks the index stripes of a stored user's email and username,
+// and of the keys returned by extra for it, then the user's shard. The
+// user has to be read before its stripes are known, so this retries if
+// its email or username changed before the locks were taken. It returns
+// the locked shard, the user as stored and a function releasing the locks.
+func (s *ShardedMemoryUserStore) lockUser(id string, extra func(user *User) []string) (*userShard, *User, func(), error) {
+	shard := s.shard(id)
+	for {
+		shard.mu.RLock()
+		user, exists := shard.users[id]
+		shard.mu.RUnlock()
+		if !exists {
+			return nil, nil, nil, ErrUserNotFound
+		}
+
+		emailKey := orgKey(user.OrgID, user.Email)
+		usernameKey := orgKey(user.OrgID, user.NormalizedUsername)
+		keys := []string{emailKey, usernameKey}
+		if extra != nil {
+			keys = append(keys, extra(user)...)
+		}
+		unlockIndexes := s.lockIndexes(keys...)
+		shard.mu.Lock()
+
+		current, exists := shard.users[id]
+		if exists && orgKey(current.OrgID, current.Email) == emailKey &&
+			orgKey(current.OrgID, current.NormalizedUsername) == usernameKey {
+			return shard, current, func() {
+				shard.mu.Unlock()
+				unlockIndexes()
+			}, nil
+		}
+
+		shard.mu.Unlock()
+		unlockIndexes()
+	}
+}
+
+// Ping always succeeds for the in-memory store unless the context is done
+func (s *ShardedMemoryUserStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// CreateUser creates a new user
+func (s *ShardedMemoryUserStore) CreateUser(ctx context.Context, user *User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	emailKey := orgKey(user.OrgID, NormalizeEmail(user.Email))
+	usernameKey := orgKey(user.OrgID, NormalizeUsername(user.Username))
+
+	unlock := s.lockIndexes(emailKey, usernameKey)
+	defer unlock()
+
+	shard := s.shard(user.ID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, exists := shard.users[user.ID]; exists {
+		return ErrUserExists
+	}
+	if _, exists := s.stripe(emailKey).emails[emailKey]; exists {
+		return ErrUserExists
+	}
+	if _, exists := s.stripe(usernameKey).usernames[usernameKey]; exists {
+		return ErrUserExists
+	}
+
+	userCopy := copyUser(user)
+	userCopy.Email = NormalizeEmail(user.Email)
+	userCopy.NormalizedUsername = NormalizeUsername(user.Username)
+	userCopy.CreatedAt = time.Now()
+	userCopy.UpdatedAt = time.Now()
+	userCopy.IsActive = true
+	if userCopy.Role == "" {
+		userCopy.Role = RoleUser
+	}
+
+	shard.users[user.ID] = userCopy
+	s.stripe(emailKey).emails[emailKey] = user.ID
+	s.stripe(usernameKey).usernames[usernameKey] = user.ID
+
+	return nil
+}
+
+// GetUserByID retrieves a user by ID, locking only the user's shard
+func (s *ShardedMemoryUserStore) GetUserByID(ctx context.Context, id string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	shard := s.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	user, exists := shard.users[id]
+	if !exists || user.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+// GetUserByEmail retrieves a user by email, locking only the email's stripe
+// and the user's shard
+func (s *ShardedMemoryUserStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := orgKey(tenant.FromContext(ctx), NormalizeEmail(email))
+	stripe := s.stripe(key)
+	stripe.mu.RLock()
+	defer stripe.mu.RUnlock()
+
+	userID, exists := stripe.emails[key]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	return s.indexedUser(userID)
+}
+
+// GetUserByUsername retrieves a user by username, locking only the
+// username's stripe and the user's shard
+func (s *ShardedMemoryUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := orgKey(tenant.FromContext(ctx), NormalizeUsername(username))
+	stripe := s.stripe(key)
+	stripe.mu.RLock()
+	defer stripe.mu.RUnlock()
+
+	userID, exists := stripe.usernames[key]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	return s.indexedUser(userID)
+}
+
+// GetUserByProvider retrieves the user linked to a provider identity,
+// scanning one shard at a time
+func (s *ShardedMemoryUserStore) GetUserByProvider(ctx context.Context, provider, providerUserID string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	orgID := tenant.FromContext(ctx)
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		user := findByProvider(shard.users, orgID, provider, providerUserID)
+		shard.mu.RUnlock()
+		if user != nil {
+			return copyUser(user), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// indexedUser returns a copy of a user found through an index. The caller
+// holds the stripe of the key it looked up, so the index and shard agree.
+func (s *ShardedMemoryUserStore) indexedUser(id string) (*User, error) {
+	shard := s.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	user, exists := shard.users[id]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+// UpdateUser updates an existing user
+func (s *ShardedMemoryUserStore) UpdateUser(ctx context.Context, user *User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	email := NormalizeEmail(user.Email)
+	username := NormalizeUsername(user.Username)
+
+	// Users never move between organizations, so the new keys are in the
+	// stored user's organization
+	shard, existingUser, unlock, err := s.lockUser(user.ID, func(existing *User) []string {
+		return []string{orgKey(existing.OrgID, email), orgKey(existing.OrgID, username)}
+	})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if existingUser.DeletedAt != nil {
+		return ErrUserNotFound
+	}
+
+	orgID := existingUser.OrgID
+	emailKey := orgKey(orgID, email)
+	usernameKey := orgKey(orgID, username)
+
+	// Check both indexes before changing either so a conflict leaves them untouched
+	if email != existingUser.Email {
+		if _, exists := s.stripe(emailKey).emails[emailKey]; exists {
+			return ErrUserExists
+		}
+	}
+	if username != existingUser.NormalizedUsername {
+		if _, exists := s.stripe(usernameKey).usernames[usernameKey]; exists {
+			return ErrUserExists
+		}
+	}
+	if email != existingUser.Email {
+		oldKey := orgKey(orgID, existingUser.Email)
+		delete(s.stripe(oldKey).emails, oldKey)
+		s.stripe(emailKey).emails[emailKey] = user.ID
+	}
+	if username != existingUser.NormalizedUsername {
+		oldKey := orgKey(orgID, existingUser.NormalizedUsername)
+		delete(s.stripe(oldKey).usernames, oldKey)
+		s.stripe(usernameKey).usernames[usernameKey] = user.ID
+	}
+
+	// Only SoftDeleteUser and RestoreUser change DeletedAt, and only
+	// RecordLogin changes LastLoginAt
+	userCopy := copyUser(user)
+	userCopy.OrgID = orgID
+	userCopy.Email = email
+	userCopy.NormalizedUsername = username
+	userCopy.UpdatedAt = time.Now()
+	userCopy.DeletedAt = nil
+	userCopy.LastLoginAt = copyTime(existingUser.LastLoginAt)
+	shard.users[user.ID] = userCopy
+
+	return nil
+}
+
+// RecordLogin sets a user's last login time, locking only the user's shard
+func (s *ShardedMemoryUserStore) RecordLogin(ctx context.Context, id string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	shard := s.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	user, exists := shard.users[id]
+	if !exists || user.DeletedAt != nil {
+		return ErrUserNotFound
+	}
+
+	userCopy := copyUser(user)
+	userCopy.LastLoginAt = &at
+	shard.users[id] = userCopy
+
+	return nil
+}
+
+// DeleteUser permanently deletes a user by ID
+func (s *ShardedMemoryUserStore) DeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	shard, user, unlock, err := s.lockUser(id, nil)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	delete(shard.users, id)
+	s.unindex(user)
+
+	return nil
+}
+
+// SoftDeleteUser marks a user as deleted and frees its email and username
+func (s *ShardedMemoryUserStore) SoftDeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	shard, user, unlock, err := s.lockUser(id, nil)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if user.DeletedAt != nil || user.OrgID != tenant.FromContext(ctx) {
+		return ErrUserNotFound
+	}
+
+	now := time.Now()
+	userCopy := copyUser(user)
+	userCopy.DeletedAt = &now
+	userCopy.UpdatedAt = now
+	shard.users[id] = userCopy
+	s.unindex(user)
+
+	return nil
+}
+
+// RestoreUser brings back a soft-deleted user if its email and username are still free
+func (s *ShardedMemoryUserStore) RestoreUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	shard, user, unlock, err := s.lockUser(id, nil)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if user.DeletedAt == nil || user.OrgID != tenant.FromContext(ctx) {
+		return ErrUserNotFound
+	}
+
+	emailKey := orgKey(user.OrgID, user.Email)
+	usernameKey := orgKey(user.OrgID, user.NormalizedUsername)
+	if _, taken := s.stripe(emailKey).emails[emailKey]; taken {
+		return ErrUserExists
+	}
+	if _, taken := s.stripe(usernameKey).usernames[usernameKey]; taken {
+		return ErrUserExists
+	}
+
+	userCopy := copyUser(user)
+	userCopy.DeletedAt = nil
+	userCopy.UpdatedAt = time.Now()
+	shard.users[id] = userCopy
+	s.stripe(emailKey).emails[emailKey] = id
+	s.stripe(usernameKey).usernames[usernameKey] = id
+
+	return nil
+}
+
+// unindex removes a user's index entries if they still point at the user.
+// The caller holds the stripes of the user's email and username for writing.
+func (s *ShardedMemoryUserStore) unindex(user *User) {
+	if emailKey := orgKey(user.OrgID, user.Email); s.stripe(emailKey).emails[emailKey] == user.ID {
+		delete(s.stripe(emailKey).emails, emailKey)
+	}
+	if usernameKey := orgKey(user.OrgID, user.NormalizedUsername); s.stripe(usernameKey).usernames[usernameKey] == user.ID {
+		delete(s.stripe(usernameKey).usernames, usernameKey)
+	}
+}
+
+// ListUsers returns all users of the context's organization that aren't soft-deleted
+func (s *ShardedMemoryUserStore) ListUsers(ctx context.Context) ([]*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	orgID := tenant.FromContext(ctx)
+	users := make([]*User, 0)
+	for _, user := range s.snapshot() {
+		if user.DeletedAt != nil || user.OrgID != orgID {
+			continue
+		}
+		users = append(users, copyUser(user))
+	}
+	return users, nil
+}
+
+// ListUsersPaged returns one page of users matching opts and the total match count
+func (s *ShardedMemoryUserStore) ListUsersPaged(ctx context.Context, opts ListUsersOptions) ([]*User, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	users, total := pageUsers(s.snapshot(), tenant.FromContext(ctx), opts)
+	return users, total, nil
+}
+
+// SearchUsers returns the users of the context's organization best matching query
+func (s *ShardedMemoryUserStore) SearchUsers(ctx context.Context, query string, limit int) ([]*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return searchUsers(s.snapshot(), tenant.FromContext(ctx), query, limit), nil
+}
+
+// UserStats counts the context organization's users one shard at a time,
+// without collecting them
+func (s *ShardedMemoryUserStore) UserStats(ctx context.Context, registeredSince []time.Time) (*UserStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	orgID := tenant.FromContext(ctx)
+	stats := &UserStats{Registered: make([]int, len(registeredSince))}
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		for _, user := range shard.users {
+			stats.add(user, orgID, registeredSince)
+		}
+		shard.mu.RUnlock()
+	}
+	return stats, nil
+}
+
+// snapshot collects the stored users from every shard. Stored users are
+// replaced rather than modified, so they can be read after the locks are
+// released.
+func (s *ShardedMemoryUserStore) snapshot() map[string]*User {
+	all := make(map[string]*User)
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		for id, user := range shard.users {
+			all[id] = user
+		}
+		shard.mu.RUnlock()
+	}
+	return all
+}
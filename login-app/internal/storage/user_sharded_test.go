@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardedUserStoreConcurrentUniqueness(t *testing.T) {
+	store := NewShardedMemoryUserStore(16)
+	ctx := context.Background()
+
+	// Every user gets a distinct ID, so they land on different shards, but
+	// each email and username is claimed by several of them at once
+	const claims = 8
+	const names = 50
+	var created [names]atomic.Int32
+	var wg sync.WaitGroup
+	for claim := 0; claim < claims; claim++ {
+		for name := 0; name < names; name++ {
+			wg.Add(1)
+			go func(claim, name int) {
+				defer wg.Done()
+				err := store.CreateUser(ctx, &User{
+					ID:       fmt.Sprintf("user-%d-%d", claim, name),
+					Email:    fmt.Sprintf("User%d@Example.com", name),
+					Username: fmt.Sprintf("user%d", name),
+				})
+				switch {
+				case err == nil:
+					created[name].Add(1)
+				case !errors.Is(err, ErrUserExists):
+					t.Errorf("CreateUser: %v", err)
+				}
+			}(claim, name)
+		}
+	}
+	wg.Wait()
+
+	for name := range created {
+		if n := created[name].Load(); n != 1 {
+			t.Errorf("user%d was created %d times, want once", name, n)
+		}
+	}
+}
+
+func TestShardedUserStoreConcurrentRenames(t *testing.T) {
+	store := NewShardedMemoryUserStore(16)
+	ctx := context.Background()
+
+	const users = 20
+	for i := 0; i < users; i++ {
+		if err := store.CreateUser(ctx, &User{
+			ID:       fmt.Sprintf("user-%d", i),
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			Username: fmt.Sprintf("user%d", i),
+		}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+
+	// All users race to take the same new email and username
+	var renamed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user, err := store.GetUserByID(ctx, fmt.Sprintf("user-%d", i))
+			if err != nil {
+				t.Errorf("GetUserByID: %v", err)
+				return
+			}
+			user.Email = "taken@example.com"
+			user.Username = "taken"
+			switch err := store.UpdateUser(ctx, user); {
+			case err == nil:
+				renamed.Add(1)
+			case !errors.Is(err, ErrUserExists):
+				t.Errorf("UpdateUser: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := renamed.Load(); n != 1 {
+		t.Fatalf("%d users took the same email, want 1", n)
+	}
+	winner, err := store.GetUserByEmail(ctx, "taken@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	if byName, err := store.GetUserByUsername(ctx, "taken"); err != nil || byName.ID != winner.ID {
+		t.Errorf("username index points at %v (%v), want %s", byName, err, winner.ID)
+	}
+
+	// The winner's old email was freed, the others kept theirs
+	for i := 0; i < users; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		_, err := store.GetUserByEmail(ctx, fmt.Sprintf("user%d@example.com", i))
+		if id == winner.ID && !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("winner's old email still indexed: %v", err)
+		}
+		if id != winner.ID && err != nil {
+			t.Errorf("%s lost its email: %v", id, err)
+		}
+	}
+}
+
+func benchmarkCreateUsers(b *testing.B, store UserStore) {
+	ctx := context.Background()
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := next.Add(1)
+			if err := store.CreateUser(ctx, &User{
+				ID:       fmt.Sprintf("id-%d", n),
+				Email:    fmt.Sprintf("user%d@example.com", n),
+				Username: fmt.Sprintf("user%d", n),
+			}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func benchmarkUpdateUsers(b *testing.B, store UserStore) {
+	ctx := context.Background()
+	const users = 1024
+	for i := 0; i < users; i++ {
+		if err := store.CreateUser(ctx, &User{
+			ID:       fmt.Sprintf("id-%d", i),
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			Username: fmt.Sprintf("user%d", i),
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := next.Add(1) % users
+			user, err := store.GetUserByEmail(ctx, fmt.Sprintf("user%d@example.com", i))
+			if err != nil {
+				b.Fatal(err)
+			}
+			user.FirstName = "Updated"
+			if err := store.UpdateUser(ctx, user); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryUserStoreCreate(b *testing.B) {
+	benchmarkCreateUsers(b, NewMemoryUserStore())
+}
+
+func BenchmarkShardedUserStoreCreate(b *testing.B) {
+	benchmarkCreateUsers(b, NewShardedMemoryUserStore(32))
+}
+
+func BenchmarkMemoryUserStoreUpdate(b *testing.B) {
+	benchmarkUpdateUsers(b, NewMemoryUserStore())
+}
+
+func BenchmarkShardedUserStoreUpdate(b *testing.B) {
+	benchmarkUpdateUsers(b, NewShardedMemoryUserStore(32))
+}
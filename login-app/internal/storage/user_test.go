@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tenant"
+)
+
+// userStores returns a fresh instance of each in-memory UserStore
+func userStores() map[string]UserStore {
+	return map[string]UserStore{
+		"memory":  NewMemoryUserStore(),
+		"sharded": NewShardedMemoryUserStore(4),
+	}
+}
+
+func TestGetUserByProvider(t *testing.T) {
+	for name, store := range userStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := store.CreateUser(ctx, &User{
+				ID:              "linked",
+				Email:           "linked@example.com",
+				Username:        "linked",
+				LinkedProviders: []LinkedProvider{{Provider: "github", ProviderUserID: "gh-1"}},
+			}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+
+			user, err := store.GetUserByProvider(ctx, "github", "gh-1")
+			if err != nil || user.ID != "linked" {
+				t.Fatalf("GetUserByProvider = %+v, %v; want the linked user", user, err)
+			}
+
+			for _, lookup := range [][2]string{{"github", "gh-2"}, {"google", "gh-1"}} {
+				if _, err := store.GetUserByProvider(ctx, lookup[0], lookup[1]); !errors.Is(err, ErrUserNotFound) {
+					t.Errorf("GetUserByProvider(%s, %s): got %v, want ErrUserNotFound", lookup[0], lookup[1], err)
+				}
+			}
+
+			// Other organizations and soft-deleted users don't match
+			other := tenant.NewContext(ctx, "other")
+			if _, err := store.GetUserByProvider(other, "github", "gh-1"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("other organization: got %v, want ErrUserNotFound", err)
+			}
+			if err := store.SoftDeleteUser(ctx, "linked"); err != nil {
+				t.Fatalf("SoftDeleteUser: %v", err)
+			}
+			if _, err := store.GetUserByProvider(ctx, "github", "gh-1"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("soft-deleted: got %v, want ErrUserNotFound", err)
+			}
+		})
+	}
+}
+
+func TestUserLookupsIgnoreCase(t *testing.T) {
+	for name, store := range userStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := store.CreateUser(ctx, &User{ID: "alice", Email: "Alice@Example.com", Username: "Alice"}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+
+			byEmail, err := store.GetUserByEmail(ctx, "ALICE@example.COM")
+			if err != nil || byEmail.ID != "alice" {
+				t.Fatalf("GetUserByEmail = %+v, %v; want alice", byEmail, err)
+			}
+			if byEmail.Email != "alice@example.com" || byEmail.Username != "Alice" {
+				t.Errorf("stored email %q and username %q, want the email normalized and the username as entered", byEmail.Email, byEmail.Username)
+			}
+			if user, err := store.GetUserByUsername(ctx, "aLiCe"); err != nil || user.ID != "alice" {
+				t.Errorf("GetUserByUsername = %+v, %v; want alice", user, err)
+			}
+
+			for _, duplicate := range []*User{
+				{ID: "email-variant", Email: "alice@EXAMPLE.com", Username: "someone"},
+				{ID: "username-variant", Email: "other@example.com", Username: "ALICE"},
+			} {
+				if err := store.CreateUser(ctx, duplicate); !errors.Is(err, ErrUserExists) {
+					t.Errorf("CreateUser(%s): got %v, want ErrUserExists", duplicate.ID, err)
+				}
+			}
+
+			// Renaming onto a case variant of another user's username conflicts,
+			// changing only the casing of one's own doesn't
+			if err := store.CreateUser(ctx, &User{ID: "bob", Email: "bob@example.com", Username: "bob"}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			bob, _ := store.GetUserByID(ctx, "bob")
+			bob.Username = "alice"
+			if err := store.UpdateUser(ctx, bob); !errors.Is(err, ErrUserExists) {
+				t.Errorf("rename to a case variant: got %v, want ErrUserExists", err)
+			}
+			bob.Username = "Bob"
+			if err := store.UpdateUser(ctx, bob); err != nil {
+				t.Errorf("recase own username: %v", err)
+			}
+			if user, _ := store.GetUserByUsername(ctx, "BOB"); user == nil || user.Username != "Bob" {
+				t.Errorf("GetUserByUsername after recasing = %+v, want display name Bob", user)
+			}
+		})
+	}
+}
+
+func TestUsersAreScopedByOrganization(t *testing.T) {
+	for name, store := range userStores() {
+		t.Run(name, func(t *testing.T) {
+			// Users are created in the organization they name, and looked up
+			// in the one in the context
+			for _, orgID := range []string{"acme", "globex"} {
+				if err := store.CreateUser(context.Background(), &User{ID: orgID + "-user", OrgID: orgID, Email: "shared@example.com", Username: "shared"}); err != nil {
+					t.Fatalf("CreateUser(%s): %v", orgID, err)
+				}
+			}
+			if err := store.CreateUser(context.Background(), &User{ID: "acme-duplicate", OrgID: "acme", Email: "shared@example.com", Username: "other"}); !errors.Is(err, ErrUserExists) {
+				t.Errorf("duplicate email within an organization: got %v, want ErrUserExists", err)
+			}
+
+			for _, orgID := range []string{"acme", "globex"} {
+				ctx := tenant.NewContext(context.Background(), orgID)
+				want := orgID + "-user"
+				if user, err := store.GetUserByEmail(ctx, "shared@example.com"); err != nil || user.ID != want {
+					t.Errorf("GetUserByEmail in %s = %+v, %v; want %s", orgID, user, err, want)
+				}
+				if user, err := store.GetUserByUsername(ctx, "shared"); err != nil || user.ID != want {
+					t.Errorf("GetUserByUsername in %s = %+v, %v; want %s", orgID, user, err, want)
+				}
+				if users, err := store.ListUsers(ctx); err != nil || len(users) != 1 || users[0].ID != want {
+					t.Errorf("ListUsers in %s = %v, %v; want only %s", orgID, users, err, want)
+				}
+			}
+
+			if _, err := store.GetUserByEmail(context.Background(), "shared@example.com"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("default organization: got %v, want ErrUserNotFound", err)
+			}
+		})
+	}
+}
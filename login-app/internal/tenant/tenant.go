@@ -0,0 +1,38 @@
+// Package tenant carries the organization a request belongs to. Users,
+// tokens and lookups are confined to one organization; single-tenant
+// deployments use the default organization, whose ID is empty.
+package tenant
+
+import "context"
+
+// contextKey is the context key type for the request's organization
+type contextKey struct{}
+
+// MaxOrgIDLength caps organization IDs so they fit in a DNS label
+const MaxOrgIDLength = 63
+
+// NewContext returns a copy of ctx carrying the given organization ID
+func NewContext(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, orgID)
+}
+
+// FromContext returns the organization ID stored in ctx, or the default
+// organization's empty ID
+func FromContext(ctx context.Context) string {
+	orgID, _ := ctx.Value(contextKey{}).(string)
+	return orgID
+}
+
+// ValidOrgID reports whether id can name an organization: lowercase
+// letters, digits and inner hyphens, so it also works as a subdomain
+func ValidOrgID(id string) bool {
+	if id == "" || len(id) > MaxOrgIDLength || id[0] == '-' || id[len(id)-1] == '-' {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+// Package tokens generates the random secrets handed to users: refresh,
+// password reset, email verification and magic link tokens among others.
+// Tokens are stored and looked up by their hash, never compared as
+// plaintext; code that does compare them directly uses Equal.
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Token encodings
+const (
+	EncodingHex       = "hex"
+	EncodingBase64URL = "base64url"
+)
+
+// Bounds on the number of random bytes in a token. The minimum keeps
+// tokens at 128 bits of entropy.
+const (
+	MinBytes = 16
+	MaxBytes = 64
+)
+
+// Generator creates tokens of a fixed size and encoding
+type Generator struct {
+	bytes  int
+	encode func([]byte) string
+}
+
+// New creates a generator of tokens with the given number of random bytes
+// in the given encoding, hex or base64url (unpadded, so the tokens are
+// safe in URLs)
+func New(bytes int, encoding string) (*Generator, error) {
+	if bytes < MinBytes || bytes > MaxBytes {
+		return nil, fmt.Errorf("token size must be between %d and %d bytes, got %d", MinBytes, MaxBytes, bytes)
+	}
+
+	g := &Generator{bytes: bytes}
+	switch encoding {
+	case EncodingHex:
+		g.encode = hex.EncodeToString
+	case EncodingBase64URL:
+		g.encode = base64.RawURLEncoding.EncodeToString
+	default:
+		return nil, fmt.Errorf("unknown token encoding %q", encoding)
+	}
+	return g, nil
+}
+
+// Generate returns a new random token
+func (g *Generator) Generate() (string, error) {
+	b := make([]byte, g.bytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return g.encode(b), nil
+}
+
+// Equal reports whether two tokens match in time that doesn't depend on
+// where they first differ
+func Equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+// userStore wraps a UserStore with a span per call
+type userStore struct {
+	next storage.UserStore
+}
+
+// WrapUserStore returns a UserStore that traces every call to store
+func WrapUserStore(store storage.UserStore) storage.UserStore {
+	return &userStore{next: store}
+}
+
+func (s *userStore) CreateUser(ctx context.Context, user *storage.User) (err error) {
+	ctx, span := Start(ctx, "UserStore.CreateUser")
+	defer End(span, &err)
+	return s.next.CreateUser(ctx, user)
+}
+
+func (s *userStore) GetUserByID(ctx context.Context, id string) (user *storage.User, err error) {
+	ctx, span := Start(ctx, "UserStore.GetUserByID")
+	defer End(span, &err)
+	return s.next.GetUserByID(ctx, id)
+}
+
+func (s *userStore) GetUserByEmail(ctx context.Context, email string) (user *storage.User, err error) {
+	ctx, span := Start(ctx, "UserStore.GetUserByEmail")
+	defer End(span, &err)
+	return s.next.GetUserByEmail(ctx, email)
+}
+
+func (s *userStore) GetUserByUsername(ctx context.Context, username string) (user *storage.User, err error) {
+	ctx, span := Start(ctx, "UserStore.GetUserByUsername")
+	defer End(span, &err)
+	return s.next.GetUserByUsername(ctx, username)
+}
+
+func (s *userStore) GetUserByProvider(ctx context.Context, provider, providerUserID string) (user *storage.User, err error) {
+	ctx, span := Start(ctx, "UserStore.GetUserByProvider")
+	defer End(span, &err)
+	return s.next.GetUserByProvider(ctx, provider, providerUserID)
+}
+
+func (s *userStore) UpdateUser(ctx context.Context, user *storage.User) (err error) {
+	ctx, span := Start(ctx, "UserStore.UpdateUser")
+	defer End(span, &err)
+	return s.next.UpdateUser(ctx, user)
+}
+
+func (s *userStore) RecordLogin(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, span := Start(ctx, "UserStore.RecordLogin")
+	defer End(span, &err)
+	return s.next.RecordLogin(ctx, id, at)
+}
+
+func (s *userStore) DeleteUser(ctx context.Context, id string) (err error) {
+	ctx, span := Start(ctx, "UserStore.DeleteUser")
+	defer End(span, &err)
+	return s.next.DeleteUser(ctx, id)
+}
+
+func (s *userStore) SoftDeleteUser(ctx context.Context, id string) (err error) {
+	ctx, span := Start(ctx, "UserStore.SoftDeleteUser")
+	defer End(span, &err)
+	return s.next.SoftDeleteUser(ctx, id)
+}
+
+func (s *userStore) RestoreUser(ctx context.Context, id string) (err error) {
+	ctx, span := Start(ctx, "UserStore.RestoreUser")
+	defer End(span, &err)
+	return s.next.RestoreUser(ctx, id)
+}
+
+func (s *userStore) ListUsers(ctx context.Context) (users []*storage.User, err error) {
+	ctx, span := Start(ctx, "UserStore.ListUsers")
+	defer End(span, &err)
+	return s.next.ListUsers(ctx)
+}
+
+func (s *userStore) ListUsersPaged(ctx context.Context, opts storage.ListUsersOptions) (users []*storage.User, total int, err error) {
+	ctx, span := Start(ctx, "UserStore.ListUsersPaged",
+		attribute.Int("offset", opts.Offset), attribute.Int("limit", opts.Limit))
+	defer End(span, &err)
+	return s.next.ListUsersPaged(ctx, opts)
+}
+
+func (s *userStore) SearchUsers(ctx context.Context, query string, limit int) (users []*storage.User, err error) {
+	ctx, span := Start(ctx, "UserStore.SearchUsers")
+	defer End(span, &err)
+	return s.next.SearchUsers(ctx, query, limit)
+}
+
+func (s *userStore) UserStats(ctx context.Context, registeredSince []time.Time) (stats *storage.UserStats, err error) {
+	ctx, span := Start(ctx, "UserStore.UserStats")
+	defer End(span, &err)
+	return s.next.UserStats(ctx, registeredSince)
+}
+
+func (s *userStore) Ping(ctx context.Context) (err error) {
+	ctx, span := Start(ctx, "UserStore.Ping")
+	defer End(span, &err)
+	return s.next.Ping(ctx)
+}
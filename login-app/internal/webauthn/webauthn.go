@@ -0,0 +1,280 @@
+// Package webauthn implements passkey registration and login ceremonies on
+// top of go-webauthn, keeping the library's types out of the rest of the app
+package webauthn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	gowebauthn "github.com/go-webauthn/webauthn/webauthn"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+var (
+	// ErrVerificationFailed is returned when an authenticator response is
+	// malformed or doesn't verify against the ceremony
+	ErrVerificationFailed = errors.New("passkey verification failed")
+
+	// ErrClonedAuthenticator is returned when a login's signature counter
+	// is not ahead of the stored one, a sign the credential was copied
+	ErrClonedAuthenticator = errors.New("passkey signature counter went backwards")
+)
+
+// Ceremony is a started registration or login. Options are passed to the
+// browser's navigator.credentials call; Session must be kept server-side
+// and handed back when the ceremony finishes.
+type Ceremony struct {
+	Options interface{}
+	Session []byte
+}
+
+// RelyingParty runs passkey ceremonies for one site
+type RelyingParty struct {
+	webauthn *gowebauthn.WebAuthn
+}
+
+// New creates a relying party from configuration. The RP ID and origins
+// default to the host and origin of baseURL, the app's public address.
+func New(cfg config.WebAuthnConfig, baseURL string) (*RelyingParty, error) {
+	rpID := cfg.RPID
+	origins := cfg.RPOrigins
+	if rpID == "" || len(origins) == 0 {
+		u, err := url.Parse(baseURL)
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("webauthn: base URL %q has no host to derive the relying party from", baseURL)
+		}
+		if rpID == "" {
+			rpID = u.Hostname()
+		}
+		if len(origins) == 0 {
+			origins = []string{u.Scheme + "://" + u.Host}
+		}
+	}
+
+	timeout := gowebauthn.TimeoutConfig{Enforce: true, Timeout: cfg.Timeout, TimeoutUVD: cfg.Timeout}
+	wa, err := gowebauthn.New(&gowebauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     origins,
+		// Passkeys are discoverable so logins need no username, and
+		// verify the user so they count as two factors on their own
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		},
+		Timeouts: gowebauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelyingParty{webauthn: wa}, nil
+}
+
+// BeginRegistration starts registering a new passkey for a user. The
+// user's existing passkeys are excluded so an authenticator isn't
+// registered twice.
+func (rp *RelyingParty) BeginRegistration(user *storage.User) (*Ceremony, error) {
+	u := webauthnUser{user}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.WebAuthnCredentials))
+	for _, credential := range u.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	options, session, err := rp.webauthn.BeginRegistration(u, gowebauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, err
+	}
+	return newCeremony(options, session)
+}
+
+// FinishRegistration verifies the browser's response to a registration
+// ceremony and returns the new credential
+func (rp *RelyingParty) FinishRegistration(user *storage.User, session, response []byte) (*storage.WebAuthnCredential, error) {
+	sessionData, err := decodeSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, verificationError(err)
+	}
+
+	credential, err := rp.webauthn.CreateCredential(webauthnUser{user}, *sessionData, parsed)
+	if err != nil {
+		return nil, verificationError(err)
+	}
+
+	for _, existing := range user.WebAuthnCredentials {
+		if bytes.Equal(existing.ID, credential.ID) {
+			return nil, fmt.Errorf("%w: passkey is already registered", ErrVerificationFailed)
+		}
+	}
+
+	stored := fromLibraryCredential(credential)
+	stored.CreatedAt = time.Now()
+	return &stored, nil
+}
+
+// BeginLogin starts a passwordless login. No account is named up front;
+// the authenticator offers the passkeys it holds for this site.
+func (rp *RelyingParty) BeginLogin() (*Ceremony, error) {
+	options, session, err := rp.webauthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, err
+	}
+	return newCeremony(options, session)
+}
+
+// FinishLogin verifies the browser's response to a login ceremony.
+// findUser looks up the account by the user handle the authenticator
+// returned, which is the user ID. It returns the user and their credential
+// with the signature counter and flags updated from this login. The user is
+// also returned with ErrClonedAuthenticator so the incident can be reported.
+func (rp *RelyingParty) FinishLogin(session, response []byte, findUser func(userID string) (*storage.User, error)) (*storage.User, *storage.WebAuthnCredential, error) {
+	sessionData, err := decodeSession(session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, nil, verificationError(err)
+	}
+
+	// The library flattens lookup errors into its own, so keep the
+	// original to tell an unknown user from a failing store
+	var user *storage.User
+	var lookupErr error
+	credential, err := rp.webauthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (gowebauthn.User, error) {
+		user, lookupErr = findUser(string(userHandle))
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		return webauthnUser{user}, nil
+	}, *sessionData, parsed)
+	if lookupErr != nil {
+		return nil, nil, lookupErr
+	}
+	if err != nil {
+		return nil, nil, verificationError(err)
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return user, nil, ErrClonedAuthenticator
+	}
+
+	for _, stored := range user.WebAuthnCredentials {
+		if bytes.Equal(stored.ID, credential.ID) {
+			stored.SignCount = credential.Authenticator.SignCount
+			stored.BackupState = credential.Flags.BackupState
+			return user, &stored, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: unknown passkey", ErrVerificationFailed)
+}
+
+// webauthnUser adapts a storage.User to the library's User interface. The
+// user ID is the WebAuthn user handle, so it contains no personal data.
+type webauthnUser struct {
+	*storage.User
+}
+
+func (u webauthnUser) WebAuthnID() []byte {
+	return []byte(u.ID)
+}
+
+func (u webauthnUser) WebAuthnName() string {
+	return u.Email
+}
+
+func (u webauthnUser) WebAuthnDisplayName() string {
+	return u.Username
+}
+
+func (u webauthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u webauthnUser) WebAuthnCredentials() []gowebauthn.Credential {
+	credentials := make([]gowebauthn.Credential, 0, len(u.User.WebAuthnCredentials))
+	for _, stored := range u.User.WebAuthnCredentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(stored.Transports))
+		for _, transport := range stored.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, gowebauthn.Credential{
+			ID:              stored.ID,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Transport:       transports,
+			Flags: gowebauthn.CredentialFlags{
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: gowebauthn.Authenticator{
+				AAGUID:    stored.AAGUID,
+				SignCount: stored.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+// fromLibraryCredential converts a verified credential for storage
+func fromLibraryCredential(credential *gowebauthn.Credential) storage.WebAuthnCredential {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return storage.WebAuthnCredential{
+		ID:              credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+}
+
+// newCeremony serializes a ceremony's session data for storage
+func newCeremony(options interface{}, session *gowebauthn.SessionData) (*Ceremony, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	return &Ceremony{Options: options, Session: data}, nil
+}
+
+// decodeSession restores session data saved by newCeremony
+func decodeSession(session []byte) (*gowebauthn.SessionData, error) {
+	var data gowebauthn.SessionData
+	if err := json.Unmarshal(session, &data); err != nil {
+		return nil, fmt.Errorf("decoding passkey session: %w", err)
+	}
+	return &data, nil
+}
+
+// verificationError wraps a library error in ErrVerificationFailed, keeping
+// the library's explanation for logs
+func verificationError(err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.DevInfo != "" {
+		return fmt.Errorf("%w: %s: %s", ErrVerificationFailed, protocolErr.Details, protocolErr.DevInfo)
+	}
+	return fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+}
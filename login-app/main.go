@@ -4,7 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,21 +12,48 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/auth"
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/email"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/events"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/lifecycle"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/server"
 	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/tracing"
 )
 
 var (
 	flagVersion = flag.Bool("version", false, "show version")
 	flagPort    = flag.String("port", "8080", "port to listen on")
-	flagEnv     = flag.String("env", "development", "environment (development, production)")
+	flagEnv     = flag.String("env", "development", "environment (development, test, staging, production)")
+	flagConfig  = flag.String("config", "", "path to a YAML or JSON config file")
+
+	flagCheckConfig = flag.Bool("check-config", false, "validate the configuration, print the effective values and exit")
+	flagDiskAssets  = flag.Bool("disk-assets", false, "serve templates and static files from disk instead of the embedded copies")
+	flagTokenCookie = flag.Bool("token-cookie", false, "also send access tokens to browsers in an HttpOnly cookie")
+	flagAPIOnly     = flag.Bool("api-only", false, "serve the JSON API without the web pages")
 )
 
 // buildVersion is set at compile time
 var buildVersion = "dev"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "create-admin" {
+		if err := runCreateAdmin(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "create-admin:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	flag.Parse()
 
 	if *flagVersion {
@@ -35,60 +62,257 @@ func main() {
 		return
 	}
 
-	log.Printf("Starting login-app version %s; Go %s (%s/%s)",
-		buildVersion, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	// Flags only override the config when set explicitly
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
 
-	// Load configuration
-	cfg, err := config.Load(*flagEnv)
+	// Load configuration; precedence is file < environment variables < flags
+	cfg, err := loadConfig(*flagConfig, *flagEnv, explicit["env"])
+	if *flagCheckConfig {
+		os.Exit(checkConfig(cfg, err, explicit))
+	}
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
 	}
 
-	// Override port from command line if provided
-	if *flagPort != "8080" {
-		cfg.Server.Port = *flagPort
+	// Route all logging, including the standard log package, through slog
+	slog.SetDefault(logging.New(cfg.Log, os.Stderr))
+
+	slog.Info("Starting login-app",
+		"version", buildVersion,
+		"go_version", runtime.Version(),
+		"os", runtime.GOOS,
+		"arch", runtime.GOARCH,
+		"environment", cfg.Environment,
+	)
+	if cfg.UsesDefaultJWTSecret() {
+		slog.Warn("Using the built-in JWT secret; anyone can forge tokens for this server. Set JWT_SECRET, or REQUIRE_JWT_SECRET=true to refuse to start without it",
+			"environment", cfg.Environment,
+		)
 	}
 
-	// Initialize storage (in-memory for this demo)
-	userStore := storage.NewMemoryUserStore()
+	// Override settings from the command line if provided
+	applyFlags(cfg, explicit)
+
+	if cfg.InsecureTokenCookie() {
+		slog.Warn("The token cookie is sent without the Secure attribute, so browsers may send access tokens over plain HTTP. Leave TOKEN_COOKIE_SECURE unset outside local development",
+			"environment", cfg.Environment,
+		)
+	}
+
+	// Time bcrypt before anything hashes with it
+	auth.CalibrateBCrypt(&cfg.Auth)
+
+	// Export traces; without tracing enabled spans go to a no-op tracer
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, buildVersion)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+
+	if cfg.Storage.Database.MigrateOnStart {
+		if err := migrateDatabase(context.Background(), cfg.Storage.Database); err != nil {
+			fatal("Failed to migrate database", err)
+		}
+	}
+
+	userStore := newUserStore(cfg)
+	if cfg.Tracing.Enabled {
+		userStore = tracing.WrapUserStore(userStore)
+	}
+	// Outside the tracing wrapper, so every attempt gets its own span
+	if retry := cfg.Storage.Retry; retry.MaxAttempts > 1 {
+		userStore = storage.NewRetryingUserStore(userStore, storage.RetryPolicy{
+			MaxAttempts: retry.MaxAttempts,
+			BaseDelay:   retry.BaseDelay,
+			MaxDelay:    retry.MaxDelay,
+		})
+	}
+	// Outside the tracing wrapper, so cache hits don't show as store calls
+	if cache := cfg.Storage.UserCache; cache.MaxSize > 0 {
+		userStore = storage.NewCachedUserStore(userStore, cache.MaxSize, cache.TTL)
+	}
+
+	sessionStores, closeSessionStores, err := newSessionStores(cfg)
+	if err != nil {
+		fatal("Failed to create session stores", err)
+	}
+
+	// Initialize the security event stream
+	sinks, err := events.NewSinks(cfg.Events)
+	if err != nil {
+		fatal("Failed to create security event sinks", err)
+	}
+	eventBus := events.NewBus(sinks, events.Options{
+		BufferSize:    cfg.Events.BufferSize,
+		BatchSize:     cfg.Events.BatchSize,
+		FlushInterval: cfg.Events.FlushInterval,
+	})
+
+	// Background workers are stopped together, and waited for, on shutdown.
+	// Pending security events are flushed once the workers are told to stop.
+	workers := lifecycle.New()
+	workers.Go("security_events", func(ctx context.Context) {
+		<-ctx.Done()
+		if err := eventBus.Close(); err != nil {
+			slog.Error("Failed to close security event sinks", "error", err)
+		}
+	})
+
+	// Outgoing email for reset and login links
+	mailer, err := email.New(cfg.Email)
+	if err != nil {
+		fatal("Failed to create email sender", err)
+	}
 
 	// Create server
-	srv, err := server.New(cfg, userStore)
+	server.Version = buildVersion
+	srv, err := server.New(cfg, userStore, sessionStores, eventBus, mailer, workers)
 	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
+		fatal("Failed to create server", err)
 	}
 
 	// Setup HTTP server
 	httpServer := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
 		Handler:      srv.Handler(),
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server starting on port %s", cfg.Server.Port)
+		slog.Info("Server starting", "port", cfg.Server.Port)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+			fatal("Server failed to start", err)
 		}
 	}()
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
+	sig := <-quit
+	slog.Info("Shutting down server", "signal", sig.String(), "in_flight", srv.InFlight())
+
+	// Report not ready so load balancers stop sending new traffic, and give
+	// them time to notice before the listener closes
+	srv.Drain()
+	if cfg.Server.DrainDelay > 0 {
+		time.Sleep(cfg.Server.DrainDelay)
+	}
 
 	// Create a context with timeout for shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	// Shutdown server gracefully
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		slog.Error("Server forced to shutdown", "error", err, "in_flight", srv.InFlight(),
+			"timeout", cfg.Server.ShutdownTimeout.String())
 	}
 
-	log.Println("Server exited")
+	// Stop background workers, giving them their own timeout so a slow
+	// HTTP shutdown doesn't leave them no time to drain
+	workerCtx, cancelWorkers := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancelWorkers()
+	if unfinished := workers.Shutdown(workerCtx); len(unfinished) > 0 {
+		slog.Warn("Background workers did not finish before the shutdown timeout",
+			"workers", unfinished, "timeout", cfg.Server.ShutdownTimeout.String())
+	}
+
+	closeSessionStores()
+
+	// Flush pending spans
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server exited")
+}
+
+// loadConfig loads configuration from a file when a path is given, or from
+// defaults and the environment otherwise. An explicitly chosen environment
+// overrides the file's.
+func loadConfig(path, environment string, environmentSet bool) (*config.Config, error) {
+	if path == "" {
+		return config.Load(environment)
+	}
+	if environmentSet {
+		os.Setenv("ENVIRONMENT", environment)
+	}
+	return config.LoadFromFile(path)
+}
+
+// checkConfig reports the outcome of loading the configuration for
+// --check-config and returns the exit code
+func checkConfig(cfg *config.Config, err error, explicit map[string]bool) int {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Configuration is invalid:", err)
+		return 1
+	}
+	applyFlags(cfg, explicit)
+
+	fmt.Printf("Configuration is valid (environment: %s)\n\n", cfg.Environment)
+	if err := cfg.WriteReport(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write configuration report:", err)
+		return 1
+	}
+	return 0
+}
+
+// applyFlags overrides the configuration with explicitly set flags
+func applyFlags(cfg *config.Config, explicit map[string]bool) {
+	if explicit["port"] {
+		cfg.Server.Port = *flagPort
+	}
+	if explicit["disk-assets"] {
+		cfg.Server.DiskAssets = *flagDiskAssets
+	}
+	if explicit["token-cookie"] {
+		cfg.Auth.TokenCookie.Enabled = *flagTokenCookie
+	}
+	if explicit["api-only"] {
+		cfg.Server.APIOnly = *flagAPIOnly
+	}
+}
+
+// newUserStore creates the user store (in-memory for this demo)
+func newUserStore(cfg *config.Config) storage.UserStore {
+	if shards := cfg.Storage.UserStoreShards; shards > 0 {
+		slog.Info("Using sharded in-memory user store", "shards", shards)
+		return storage.NewShardedMemoryUserStore(shards)
+	}
+	return storage.NewMemoryUserStore()
+}
+
+// newSessionStores creates the stores for sessions, refresh tokens and
+// revoked tokens. The memory backend is selected by leaving them nil.
+func newSessionStores(cfg *config.Config) (storage.SessionStores, func(), error) {
+	if cfg.Storage.SessionBackend != config.SessionBackendRedis {
+		return storage.SessionStores{}, func() {}, nil
+	}
+
+	client, err := storage.NewRedisClient(cfg.Storage.Redis)
+	if err != nil {
+		return storage.SessionStores{}, nil, err
+	}
+	slog.Info("Using Redis session store", "addr", cfg.Storage.Redis.Addr, "db", cfg.Storage.Redis.DB)
+
+	prefix := cfg.Storage.Redis.KeyPrefix
+	stores := storage.SessionStores{
+		Sessions:      storage.NewRedisSessionStore(client, prefix),
+		RefreshTokens: storage.NewRedisRefreshTokenStore(client, prefix),
+		RevokedTokens: storage.NewRedisRevokedTokenStore(client, prefix),
+	}
+	return stores, func() {
+		if err := client.Close(); err != nil {
+			slog.Error("Failed to close Redis client", "error", err)
+		}
+	}, nil
+}
+
+// fatal logs an error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	// The SQLite driver is compiled in; other database/sql drivers have to
+	// be imported here to be usable as DATABASE_DRIVER
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/config"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/logging"
+	"github.com/HelloImKevo/UdemyGolangApps/login-app/internal/storage"
+)
+
+// runMigrate implements the migrate subcommand, which applies pending
+// schema migrations to the configured database, or with -down reverts the
+// latest ones:
+//
+//	DATABASE_DRIVER=sqlite3 DATABASE_URL=file:login.db login-app migrate
+//
+// Without a database the in-memory stores have no schema, so it does nothing.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	env := fs.String("env", "development", "environment (development, test, staging, production)")
+	configPath := fs.String("config", "", "path to a YAML or JSON config file")
+	down := fs.Int("down", 0, "roll back this many of the latest migrations instead of applying pending ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *down < 0 {
+		return fmt.Errorf("-down must not be negative")
+	}
+
+	envSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "env" {
+			envSet = true
+		}
+	})
+
+	cfg, err := loadConfig(*configPath, *env, envSet)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	slog.SetDefault(logging.New(cfg.Log, os.Stderr))
+
+	if cfg.Storage.Database.Driver == "" {
+		slog.Info("No database configured, the in-memory stores need no migrations")
+		return nil
+	}
+	if *down > 0 {
+		return rollbackDatabase(context.Background(), cfg.Storage.Database, *down)
+	}
+	return migrateDatabase(context.Background(), cfg.Storage.Database)
+}
+
+// migrateDatabase applies pending migrations to the database in cfg
+func migrateDatabase(ctx context.Context, cfg config.DatabaseConfig) error {
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	status, err := storage.Migrate(ctx, db, cfg.Driver)
+	if status != nil {
+		for _, migration := range status.Applied {
+			slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Database schema is up to date", "version", status.Version, "applied", len(status.Applied))
+	return nil
+}
+
+// rollbackDatabase reverts the latest steps migrations of the database in cfg
+func rollbackDatabase(ctx context.Context, cfg config.DatabaseConfig, steps int) error {
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	status, err := storage.Rollback(ctx, db, cfg.Driver, steps)
+	if status != nil {
+		for _, migration := range status.RolledBack {
+			slog.Info("Rolled back migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Database schema rolled back", "version", status.Version, "rolled_back", len(status.RolledBack))
+	return nil
+}
+
+// openDatabase opens the database in cfg with a compiled-in driver
+func openDatabase(cfg config.DatabaseConfig) (*sql.DB, error) {
+	if !slices.Contains(sql.Drivers(), cfg.Driver) {
+		return nil, fmt.Errorf("unknown DATABASE_DRIVER %q: compiled-in drivers are %v", cfg.Driver, sql.Drivers())
+	}
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", cfg.Driver, err)
+	}
+	return db, nil
+}
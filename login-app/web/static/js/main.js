@@ -219,8 +219,8 @@ const validation = {
 
         if (!formData.password) {
             errors.push('Password is required');
-        } else if (formData.password.length < 6) {
-            errors.push('Password must be at least 6 characters long');
+        } else if (formData.password.length < 8) {
+            errors.push('Password must be at least 8 characters long');
         }
 
         return errors;
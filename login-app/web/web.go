@@ -0,0 +1,10 @@
+// Package web holds the page templates and static files served by the app.
+// They are embedded so a single binary runs from any working directory.
+package web
+
+import "embed"
+
+// Assets contains templates/*.html and everything under static/
+//
+//go:embed templates/*.html static
+var Assets embed.FS